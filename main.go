@@ -6,8 +6,8 @@ import (
 	"runtime"
 	"syscall"
 
-	nested "github.com/antonfisher/nested-logrus-formatter"
 	"github.com/cnrancher/hangar/pkg/commands"
+	"github.com/cnrancher/hangar/pkg/logging"
 	"github.com/moby/term"
 	"github.com/sirupsen/logrus"
 	"github.com/sirupsen/logrus/hooks/writer"
@@ -22,16 +22,9 @@ func main() {
 }
 
 func setup() {
-	formatter := &nested.Formatter{
-		HideKeys:        false,
-		TimestampFormat: "[15:04:05]", // hour, time, sec only
-		FieldsOrder:     []string{"IMG"},
-	}
-	if !term.IsTerminal(uintptr(syscall.Stdout)) || !term.IsTerminal(uintptr(syscall.Stderr)) {
-		// Disable if the output is not terminal.
-		formatter.NoColors = true
-	}
-	logrus.SetFormatter(formatter)
+	// Disable colors if the output is not terminal.
+	colors := term.IsTerminal(uintptr(syscall.Stdout)) && term.IsTerminal(uintptr(syscall.Stderr))
+	logrus.SetFormatter(logging.NewTextFormatter(colors))
 	logrus.SetOutput(io.Discard)
 	logrus.AddHook(&writer.Hook{
 		// Send logs with level higher than warning to stderr.
@@ -0,0 +1,273 @@
+package cmdconfig
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/yaml"
+)
+
+// EnvPrefix is the prefix of the environment variables of the flags,
+// example: 'HANGAR_TLS_VERIFY' for the '--tls-verify' flag.
+const EnvPrefix = "HANGAR_"
+
+// The reserved keys of the configuration file.
+const (
+	keyProfile  = "profile"
+	keyProfiles = "profiles"
+)
+
+// EnvName returns the environment variable name of the flag.
+func EnvName(flag string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(flag, "-", "_"))
+}
+
+// LookupEnv returns the value of the environment variable of the flag.
+func LookupEnv(flag string) (string, bool) {
+	return os.LookupEnv(EnvName(flag))
+}
+
+// File is the configuration file of the hangar commands, the keys are the
+// flag names and the values are applied to the flags not set in the command
+// line.
+//
+// Example:
+//
+//	# Values for all commands.
+//	debug: false
+//	jobs: 4
+//	# Values for the 'mirror' command and its sub-commands.
+//	mirror:
+//	  arch: [ "amd64", "arm64" ]
+//	  # Values for the 'mirror validate' command.
+//	  validate:
+//	    jobs: 10
+//	# Default profile if not specified by '--profile'.
+//	profile: lab-registry
+//	profiles:
+//	  prod-harbor:
+//	    destination: [ "harbor.example.io" ]
+//	    tls-verify: true
+//	    login:
+//	      authfile: /etc/hangar/prod-auth.json
+//	  lab-registry:
+//	    destination: [ "registry.lab.local:5000" ]
+//	    tls-verify: false
+type File struct {
+	name string
+	data map[string]any
+}
+
+// LoadFile reads the YAML/JSON configuration file.
+func LoadFile(name string) (*File, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file %q: %w", name, err)
+	}
+	f := &File{
+		name: name,
+		data: make(map[string]any),
+	}
+	if err := yaml.Unmarshal(b, &f.data); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %q: %w", name, err)
+	}
+	if f.data == nil {
+		f.data = make(map[string]any)
+	}
+	if p, ok := f.data[keyProfiles]; ok {
+		if _, ok := p.(map[string]any); !ok {
+			return nil, fmt.Errorf("invalid config file %q: %q is not a map",
+				name, keyProfiles)
+		}
+	}
+	return f, nil
+}
+
+// Name returns the file name of the configuration file.
+func (f *File) Name() string {
+	if f == nil {
+		return ""
+	}
+	return f.name
+}
+
+// Profile returns the default profile name of the configuration file.
+func (f *File) Profile() string {
+	if f == nil {
+		return ""
+	}
+	s, _ := f.data[keyProfile].(string)
+	return s
+}
+
+// Values returns the flag values of the command, the command is the command
+// path without the root command name, example: [ "mirror", "validate" ].
+//
+// The values are merged in ascending order of priority:
+// global values, command sections, profile global values and profile command
+// sections. The value is either string or []string.
+func (f *File) Values(command []string, profile string) (map[string]any, error) {
+	values := make(map[string]any)
+	if f == nil {
+		if profile != "" {
+			return nil, fmt.Errorf("profile %q provided without config file", profile)
+		}
+		return values, nil
+	}
+	if err := mergeSections(values, f.data, command); err != nil {
+		return nil, fmt.Errorf("invalid config file %q: %w", f.name, err)
+	}
+	if profile == "" {
+		return values, nil
+	}
+	profiles, _ := f.data[keyProfiles].(map[string]any)
+	p, ok := profiles[profile]
+	if !ok {
+		return nil, fmt.Errorf("profile %q not found in config file %q",
+			profile, f.name)
+	}
+	data, ok := p.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("invalid config file %q: profile %q is not a map",
+			f.name, profile)
+	}
+	if err := mergeSections(values, data, command); err != nil {
+		return nil, fmt.Errorf("invalid config file %q: profile %q: %w",
+			f.name, profile, err)
+	}
+	return values, nil
+}
+
+// Validate checks the keys of the configuration file and its profiles by
+// the command tree of the root command, the keys of the section should be
+// the flags of the command (or its sub-commands) and the sub-sections
+// should be the sub-command names.
+func (f *File) Validate(root *cobra.Command) error {
+	if f == nil {
+		return nil
+	}
+	if err := validateSection(f.data, root); err != nil {
+		return fmt.Errorf("invalid config file %q: %w", f.name, err)
+	}
+	profiles, _ := f.data[keyProfiles].(map[string]any)
+	for name, p := range profiles {
+		data, ok := p.(map[string]any)
+		if !ok {
+			return fmt.Errorf("invalid config file %q: profile %q is not a map",
+				f.name, name)
+		}
+		if err := validateSection(data, root); err != nil {
+			return fmt.Errorf("invalid config file %q: profile %q: %w",
+				f.name, name, err)
+		}
+	}
+	return nil
+}
+
+// validateSection checks the keys of the section of the command.
+func validateSection(section map[string]any, cmd *cobra.Command) error {
+	for k, v := range section {
+		if cmd.Parent() == nil && (k == keyProfile || k == keyProfiles) {
+			continue
+		}
+		sub, ok := v.(map[string]any)
+		if !ok {
+			if !hasFlag(cmd, k) {
+				return fmt.Errorf("unknown key %q: not a flag of the %q command",
+					k, cmd.CommandPath())
+			}
+			continue
+		}
+		var subCmd *cobra.Command
+		for _, c := range cmd.Commands() {
+			if c.Name() == k {
+				subCmd = c
+				break
+			}
+		}
+		if subCmd == nil {
+			return fmt.Errorf("unknown section %q: not a sub-command of the %q command",
+				k, cmd.CommandPath())
+		}
+		if err := validateSection(sub, subCmd); err != nil {
+			return fmt.Errorf("%s: %w", k, err)
+		}
+	}
+	return nil
+}
+
+// hasFlag returns true if the flag is defined by the command, its parents
+// (persistent flags) or its sub-commands.
+func hasFlag(cmd *cobra.Command, name string) bool {
+	if cmd.Flags().Lookup(name) != nil || cmd.InheritedFlags().Lookup(name) != nil {
+		return true
+	}
+	for _, c := range cmd.Commands() {
+		if hasFlag(c, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeSections merges the values of the section and the sub-sections of
+// the command into dst.
+func mergeSections(dst map[string]any, section map[string]any, command []string) error {
+	for k, v := range section {
+		if k == keyProfile || k == keyProfiles {
+			continue
+		}
+		if _, ok := v.(map[string]any); ok {
+			continue
+		}
+		value, err := flagValue(v)
+		if err != nil {
+			return fmt.Errorf("key %q: %w", k, err)
+		}
+		dst[k] = value
+	}
+	if len(command) == 0 {
+		return nil
+	}
+	sub, ok := section[command[0]].(map[string]any)
+	if !ok {
+		return nil
+	}
+	if err := mergeSections(dst, sub, command[1:]); err != nil {
+		return fmt.Errorf("%s: %w", command[0], err)
+	}
+	return nil
+}
+
+// flagValue converts the YAML value into the string or []string value of
+// the flag.
+func flagValue(v any) (any, error) {
+	switch v := v.(type) {
+	case []any:
+		s := make([]string, 0, len(v))
+		for _, e := range v {
+			ev, err := flagValue(e)
+			if err != nil {
+				return nil, err
+			}
+			es, ok := ev.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid list item %v", e)
+			}
+			s = append(s, es)
+		}
+		return s, nil
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case nil:
+		return "", nil
+	}
+	return nil, fmt.Errorf("invalid value type %T", v)
+}
@@ -0,0 +1,170 @@
+package cmdconfig_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cnrancher/hangar/pkg/cmdconfig"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+const testConfig = `
+debug: true
+jobs: 4
+arch: [ "amd64", "arm64" ]
+mirror:
+  os: linux
+  jobs: 8
+  validate:
+    jobs: 10
+profile: lab-registry
+profiles:
+  prod-harbor:
+    destination: [ "harbor.example.io" ]
+    tls-verify: true
+    mirror:
+      jobs: 20
+  lab-registry:
+    destination: [ "registry.lab.local:5000" ]
+    tls-verify: false
+`
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	name := filepath.Join(t.TempDir(), "hangar.yaml")
+	assert.NoError(t, os.WriteFile(name, []byte(content), 0644))
+	return name
+}
+
+func Test_EnvName(t *testing.T) {
+	assert.Equal(t, "HANGAR_TLS_VERIFY", cmdconfig.EnvName("tls-verify"))
+	assert.Equal(t, "HANGAR_JOBS", cmdconfig.EnvName("jobs"))
+
+	t.Setenv("HANGAR_SKIP_LOGIN", "true")
+	v, ok := cmdconfig.LookupEnv("skip-login")
+	assert.True(t, ok)
+	assert.Equal(t, "true", v)
+	_, ok = cmdconfig.LookupEnv("not-exists")
+	assert.False(t, ok)
+}
+
+func Test_LoadFile(t *testing.T) {
+	f, err := cmdconfig.LoadFile(writeConfig(t, testConfig))
+	assert.NoError(t, err)
+	assert.Equal(t, "lab-registry", f.Profile())
+
+	_, err = cmdconfig.LoadFile(writeConfig(t, "profiles: [ a, b ]"))
+	assert.Error(t, err)
+	_, err = cmdconfig.LoadFile(writeConfig(t, "jobs: [ 1"))
+	assert.Error(t, err)
+	_, err = cmdconfig.LoadFile(filepath.Join(t.TempDir(), "not-exists.yaml"))
+	assert.Error(t, err)
+
+	f, err = cmdconfig.LoadFile(writeConfig(t, ""))
+	assert.NoError(t, err)
+	v, err := f.Values([]string{"mirror"}, "")
+	assert.NoError(t, err)
+	assert.Empty(t, v)
+}
+
+func Test_Values(t *testing.T) {
+	f, err := cmdconfig.LoadFile(writeConfig(t, testConfig))
+	assert.NoError(t, err)
+
+	v, err := f.Values(nil, "")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"debug": "true",
+		"jobs":  "4",
+		"arch":  []string{"amd64", "arm64"},
+	}, v)
+
+	v, err = f.Values([]string{"mirror"}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "8", v["jobs"])
+	assert.Equal(t, "linux", v["os"])
+
+	v, err = f.Values([]string{"mirror", "validate"}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "10", v["jobs"])
+	assert.Equal(t, "linux", v["os"])
+
+	v, err = f.Values([]string{"save"}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "4", v["jobs"])
+	assert.Nil(t, v["os"])
+
+	// Profile values override the global and command section values.
+	v, err = f.Values([]string{"mirror", "validate"}, "prod-harbor")
+	assert.NoError(t, err)
+	assert.Equal(t, "20", v["jobs"])
+	assert.Equal(t, "true", v["tls-verify"])
+	assert.Equal(t, []string{"harbor.example.io"}, v["destination"])
+
+	v, err = f.Values([]string{"sync"}, "lab-registry")
+	assert.NoError(t, err)
+	assert.Equal(t, "4", v["jobs"])
+	assert.Equal(t, "false", v["tls-verify"])
+
+	_, err = f.Values([]string{"mirror"}, "not-exists")
+	assert.Error(t, err)
+
+	f, err = cmdconfig.LoadFile(writeConfig(t, "arch: [ { a: b } ]"))
+	assert.NoError(t, err)
+	_, err = f.Values(nil, "")
+	assert.Error(t, err)
+}
+
+// testCommand returns the command tree of the test config.
+func testCommand() *cobra.Command {
+	root := &cobra.Command{Use: "hangar"}
+	root.PersistentFlags().Bool("debug", false, "")
+	mirror := &cobra.Command{Use: "mirror"}
+	mirror.Flags().Int("jobs", 1, "")
+	mirror.Flags().String("os", "", "")
+	mirror.Flags().StringSlice("arch", nil, "")
+	mirror.Flags().StringSlice("destination", nil, "")
+	mirror.Flags().Bool("tls-verify", true, "")
+	validate := &cobra.Command{Use: "validate"}
+	validate.Flags().Int("jobs", 1, "")
+	mirror.AddCommand(validate)
+	root.AddCommand(mirror, &cobra.Command{Use: "version"})
+	return root
+}
+
+func Test_Validate(t *testing.T) {
+	f, err := cmdconfig.LoadFile(writeConfig(t, testConfig))
+	assert.NoError(t, err)
+	assert.NoError(t, f.Validate(testCommand()))
+
+	invalid := []string{
+		// Typo of the flag name.
+		"tls-verfy: true",
+		"profiles: { lab: { jobz: 1 } }",
+		// Unknown sub-command section.
+		"mirorr: { jobs: 1 }",
+		"mirror: { validate: { os: linux } }",
+		"version: { debug: true, jobs: 1 }",
+		"profiles: { lab: { mirror: { profile: lab } } }",
+	}
+	for _, s := range invalid {
+		f, err := cmdconfig.LoadFile(writeConfig(t, s))
+		assert.NoError(t, err, s)
+		assert.Error(t, f.Validate(testCommand()), s)
+	}
+	var nilFile *cmdconfig.File
+	assert.NoError(t, nilFile.Validate(testCommand()))
+}
+
+func Test_NilFile(t *testing.T) {
+	var f *cmdconfig.File
+	assert.Equal(t, "", f.Name())
+	assert.Equal(t, "", f.Profile())
+	v, err := f.Values([]string{"mirror"}, "")
+	assert.NoError(t, err)
+	assert.Empty(t, v)
+	_, err = f.Values([]string{"mirror"}, "prod-harbor")
+	assert.Error(t, err)
+}
@@ -20,7 +20,10 @@ func newArchiveCmd() *archiveCmd {
 		Long:  "",
 		Example: `
 # Show images in archive file:
-hangar archive ls -f SAVED_ARCHIVE.zip`,
+hangar archive ls -f SAVED_ARCHIVE.zip
+
+# Export images in archive file into RKE2/K3s air-gap tarballs:
+hangar archive export -s SAVED_ARCHIVE.zip --format rke2-airgap`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			initializeFlagsConfig(cmd, cmdconfig.DefaultProvider)
 			if cc.baseCmd.debug {
@@ -36,6 +39,7 @@ hangar archive ls -f SAVED_ARCHIVE.zip`,
 
 	addCommands(cc.cmd,
 		newArchiveLsCmd(),
+		newArchiveExportCmd(),
 	)
 	return cc
 }
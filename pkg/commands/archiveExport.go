@@ -0,0 +1,155 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/cnrancher/hangar/pkg/cmdconfig"
+	"github.com/cnrancher/hangar/pkg/hangar"
+	"github.com/cnrancher/hangar/pkg/hangar/imagelist"
+	"github.com/cnrancher/hangar/pkg/rancher/kdmimages"
+	"github.com/cnrancher/hangar/pkg/utils"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+type archiveExportCmd struct {
+	*baseCmd
+
+	source      string
+	file        string
+	format      string
+	rke2        string
+	k3s         string
+	arch        []string
+	output      string
+	compression string
+	failed      string
+}
+
+func newArchiveExportCmd() *archiveExportCmd {
+	cc := &archiveExportCmd{}
+
+	cc.baseCmd = newBaseCmd(&cobra.Command{
+		Use:   "export -s SAVED_ARCHIVE.zip --format rke2-airgap",
+		Short: "Export images in Hangar archive file to other formats",
+		Long: `Export images in Hangar archive file to other formats.
+
+The 'rke2-airgap' format exports the images into per-arch multi-image
+docker-archive tarballs (zstd compressed by default), which can be copied
+into the '/var/lib/rancher/rke2/agent/images/' directory of the RKE2 node or
+'/var/lib/rancher/k3s/agent/images/' directory of the K3s node.
+
+Use '--rke2' or '--k3s' to export the images of the RKE2/K3s release only,
+the image list of the release is downloaded from GitHub release page.
+`,
+		Example: `# Export the images of RKE2 release into air-gap tarballs.
+hangar archive export \
+	--source SAVED_ARCHIVE.zip \
+	--format rke2-airgap \
+	--rke2 v1.27.8+rke2r1 \
+	--arch amd64,arm64 \
+	--output ./images
+
+# Export the images of the image list file into air-gap tarballs.
+hangar archive export \
+	--source SAVED_ARCHIVE.zip \
+	--file IMAGE_LIST.txt \
+	--arch amd64`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			initializeFlagsConfig(cmd, cmdconfig.DefaultProvider)
+			if cc.baseCmd.debug {
+				logrus.SetLevel(logrus.DebugLevel)
+				logrus.Debugf("debug output enabled")
+				logrus.Debugf("%v", utils.PrintObject(cmdconfig.Get("")))
+			}
+
+			h, err := cc.prepareHangar()
+			if err != nil {
+				return err
+			}
+			if err := run(h); err != nil {
+				return err
+			}
+			return nil
+		},
+	})
+
+	flags := cc.baseCmd.cmd.Flags()
+	flags.StringVarP(&cc.source, "source", "s", "", "saved archive filename")
+	flags.SetAnnotation("source", cobra.BashCompFilenameExt, []string{"zip"})
+	flags.SetAnnotation("source", cobra.BashCompOneRequiredFlag, []string{""})
+	flags.StringVarP(&cc.file, "file", "f", "", "image list file (optional: export all images from archive if not provided)")
+	flags.SetAnnotation("file", cobra.BashCompFilenameExt, []string{"txt"})
+	flags.StringVarP(&cc.format, "format", "", hangar.ExportFormatRKE2Airgap, "export format, available: rke2-airgap")
+	flags.StringVarP(&cc.rke2, "rke2", "", "", "export the images of the RKE2 release (example: v1.27.8+rke2r1)")
+	flags.StringVarP(&cc.k3s, "k3s", "", "", "export the images of the K3s release (example: v1.27.8+k3s2)")
+	flags.StringSliceVarP(&cc.arch, "arch", "a", []string{"amd64", "arm64"}, "architecture list of images")
+	flags.StringVarP(&cc.output, "output", "o", ".", "output directory of the exported tarballs")
+	flags.StringVarP(&cc.compression, "compression", "", hangar.CompressionZstd, "compression of the tarballs, available: zstd, none")
+	flags.StringVarP(&cc.failed, "failed", "", "export-failed.txt", "file name of the export failed image list")
+	flags.SetAnnotation("failed", cobra.BashCompFilenameExt, []string{"txt"})
+
+	return cc
+}
+
+func (cc *archiveExportCmd) prepareHangar() (hangar.Hangar, error) {
+	if cc.source == "" {
+		return nil, fmt.Errorf("source file not provided, use '--source' to provide the archive file")
+	}
+	if cc.rke2 != "" && cc.k3s != "" {
+		return nil, fmt.Errorf("'--rke2' and '--k3s' can not be specified together")
+	}
+	if cc.file != "" && (cc.rke2 != "" || cc.k3s != "") {
+		return nil, fmt.Errorf("'--file' can not be specified with '--rke2' or '--k3s'")
+	}
+
+	var (
+		images       []string
+		entries      []*imagelist.Entry
+		distribution string
+		err          error
+	)
+	switch {
+	case cc.file != "":
+		images, entries, err = readImageList(cc.file)
+		if err != nil {
+			return nil, err
+		}
+	case cc.rke2 != "":
+		distribution = kdmimages.RKE2
+		images, err = kdmimages.GetReleaseImages(distribution, cc.rke2)
+	case cc.k3s != "":
+		distribution = kdmimages.K3S
+		images, err = kdmimages.GetReleaseImages(distribution, cc.k3s)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get images of %s release: %w",
+			distribution, err)
+	}
+
+	policy, err := cc.getPolicy()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get policy: %w", err)
+	}
+	e, err := hangar.NewExporter(&hangar.ExporterOpts{
+		CommonOpts: hangar.CommonOpts{
+			Images:              images,
+			Entries:             entries,
+			Arch:                cc.arch,
+			OS:                  []string{"linux"},
+			Workers:             1,
+			FailedImageListName: cc.failed,
+			SystemContext:       cc.baseCmd.newSystemContext(),
+			Policy:              policy,
+		},
+		Format:       cc.format,
+		Distribution: distribution,
+		Compression:  cc.compression,
+		OutputDir:    cc.output,
+		ArchiveName:  cc.source,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create exporter: %w", err)
+	}
+	return e, nil
+}
@@ -85,8 +85,8 @@ func (cc *archiveLsCmd) run() error {
 	logrus.Infof("Index version: %v", index.Version)
 	logrus.Infof("Images:")
 	for i, image := range index.List {
-		fmt.Printf("%4d | %s:%s | %s | %s\n",
-			i+1, image.Source, image.Tag,
+		fmt.Printf("%4d | %s | %s | %s\n",
+			i+1, image.Reference(),
 			strings.Join(image.ArchList, ","),
 			strings.Join(image.OsList, ","))
 	}
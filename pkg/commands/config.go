@@ -1,10 +1,12 @@
 package commands
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 
 	"github.com/cnrancher/hangar/pkg/cmdconfig"
+	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	flag "github.com/spf13/pflag"
 )
@@ -28,6 +30,10 @@ func setValueFromFlag(flags *flag.FlagSet, key string, cfg cmdconfig.Provider, t
 		if targetKey != "" {
 			configKey = targetKey
 		}
+		if _, ok := f.Annotations[sensitiveAnnotation]; ok && f.Value.String() != "" {
+			cfg.Set(configKey, "******")
+			return
+		}
 		// Gotta love this API too.
 		switch f.Value.Type() {
 		case "bool":
@@ -52,3 +58,142 @@ func setValueFromFlag(flags *flag.FlagSet, key string, cfg cmdconfig.Provider, t
 		}
 	}
 }
+
+// configOpts is the global options of the configuration file.
+type configOpts struct {
+	config  string
+	profile string
+	// origins is the origin of the flag values not set in the command line:
+	// map[flag name]origin
+	origins map[string]valueOrigin
+}
+
+// valueOrigin is where the flag value comes from.
+type valueOrigin string
+
+const (
+	originDefault valueOrigin = "default"
+	originFlag    valueOrigin = "flag"
+	originEnv     valueOrigin = "env"
+	originConfig  valueOrigin = "config"
+)
+
+func (o *configOpts) addFlags(flags *flag.FlagSet) {
+	flags.StringVarP(&o.config, "config", "", "",
+		"configuration file of the command flags (YAML/JSON)")
+	flags.SetAnnotation("config", cobra.BashCompFilenameExt, []string{"yaml", "yml", "json"})
+	flags.StringVarP(&o.profile, "profile", "", "",
+		"profile name in the configuration file")
+}
+
+// loadConfig applies the values of the environment variables and the
+// configuration file to the flags not set in the command line.
+// The precedence is: flag > env > config file > default.
+func (o *configOpts) loadConfig(cmd *cobra.Command) error {
+	flags := cmd.Flags()
+	name := o.config
+	if !flags.Changed("config") {
+		name, _ = cmdconfig.LookupEnv("config")
+	}
+	var file *cmdconfig.File
+	if name != "" {
+		f, err := cmdconfig.LoadFile(name)
+		if err != nil {
+			return err
+		}
+		if err := f.Validate(cmd.Root()); err != nil {
+			return err
+		}
+		file = f
+	}
+	profile := o.profile
+	if !flags.Changed("profile") {
+		if p, ok := cmdconfig.LookupEnv("profile"); ok {
+			profile = p
+		} else {
+			profile = file.Profile()
+		}
+	}
+	command := strings.Fields(
+		strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()))
+	values, err := file.Values(command, profile)
+	if err != nil {
+		return err
+	}
+	o.config, o.profile = name, profile
+	o.origins = make(map[string]valueOrigin)
+
+	var errs []error
+	flags.VisitAll(func(f *flag.Flag) {
+		switch {
+		case f.Changed:
+			return
+		case f.Name == "config" || f.Name == "profile":
+			return
+		}
+		if v, ok := cmdconfig.LookupEnv(f.Name); ok {
+			if err := setFlagValue(f, v); err != nil {
+				errs = append(errs, fmt.Errorf("invalid env %s: %w",
+					cmdconfig.EnvName(f.Name), err))
+			}
+			o.origins[f.Name] = originEnv
+			return
+		}
+		if v, ok := values[f.Name]; ok {
+			if err := setFlagValue(f, v); err != nil {
+				errs = append(errs, fmt.Errorf("invalid value of %q in config file %q: %w",
+					f.Name, file.Name(), err))
+			}
+			o.origins[f.Name] = originConfig
+		}
+	})
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	if file != nil {
+		logrus.Debugf("loaded config file %q (profile %q)", file.Name(), profile)
+	}
+	return nil
+}
+
+// flagOrigin returns where the value of the flag comes from, the flag set
+// by the environment variable or the config file is not marked as changed,
+// so use this instead of 'Changed()' to check whether the flag is provided
+// by the user.
+func (o *configOpts) flagOrigin(flags *flag.FlagSet, name string) valueOrigin {
+	if flags.Changed(name) {
+		return originFlag
+	}
+	if origin, ok := o.origins[name]; ok {
+		return origin
+	}
+	return originDefault
+}
+
+// debugOrigins outputs the flags set by the environment variables and the
+// config file in debug level.
+func (o *configOpts) debugOrigins(cmd *cobra.Command) {
+	flags := cmd.Flags()
+	flags.VisitAll(func(f *flag.Flag) {
+		switch origin := o.flagOrigin(flags, f.Name); origin {
+		case originEnv, originConfig:
+			logrus.Debugf("Flag '--%s' is set by %s", f.Name, origin)
+		}
+	})
+}
+
+// setFlagValue sets the string or []string value to the flag, the flag
+// is not marked as changed to keep it distinct from the command line flag.
+func setFlagValue(f *flag.Flag, value any) error {
+	switch v := value.(type) {
+	case []string:
+		if sv, ok := f.Value.(flag.SliceValue); ok {
+			return sv.Replace(v)
+		}
+		if len(v) != 1 {
+			return fmt.Errorf("flag %q does not accept the list value", f.Name)
+		}
+		value = v[0]
+	}
+	return f.Value.Set(value.(string))
+}
@@ -0,0 +1,36 @@
+package commands
+
+import (
+	"github.com/cnrancher/hangar/pkg/containerd"
+	hangartypes "github.com/cnrancher/hangar/pkg/types"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
+)
+
+// containerdOpts is the options to use the containerd image store instead
+// of the registry server.
+type containerdOpts struct {
+	containerd          bool
+	containerdAddress   string
+	containerdNamespace string
+}
+
+func (o *containerdOpts) addFlags(flags *pflag.FlagSet, usage string) {
+	flags.BoolVarP(&o.containerd, "containerd", "", false, usage)
+	flags.StringVarP(&o.containerdAddress, "containerd-address", "", containerd.DefaultAddress,
+		"containerd socket address (K3s/RKE2: /run/k3s/containerd/containerd.sock)")
+	flags.StringVarP(&o.containerdNamespace, "containerd-namespace", "", containerd.DefaultNamespace,
+		"containerd namespace of the images")
+}
+
+// imageType configures the containerd transport and returns the image type
+// of the containerd image store if enabled, otherwise returns docker type.
+func (o *containerdOpts) imageType() hangartypes.ImageType {
+	if !o.containerd {
+		return hangartypes.TypeDocker
+	}
+	containerd.Configure(o.containerdAddress, o.containerdNamespace)
+	logrus.Infof("Using containerd %q namespace %q",
+		o.containerdAddress, o.containerdNamespace)
+	return hangartypes.TypeContainerd
+}
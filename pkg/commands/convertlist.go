@@ -109,20 +109,23 @@ func (cc *convertListCmd) run() error {
 			continue
 		}
 
-		spec := make([]string, 0, 2)
-		for _, v := range strings.Split(l, ":") {
-			if len(v) > 0 {
-				spec = append(spec, v)
-			}
+		// Remove the tag & digest of the image.
+		image := l
+		if i := strings.LastIndex(image, "@"); i >= 0 {
+			image = image[:i]
+		}
+		if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+			image = image[:i]
 		}
-		if len(spec) != 2 {
-			if len(spec) == 1 {
-				spec = append(spec, "latest")
+		tag := utils.GetImageTag(l)
+		if dig := utils.GetImageDigest(l); dig != "" {
+			if tag != "" {
+				tag = tag + "@" + dig
 			} else {
-				logrus.Warnf("Ignore line: %q: format unknow", l)
-				continue
+				tag = dig
 			}
 		}
+		spec := []string{image, tag}
 
 		var srcImage string
 		if cc.source == "" {
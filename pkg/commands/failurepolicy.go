@@ -0,0 +1,52 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/cnrancher/hangar/pkg/hangar"
+	"github.com/spf13/pflag"
+)
+
+// failurePolicyOpts is the options to decide how to handle the failed images.
+type failurePolicyOpts struct {
+	warnOn    []string
+	abortOn   []string
+	maxErrors int
+}
+
+func (o *failurePolicyOpts) addFlags(flags *pflag.FlagSet) {
+	flags.StringSliceVarP(&o.warnOn, "warn-on", "", nil,
+		"error categories treated as warning instead of failure (example: not-found)")
+	flags.StringSliceVarP(&o.abortOn, "abort-on", "", nil,
+		"error categories to abort the whole run on the first error (example: auth)")
+	flags.IntVarP(&o.maxErrors, "max-errors", "", 0,
+		"abort the whole run after the number of images failed (0 for unlimited)")
+}
+
+// failurePolicy returns the failure policy, returns nil if not specified.
+func (o *failurePolicyOpts) failurePolicy() (*hangar.FailurePolicy, error) {
+	if len(o.warnOn) == 0 && len(o.abortOn) == 0 && o.maxErrors == 0 {
+		return nil, nil
+	}
+	if o.maxErrors < 0 {
+		return nil, fmt.Errorf("invalid '--max-errors' value %d", o.maxErrors)
+	}
+	p := &hangar.FailurePolicy{
+		MaxErrors: o.maxErrors,
+	}
+	for _, s := range o.warnOn {
+		c, err := hangar.ParseErrorCategory(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid '--warn-on' value: %w", err)
+		}
+		p.Warn = append(p.Warn, c)
+	}
+	for _, s := range o.abortOn {
+		c, err := hangar.ParseErrorCategory(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid '--abort-on' value: %w", err)
+		}
+		p.Abort = append(p.Abort, c)
+	}
+	return p, nil
+}
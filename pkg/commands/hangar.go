@@ -1,12 +1,16 @@
 package commands
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/cnrancher/hangar/pkg/hangar"
+	"github.com/cnrancher/hangar/pkg/hangar/imagelist"
 	"github.com/containers/common/pkg/auth"
 	"github.com/containers/common/pkg/retry"
 	"github.com/containers/image/v5/pkg/docker/config"
@@ -32,6 +36,8 @@ func Execute(args []string) error {
 
 type hangarCmd struct {
 	*baseCmd
+	configOpts
+	logOpts
 }
 
 func newHangarCmd() *hangarCmd {
@@ -43,8 +49,27 @@ func newHangarCmd() *hangarCmd {
 multi-architecture & multi-platform container images between image registries.
 Aiming to simplify the process of copying container images between registries.
 
+Use '--config' to provide the flags in the configuration file, the flags can also
+be provided by the 'HANGAR_*' environment variables (example: HANGAR_TLS_VERIFY).
+The precedence is: command line flag > environment variable > config file > default.
+The keys of the config file should be the flag names of the commands, unknown
+keys are rejected.
+
 https://hangar.cnrancher.com
 `,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := cc.loadConfig(cmd); err != nil {
+				return err
+			}
+			if err := cc.setupLogging(cmd); err != nil {
+				return err
+			}
+			if cc.baseCmd.debug {
+				logrus.SetLevel(logrus.DebugLevel)
+				cc.debugOrigins(cmd)
+			}
+			return nil
+		},
 		Run: func(cmd *cobra.Command, args []string) {
 			cmd.Help()
 		},
@@ -56,6 +81,8 @@ https://hangar.cnrancher.com
 	flags := cc.cmd.PersistentFlags()
 	flags.BoolVarP(&cc.baseCmd.debug, "debug", "", false, "enable debug output")
 	flags.BoolVar(&cc.baseCmd.insecurePolicy, "insecure-policy", false, "run Hangar without policy check")
+	cc.configOpts.addFlags(flags)
+	cc.logOpts.addFlags(flags)
 
 	return cc
 }
@@ -78,6 +105,9 @@ func (cc *hangarCmd) addCommands() {
 		newInspectCmd(),
 		newConvertListCmd(),
 		newGenerateListCmd(),
+		newHarborCmd(),
+		newRegistryConfigCmd(),
+		newServeCmd(),
 	)
 }
 
@@ -88,6 +118,12 @@ func run(h hangar.Hangar) error {
 		if err := h.SaveFailedImages(); err != nil {
 			return err
 		}
+		if err := h.SaveReport(); err != nil {
+			return err
+		}
+		return err
+	}
+	if err := h.SaveReport(); err != nil {
 		return err
 	}
 	logrus.Infof("Done")
@@ -101,12 +137,46 @@ func validate(h hangar.Hangar) error {
 		if err := h.SaveFailedImages(); err != nil {
 			return err
 		}
+		if err := h.SaveReport(); err != nil {
+			return err
+		}
+		return err
+	}
+	if err := h.SaveReport(); err != nil {
 		return err
 	}
 	logrus.Infof("Done")
 	return nil
 }
 
+// readImageList reads the image list file, returns the image list lines
+// or the entries if the file is the structured (YAML/JSON) image list.
+func readImageList(name string) ([]string, []*imagelist.Entry, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read %q: %w", name, err)
+	}
+	if imagelist.IsStructured(name, b) {
+		list, err := imagelist.ParseStructured(b)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse %q: %w", name, err)
+		}
+		return nil, list.Images, nil
+	}
+
+	images := []string{}
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	sc.Split(bufio.ScanLines)
+	for sc.Scan() {
+		l := strings.TrimSpace(sc.Text())
+		if l == "" || strings.HasPrefix(l, "#") || strings.HasPrefix(l, "//") {
+			continue
+		}
+		images = append(images, l)
+	}
+	return images, nil, nil
+}
+
 func prepareLogin(
 	ctx context.Context,
 	registrySet map[string]bool,
@@ -0,0 +1,40 @@
+package commands
+
+import (
+	"github.com/cnrancher/hangar/pkg/cmdconfig"
+	"github.com/cnrancher/hangar/pkg/utils"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+type harborCmd struct {
+	*baseCmd
+}
+
+func newHarborCmd() *harborCmd {
+	cc := &harborCmd{}
+
+	cc.baseCmd = newBaseCmd(&cobra.Command{
+		Use:   "harbor",
+		Short: "Action for Harbor V2 registry server",
+		Long:  "",
+		Example: `
+# Migrate all projects and images from OLD HARBOR to NEW HARBOR:
+hangar harbor migrate -s OLD_HARBOR_REGISTRY -d NEW_HARBOR_REGISTRY`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			initializeFlagsConfig(cmd, cmdconfig.DefaultProvider)
+			if cc.baseCmd.debug {
+				logrus.SetLevel(logrus.DebugLevel)
+				logrus.Debugf("debug output enabled")
+				logrus.Debugf("%v", utils.PrintObject(cmdconfig.Get("")))
+			}
+			return cmd.Help()
+		},
+	})
+
+	addCommands(cc.cmd,
+		newHarborMigrateCmd(),
+		newHarborProjectCmd(),
+	)
+	return cc
+}
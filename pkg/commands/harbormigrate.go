@@ -0,0 +1,313 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cnrancher/hangar/pkg/cmdconfig"
+	"github.com/cnrancher/hangar/pkg/hangar"
+	"github.com/cnrancher/hangar/pkg/harbor"
+	"github.com/cnrancher/hangar/pkg/provider"
+	"github.com/cnrancher/hangar/pkg/utils"
+	commonFlag "github.com/containers/common/pkg/flag"
+	"github.com/containers/image/v5/pkg/docker/config"
+	"github.com/containers/image/v5/types"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+type harborMigrateOpts struct {
+	source      string
+	destination string
+	projects    []string
+	arch        []string
+	os          []string
+	failed      string
+	jobs        int
+	timeout     time.Duration
+	skipLogin   bool
+	tlsVerify   commonFlag.OptionalBool
+}
+
+type harborMigrateCmd struct {
+	*baseCmd
+	*harborMigrateOpts
+
+	// nested is the images of the nested repositories not supported by
+	// the mirrorer, recorded as failed images.
+	nested []string
+}
+
+func newHarborMigrateCmd() *harborMigrateCmd {
+	cc := &harborMigrateCmd{
+		harborMigrateOpts: new(harborMigrateOpts),
+	}
+	cc.baseCmd = newBaseCmd(&cobra.Command{
+		Use:   "migrate -s SOURCE_HARBOR -d DESTINATION_HARBOR",
+		Short: "Migrate projects and images between Harbor V2 registry servers",
+		Long: `Migrate projects and images between Harbor V2 registry servers.
+
+The migrate command enumerates all projects and repositories of the source
+Harbor by the Harbor V2 API, creates the projects with the same visibility
+and storage quota on the destination Harbor and mirrors all the artifacts.
+
+The nested repositories (e.g. 'project/a/b') are not supported, their images
+are recorded into the failed image list and the migrate command fails.
+`,
+		Example: `# Migrate all projects from OLD HARBOR to NEW HARBOR.
+hangar harbor migrate \
+	--source OLD_HARBOR_REGISTRY \
+	--destination NEW_HARBOR_REGISTRY
+
+# Only migrate the specified projects.
+hangar harbor migrate \
+	--source OLD_HARBOR_REGISTRY \
+	--destination NEW_HARBOR_REGISTRY \
+	--project library,rancher`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			initializeFlagsConfig(cmd, cmdconfig.DefaultProvider)
+			if cc.baseCmd.debug {
+				logrus.SetLevel(logrus.DebugLevel)
+				logrus.Debugf("debug output enabled")
+				logrus.Debugf("%v", utils.PrintObject(cmdconfig.Get("")))
+			}
+			h, err := cc.prepareHangar()
+			if err != nil {
+				return err
+			}
+			if h != nil {
+				err = run(h)
+			}
+			if len(cc.nested) > 0 {
+				// The failed image list is written by the mirrorer if failed.
+				if err := cc.saveNestedImages(err != nil); err != nil {
+					return err
+				}
+				return errors.Join(err, fmt.Errorf(
+					"%d images in nested repositories not migrated, see %q",
+					len(cc.nested), cc.failed))
+			}
+			return err
+		},
+	})
+
+	flags := cc.baseCmd.cmd.Flags()
+	flags.StringVarP(&cc.source, "source", "s", "", "source Harbor V2 registry")
+	flags.SetAnnotation("source", cobra.BashCompOneRequiredFlag, []string{""})
+	flags.StringVarP(&cc.destination, "destination", "d", "", "destination Harbor V2 registry")
+	flags.SetAnnotation("destination", cobra.BashCompOneRequiredFlag, []string{""})
+	flags.StringSliceVarP(&cc.projects, "project", "", nil, "only migrate the specified projects (default: all projects)")
+	flags.StringSliceVarP(&cc.arch, "arch", "a", nil, "architecture list of images (default: all architectures)")
+	flags.StringSliceVarP(&cc.os, "os", "", nil, "OS list of images (default: all OS)")
+	flags.StringVarP(&cc.failed, "failed", "o", "migrate-failed.txt", "file name of the migrate failed image list")
+	flags.SetAnnotation("failed", cobra.BashCompFilenameExt, []string{"txt"})
+	flags.IntVarP(&cc.jobs, "jobs", "j", 1, "worker number, copy images parallelly (1-20)")
+	flags.DurationVarP(&cc.timeout, "timeout", "", time.Minute*10, "timeout when mirror each images")
+	commonFlag.OptionalBoolFlag(flags, &cc.tlsVerify, "tls-verify", "require HTTPS and verify certificates")
+	flags.BoolVarP(&cc.skipLogin, "skip-login", "", false,
+		"skip check the registries are logged in (used in shell script)")
+
+	return cc
+}
+
+func (cc *harborMigrateCmd) prepareHangar() (hangar.Hangar, error) {
+	if cc.source == "" {
+		return nil, fmt.Errorf("source registry not provided, use '--source' to provide the registry")
+	}
+	if cc.destination == "" {
+		return nil, fmt.Errorf("destination registry not provided, use '--destination' to provide the registry")
+	}
+	if cc.debug {
+		logrus.Infof("debug mode enabled, force worker number to 1")
+		cc.jobs = 1
+	} else {
+		if cc.jobs > utils.MaxWorkerNum || cc.jobs < utils.MinWorkerNum {
+			logrus.Warnf("invalid worker num: %v, set to 1", cc.jobs)
+			cc.jobs = 1
+		}
+	}
+
+	sysCtx := cc.baseCmd.newSystemContext()
+	if cc.tlsVerify.Present() {
+		sysCtx.DockerInsecureSkipTLSVerify = types.NewOptionalBool(!cc.tlsVerify.Value())
+		sysCtx.OCIInsecureSkipTLSVerify = !cc.tlsVerify.Value()
+	}
+	if !cc.skipLogin {
+		if err := prepareLogin(
+			signalContext,
+			map[string]bool{cc.source: true, cc.destination: true},
+			utils.CopySystemContext(sysCtx),
+		); err != nil {
+			return nil, err
+		}
+	}
+
+	images, err := cc.migrateProjects(sysCtx)
+	if err != nil {
+		return nil, err
+	}
+	if len(images) == 0 {
+		if len(cc.nested) > 0 {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("no images found in source registry %q", cc.source)
+	}
+
+	policy, err := cc.getPolicy()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get policy: %w", err)
+	}
+	m, err := hangar.NewMirrorer(&hangar.MirrorerOpts{
+		CommonOpts: hangar.CommonOpts{
+			Images:              images,
+			Arch:                cc.arch,
+			OS:                  cc.os,
+			Variant:             nil,
+			Timeout:             cc.timeout,
+			Workers:             cc.jobs,
+			FailedImageListName: cc.failed,
+			SystemContext:       sysCtx,
+			Policy:              policy,
+		},
+
+		DestinationRegistry: cc.destination,
+		// The projects are created by migrateProjects with the source spec.
+		ProjectOpts: hangar.ProjectOpts{
+			RegistryProvider: provider.TypeNone,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create mirrorer: %v", err)
+	}
+	return m, nil
+}
+
+// migrateProjects creates the projects of the source Harbor on the
+// destination Harbor, returns the image list of all the artifacts.
+func (cc *harborMigrateCmd) migrateProjects(
+	sysCtx *types.SystemContext,
+) ([]string, error) {
+	sourceURL, err := harbor.GetRegistryURL(signalContext, cc.source, sysCtx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get URL of source registry %q: %w",
+			cc.source, err)
+	}
+	destURL, err := harbor.GetRegistryURL(signalContext, cc.destination, sysCtx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get URL of destination registry %q: %w",
+			cc.destination, err)
+	}
+	sourceRegistryCredential, err := config.GetCredentials(sysCtx, cc.source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get credential of %q: %w", cc.source, err)
+	}
+	destRegistryCredential, err := config.GetCredentials(sysCtx, cc.destination)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get credential of %q: %w", cc.destination, err)
+	}
+	sourceCredential := harbor.APICredential(sourceRegistryCredential, "")
+	destCredential := harbor.APICredential(destRegistryCredential, "")
+
+	projects, err := harbor.ListProjects(
+		signalContext, sourceURL, sourceCredential, sysCtx)
+	if err != nil {
+		return nil, err
+	}
+	projectSet := map[string]bool{}
+	for _, p := range cc.projects {
+		projectSet[p] = true
+	}
+
+	var images []string
+	for _, p := range projects {
+		if len(projectSet) > 0 && !projectSet[p.Name] {
+			continue
+		}
+		if err := cc.createProject(p, destURL, destCredential, sysCtx); err != nil {
+			if !errors.Is(err, harbor.ErrPermissionDenied) {
+				return nil, err
+			}
+			logrus.Warnf("Skip creating Harbor V2 project %q: %v", p.Name, err)
+		}
+
+		n := len(images)
+		repositories, err := harbor.ListRepositories(
+			signalContext, p.Name, sourceURL, sourceCredential, sysCtx)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range repositories {
+			name := strings.TrimPrefix(r, p.Name+"/")
+			artifacts, err := harbor.ListArtifacts(
+				signalContext, p.Name, name, sourceURL, sourceCredential, sysCtx)
+			if err != nil {
+				return nil, err
+			}
+			var found []string
+			for _, a := range artifacts {
+				if len(a.Tags) == 0 {
+					found = append(found, fmt.Sprintf("%s/%s/%s@%s",
+						cc.source, p.Name, name, a.Digest))
+					continue
+				}
+				for _, t := range a.Tags {
+					found = append(found, fmt.Sprintf("%s/%s/%s:%s",
+						cc.source, p.Name, name, t))
+				}
+			}
+			if strings.Contains(name, "/") {
+				logrus.Errorf("Failed to migrate repository %q: nested repository not supported", r)
+				cc.nested = append(cc.nested, found...)
+				continue
+			}
+			images = append(images, found...)
+		}
+		logrus.Infof("Found %d images in project %q", len(images)-n, p.Name)
+	}
+	return images, nil
+}
+
+// createProject creates the project on the destination Harbor if not exists.
+func (cc *harborMigrateCmd) createProject(
+	p *harbor.Project, u string, credential *types.DockerAuthConfig, sysCtx *types.SystemContext,
+) error {
+	exists, err := harbor.ProjectExists(signalContext, p.Name, u, credential, sysCtx)
+	if err != nil {
+		return err
+	}
+	if exists {
+		logrus.Infof("Harbor project %q already exists in %q",
+			p.Name, cc.destination)
+		return nil
+	}
+	err = harbor.CreateProjectWithSpec(signalContext, p, u, credential, sysCtx)
+	if err != nil {
+		return err
+	}
+	logrus.Infof("Created Harbor V2 project %q (public: %v) for registry %q",
+		p.Name, p.Public, cc.destination)
+	return nil
+}
+
+// saveNestedImages writes the images of the nested repositories into the
+// failed image list, appends to the failed image list of the mirrorer.
+func (cc *harborMigrateCmd) saveNestedImages(appendFile bool) error {
+	flag := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if appendFile {
+		flag = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+	f, err := os.OpenFile(cc.failed, flag, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open file %q: %w", cc.failed, err)
+	}
+	defer f.Close()
+	for _, image := range cc.nested {
+		if _, err := f.WriteString(image + "\n"); err != nil {
+			return fmt.Errorf("failed to write file: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,247 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/cnrancher/hangar/pkg/cmdconfig"
+	"github.com/cnrancher/hangar/pkg/harbor"
+	"github.com/cnrancher/hangar/pkg/utils"
+	commonFlag "github.com/containers/common/pkg/flag"
+	"github.com/containers/image/v5/pkg/docker/config"
+	"github.com/containers/image/v5/types"
+	"github.com/docker/go-units"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// harborProjectOpts is the options of the Harbor V2 projects created by
+// hangar, shared by the commands creating projects.
+type harborProjectOpts struct {
+	public        bool
+	storageLimit  string
+	autoScan      bool
+	cveAllowlist  []string
+	robot         string
+	robotPush     bool
+	robotDuration int
+	robotOutput   string
+	token         string
+}
+
+func (o *harborProjectOpts) addFlags(flags *pflag.FlagSet) {
+	flags.BoolVarP(&o.public, "harbor-project-public", "", false,
+		"create public Harbor V2 projects")
+	flags.StringVarP(&o.storageLimit, "harbor-project-storage-limit", "", "",
+		"storage quota of the created Harbor V2 projects, example: 10GiB (-1 for unlimited)")
+	flags.BoolVarP(&o.autoScan, "harbor-project-auto-scan", "", false,
+		"scan images automatically on push for the created Harbor V2 projects")
+	flags.StringSliceVarP(&o.cveAllowlist, "harbor-project-cve-allowlist", "", nil,
+		"CVE allowlist of the created Harbor V2 projects, example: CVE-2023-1234")
+	flags.StringVarP(&o.robot, "harbor-robot", "", "",
+		"create the pull robot account with the name for Harbor V2 projects")
+	flags.BoolVarP(&o.robotPush, "harbor-robot-push", "", false,
+		"grant push permission to the created robot accounts")
+	flags.IntVarP(&o.robotDuration, "harbor-robot-duration", "", -1,
+		"expiration days of the created robot accounts (-1 for never expire)")
+	flags.StringVarP(&o.robotOutput, "harbor-robot-output", "", "",
+		"file name to save the robot accounts in docker config JSON format (default: print to stdout)")
+	flags.StringVarP(&o.token, "harbor-token", "", "",
+		"bearer token (OIDC ID token) for Harbor V2 API (default: use the registry username and password)")
+}
+
+// projectSpec returns the spec of the Harbor V2 project to be created.
+func (o *harborProjectOpts) projectSpec() (*harbor.Project, error) {
+	p := &harbor.Project{
+		Public:       o.public,
+		AutoScan:     o.autoScan,
+		CVEAllowlist: o.cveAllowlist,
+	}
+	switch o.storageLimit {
+	case "":
+	case "-1":
+		p.StorageLimit = -1
+	default:
+		limit, err := units.RAMInBytes(o.storageLimit)
+		if err != nil || limit <= 0 {
+			return nil, fmt.Errorf("invalid storage limit %q", o.storageLimit)
+		}
+		p.StorageLimit = limit
+	}
+	return p, nil
+}
+
+// robotOptions returns the options of the robot account to be created,
+// returns nil if not specified.
+func (o *harborProjectOpts) robotOptions() *harbor.RobotOptions {
+	if o.robot == "" {
+		return nil
+	}
+	return &harbor.RobotOptions{
+		Name:        o.robot,
+		Description: "Created by hangar",
+		Duration:    o.robotDuration,
+		Push:        o.robotPush,
+	}
+}
+
+type harborProjectCmd struct {
+	*baseCmd
+}
+
+func newHarborProjectCmd() *harborProjectCmd {
+	cc := &harborProjectCmd{}
+
+	cc.baseCmd = newBaseCmd(&cobra.Command{
+		Use:   "project",
+		Short: "Manage Harbor V2 projects",
+		Long:  "",
+		Example: `
+# Create private Harbor V2 project with 10GiB storage quota and pull robot account:
+hangar harbor project create library \
+	--registry HARBOR_REGISTRY \
+	--harbor-project-storage-limit 10GiB \
+	--harbor-robot puller`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Help()
+		},
+	})
+
+	addCommands(cc.cmd,
+		newHarborProjectCreateCmd(),
+	)
+	return cc
+}
+
+type harborProjectCreateOpts struct {
+	harborProjectOpts
+
+	registry  string
+	skipLogin bool
+	tlsVerify commonFlag.OptionalBool
+}
+
+type harborProjectCreateCmd struct {
+	*baseCmd
+	*harborProjectCreateOpts
+}
+
+func newHarborProjectCreateCmd() *harborProjectCreateCmd {
+	cc := &harborProjectCreateCmd{
+		harborProjectCreateOpts: new(harborProjectCreateOpts),
+	}
+
+	cc.baseCmd = newBaseCmd(&cobra.Command{
+		Use:   "create PROJECT [PROJECT...] -r HARBOR_REGISTRY",
+		Short: "Create Harbor V2 projects",
+		Long:  "",
+		Example: `
+# Create public Harbor V2 projects with auto scan enabled:
+hangar harbor project create library rancher \
+	--registry HARBOR_REGISTRY \
+	--harbor-project-public \
+	--harbor-project-auto-scan`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			initializeFlagsConfig(cmd, cmdconfig.DefaultProvider)
+			if cc.baseCmd.debug {
+				logrus.SetLevel(logrus.DebugLevel)
+				logrus.Debugf("debug output enabled")
+				logrus.Debugf("%v", utils.PrintObject(cmdconfig.Get("")))
+			}
+			return cc.run(args)
+		},
+	})
+
+	flags := cc.baseCmd.cmd.Flags()
+	flags.StringVarP(&cc.registry, "registry", "r", "", "Harbor V2 registry")
+	flags.SetAnnotation("registry", cobra.BashCompOneRequiredFlag, []string{""})
+	commonFlag.OptionalBoolFlag(flags, &cc.tlsVerify, "tls-verify", "require HTTPS and verify certificates")
+	flags.BoolVarP(&cc.skipLogin, "skip-login", "", false,
+		"skip check the registry is logged in (used in shell script)")
+	cc.harborProjectOpts.addFlags(flags)
+
+	return cc
+}
+
+func (cc *harborProjectCreateCmd) run(projects []string) error {
+	if cc.registry == "" {
+		return fmt.Errorf("registry not provided, use '--registry' to provide the registry")
+	}
+	spec, err := cc.projectSpec()
+	if err != nil {
+		return err
+	}
+
+	sysCtx := cc.baseCmd.newSystemContext()
+	if cc.tlsVerify.Present() {
+		sysCtx.DockerInsecureSkipTLSVerify = types.NewOptionalBool(!cc.tlsVerify.Value())
+		sysCtx.OCIInsecureSkipTLSVerify = !cc.tlsVerify.Value()
+	}
+	if !cc.skipLogin {
+		if err := prepareLogin(
+			signalContext,
+			map[string]bool{cc.registry: true},
+			utils.CopySystemContext(sysCtx),
+		); err != nil {
+			return err
+		}
+	}
+	harborURL, err := harbor.GetRegistryURL(signalContext, cc.registry, sysCtx)
+	if err != nil {
+		return fmt.Errorf("failed to get URL of registry %q: %w", cc.registry, err)
+	}
+	registryCredential, err := config.GetCredentials(sysCtx, cc.registry)
+	if err != nil {
+		return fmt.Errorf("failed to get credential of %q: %w", cc.registry, err)
+	}
+	credential := harbor.APICredential(registryCredential, cc.token)
+
+	var robots []*harbor.Robot
+	for _, project := range projects {
+		exists, err := harbor.ProjectExists(
+			signalContext, project, harborURL, credential, sysCtx)
+		if err != nil {
+			return err
+		}
+		if exists {
+			logrus.Infof("Harbor project %q already exists", project)
+		} else {
+			p := *spec
+			p.Name = project
+			err = harbor.CreateProjectWithSpec(
+				signalContext, &p, harborURL, credential, sysCtx)
+			if err != nil {
+				return err
+			}
+			logrus.Infof("Created Harbor V2 project %q for registry %q",
+				project, cc.registry)
+		}
+
+		robotOpts := cc.robotOptions()
+		if robotOpts == nil {
+			continue
+		}
+		robot, err := harbor.CreateRobotAccount(
+			signalContext, project, robotOpts, harborURL, credential, sysCtx)
+		if err != nil {
+			return err
+		}
+		logrus.Infof("Created robot account %q of project %q", robot.Name, project)
+		robots = append(robots, robot)
+	}
+	if len(robots) == 0 {
+		return nil
+	}
+	if cc.robotOutput == "" {
+		for _, r := range robots {
+			logrus.Infof("Robot account %q secret: %v", r.Name, r.Secret)
+		}
+		return nil
+	}
+	if err := harbor.SaveRobotAccounts(cc.robotOutput, cc.registry, robots); err != nil {
+		return err
+	}
+	logrus.Infof("Robot accounts exported to %q", cc.robotOutput)
+	return nil
+}
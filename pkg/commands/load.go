@@ -1,14 +1,14 @@
 package commands
 
 import (
-	"bufio"
 	"fmt"
-	"os"
 	"strings"
 	"time"
 
 	"github.com/cnrancher/hangar/pkg/cmdconfig"
 	"github.com/cnrancher/hangar/pkg/hangar"
+	"github.com/cnrancher/hangar/pkg/hangar/imagelist"
+	"github.com/cnrancher/hangar/pkg/provider"
 	"github.com/cnrancher/hangar/pkg/utils"
 	commonFlag "github.com/containers/common/pkg/flag"
 	"github.com/containers/image/v5/types"
@@ -30,6 +30,19 @@ type loadOpts struct {
 	project        string
 	skipLogin      bool
 	tlsVerify      commonFlag.OptionalBool
+
+	harborProjectOpts
+	registryProviderOpts
+	storageOpts
+	containerdOpts
+	progressOpts
+	reportOpts
+	failurePolicyOpts
+	metricsOpts
+	tracingOpts
+	registrySettingsOpts
+
+	destAuth registryAuthOpts
 }
 
 type loadCmd struct {
@@ -46,7 +59,21 @@ func newLoadCmd() *loadCmd {
 		Short: "Load images from zip archive created by 'save' command to registry server",
 		Long: `Load images from zip archive created by 'save' command to registry server.
 
-The load command will create Harbor V2 projects for destination registry automatically.
+Use '--storage-dir' instead of '--destination' to write images into the
+filesystem storage directory of the distribution registry (registry:2), the
+directory can be mounted as '/var/lib/registry' of the registry container.
+
+Use '--containerd' to load images into the containerd image store of the node
+directly, the '--destination' overrides the registry of the image names
+(optional).
+
+The load command will create the projects for destination registry automatically,
+the Harbor V2 projects, Quay organizations, Nexus docker repositories, GitLab
+groups and projects, and ACR (Alibaba Cloud Container Registry Enterprise
+Edition) namespaces are supported, use '--registry-provider' to specify the
+registry provider if it can not be detected automatically. The AccessKey of ACR
+is read from the ALIBABA_CLOUD_ACCESS_KEY_ID and ALIBABA_CLOUD_ACCESS_KEY_SECRET
+environment variables.
 `,
 		Example: `# Load images from SAVED_ARCHIVE.zip to REGISTRY SERVER.
 hangar load \
@@ -54,7 +81,18 @@ hangar load \
 	--source SAVED_ARCHIVE.zip \
 	--destination REGISTRY_URL \
 	--arch amd64,arm64 \
-	--os linux`,
+	--os linux
+
+# Load images into the storage directory of the registry:2 container.
+hangar load \
+	--source SAVED_ARCHIVE.zip \
+	--storage-dir /var/lib/registry
+
+# Load images into the containerd image store of the K3s/RKE2 node.
+hangar load \
+	--source SAVED_ARCHIVE.zip \
+	--containerd \
+	--containerd-address /run/k3s/containerd/containerd.sock`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			initializeFlagsConfig(cmd, cmdconfig.DefaultProvider)
 			if cc.baseCmd.debug {
@@ -63,6 +101,11 @@ hangar load \
 				logrus.Debugf("%v", utils.PrintObject(cmdconfig.Get("")))
 			}
 
+			defer cc.closeStorage()
+			defer cc.closeProgress()
+			defer cc.closeMetrics()
+			defer cc.closeTracing()
+			defer cc.closeRegistrySettings()
 			h, err := cc.prepareHangar()
 			if err != nil {
 				return err
@@ -94,6 +137,18 @@ hangar load \
 
 	flags.BoolVarP(&cc.skipLogin, "skip-login", "", false,
 		"skip check the destination registry is logged in (used in shell script)")
+	cc.harborProjectOpts.addFlags(flags)
+	cc.registryProviderOpts.addFlags(flags)
+	cc.storageOpts.addFlags(flags)
+	cc.containerdOpts.addFlags(flags,
+		"load images into the containerd image store instead of registry server")
+	cc.progressOpts.addFlags(flags)
+	cc.reportOpts.addFlags(flags)
+	cc.failurePolicyOpts.addFlags(flags)
+	cc.metricsOpts.addFlags(flags)
+	cc.tracingOpts.addFlags(flags)
+	cc.registrySettingsOpts.addFlags(flags)
+	cc.destAuth.addFlags(flags, "dest", "destination")
 
 	addCommands(
 		cc.cmd,
@@ -106,9 +161,15 @@ func (cc *loadCmd) prepareHangar() (hangar.Hangar, error) {
 	if cc.source == "" {
 		return nil, fmt.Errorf("source file not provided, use '--source' to provide the archive file")
 	}
-	if cc.destination == "" {
+	if cc.destination == "" && cc.storageDir == "" && !cc.containerd {
 		return nil, fmt.Errorf("destination registry URL not provided, use '--destination' to provide the registry")
 	}
+	if cc.destination != "" && cc.storageDir != "" {
+		return nil, fmt.Errorf("'--destination' and '--storage-dir' can not be specified together")
+	}
+	if cc.containerd && cc.storageDir != "" {
+		return nil, fmt.Errorf("'--containerd' and '--storage-dir' can not be specified together")
+	}
 	if cc.debug {
 		logrus.Infof("debug mode enabled, force worker number to 1")
 		cc.jobs = 1
@@ -119,23 +180,15 @@ func (cc *loadCmd) prepareHangar() (hangar.Hangar, error) {
 		}
 	}
 
-	var images []string
+	var (
+		images  []string
+		entries []*imagelist.Entry
+		err     error
+	)
 	if cc.file != "" {
-		file, err := os.Open(cc.file)
+		images, entries, err = readImageList(cc.file)
 		if err != nil {
-			return nil, fmt.Errorf("failed to open %q: %v", cc.file, err)
-		}
-		sc := bufio.NewScanner(file)
-		sc.Split(bufio.ScanLines)
-		for sc.Scan() {
-			l := strings.TrimSpace(sc.Text())
-			if l == "" || strings.HasPrefix(l, "#") || strings.HasPrefix(l, "//") {
-				continue
-			}
-			images = append(images, l)
-		}
-		if err := file.Close(); err != nil {
-			return nil, fmt.Errorf("failed to close %q: %v", cc.file, err)
+			return nil, err
 		}
 	}
 
@@ -144,40 +197,101 @@ func (cc *loadCmd) prepareHangar() (hangar.Hangar, error) {
 		sysCtx.DockerInsecureSkipTLSVerify = types.NewOptionalBool(!cc.tlsVerify.Value())
 		sysCtx.OCIInsecureSkipTLSVerify = !cc.tlsVerify.Value()
 	}
+	destination := cc.destination
+	providerType, err := cc.providerType()
+	if err != nil {
+		return nil, err
+	}
+	if cc.storageDir != "" {
+		if destination, err = cc.startStorage(&cc.registrySettingsOpts); err != nil {
+			return nil, err
+		}
+		providerType = provider.TypeNone
+	}
+	registrySettings, err := cc.startRegistrySettings()
+	if err != nil {
+		return nil, err
+	}
+	destCtx, err := cc.destAuth.systemContext(sysCtx)
+	if err != nil {
+		return nil, err
+	}
+	destinationType := cc.imageType()
 
-	if !cc.skipLogin {
+	if !cc.skipLogin && cc.storageDir == "" && !cc.containerd {
 		// Only check whether the destination registry needs login.
 		if err := prepareLogin(
 			signalContext,
 			map[string]bool{cc.destination: true},
-			utils.CopySystemContext(sysCtx),
+			utils.CopySystemContext(destCtx),
 		); err != nil {
 			return nil, err
 		}
 	}
 
+	projectSpec, err := cc.projectSpec()
+	if err != nil {
+		return nil, err
+	}
 	policy, err := cc.getPolicy()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get policy: %w", err)
 	}
+	tracker, err := cc.newProgressTracker()
+	if err != nil {
+		return nil, err
+	}
+	reportFormat, err := cc.reportFormatValue()
+	if err != nil {
+		return nil, err
+	}
+	failurePolicy, err := cc.failurePolicy()
+	if err != nil {
+		return nil, err
+	}
+	metricsServer, err := cc.startMetrics()
+	if err != nil {
+		return nil, err
+	}
+	if err := cc.startTracing(); err != nil {
+		return nil, err
+	}
 	l, err := hangar.NewLoader(&hangar.LoaderOpts{
 		CommonOpts: hangar.CommonOpts{
-			Images:              images,
-			Arch:                cc.arch,
-			OS:                  cc.os,
-			Variant:             nil,
-			Timeout:             cc.timeout,
-			Workers:             cc.jobs,
-			FailedImageListName: cc.failed,
-			SystemContext:       sysCtx,
-			Policy:              policy,
+			Images:                   images,
+			Entries:                  entries,
+			Arch:                     cc.arch,
+			OS:                       cc.os,
+			Variant:                  nil,
+			Timeout:                  cc.timeout,
+			Workers:                  cc.jobs,
+			FailedImageListName:      cc.failed,
+			SystemContext:            sysCtx,
+			DestinationSystemContext: destCtx,
+			Policy:                   policy,
+			Progress:                 tracker,
+			ReportName:               cc.report,
+			ReportFormat:             reportFormat,
+			FailurePolicy:            failurePolicy,
+			Metrics:                  metricsServer,
+			Registries:               registrySettings,
+			RegistryNames:            cc.storageRegistryNames(),
 		},
 
 		SourceRegistry:      cc.sourceRegistry,
-		DestinationRegistry: cc.destination,
+		DestinationRegistry: destination,
 		DestinationProject:  cc.project,
+		DestinationType:     destinationType,
 		SharedBlobDirPath:   "", // Use the default shared blob dir path.
 		ArchiveName:         cc.source,
+		ProjectOpts: hangar.ProjectOpts{
+			HarborProject:       projectSpec,
+			HarborRobot:         cc.robotOptions(),
+			HarborRobotFile:     cc.robotOutput,
+			HarborToken:         cc.token,
+			RegistryProvider:    providerType,
+			RegistryProviderURL: cc.providerURL,
+		},
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create loader: %v", err)
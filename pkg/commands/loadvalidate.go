@@ -35,6 +35,11 @@ hangar load validate \
 				logrus.Debugf("debug output enabled")
 				logrus.Debugf("%v", utils.PrintObject(cmdconfig.Get("")))
 			}
+			defer cc.closeStorage()
+			defer cc.closeProgress()
+			defer cc.closeMetrics()
+			defer cc.closeTracing()
+			defer cc.closeRegistrySettings()
 			h, err := cc.prepareHangar()
 			if err != nil {
 				return err
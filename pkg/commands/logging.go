@@ -0,0 +1,51 @@
+package commands
+
+import (
+	"strings"
+
+	"github.com/cnrancher/hangar/pkg/logging"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// logOpts is the global options of the log format and the log file.
+type logOpts struct {
+	logFormat         string
+	logFile           string
+	logFileMaxSize    int
+	logFileMaxBackups int
+}
+
+func (o *logOpts) addFlags(flags *pflag.FlagSet) {
+	flags.StringVarP(&o.logFormat, "log-format", "", string(logging.FormatText),
+		"log output format, available: text, json")
+	flags.StringVarP(&o.logFile, "log-file", "", "",
+		"write the logs into the file additionally (rotated by size)")
+	flags.IntVarP(&o.logFileMaxSize, "log-file-max-size", "", 100,
+		"maximum size in megabytes of the log file before rotated")
+	flags.IntVarP(&o.logFileMaxBackups, "log-file-max-backups", "", 3,
+		"maximum number of the rotated log files to retain (0 to retain all)")
+}
+
+// setupLogging sets the log formatter and the log file hook of the command,
+// the log file is kept open until exit to record the error of the command.
+func (o *logOpts) setupLogging(cmd *cobra.Command) error {
+	format, err := logging.ParseFormat(o.logFormat)
+	if err != nil {
+		return err
+	}
+	command := strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()+" ")
+	if format == logging.FormatJSON {
+		logrus.SetFormatter(logging.NewJSONFormatter(command))
+	}
+	if o.logFile == "" {
+		return nil
+	}
+	logrus.AddHook(logging.NewFileHook(&logging.FileOptions{
+		Name:       o.logFile,
+		MaxSize:    o.logFileMaxSize,
+		MaxBackups: o.logFileMaxBackups,
+	}, logging.NewFormatter(format, command, false)))
+	return nil
+}
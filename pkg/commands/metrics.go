@@ -0,0 +1,46 @@
+package commands
+
+import (
+	"github.com/cnrancher/hangar/pkg/metrics"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
+)
+
+// metricsOpts is the options to expose the Prometheus metrics and the
+// status page during the run.
+type metricsOpts struct {
+	metricsListen string
+
+	metrics *metrics.Metrics
+}
+
+func (o *metricsOpts) addFlags(flags *pflag.FlagSet) {
+	flags.StringVarP(&o.metricsListen, "metrics-listen", "", "",
+		"listen address to serve the Prometheus metrics on '/metrics' "+
+			"and in-flight images on '/status' (example: 127.0.0.1:9090)")
+}
+
+// startMetrics starts the metrics server, returns nil if the metrics
+// listen address not specified.
+func (o *metricsOpts) startMetrics() (*metrics.Metrics, error) {
+	if o.metricsListen == "" {
+		return nil, nil
+	}
+	m := metrics.New()
+	if err := m.Start(o.metricsListen); err != nil {
+		return nil, err
+	}
+	o.metrics = m
+	return m, nil
+}
+
+// closeMetrics stops the metrics server.
+func (o *metricsOpts) closeMetrics() {
+	if o.metrics == nil {
+		return
+	}
+	if err := o.metrics.Close(); err != nil {
+		logrus.Warnf("Failed to stop metrics server: %v", err)
+	}
+	o.metrics = nil
+}
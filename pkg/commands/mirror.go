@@ -1,15 +1,16 @@
 package commands
 
 import (
-	"bufio"
 	"fmt"
-	"os"
+	"regexp"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/cnrancher/hangar/pkg/cmdconfig"
 	"github.com/cnrancher/hangar/pkg/hangar"
 	"github.com/cnrancher/hangar/pkg/hangar/imagelist"
+	"github.com/cnrancher/hangar/pkg/registry"
 	"github.com/cnrancher/hangar/pkg/utils"
 	commonFlag "github.com/containers/common/pkg/flag"
 	"github.com/containers/image/v5/types"
@@ -22,7 +23,7 @@ type mirrorOpts struct {
 	arch        []string
 	os          []string
 	source      string
-	destination string
+	destination []string
 	failed      string
 	jobs        int
 	repoType    string
@@ -32,6 +33,25 @@ type mirrorOpts struct {
 
 	sourceProject      string
 	destinationProject string
+
+	allTags         bool
+	repositories    []string
+	projects        []string
+	tagRegex        string
+	repositoryRegex string
+
+	harborProjectOpts
+	registryProviderOpts
+	storageOpts
+	progressOpts
+	reportOpts
+	failurePolicyOpts
+	metricsOpts
+	tracingOpts
+	registrySettingsOpts
+
+	srcAuth  registryAuthOpts
+	destAuth registryAuthOpts
 }
 
 type mirrorCmd struct {
@@ -46,14 +66,64 @@ func newMirrorCmd() *mirrorCmd {
 	cc.baseCmd = newBaseCmd(&cobra.Command{
 		Use:   "mirror -f IMAGE_LIST.txt -d DESTINATION_REGISTRY",
 		Short: "Mirror images between registry servers",
-		Long:  ``,
+		Long: `Mirror images between registry servers.
+
+The mirror command will create the projects for destination registries automatically,
+use '--registry-provider none' to disable it.
+
+Use '--storage-dir' to write images into the filesystem storage directory of the
+distribution registry (registry:2) as an additional destination, the directory
+can be mounted as '/var/lib/registry' of the registry container.
+
+Use '--registry-settings' to provide the CA, client certificates, insecure,
+mirrors, bandwidth and concurrency limits of each registry, the settings are
+applied to the source and destination registries separately. Use
+'--bandwidth-limit' and '--bandwidth-window' to limit the bandwidth of all
+images copied in the time windows (example: 'Mon-Fri 08:00-18:00').
+
+Use the '--src-*' and '--dest-*' options to provide the credentials, the
+authentication file, the certificate directory and the TLS verification of the
+source and destination registries separately.
+
+The failed image list keeps the order and the format of the image list file,
+use '--file mirror-failed.txt' to retry the failed images. The failed entries
+of the structured image list only keep the destination images and tags failed
+to copy, retry without '--destination' to copy to the failed destinations only.
+If some images failed on some of the destinations but not all, the failed
+image list is written in the structured format for the same reason.
+The error category of each failed image is written into the
+'mirror-failed-reason.txt' file.
+
+Use '--warn-on', '--abort-on' and '--max-errors' to decide how to handle the
+failed images by the error category, available categories: auth, not-found,
+rate-limited, timeout, canceled, manifest-unsupported, digest-mismatch,
+platform-missing, quota, network, unknown.
+`,
 		Example: `# Mirror images from SOURCE REGISTRY to DESTINATION REGISTRY.
 hangar mirror \
 	--file IMAGE_LIST.txt \
 	--source SOURCE_REGISTRY \
 	--destination DESTINATION_REGISTRY \
 	--arch amd64,arm64 \
-	--os linux`,
+	--os linux
+
+# Mirror images to multiple DESTINATION REGISTRIES in one pass.
+hangar mirror \
+	--file IMAGE_LIST.txt \
+	--destination DESTINATION_REGISTRY_1 \
+	--destination DESTINATION_REGISTRY_2
+
+# Mirror images into the storage directory of the registry:2 container.
+hangar mirror \
+	--file IMAGE_LIST.txt \
+	--storage-dir /var/lib/registry
+
+# Mirror all repositories of the project from old Harbor to new Harbor.
+hangar mirror \
+	--source OLD_HARBOR_REGISTRY \
+	--project library \
+	--tag-regex '^v[0-9]+' \
+	--destination NEW_HARBOR_REGISTRY`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			initializeFlagsConfig(cmd, cmdconfig.DefaultProvider)
 			if cc.baseCmd.debug {
@@ -61,6 +131,11 @@ hangar mirror \
 				logrus.Debugf("debug output enabled")
 				logrus.Debugf("%v", utils.PrintObject(cmdconfig.Get("")))
 			}
+			defer cc.closeStorage()
+			defer cc.closeProgress()
+			defer cc.closeMetrics()
+			defer cc.closeTracing()
+			defer cc.closeRegistrySettings()
 			h, err := cc.prepareHangar()
 			if err != nil {
 				return err
@@ -79,7 +154,10 @@ hangar mirror \
 	flags.StringSliceVarP(&cc.arch, "arch", "a", []string{"amd64", "arm64"}, "architecture list of images")
 	flags.StringSliceVarP(&cc.os, "os", "", []string{"linux"}, "OS list of images")
 	flags.StringVarP(&cc.source, "source", "s", "", "override the source registry in image list")
-	flags.StringVarP(&cc.destination, "destination", "d", "", "specify the destination image registry")
+	flags.StringSliceVarP(&cc.destination, "destination", "d", nil,
+		"specify the destination image registry, "+
+			"multiple registries are mirrored in parallel in one pass (fan-out), "+
+			"the source blobs are pulled only once")
 	flags.StringVarP(&cc.failed, "failed", "o", "mirror-failed.txt", "file name of the mirror failed image list")
 	flags.SetAnnotation("failed", cobra.BashCompFilenameExt, []string{"txt"})
 	flags.IntVarP(&cc.jobs, "jobs", "j", 1, "worker number,copy images parallelly (1-20)")
@@ -92,6 +170,27 @@ hangar mirror \
 		"override all source image projects")
 	flags.StringVarP(&cc.destinationProject, "destination-project", "", "",
 		"override all destination image projects")
+	flags.BoolVarP(&cc.allTags, "all-tags", "", false,
+		"mirror all tags of the image repositories in image list (not for the structured image list)")
+	flags.StringSliceVarP(&cc.repositories, "repository", "", nil,
+		"mirror all tags of the repository (example: docker.io/library/nginx)")
+	flags.StringSliceVarP(&cc.projects, "project", "", nil,
+		"mirror all repositories of the project in source registry")
+	flags.StringVarP(&cc.tagRegex, "tag-regex", "", "",
+		"only mirror the tags matching the regex when listing tags")
+	flags.StringVarP(&cc.repositoryRegex, "repository-regex", "", "",
+		"only mirror the repositories matching the regex when listing repositories")
+	cc.harborProjectOpts.addFlags(flags)
+	cc.registryProviderOpts.addFlags(flags)
+	cc.storageOpts.addFlags(flags)
+	cc.progressOpts.addFlags(flags)
+	cc.reportOpts.addFlags(flags)
+	cc.failurePolicyOpts.addFlags(flags)
+	cc.metricsOpts.addFlags(flags)
+	cc.tracingOpts.addFlags(flags)
+	cc.registrySettingsOpts.addFlags(flags)
+	cc.srcAuth.addFlags(flags, "src", "source")
+	cc.destAuth.addFlags(flags, "dest", "destination")
 
 	addCommands(
 		cc.cmd,
@@ -102,9 +201,12 @@ hangar mirror \
 }
 
 func (cc *mirrorCmd) prepareHangar() (hangar.Hangar, error) {
-	if cc.file == "" {
+	if cc.file == "" && len(cc.repositories) == 0 && len(cc.projects) == 0 {
 		return nil, fmt.Errorf("file not provided")
 	}
+	if len(cc.projects) > 0 && cc.source == "" {
+		return nil, fmt.Errorf("source registry not provided, use '--source' to provide the registry of the projects")
+	}
 	// if cc.destination == "" {
 	// 	return fmt.Errorf("destination registry URL not provided")
 	// }
@@ -118,23 +220,20 @@ func (cc *mirrorCmd) prepareHangar() (hangar.Hangar, error) {
 		}
 	}
 
-	file, err := os.Open(cc.file)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open %q: %v", cc.file, err)
-	}
-
-	images := []string{}
-	sc := bufio.NewScanner(file)
-	sc.Split(bufio.ScanLines)
-	for sc.Scan() {
-		l := strings.TrimSpace(sc.Text())
-		if l == "" || strings.HasPrefix(l, "#") || strings.HasPrefix(l, "//") {
-			continue
+	var (
+		images  []string
+		entries []*imagelist.Entry
+		err     error
+	)
+	if cc.file != "" {
+		images, entries, err = readImageList(cc.file)
+		if err != nil {
+			return nil, err
+		}
+		if len(entries) > 0 && (cc.allTags || len(cc.repositories) > 0 || len(cc.projects) > 0) {
+			return nil, fmt.Errorf("structured image list %q cannot be used with "+
+				"'--all-tags', '--repository' or '--project'", cc.file)
 		}
-		images = append(images, l)
-	}
-	if err := file.Close(); err != nil {
-		return nil, fmt.Errorf("failed to close %q: %v", cc.file, err)
 	}
 
 	sysCtx := cc.baseCmd.newSystemContext()
@@ -142,40 +241,116 @@ func (cc *mirrorCmd) prepareHangar() (hangar.Hangar, error) {
 		sysCtx.DockerInsecureSkipTLSVerify = types.NewOptionalBool(!cc.tlsVerify.Value())
 		sysCtx.OCIInsecureSkipTLSVerify = !cc.tlsVerify.Value()
 	}
+	storageRegistry, err := cc.startStorage(&cc.registrySettingsOpts)
+	if err != nil {
+		return nil, err
+	}
+	registrySettings, err := cc.startRegistrySettings()
+	if err != nil {
+		return nil, err
+	}
+	srcCtx, err := cc.srcAuth.systemContext(sysCtx)
+	if err != nil {
+		return nil, err
+	}
+	destCtx, err := cc.destAuth.systemContext(sysCtx)
+	if err != nil {
+		return nil, err
+	}
+	if cc.allTags || len(cc.repositories) > 0 || len(cc.projects) > 0 {
+		images, err = cc.listImages(images, registrySettings.SystemContext(srcCtx, cc.source))
+		if err != nil {
+			return nil, err
+		}
+		if len(images) == 0 {
+			return nil, fmt.Errorf("no images found in repositories")
+		}
+	}
+
+	destinations := cc.destination
+	if storageRegistry != "" {
+		destinations = append(slices.Clone(cc.destination), storageRegistry)
+	}
 
-	if !cc.skipLogin {
+	if !cc.skipLogin && (storageRegistry == "" || len(cc.destination) > 0) {
 		// Only check whether the destination registry URL needs login.
-		registrySet := cc.getRegistrySet(images)
+		registrySet := cc.getRegistrySet(images, entries)
 		if err := prepareLogin(
 			signalContext,
 			registrySet,
-			utils.CopySystemContext(sysCtx),
+			utils.CopySystemContext(destCtx),
 		); err != nil {
 			return nil, err
 		}
 	}
 
+	projectSpec, err := cc.projectSpec()
+	if err != nil {
+		return nil, err
+	}
+	providerType, err := cc.providerType()
+	if err != nil {
+		return nil, err
+	}
 	policy, err := cc.getPolicy()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get policy: %w", err)
 	}
+	tracker, err := cc.newProgressTracker()
+	if err != nil {
+		return nil, err
+	}
+	reportFormat, err := cc.reportFormatValue()
+	if err != nil {
+		return nil, err
+	}
+	failurePolicy, err := cc.failurePolicy()
+	if err != nil {
+		return nil, err
+	}
+	metricsServer, err := cc.startMetrics()
+	if err != nil {
+		return nil, err
+	}
+	if err := cc.startTracing(); err != nil {
+		return nil, err
+	}
 	m, err := hangar.NewMirrorer(&hangar.MirrorerOpts{
 		CommonOpts: hangar.CommonOpts{
-			Images:              images,
-			Arch:                cc.arch,
-			OS:                  cc.os,
-			Variant:             nil, // TODO: support variants
-			Timeout:             cc.timeout,
-			Workers:             cc.jobs,
-			FailedImageListName: cc.failed,
-			SystemContext:       sysCtx,
-			Policy:              policy,
+			Images:                   images,
+			Entries:                  entries,
+			Arch:                     cc.arch,
+			OS:                       cc.os,
+			Variant:                  nil, // TODO: support variants
+			Timeout:                  cc.timeout,
+			Workers:                  cc.jobs,
+			FailedImageListName:      cc.failed,
+			SystemContext:            sysCtx,
+			SourceSystemContext:      srcCtx,
+			DestinationSystemContext: destCtx,
+			Policy:                   policy,
+			Progress:                 tracker,
+			ReportName:               cc.report,
+			ReportFormat:             reportFormat,
+			FailurePolicy:            failurePolicy,
+			Metrics:                  metricsServer,
+			Registries:               registrySettings,
+			RegistryNames:            cc.storageRegistryNames(),
 		},
 
-		SourceRegistry:      cc.source,
-		SourceProject:       cc.sourceProject,
-		DestinationRegistry: cc.destination,
-		DestinationProject:  cc.destinationProject,
+		SourceRegistry:        cc.source,
+		SourceProject:         cc.sourceProject,
+		DestinationRegistries: destinations,
+		DestinationProject:    cc.destinationProject,
+		ProjectOpts: hangar.ProjectOpts{
+			HarborProject:       projectSpec,
+			HarborRobot:         cc.robotOptions(),
+			HarborRobotFile:     cc.robotOutput,
+			HarborToken:         cc.token,
+			RegistryProvider:    providerType,
+			RegistryProviderURL: cc.providerURL,
+			SkipRegistries:      []string{storageRegistry},
+		},
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create mirrorer: %v", err)
@@ -187,11 +362,15 @@ func (cc *mirrorCmd) prepareHangar() (hangar.Hangar, error) {
 }
 
 // getRegistrySet only gets the destination registry set: map[registry-url]true.
-func (cc *mirrorCmd) getRegistrySet(images []string) map[string]bool {
+func (cc *mirrorCmd) getRegistrySet(
+	images []string, entries []*imagelist.Entry,
+) map[string]bool {
 	set := map[string]bool{}
-	if cc.destination != "" {
+	if len(cc.destination) != 0 {
 		// The registry of image list were overrided by command option.
-		set[cc.destination] = true
+		for _, d := range cc.destination {
+			set[d] = true
+		}
 		return set
 	}
 	for _, line := range images {
@@ -208,5 +387,59 @@ func (cc *mirrorCmd) getRegistrySet(images []string) map[string]bool {
 		default:
 		}
 	}
+	for _, e := range entries {
+		if e.Skip {
+			continue
+		}
+		destImages := e.DestinationImages()
+		if len(destImages) == 0 {
+			set[utils.GetRegistryName(e.Source)] = true
+		}
+		for _, d := range destImages {
+			set[utils.GetRegistryName(d)] = true
+		}
+	}
 	return set
 }
+
+// listImages lists the tags of the repositories in image list, the repositories
+// and projects specified by the command option.
+func (cc *mirrorCmd) listImages(
+	images []string, sysCtx *types.SystemContext,
+) ([]string, error) {
+	o := &registry.ListImagesOption{
+		Registry:      cc.source,
+		Repositories:  cc.repositories,
+		Projects:      cc.projects,
+		SystemContext: sysCtx,
+	}
+	if cc.allTags {
+		for _, line := range images {
+			switch imagelist.Detect(line) {
+			case imagelist.TypeDefault:
+				o.Repositories = append(o.Repositories, line)
+			default:
+				logrus.Warnf("Ignore image list line %q: only default format "+
+					"is supported when listing all tags", line)
+			}
+		}
+	} else if len(images) > 0 {
+		logrus.Warnf("Ignore image list %q: '--all-tags' not specified", cc.file)
+	}
+	if cc.tagRegex != "" {
+		re, err := regexp.Compile(cc.tagRegex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tag regex %q: %w", cc.tagRegex, err)
+		}
+		o.TagRegex = re
+	}
+	if cc.repositoryRegex != "" {
+		re, err := regexp.Compile(cc.repositoryRegex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid repository regex %q: %w",
+				cc.repositoryRegex, err)
+		}
+		o.RepositoryRegex = re
+	}
+	return registry.ListImages(signalContext, o)
+}
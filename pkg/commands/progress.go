@@ -0,0 +1,77 @@
+package commands
+
+import (
+	"io"
+	"os"
+	"time"
+
+	"github.com/cnrancher/hangar/pkg/progress"
+	"github.com/moby/term"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/writer"
+	"github.com/spf13/pflag"
+)
+
+// progressOpts is the options to report the copy progress of the images.
+type progressOpts struct {
+	progress         string
+	progressInterval time.Duration
+
+	// hookWriters is the original writers of the log hooks replaced to
+	// render the log messages above the progress bars.
+	hookWriters map[*writer.Hook]io.Writer
+}
+
+func (o *progressOpts) addFlags(flags *pflag.FlagSet) {
+	flags.StringVarP(&o.progress, "progress", "", string(progress.FormatAuto),
+		"progress output format, available: auto, tty, plain, json, none")
+	flags.DurationVarP(&o.progressInterval, "progress-interval", "", 0,
+		"interval of the progress output (default 500ms for tty, 10s for plain/json)")
+}
+
+// newProgressTracker creates the progress tracker, returns nil if the
+// progress output is disabled. The bars are rendered if the output is a
+// terminal when format is auto, otherwise the plain-text lines are printed.
+func (o *progressOpts) newProgressTracker() (*progress.Tracker, error) {
+	format, err := progress.ParseFormat(o.progress)
+	if err != nil {
+		return nil, err
+	}
+	if format == progress.FormatNone {
+		return nil, nil
+	}
+	if format == progress.FormatAuto {
+		format = progress.FormatPlain
+		if term.IsTerminal(os.Stderr.Fd()) {
+			format = progress.FormatTTY
+		}
+	}
+	t := progress.NewTracker(&progress.Options{
+		Format:   format,
+		Output:   os.Stderr,
+		Interval: o.progressInterval,
+	})
+	if format == progress.FormatTTY {
+		// Render the log messages of the hooks above the progress bars.
+		o.hookWriters = make(map[*writer.Hook]io.Writer)
+		for _, hooks := range logrus.StandardLogger().Hooks {
+			for _, h := range hooks {
+				wh, ok := h.(*writer.Hook)
+				if !ok || o.hookWriters[wh] != nil {
+					continue
+				}
+				o.hookWriters[wh] = wh.Writer
+				wh.Writer = t.Writer(wh.Writer)
+			}
+		}
+	}
+	return t, nil
+}
+
+// closeProgress restores the writers of the log hooks.
+func (o *progressOpts) closeProgress() {
+	for h, w := range o.hookWriters {
+		h.Writer = w
+	}
+	o.hookWriters = nil
+}
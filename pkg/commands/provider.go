@@ -0,0 +1,41 @@
+package commands
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/cnrancher/hangar/pkg/provider"
+	"github.com/spf13/pflag"
+)
+
+// registryProviderOpts is the options of the registry provider to create
+// the destination projects automatically.
+type registryProviderOpts struct {
+	provider    string
+	providerURL string
+}
+
+func (o *registryProviderOpts) addFlags(flags *pflag.FlagSet) {
+	var types []string
+	for _, t := range provider.Types() {
+		types = append(types, string(t))
+	}
+	flags.StringVarP(&o.provider, "registry-provider", "", string(provider.TypeAuto),
+		fmt.Sprintf("provider of the destination registry to create projects (%v)",
+			strings.Join(types, ", ")))
+	flags.StringVarP(&o.providerURL, "registry-provider-url", "", "",
+		"API server URL of the registry provider, example: https://gitlab.example.io (default: destination registry)")
+}
+
+// providerType returns the provider type of the destination registry.
+func (o *registryProviderOpts) providerType() (provider.Type, error) {
+	t := provider.Type(strings.ToLower(o.provider))
+	if t == "" {
+		return provider.TypeAuto, nil
+	}
+	if !slices.Contains(provider.Types(), t) {
+		return "", fmt.Errorf("invalid registry provider %q", o.provider)
+	}
+	return t, nil
+}
@@ -0,0 +1,106 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/cnrancher/hangar/pkg/registries"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// registrySettingsOpts is the options of the per-registry TLS certificates,
+// insecure registries, mirrors, bandwidth and concurrency limits.
+type registrySettingsOpts struct {
+	registrySettings string
+	registriesConf   string
+	certsDir         string
+	bandwidthLimit   string
+	bandwidthWindows []string
+	// extraRegistries is the settings of the registries started by the
+	// command, e.g. the embedded registry of the storage directory.
+	extraRegistries map[string]*registries.Registry
+
+	registries *registries.Settings
+}
+
+func (o *registrySettingsOpts) addFlags(flags *pflag.FlagSet) {
+	flags.StringVarP(&o.registrySettings, "registry-settings", "", "",
+		"per-registry settings file of the CA, client certificates, insecure, "+
+			"mirrors, bandwidth and concurrency limits (YAML/JSON)")
+	flags.SetAnnotation("registry-settings", cobra.BashCompFilenameExt, []string{"yaml", "yml", "json"})
+	flags.StringVarP(&o.registriesConf, "registries-conf", "", "",
+		"containers-registries.conf(5) file of the insecure registries and mirrors")
+	flags.StringVarP(&o.certsDir, "certs-dir", "", "",
+		"directory containing the 'host[:port]' sub-directories of the "+
+			"ca.crt, client.cert and client.key files (example: /etc/docker/certs.d)")
+	flags.StringVarP(&o.bandwidthLimit, "bandwidth-limit", "", "",
+		"maximum bandwidth of all images copied (example: 200Mbps, 25MB)")
+	flags.StringSliceVarP(&o.bandwidthWindows, "bandwidth-window", "", nil,
+		"time windows in local time when the bandwidth limit applies, "+
+			"always applies if not set (example: 'Mon-Fri 08:00-18:00')")
+}
+
+// addRegistry adds the settings of the registry started by the command,
+// should be called before startRegistrySettings.
+func (o *registrySettingsOpts) addRegistry(name string, r *registries.Registry) {
+	if o.extraRegistries == nil {
+		o.extraRegistries = make(map[string]*registries.Registry)
+	}
+	o.extraRegistries[name] = r
+}
+
+// startRegistrySettings loads the per-registry settings, returns nil if
+// no settings provided.
+func (o *registrySettingsOpts) startRegistrySettings() (*registries.Settings, error) {
+	if o.registrySettings == "" && o.registriesConf == "" && o.certsDir == "" &&
+		o.bandwidthLimit == "" && len(o.bandwidthWindows) == 0 &&
+		len(o.extraRegistries) == 0 {
+		return nil, nil
+	}
+	c := &registries.Config{}
+	if o.registrySettings != "" {
+		var err error
+		c, err = registries.LoadConfig(o.registrySettings)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if o.registriesConf != "" {
+		c.RegistriesConf = o.registriesConf
+	}
+	if o.certsDir != "" {
+		c.CertsDir = o.certsDir
+	}
+	if o.bandwidthLimit != "" {
+		c.Limits = append(c.Limits, &registries.Limit{
+			Bandwidth: o.bandwidthLimit,
+			Windows:   o.bandwidthWindows,
+		})
+	} else if len(o.bandwidthWindows) > 0 {
+		return nil, fmt.Errorf("bandwidth limit not provided, use '--bandwidth-limit' to provide the limit of the windows")
+	}
+	for name, r := range o.extraRegistries {
+		if c.Registries == nil {
+			c.Registries = make(map[string]*registries.Registry)
+		}
+		c.Registries[name] = r
+	}
+	s, err := registries.New(c)
+	if err != nil {
+		return nil, err
+	}
+	o.registries = s
+	return s, nil
+}
+
+// closeRegistrySettings removes the generated files of the settings.
+func (o *registrySettingsOpts) closeRegistrySettings() {
+	if o.registries == nil {
+		return
+	}
+	if err := o.registries.Close(); err != nil {
+		logrus.Warnf("Failed to clean up registry settings: %v", err)
+	}
+	o.registries = nil
+}
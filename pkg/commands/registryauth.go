@@ -0,0 +1,72 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cnrancher/hangar/pkg/utils"
+	commonFlag "github.com/containers/common/pkg/flag"
+	"github.com/containers/image/v5/types"
+	"github.com/spf13/pflag"
+)
+
+// sensitiveAnnotation is the flag annotation to hide the flag value in the
+// debug output.
+const sensitiveAnnotation = "hangar_sensitive"
+
+// registryAuthOpts is the credentials, certificates and TLS options of the
+// source or destination registries, which override the global options.
+type registryAuthOpts struct {
+	prefix    string
+	creds     string
+	authfile  string
+	certDir   string
+	tlsVerify commonFlag.OptionalBool
+}
+
+// addFlags adds the flags with the prefix ('src' or 'dest'), the target is
+// used in the usage of the flags ('source' or 'destination').
+func (o *registryAuthOpts) addFlags(flags *pflag.FlagSet, prefix, target string) {
+	o.prefix = prefix
+	flags.StringVarP(&o.creds, prefix+"-creds", "", "",
+		fmt.Sprintf("use USERNAME:PASSWORD for accessing the %s registries", target))
+	flags.SetAnnotation(prefix+"-creds", sensitiveAnnotation, []string{"true"})
+	flags.StringVarP(&o.authfile, prefix+"-authfile", "", "",
+		fmt.Sprintf("path of the authentication file of the %s registries", target))
+	flags.StringVarP(&o.certDir, prefix+"-cert-dir", "", "",
+		fmt.Sprintf("use certificates (*.crt, *.cert, *.key) at the directory "+
+			"to connect to the %s registries", target))
+	commonFlag.OptionalBoolFlag(flags, &o.tlsVerify, prefix+"-tls-verify",
+		fmt.Sprintf("require HTTPS and verify certificates of the %s registries "+
+			"(overrides '--tls-verify')", target))
+}
+
+// systemContext returns the copy of the system context with the options
+// applied.
+func (o *registryAuthOpts) systemContext(
+	sysCtx *types.SystemContext,
+) (*types.SystemContext, error) {
+	n := utils.CopySystemContext(sysCtx)
+	if o.creds != "" {
+		username, password, ok := strings.Cut(o.creds, ":")
+		if !ok || username == "" {
+			return nil, fmt.Errorf("invalid '--%s-creds': should be USERNAME:PASSWORD",
+				o.prefix)
+		}
+		n.DockerAuthConfig = &types.DockerAuthConfig{
+			Username: username,
+			Password: password,
+		}
+	}
+	if o.authfile != "" {
+		n.AuthFilePath = o.authfile
+	}
+	if o.certDir != "" {
+		n.DockerCertPath = o.certDir
+	}
+	if o.tlsVerify.Present() {
+		n.DockerInsecureSkipTLSVerify = types.NewOptionalBool(!o.tlsVerify.Value())
+		n.OCIInsecureSkipTLSVerify = !o.tlsVerify.Value()
+	}
+	return n, nil
+}
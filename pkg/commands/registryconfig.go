@@ -0,0 +1,225 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/cnrancher/hangar/pkg/cmdconfig"
+	"github.com/cnrancher/hangar/pkg/hangar/archive"
+	"github.com/cnrancher/hangar/pkg/registryconfig"
+	"github.com/cnrancher/hangar/pkg/utils"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+const (
+	registryConfigFormatRKE2       = "rke2"
+	registryConfigFormatContainerd = "containerd"
+	registryConfigFormatDocker     = "docker"
+)
+
+type registryConfigOpts struct {
+	file        string
+	source      string
+	destination string
+	project     string
+	output      string
+	format      []string
+	insecure    bool
+	plainHTTP   bool
+	caFile      string
+}
+
+type registryConfigCmd struct {
+	*baseCmd
+	*registryConfigOpts
+}
+
+func newRegistryConfigCmd() *registryConfigCmd {
+	cc := &registryConfigCmd{
+		registryConfigOpts: new(registryConfigOpts),
+	}
+
+	cc.baseCmd = newBaseCmd(&cobra.Command{
+		Use:   "registry-config -d PRIVATE_REGISTRY [-f IMAGE_LIST.txt | -s SAVED_ARCHIVE.zip]",
+		Short: "Generate registry mirror configurations for RKE2/K3s, containerd and Docker",
+		Long: `Generate registry mirror configurations to pull images from the private registry.
+
+The upstream registries (docker.io, quay.io, etc.) of the image list or the
+archive index are mirrored to the private registry:
+
+  registries.yaml                 RKE2/K3s registry configuration
+  certs.d/REGISTRY/hosts.toml     containerd registry host namespaces
+  daemon.json                     Docker registry mirrors (Docker Hub only)
+
+The mirror format lines changing the repository path of the image are skipped
+since they can not be mirrored transparently.
+`,
+		Example: `
+# Generate registry configurations of the loaded archive:
+hangar registry-config \
+	--source SAVED_ARCHIVE.zip \
+	--destination PRIVATE_REGISTRY \
+	--output registry-config
+
+# Generate RKE2/K3s registries.yaml with the project overridden:
+hangar registry-config \
+	--file IMAGE_LIST.txt \
+	--destination PRIVATE_REGISTRY \
+	--project mirrored \
+	--format rke2`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			initializeFlagsConfig(cmd, cmdconfig.DefaultProvider)
+			if cc.baseCmd.debug {
+				logrus.SetLevel(logrus.DebugLevel)
+				logrus.Debugf("debug output enabled")
+				logrus.Debugf("%v", utils.PrintObject(cmdconfig.Get("")))
+			}
+			return cc.run()
+		},
+	})
+
+	flags := cc.baseCmd.cmd.Flags()
+	flags.StringVarP(&cc.file, "file", "f", "", "image list file")
+	flags.SetAnnotation("file", cobra.BashCompFilenameExt, []string{"txt"})
+	flags.StringVarP(&cc.source, "source", "s", "", "saved archive filename")
+	flags.SetAnnotation("source", cobra.BashCompFilenameExt, []string{"zip"})
+	flags.StringVarP(&cc.destination, "destination", "d", "", "private registry server")
+	flags.SetAnnotation("destination", cobra.BashCompOneRequiredFlag, []string{""})
+	flags.StringVarP(&cc.project, "project", "", "",
+		"project of all images overridden when loading/mirroring (rewrite is only supported by RKE2/K3s)")
+	flags.StringVarP(&cc.output, "output", "o", "registry-config", "output directory")
+	flags.StringSliceVarP(&cc.format, "format", "", []string{
+		registryConfigFormatRKE2, registryConfigFormatContainerd, registryConfigFormatDocker,
+	}, "configuration formats to generate (rke2, containerd, docker)")
+	flags.BoolVarP(&cc.insecure, "insecure", "", false,
+		"skip verifying the certificate of the private registry")
+	flags.BoolVarP(&cc.plainHTTP, "plain-http", "", false,
+		"use HTTP protocol for the private registry")
+	flags.StringVarP(&cc.caFile, "ca-file", "", "",
+		"CA certificate file path of the private registry on the nodes")
+
+	return cc
+}
+
+func (cc *registryConfigCmd) run() error {
+	if cc.destination == "" {
+		return fmt.Errorf("private registry not provided, use '--destination' to provide the registry")
+	}
+	if cc.file == "" && cc.source == "" {
+		return fmt.Errorf("image list or archive not provided, use '--file' or '--source' to provide the images")
+	}
+	for _, f := range cc.format {
+		switch f {
+		case registryConfigFormatRKE2, registryConfigFormatContainerd, registryConfigFormatDocker:
+		default:
+			return fmt.Errorf("invalid format %q", f)
+		}
+	}
+
+	images, err := cc.images()
+	if err != nil {
+		return err
+	}
+	c, err := registryconfig.New(&registryconfig.Options{
+		Registry:  cc.destination,
+		Project:   cc.project,
+		Images:    images,
+		Insecure:  cc.insecure,
+		PlainHTTP: cc.plainHTTP,
+		CAFile:    cc.caFile,
+	})
+	if err != nil {
+		return err
+	}
+	logrus.Infof("Upstream registries: [%v]", strings.Join(c.Registries(), ","))
+
+	if slices.Contains(cc.format, registryConfigFormatRKE2) {
+		b, err := c.RKE2()
+		if err != nil {
+			return err
+		}
+		if err := cc.writeFile("registries.yaml", b); err != nil {
+			return err
+		}
+	}
+	if slices.Contains(cc.format, registryConfigFormatContainerd) {
+		if cc.project != "" {
+			logrus.Warnf("Repository rewrite is not supported by containerd hosts.toml, " +
+				"the project override is ignored")
+		}
+		hosts, err := c.Containerd()
+		if err != nil {
+			return err
+		}
+		for registry, b := range hosts {
+			name := filepath.Join("certs.d", registry, "hosts.toml")
+			if err := cc.writeFile(name, b); err != nil {
+				return err
+			}
+		}
+	}
+	if slices.Contains(cc.format, registryConfigFormatDocker) {
+		if v := c.DockerUnsupported(); len(v) > 0 {
+			logrus.Warnf("Docker registry mirrors only support Docker Hub, "+
+				"registries not mirrored: [%v]", strings.Join(v, ","))
+		}
+		b, err := c.Docker()
+		if err != nil {
+			return err
+		}
+		if err := cc.writeFile("daemon.json", b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// images returns the images of the image list and the archive index.
+func (cc *registryConfigCmd) images() ([]string, error) {
+	var images []string
+	if cc.file != "" {
+		lines, entries, err := readImageList(cc.file)
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, lines...)
+		for _, e := range entries {
+			images = append(images, e.Source)
+		}
+	}
+	if cc.source != "" {
+		reader, err := archive.NewReader(cc.source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %q: %w", cc.source, err)
+		}
+		b, err := reader.Index()
+		reader.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get index from archive: %w", err)
+		}
+		index := archive.NewIndex()
+		if err = index.Unmarshal(b); err != nil {
+			return nil, fmt.Errorf("failed to get index: %w", err)
+		}
+		for _, image := range index.List {
+			images = append(images, image.Source)
+		}
+	}
+	return images, nil
+}
+
+func (cc *registryConfigCmd) writeFile(name string, b []byte) error {
+	name = filepath.Join(cc.output, name)
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	if err := os.WriteFile(name, b, 0644); err != nil {
+		return fmt.Errorf("failed to write %q: %w", name, err)
+	}
+	logrus.Infof("Generated %q", name)
+	return nil
+}
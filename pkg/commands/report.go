@@ -0,0 +1,26 @@
+package commands
+
+import (
+	"github.com/cnrancher/hangar/pkg/hangar/report"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// reportOpts is the options to export the machine-readable run report.
+type reportOpts struct {
+	report       string
+	reportFormat string
+}
+
+func (o *reportOpts) addFlags(flags *pflag.FlagSet) {
+	flags.StringVarP(&o.report, "report", "", "",
+		"file name of the run report (optional)")
+	flags.SetAnnotation("report", cobra.BashCompFilenameExt, []string{"json", "xml"})
+	flags.StringVarP(&o.reportFormat, "report-format", "", "",
+		"format of the run report, available: json, junit (default: detect by file extension)")
+}
+
+// reportFormatValue returns the format of the run report.
+func (o *reportOpts) reportFormatValue() (report.Format, error) {
+	return report.ParseFormat(o.reportFormat, o.report)
+}
@@ -1,7 +1,6 @@
 package commands
 
 import (
-	"bufio"
 	"fmt"
 	"os"
 	"strings"
@@ -27,6 +26,16 @@ type saveOpts struct {
 	timeout     time.Duration
 	tlsVerify   commonFlag.OptionalBool
 	autoYes     bool
+
+	containerdOpts
+	progressOpts
+	reportOpts
+	failurePolicyOpts
+	metricsOpts
+	tracingOpts
+	registrySettingsOpts
+
+	srcAuth registryAuthOpts
 }
 
 type saveCmd struct {
@@ -41,14 +50,25 @@ func newSaveCmd() *saveCmd {
 	cc.baseCmd = newBaseCmd(&cobra.Command{
 		Use:   "save -f IMAGE_LIST.txt -d SAVED_ARCHIVE.zip",
 		Short: "Save images from registry server into local archive file",
-		Long:  "",
+		Long: `Save images from registry server into local archive file.
+
+Use '--containerd' to save images from the containerd image store of the node
+instead of the registry server.
+`,
 		Example: `
 hangar save \
 	--file IMAGE_LIST.txt \
 	--source SOURCE_REGISTRY \
 	--destination SAVED_ARCHIVE.zip \
 	--arch amd64,arm64 \
-	--os linux`,
+	--os linux
+
+# Save images from the containerd image store of the K3s/RKE2 node.
+hangar save \
+	--file IMAGE_LIST.txt \
+	--destination SAVED_ARCHIVE.zip \
+	--containerd \
+	--containerd-address /run/k3s/containerd/containerd.sock`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			initializeFlagsConfig(cmd, cmdconfig.DefaultProvider)
 			if cc.baseCmd.debug {
@@ -56,6 +76,10 @@ hangar save \
 				logrus.Debugf("debug output enabled")
 				logrus.Debugf("%v", utils.PrintObject(cmdconfig.Get("")))
 			}
+			defer cc.closeProgress()
+			defer cc.closeMetrics()
+			defer cc.closeTracing()
+			defer cc.closeRegistrySettings()
 			h, err := cc.prepareHangar()
 			if err != nil {
 				return err
@@ -104,6 +128,15 @@ hangar save \
 	flags.DurationVarP(&cc.timeout, "timeout", "", time.Minute*10, "timeout when save each images")
 	commonFlag.OptionalBoolFlag(flags, &cc.tlsVerify, "tls-verify", "require HTTPS and verify certificates")
 	flags.BoolVarP(&cc.autoYes, "auto-yes", "y", false, "answer yes automatically (used in shell script)")
+	cc.containerdOpts.addFlags(flags,
+		"save images from the containerd image store instead of registry server")
+	cc.progressOpts.addFlags(flags)
+	cc.reportOpts.addFlags(flags)
+	cc.failurePolicyOpts.addFlags(flags)
+	cc.metricsOpts.addFlags(flags)
+	cc.tracingOpts.addFlags(flags)
+	cc.registrySettingsOpts.addFlags(flags)
+	cc.srcAuth.addFlags(flags, "src", "source")
 
 	addCommands(
 		cc.cmd,
@@ -126,22 +159,9 @@ func (cc *saveCmd) prepareHangar() (hangar.Hangar, error) {
 		}
 	}
 
-	file, err := os.Open(cc.file)
+	images, entries, err := readImageList(cc.file)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open %q: %v", cc.file, err)
-	}
-	images := []string{}
-	sc := bufio.NewScanner(file)
-	sc.Split(bufio.ScanLines)
-	for sc.Scan() {
-		l := strings.TrimSpace(sc.Text())
-		if l == "" || strings.HasPrefix(l, "#") || strings.HasPrefix(l, "//") {
-			continue
-		}
-		images = append(images, l)
-	}
-	if err := file.Close(); err != nil {
-		return nil, fmt.Errorf("failed to close %q: %v", cc.file, err)
+		return nil, err
 	}
 
 	sysCtx := cc.baseCmd.newSystemContext()
@@ -149,14 +169,42 @@ func (cc *saveCmd) prepareHangar() (hangar.Hangar, error) {
 		sysCtx.DockerInsecureSkipTLSVerify = types.NewOptionalBool(!cc.tlsVerify.Value())
 		sysCtx.OCIInsecureSkipTLSVerify = !cc.tlsVerify.Value()
 	}
+	registrySettings, err := cc.startRegistrySettings()
+	if err != nil {
+		return nil, err
+	}
+	srcCtx, err := cc.srcAuth.systemContext(sysCtx)
+	if err != nil {
+		return nil, err
+	}
 
 	policy, err := cc.getPolicy()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get policy: %w", err)
 	}
+	tracker, err := cc.newProgressTracker()
+	if err != nil {
+		return nil, err
+	}
+	reportFormat, err := cc.reportFormatValue()
+	if err != nil {
+		return nil, err
+	}
+	failurePolicy, err := cc.failurePolicy()
+	if err != nil {
+		return nil, err
+	}
+	metricsServer, err := cc.startMetrics()
+	if err != nil {
+		return nil, err
+	}
+	if err := cc.startTracing(); err != nil {
+		return nil, err
+	}
 	s, err := hangar.NewSaver(&hangar.SaverOpts{
 		CommonOpts: hangar.CommonOpts{
 			Images:              images,
+			Entries:             entries,
 			Arch:                cc.arch,
 			OS:                  cc.os,
 			Variant:             nil,
@@ -164,10 +212,18 @@ func (cc *saveCmd) prepareHangar() (hangar.Hangar, error) {
 			Workers:             cc.jobs,
 			FailedImageListName: cc.failed,
 			SystemContext:       sysCtx,
+			SourceSystemContext: srcCtx,
 			Policy:              policy,
+			Progress:            tracker,
+			ReportName:          cc.report,
+			ReportFormat:        reportFormat,
+			FailurePolicy:       failurePolicy,
+			Metrics:             metricsServer,
+			Registries:          registrySettings,
 		},
 
 		SourceRegistry:    cc.source,
+		SourceType:        cc.imageType(),
 		SharedBlobDirPath: "", // Use the default shared blob dir path.
 		ArchiveName:       cc.destination,
 	})
@@ -0,0 +1,91 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cnrancher/hangar/pkg/cmdconfig"
+	"github.com/cnrancher/hangar/pkg/server"
+	"github.com/cnrancher/hangar/pkg/utils"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+type serveOpts struct {
+	listen   string
+	tlsCert  string
+	tlsKey   string
+	username string
+	password string
+}
+
+type serveCmd struct {
+	*baseCmd
+	*serveOpts
+}
+
+func newServeCmd() *serveCmd {
+	cc := &serveCmd{
+		serveOpts: new(serveOpts),
+	}
+
+	cc.baseCmd = newBaseCmd(&cobra.Command{
+		Use:   "serve SAVED_ARCHIVE.zip",
+		Short: "Serve the archive created by 'save' command as a read-only registry",
+		Long: `Serve the archive created by 'save' command as a read-only OCI registry.
+
+The images in archive can be pulled directly without loading to registry server,
+the repository name is 'PROJECT/NAME' or 'REGISTRY/PROJECT/NAME' of the source image.
+`,
+		Example: `
+# Serve the archive on port 5000:
+hangar serve SAVED_ARCHIVE.zip --listen :5000
+
+# Serve the archive with HTTPS and basic authentication:
+hangar serve SAVED_ARCHIVE.zip \
+	--listen :5000 \
+	--tls-cert server.crt \
+	--tls-key server.key \
+	--username admin \
+	--password PASSWORD`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			initializeFlagsConfig(cmd, cmdconfig.DefaultProvider)
+			if cc.baseCmd.debug {
+				logrus.SetLevel(logrus.DebugLevel)
+				logrus.Debugf("debug output enabled")
+				logrus.Debugf("%v", utils.PrintObject(cmdconfig.Get("")))
+			}
+			return cc.run(args[0])
+		},
+	})
+
+	flags := cc.baseCmd.cmd.Flags()
+	flags.StringVarP(&cc.listen, "listen", "l", ":5000", "TCP address to listen")
+	flags.StringVarP(&cc.tlsCert, "tls-cert", "", "", "TLS certificate file to serve HTTPS")
+	flags.StringVarP(&cc.tlsKey, "tls-key", "", "", "TLS private key file to serve HTTPS")
+	flags.StringVarP(&cc.username, "username", "u", "", "username of the basic authentication")
+	flags.StringVarP(&cc.password, "password", "p", "", "password of the basic authentication")
+
+	return cc
+}
+
+func (cc *serveCmd) run(name string) error {
+	if cc.username != "" && cc.password == "" {
+		return fmt.Errorf("password not provided, use '--password' to provide the password")
+	}
+	s, err := server.New(&server.Options{
+		Archive:  name,
+		Listen:   cc.listen,
+		TLSCert:  cc.tlsCert,
+		TLSKey:   cc.tlsKey,
+		Username: cc.username,
+		Password: cc.password,
+	})
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	logrus.Infof("Repositories: [%v]", strings.Join(s.Repositories(), ","))
+	return s.Serve(signalContext)
+}
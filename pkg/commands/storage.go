@@ -0,0 +1,71 @@
+package commands
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/cnrancher/hangar/pkg/distribution"
+	"github.com/cnrancher/hangar/pkg/registries"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
+)
+
+// storageOpts is the options to write the images into the filesystem
+// storage directory of the distribution registry (registry:2).
+type storageOpts struct {
+	storageDir string
+
+	server *distribution.Server
+}
+
+func (o *storageOpts) addFlags(flags *pflag.FlagSet) {
+	flags.StringVarP(&o.storageDir, "storage-dir", "", "",
+		"write images into the distribution registry filesystem storage directory "+
+			"(mounted as '/var/lib/registry' of the 'registry:2' container)")
+}
+
+// startStorage starts the embedded registry of the storage directory and
+// trusts its certificate in the registry settings, returns the registry
+// address or empty string if the storage directory not specified.
+func (o *storageOpts) startStorage(settings *registrySettingsOpts) (string, error) {
+	if o.storageDir == "" {
+		return "", nil
+	}
+	s, err := distribution.NewServer(o.storageDir)
+	if err != nil {
+		return "", err
+	}
+	addr, err := s.Start()
+	if err != nil {
+		return "", fmt.Errorf("failed to start embedded registry: %w", err)
+	}
+	o.server = s
+	// Only the embedded registry trusts its own certificate, the
+	// certificates of other registries are not affected.
+	settings.addRegistry(addr, &registries.Registry{
+		CertDir: filepath.Join(s.CertDir(), addr),
+	})
+	logrus.Infof("Writing images into storage directory %q by embedded registry %q",
+		o.storageDir, addr)
+	return addr, nil
+}
+
+// storageRegistryNames returns the storage directory as the name of the
+// embedded registry in the failed image list and the run report.
+func (o *storageOpts) storageRegistryNames() map[string]string {
+	if o.server == nil {
+		return nil
+	}
+	return map[string]string{o.server.Addr(): o.storageDir}
+}
+
+// closeStorage stops the embedded registry of the storage directory.
+func (o *storageOpts) closeStorage() {
+	if o.server == nil {
+		return
+	}
+	if err := o.server.Close(); err != nil {
+		logrus.Warnf("Failed to stop embedded registry: %v", err)
+	}
+	o.server = nil
+}
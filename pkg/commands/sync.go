@@ -1,7 +1,6 @@
 package commands
 
 import (
-	"bufio"
 	"fmt"
 	"os"
 	"strings"
@@ -26,6 +25,15 @@ type syncOpts struct {
 	jobs        int
 	timeout     time.Duration
 	tlsVerify   commonFlag.OptionalBool
+
+	progressOpts
+	reportOpts
+	failurePolicyOpts
+	metricsOpts
+	tracingOpts
+	registrySettingsOpts
+
+	srcAuth registryAuthOpts
 }
 
 type syncCmd struct {
@@ -56,6 +64,10 @@ hangar sync \
 				logrus.Debugf("%v", utils.PrintObject(cmdconfig.Get("")))
 			}
 
+			defer cc.closeProgress()
+			defer cc.closeMetrics()
+			defer cc.closeTracing()
+			defer cc.closeRegistrySettings()
 			h, err := cc.prepareHangar()
 			if err != nil {
 				return err
@@ -81,6 +93,13 @@ hangar sync \
 	flags.IntVarP(&cc.jobs, "jobs", "j", 1, "worker number,copy images parallelly (1-20)")
 	flags.DurationVarP(&cc.timeout, "timeout", "", time.Minute*10, "timeout when save each images")
 	commonFlag.OptionalBoolFlag(flags, &cc.tlsVerify, "tls-verify", "require HTTPS and verify certificates")
+	cc.progressOpts.addFlags(flags)
+	cc.reportOpts.addFlags(flags)
+	cc.failurePolicyOpts.addFlags(flags)
+	cc.metricsOpts.addFlags(flags)
+	cc.tracingOpts.addFlags(flags)
+	cc.registrySettingsOpts.addFlags(flags)
+	cc.srcAuth.addFlags(flags, "src", "source")
 
 	addCommands(
 		cc.cmd,
@@ -107,22 +126,9 @@ func (cc *syncCmd) prepareHangar() (hangar.Hangar, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to stat %v: %w", cc.destination, err)
 	}
-	file, err := os.Open(cc.file)
+	images, entries, err := readImageList(cc.file)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open %q: %v", cc.file, err)
-	}
-	images := []string{}
-	sc := bufio.NewScanner(file)
-	sc.Split(bufio.ScanLines)
-	for sc.Scan() {
-		l := strings.TrimSpace(sc.Text())
-		if l == "" || strings.HasPrefix(l, "#") || strings.HasPrefix(l, "//") {
-			continue
-		}
-		images = append(images, l)
-	}
-	if err := file.Close(); err != nil {
-		return nil, fmt.Errorf("failed to close %q: %v", cc.file, err)
+		return nil, err
 	}
 
 	sysCtx := cc.baseCmd.newSystemContext()
@@ -130,14 +136,42 @@ func (cc *syncCmd) prepareHangar() (hangar.Hangar, error) {
 		sysCtx.DockerInsecureSkipTLSVerify = types.NewOptionalBool(!cc.tlsVerify.Value())
 		sysCtx.OCIInsecureSkipTLSVerify = !cc.tlsVerify.Value()
 	}
+	registrySettings, err := cc.startRegistrySettings()
+	if err != nil {
+		return nil, err
+	}
+	srcCtx, err := cc.srcAuth.systemContext(sysCtx)
+	if err != nil {
+		return nil, err
+	}
 
 	policy, err := cc.getPolicy()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get policy: %w", err)
 	}
+	tracker, err := cc.newProgressTracker()
+	if err != nil {
+		return nil, err
+	}
+	reportFormat, err := cc.reportFormatValue()
+	if err != nil {
+		return nil, err
+	}
+	failurePolicy, err := cc.failurePolicy()
+	if err != nil {
+		return nil, err
+	}
+	metricsServer, err := cc.startMetrics()
+	if err != nil {
+		return nil, err
+	}
+	if err := cc.startTracing(); err != nil {
+		return nil, err
+	}
 	s, err := hangar.NewSyncer(&hangar.SyncerOpts{
 		CommonOpts: hangar.CommonOpts{
 			Images:              images,
+			Entries:             entries,
 			Arch:                cc.arch,
 			OS:                  cc.os,
 			Variant:             nil,
@@ -145,7 +179,14 @@ func (cc *syncCmd) prepareHangar() (hangar.Hangar, error) {
 			Workers:             cc.jobs,
 			FailedImageListName: cc.failed,
 			SystemContext:       sysCtx,
+			SourceSystemContext: srcCtx,
 			Policy:              policy,
+			Progress:            tracker,
+			ReportName:          cc.report,
+			ReportFormat:        reportFormat,
+			FailurePolicy:       failurePolicy,
+			Metrics:             metricsServer,
+			Registries:          registrySettings,
 		},
 
 		SourceRegistry:    cc.source,
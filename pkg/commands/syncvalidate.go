@@ -36,6 +36,10 @@ hangar sync validate \
 				logrus.Debugf("%v", utils.PrintObject(cmdconfig.Get("")))
 			}
 
+			defer cc.closeProgress()
+			defer cc.closeMetrics()
+			defer cc.closeTracing()
+			defer cc.closeRegistrySettings()
 			h, err := cc.prepareHangar()
 			if err != nil {
 				return err
@@ -0,0 +1,55 @@
+package commands
+
+import (
+	"context"
+	"time"
+
+	"github.com/cnrancher/hangar/pkg/tracing"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
+)
+
+// tracingOpts is the options to export the OpenTelemetry spans of the
+// images copy pipeline.
+type tracingOpts struct {
+	traceEndpoint string
+	traceFile     string
+
+	shutdown func(context.Context) error
+}
+
+func (o *tracingOpts) addFlags(flags *pflag.FlagSet) {
+	flags.StringVarP(&o.traceEndpoint, "trace-endpoint", "", "",
+		"OTLP/HTTP collector endpoint to export the OpenTelemetry spans "+
+			"(example: http://127.0.0.1:4318)")
+	flags.StringVarP(&o.traceFile, "trace-file", "", "",
+		"file name to write the OpenTelemetry spans in JSON format")
+}
+
+// startTracing initializes the OpenTelemetry tracer provider if the
+// endpoint or the file is specified.
+func (o *tracingOpts) startTracing() error {
+	shutdown, err := tracing.Init(signalContext, &tracing.Options{
+		Endpoint: o.traceEndpoint,
+		File:     o.traceFile,
+	})
+	if err != nil {
+		return err
+	}
+	o.shutdown = shutdown
+	return nil
+}
+
+// closeTracing flushes the spans and stops the tracer provider.
+func (o *tracingOpts) closeTracing() {
+	if o.shutdown == nil {
+		return
+	}
+	// The signal context may be canceled, use a new context to flush spans.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	if err := o.shutdown(ctx); err != nil {
+		logrus.Warnf("Failed to export trace spans: %v", err)
+	}
+	o.shutdown = nil
+}
@@ -0,0 +1,244 @@
+package containerd
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/containerd/containerd/content"
+	"github.com/containerd/containerd/content/local"
+	"github.com/containerd/containerd/errdefs"
+	"github.com/containerd/containerd/images"
+	"github.com/containerd/containerd/leases"
+	"github.com/containers/image/v5/copy"
+	"github.com/containers/image/v5/oci/layout"
+	"github.com/containers/image/v5/signature"
+	"github.com/containers/image/v5/transports/alltransports"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeStore is the local content store with in-memory image and label
+// store used for testing without the containerd daemon.
+type fakeStore struct {
+	cs     content.Store
+	images *fakeImages
+	labels *fakeLabels
+}
+
+func newFakeStore(t *testing.T) *fakeStore {
+	s := &fakeStore{
+		images: &fakeImages{images: map[string]images.Image{}},
+		labels: &fakeLabels{labels: map[digest.Digest]map[string]string{}},
+	}
+	cs, err := local.NewLabeledStore(t.TempDir(), s.labels)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.cs = cs
+	return s
+}
+
+func (s *fakeStore) ContentStore() content.Store   { return s.cs }
+func (s *fakeStore) ImageService() images.Store    { return s.images }
+func (s *fakeStore) LeasesService() leases.Manager { return fakeLeases{} }
+func (s *fakeStore) Close() error                  { return nil }
+
+type fakeImages struct {
+	images.Store
+	mu     sync.Mutex
+	images map[string]images.Image
+}
+
+func (s *fakeImages) Get(ctx context.Context, name string) (images.Image, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	img, ok := s.images[name]
+	if !ok {
+		return images.Image{}, errdefs.ErrNotFound
+	}
+	return img, nil
+}
+
+func (s *fakeImages) Create(ctx context.Context, img images.Image) (images.Image, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.images[img.Name]; ok {
+		return images.Image{}, errdefs.ErrAlreadyExists
+	}
+	s.images[img.Name] = img
+	return img, nil
+}
+
+func (s *fakeImages) Update(
+	ctx context.Context, img images.Image, fieldpaths ...string,
+) (images.Image, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.images[img.Name]; !ok {
+		return images.Image{}, errdefs.ErrNotFound
+	}
+	s.images[img.Name] = img
+	return img, nil
+}
+
+type fakeLabels struct {
+	mu     sync.Mutex
+	labels map[digest.Digest]map[string]string
+}
+
+func (s *fakeLabels) Get(d digest.Digest) (map[string]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.labels[d], nil
+}
+
+func (s *fakeLabels) Set(d digest.Digest, labels map[string]string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.labels[d] = labels
+	return nil
+}
+
+func (s *fakeLabels) Update(d digest.Digest, labels map[string]string) (map[string]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.labels[d] == nil {
+		s.labels[d] = map[string]string{}
+	}
+	for k, v := range labels {
+		s.labels[d][k] = v
+	}
+	return s.labels[d], nil
+}
+
+type fakeLeases struct {
+	leases.Manager
+}
+
+func (fakeLeases) Create(ctx context.Context, opts ...leases.Opt) (leases.Lease, error) {
+	return leases.Lease{ID: "test"}, nil
+}
+
+// writeOCILayout writes a single layer OCI image layout with tag "v1".
+func writeOCILayout(t *testing.T, dir string) digest.Digest {
+	t.Helper()
+	blobs := filepath.Join(dir, "blobs", "sha256")
+	if err := os.MkdirAll(blobs, 0755); err != nil {
+		t.Fatal(err)
+	}
+	write := func(b []byte) digest.Digest {
+		d := digest.FromBytes(b)
+		if err := os.WriteFile(filepath.Join(blobs, d.Encoded()), b, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	buf := &bytes.Buffer{}
+	gw := gzip.NewWriter(buf)
+	gw.Write([]byte("layer"))
+	gw.Close()
+	layer := buf.Bytes()
+	layerDigest := write(layer)
+	config := []byte(fmt.Sprintf(`{"architecture":"amd64","os":"linux",`+
+		`"rootfs":{"type":"layers","diff_ids":["%s"]}}`, digest.FromString("layer")))
+	configDigest := write(config)
+	manifest := []byte(fmt.Sprintf(`{"schemaVersion":2,`+
+		`"mediaType":"application/vnd.oci.image.manifest.v1+json",`+
+		`"config":{"mediaType":"application/vnd.oci.image.config.v1+json","digest":"%s","size":%d},`+
+		`"layers":[{"mediaType":"application/vnd.oci.image.layer.v1.tar+gzip","digest":"%s","size":%d}]}`,
+		configDigest, len(config), layerDigest, len(layer)))
+	manifestDigest := write(manifest)
+	index, _ := json.Marshal(map[string]any{
+		"schemaVersion": 2,
+		"manifests": []any{map[string]any{
+			"mediaType":   "application/vnd.oci.image.manifest.v1+json",
+			"digest":      manifestDigest,
+			"size":        len(manifest),
+			"annotations": map[string]string{"org.opencontainers.image.ref.name": "v1"},
+		}},
+	})
+	os.WriteFile(filepath.Join(dir, "index.json"), index, 0644)
+	os.WriteFile(filepath.Join(dir, "oci-layout"), []byte(`{"imageLayoutVersion":"1.0.0"}`), 0644)
+	return manifestDigest
+}
+
+func Test_ParseReference(t *testing.T) {
+	ref, err := alltransports.ParseImageName("containerd:nginx")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "docker.io/library/nginx:latest", ref.StringWithinTransport())
+	}
+	ref, err = alltransports.ParseImageName("containerd:quay.io/example/nginx:1.25")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "quay.io/example/nginx:1.25", ref.StringWithinTransport())
+		assert.Equal(t, []string{"quay.io/example/nginx", "quay.io/example", "quay.io", "*.io"},
+			ref.PolicyConfigurationNamespaces())
+	}
+	_, err = ParseReference("nginx:1.25@sha256:" + digest.FromString("").Encoded())
+	assert.Error(t, err)
+	_, err = ParseReference("INVALID")
+	assert.Error(t, err)
+}
+
+func Test_Copy(t *testing.T) {
+	fake := newFakeStore(t)
+	openStore = func(ctx context.Context, address string) (store, error) {
+		return fake, nil
+	}
+	defer func() {
+		openStore = func(ctx context.Context, address string) (store, error) {
+			return nil, fmt.Errorf("not available")
+		}
+	}()
+	ctx := context.Background()
+	policy, _ := signature.NewPolicyContext(&signature.Policy{
+		Default: []signature.PolicyRequirement{signature.NewPRInsecureAcceptAnything()},
+	})
+	defer policy.Destroy()
+
+	dir := t.TempDir()
+	manifestDigest := writeOCILayout(t, dir)
+	src, err := layout.ParseReference(dir + ":v1")
+	if !assert.NoError(t, err) {
+		return
+	}
+	dest, err := ParseReference("docker.io/library/hello:v1")
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, err = copy.Image(ctx, policy, dest, src, &copy.Options{})
+	if !assert.NoError(t, err) {
+		return
+	}
+	img, err := fake.images.Get(ctx, "docker.io/library/hello:v1")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, manifestDigest, img.Target.Digest)
+	labels := fake.labels.labels[manifestDigest]
+	assert.NotEmpty(t, labels["containerd.io/gc.ref.content.config"])
+	assert.NotEmpty(t, labels["containerd.io/gc.ref.content.l.0"])
+
+	// Copy the image from containerd by tag and by digest.
+	out := t.TempDir()
+	src, _ = ParseReference("docker.io/library/hello:v1")
+	dest, _ = layout.ParseReference(out + ":v1")
+	_, err = copy.Image(ctx, policy, dest, src, &copy.Options{})
+	assert.NoError(t, err)
+	src, _ = ParseReference("docker.io/library/hello@" + manifestDigest.String())
+	dest, _ = layout.ParseReference(out + ":v2")
+	_, err = copy.Image(ctx, policy, dest, src, &copy.Options{})
+	assert.NoError(t, err)
+	_, err = os.Stat(filepath.Join(out, "blobs", "sha256", manifestDigest.Encoded()))
+	assert.NoError(t, err)
+
+	src, _ = ParseReference("docker.io/library/notfound:v1")
+	_, err = copy.Image(ctx, policy, dest, src, &copy.Options{})
+	assert.ErrorIs(t, err, errdefs.ErrNotFound)
+}
@@ -0,0 +1,222 @@
+package containerd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/containerd/containerd/content"
+	"github.com/containerd/containerd/errdefs"
+	"github.com/containerd/containerd/images"
+	"github.com/containerd/containerd/leases"
+	imagemanifest "github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/types"
+	"github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// leaseExpiration protects the written blobs from the containerd garbage
+// collection before the image record created.
+const leaseExpiration = time.Hour
+
+// imageDestination writes the image into the containerd content store and
+// creates the image record when the manifest is written.
+type imageDestination struct {
+	ref   containerdReference
+	store store
+	ctx   context.Context
+}
+
+func newImageDestination(
+	ctx context.Context, ref containerdReference,
+) (*imageDestination, error) {
+	s, nsCtx, err := newStore(ctx)
+	if err != nil {
+		return nil, err
+	}
+	lease, err := s.LeasesService().Create(nsCtx,
+		leases.WithRandomID(), leases.WithExpiration(leaseExpiration))
+	if err != nil {
+		s.Close()
+		return nil, fmt.Errorf("failed to create lease: %w", err)
+	}
+	return &imageDestination{
+		ref:   ref,
+		store: s,
+		ctx:   leases.WithLease(nsCtx, lease.ID),
+	}, nil
+}
+
+func (d *imageDestination) Reference() types.ImageReference {
+	return d.ref
+}
+
+// Close closes the connection, the lease will be expired automatically.
+func (d *imageDestination) Close() error {
+	return d.store.Close()
+}
+
+func (d *imageDestination) SupportedManifestMIMETypes() []string {
+	return nil
+}
+
+func (d *imageDestination) SupportsSignatures(ctx context.Context) error {
+	return fmt.Errorf("signatures are not supported by containerd transport")
+}
+
+func (d *imageDestination) DesiredLayerCompression() types.LayerCompression {
+	return types.PreserveOriginal
+}
+
+func (d *imageDestination) AcceptsForeignLayerURLs() bool {
+	return false
+}
+
+func (d *imageDestination) MustMatchRuntimeOS() bool {
+	return false
+}
+
+func (d *imageDestination) IgnoresEmbeddedDockerReference() bool {
+	return false
+}
+
+func (d *imageDestination) HasThreadSafePutBlob() bool {
+	return true
+}
+
+// PutBlob writes the blob stream into the content store.
+func (d *imageDestination) PutBlob(
+	ctx context.Context,
+	stream io.Reader,
+	inputInfo types.BlobInfo,
+	cache types.BlobInfoCache,
+	isConfig bool,
+) (types.BlobInfo, error) {
+	ctx = withNamespace(ctx, d.ctx)
+	ref := "hangar-" + inputInfo.Digest.String()
+	if inputInfo.Digest == "" {
+		ref = "hangar-" + uniqueID()
+	}
+	w, err := content.OpenWriter(ctx, d.store.ContentStore(), content.WithRef(ref),
+		content.WithDescriptor(imgspecv1.Descriptor{
+			Digest: inputInfo.Digest,
+			Size:   inputInfo.Size,
+		}))
+	if err != nil {
+		if errdefs.IsAlreadyExists(err) {
+			return inputInfo, nil
+		}
+		return types.BlobInfo{}, fmt.Errorf("failed to open content writer: %w", err)
+	}
+	defer w.Close()
+	// Discard the partial content written by the previous failed attempt.
+	if err := w.Truncate(0); err != nil {
+		return types.BlobInfo{}, fmt.Errorf("failed to truncate content writer: %w", err)
+	}
+	size, err := io.Copy(w, stream)
+	if err != nil {
+		return types.BlobInfo{}, fmt.Errorf("failed to write blob: %w", err)
+	}
+	dig := w.Digest()
+	if err := w.Commit(ctx, size, inputInfo.Digest); err != nil {
+		if !errdefs.IsAlreadyExists(err) {
+			return types.BlobInfo{}, fmt.Errorf("failed to commit blob: %w", err)
+		}
+	}
+	return types.BlobInfo{
+		Digest: dig,
+		Size:   size,
+	}, nil
+}
+
+// TryReusingBlob checks whether the blob already exists in content store.
+func (d *imageDestination) TryReusingBlob(
+	ctx context.Context,
+	info types.BlobInfo,
+	cache types.BlobInfoCache,
+	canSubstitute bool,
+) (bool, types.BlobInfo, error) {
+	if info.Digest == "" {
+		return false, types.BlobInfo{}, nil
+	}
+	ctx = withNamespace(ctx, d.ctx)
+	i, err := d.store.ContentStore().Info(ctx, info.Digest)
+	if err != nil {
+		if errdefs.IsNotFound(err) {
+			return false, types.BlobInfo{}, nil
+		}
+		return false, types.BlobInfo{}, err
+	}
+	return true, types.BlobInfo{
+		Digest: i.Digest,
+		Size:   i.Size,
+	}, nil
+}
+
+// PutManifest writes the manifest into the content store, the image record
+// will be created or updated if the instanceDigest is nil.
+func (d *imageDestination) PutManifest(
+	ctx context.Context, m []byte, instanceDigest *digest.Digest,
+) error {
+	ctx = withNamespace(ctx, d.ctx)
+	dig, err := imagemanifest.Digest(m)
+	if err != nil {
+		return fmt.Errorf("failed to compute manifest digest: %w", err)
+	}
+	if instanceDigest == nil {
+		if c, ok := d.ref.digested(); ok && c.Digest() != dig {
+			return fmt.Errorf("manifest digest %q mismatch with reference %q",
+				dig, d.ref.StringWithinTransport())
+		}
+	}
+	desc := imgspecv1.Descriptor{
+		MediaType: imagemanifest.GuessMIMEType(m),
+		Digest:    dig,
+		Size:      int64(len(m)),
+	}
+	cs := d.store.ContentStore()
+	err = content.WriteBlob(ctx, cs, "hangar-"+dig.String(), bytes.NewReader(m), desc)
+	if err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	// Set the GC labels of the children (layers, config and manifests).
+	if _, err := images.SetChildrenLabels(cs, images.ChildrenHandler(cs))(ctx, desc); err != nil {
+		return fmt.Errorf("failed to set labels of manifest %q: %w", dig, err)
+	}
+	if instanceDigest != nil {
+		return nil
+	}
+
+	is := d.store.ImageService()
+	img := images.Image{
+		Name:   d.ref.imageName(),
+		Target: desc,
+	}
+	if _, err := is.Update(ctx, img, "target"); err != nil {
+		if !errdefs.IsNotFound(err) {
+			return fmt.Errorf("failed to update image %q: %w", img.Name, err)
+		}
+		if _, err := is.Create(ctx, img); err != nil {
+			return fmt.Errorf("failed to create image %q: %w", img.Name, err)
+		}
+	}
+	return nil
+}
+
+func (d *imageDestination) PutSignatures(
+	ctx context.Context, signatures [][]byte, instanceDigest *digest.Digest,
+) error {
+	if len(signatures) != 0 {
+		return fmt.Errorf("signatures are not supported by containerd transport")
+	}
+	return nil
+}
+
+// Commit does nothing since the image record is created in PutManifest.
+func (d *imageDestination) Commit(
+	ctx context.Context, unparsedToplevel types.UnparsedImage,
+) error {
+	return nil
+}
@@ -0,0 +1,119 @@
+package containerd
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/containerd/containerd/content"
+	"github.com/containerd/containerd/errdefs"
+	imagemanifest "github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/types"
+	"github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// imageSource reads the image from the containerd content store.
+type imageSource struct {
+	ref   containerdReference
+	store store
+	ctx   context.Context
+}
+
+func newImageSource(ctx context.Context, ref containerdReference) (*imageSource, error) {
+	s, nsCtx, err := newStore(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &imageSource{
+		ref:   ref,
+		store: s,
+		ctx:   nsCtx,
+	}, nil
+}
+
+func (s *imageSource) Reference() types.ImageReference {
+	return s.ref
+}
+
+func (s *imageSource) Close() error {
+	return s.store.Close()
+}
+
+// GetManifest returns the manifest of the image, the manifest of the
+// instance will be returned if the instanceDigest is not nil.
+func (s *imageSource) GetManifest(
+	ctx context.Context, instanceDigest *digest.Digest,
+) ([]byte, string, error) {
+	ctx = withNamespace(ctx, s.ctx)
+	var desc imgspecv1.Descriptor
+	switch c, ok := s.ref.digested(); {
+	case instanceDigest != nil:
+		desc.Digest = *instanceDigest
+	case ok:
+		desc.Digest = c.Digest()
+	default:
+		img, err := s.store.ImageService().Get(ctx, s.ref.imageName())
+		if err != nil {
+			if errdefs.IsNotFound(err) {
+				return nil, "", fmt.Errorf("image %q not found in containerd: %w",
+					s.ref.imageName(), err)
+			}
+			return nil, "", fmt.Errorf("failed to get image %q: %w",
+				s.ref.imageName(), err)
+		}
+		desc = img.Target
+	}
+	b, err := content.ReadBlob(ctx, s.store.ContentStore(), desc)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read manifest %q: %w", desc.Digest, err)
+	}
+	mime := desc.MediaType
+	if mime == "" {
+		mime = imagemanifest.GuessMIMEType(b)
+	}
+	return b, mime, nil
+}
+
+// GetBlob returns the blob stream and size of the blob.
+func (s *imageSource) GetBlob(
+	ctx context.Context, info types.BlobInfo, cache types.BlobInfoCache,
+) (io.ReadCloser, int64, error) {
+	ctx = withNamespace(ctx, s.ctx)
+	ra, err := s.store.ContentStore().ReaderAt(ctx, imgspecv1.Descriptor{
+		Digest: info.Digest,
+		Size:   info.Size,
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read blob %q: %w", info.Digest, err)
+	}
+	return &blobReader{
+		Reader: io.NewSectionReader(ra, 0, ra.Size()),
+		closer: ra,
+	}, ra.Size(), nil
+}
+
+func (s *imageSource) HasThreadSafeGetBlob() bool {
+	return true
+}
+
+func (s *imageSource) GetSignatures(
+	ctx context.Context, instanceDigest *digest.Digest,
+) ([][]byte, error) {
+	return nil, nil
+}
+
+func (s *imageSource) LayerInfosForCopy(
+	ctx context.Context, instanceDigest *digest.Digest,
+) ([]types.BlobInfo, error) {
+	return nil, nil
+}
+
+type blobReader struct {
+	io.Reader
+	closer io.Closer
+}
+
+func (r *blobReader) Close() error {
+	return r.closer.Close()
+}
@@ -0,0 +1,215 @@
+// Package containerd implements the 'containerd:' image transport, which
+// reads and writes the images in the content store of the containerd daemon,
+// the images are managed in the namespace (default 'k8s.io') used by the
+// Kubernetes CRI plugin.
+//
+// Reference format: containerd:docker.io/library/nginx:1.25
+package containerd
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/containerd/containerd"
+	"github.com/containerd/containerd/content"
+	"github.com/containerd/containerd/images"
+	"github.com/containerd/containerd/leases"
+	"github.com/containerd/containerd/namespaces"
+	"github.com/containers/image/v5/docker/policyconfiguration"
+	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/image"
+	"github.com/containers/image/v5/transports"
+	"github.com/containers/image/v5/types"
+)
+
+const (
+	// DefaultAddress is the default socket address of containerd.
+	DefaultAddress = "/run/containerd/containerd.sock"
+	// DefaultNamespace is the containerd namespace used by Kubernetes.
+	DefaultNamespace = "k8s.io"
+)
+
+// Transport is the ImageTransport of the containerd image store.
+var Transport = containerdTransport{}
+
+func init() {
+	transports.Register(Transport)
+}
+
+// store is the content, image and lease store of containerd,
+// implemented by the containerd client.
+type store interface {
+	ContentStore() content.Store
+	ImageService() images.Store
+	LeasesService() leases.Manager
+	Close() error
+}
+
+var (
+	configMutex = &sync.RWMutex{}
+	address     = DefaultAddress
+	namespace   = DefaultNamespace
+
+	// openStore connects to the containerd daemon, replaced in unit tests.
+	openStore = func(ctx context.Context, address string) (store, error) {
+		return containerd.New(address)
+	}
+)
+
+// Configure sets the containerd socket address and namespace of the
+// transport, the default values are used if empty.
+func Configure(addr, ns string) {
+	configMutex.Lock()
+	defer configMutex.Unlock()
+	address, namespace = DefaultAddress, DefaultNamespace
+	if addr != "" {
+		address = addr
+	}
+	if ns != "" {
+		namespace = ns
+	}
+}
+
+// newStore connects to containerd and returns the store and the context
+// with the configured namespace.
+func newStore(ctx context.Context) (store, context.Context, error) {
+	configMutex.RLock()
+	addr, ns := address, namespace
+	configMutex.RUnlock()
+	s, err := openStore(ctx, addr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect containerd %q: %w", addr, err)
+	}
+	return s, namespaces.WithNamespace(ctx, ns), nil
+}
+
+type containerdTransport struct{}
+
+func (t containerdTransport) Name() string {
+	return "containerd"
+}
+
+func (t containerdTransport) ParseReference(s string) (types.ImageReference, error) {
+	return ParseReference(s)
+}
+
+func (t containerdTransport) ValidatePolicyConfigurationScope(scope string) error {
+	if scope == "" || strings.Contains(scope, "@") {
+		return fmt.Errorf("invalid policy configuration scope %q", scope)
+	}
+	return nil
+}
+
+// containerdReference is the image reference of the containerd transport.
+type containerdReference struct {
+	// ref is the normalized docker reference with tag or digest.
+	ref reference.Named
+}
+
+// ParseReference parses the reference of the containerd transport,
+// example: docker.io/library/nginx:1.25, nginx@sha256:abcdef...
+func ParseReference(s string) (types.ImageReference, error) {
+	ref, err := reference.ParseNormalizedNamed(strings.TrimPrefix(s, "//"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid containerd reference %q: %w", s, err)
+	}
+	ref = reference.TagNameOnly(ref)
+	_, tagged := ref.(reference.NamedTagged)
+	_, digested := ref.(reference.Canonical)
+	if tagged && digested {
+		return nil, fmt.Errorf("containerd reference %q can not have both tag and digest", s)
+	}
+	return containerdReference{ref: ref}, nil
+}
+
+func (r containerdReference) Transport() types.ImageTransport {
+	return Transport
+}
+
+func (r containerdReference) StringWithinTransport() string {
+	return r.ref.String()
+}
+
+func (r containerdReference) DockerReference() reference.Named {
+	return r.ref
+}
+
+func (r containerdReference) PolicyConfigurationIdentity() string {
+	s, err := policyconfiguration.DockerReferenceIdentity(r.ref)
+	if err != nil {
+		return ""
+	}
+	return s
+}
+
+func (r containerdReference) PolicyConfigurationNamespaces() []string {
+	return policyconfiguration.DockerReferenceNamespaces(r.ref)
+}
+
+func (r containerdReference) NewImage(
+	ctx context.Context, sys *types.SystemContext,
+) (types.ImageCloser, error) {
+	src, err := r.NewImageSource(ctx, sys)
+	if err != nil {
+		return nil, err
+	}
+	return image.FromSource(ctx, sys, src)
+}
+
+func (r containerdReference) NewImageSource(
+	ctx context.Context, sys *types.SystemContext,
+) (types.ImageSource, error) {
+	return newImageSource(ctx, r)
+}
+
+func (r containerdReference) NewImageDestination(
+	ctx context.Context, sys *types.SystemContext,
+) (types.ImageDestination, error) {
+	return newImageDestination(ctx, r)
+}
+
+func (r containerdReference) DeleteImage(ctx context.Context, sys *types.SystemContext) error {
+	s, nsCtx, err := newStore(ctx)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	if err := s.ImageService().Delete(nsCtx, r.ref.String()); err != nil {
+		return fmt.Errorf("failed to delete image %q: %w", r.ref.String(), err)
+	}
+	return nil
+}
+
+// imageName returns the image name in containerd image store.
+func (r containerdReference) imageName() string {
+	return r.ref.String()
+}
+
+// digested returns the digest-pinned reference.
+func (r containerdReference) digested() (reference.Canonical, bool) {
+	c, ok := r.ref.(reference.Canonical)
+	return c, ok
+}
+
+// withNamespace copies the namespace and lease of the store context
+// into the request context.
+func withNamespace(ctx, storeCtx context.Context) context.Context {
+	if ns, ok := namespaces.Namespace(storeCtx); ok {
+		ctx = namespaces.WithNamespace(ctx, ns)
+	}
+	if id, ok := leases.FromContext(storeCtx); ok {
+		ctx = leases.WithLease(ctx, id)
+	}
+	return ctx
+}
+
+// uniqueID returns the random ID of the content writer reference.
+func uniqueID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
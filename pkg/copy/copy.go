@@ -18,6 +18,9 @@ type Copier struct {
 	retryOptions *retry.Options
 
 	policy *signature.Policy
+
+	// retries is the number of the retries of the last copy.
+	retries int
 }
 
 type CopierOption struct {
@@ -51,8 +54,10 @@ func (c *Copier) Copy(ctx context.Context) ([]byte, error) {
 	if err != nil {
 		return nil, fmt.Errorf("copy: failed to create policy context: %w", err)
 	}
+	attempts := 0
 	err = retry.IfNecessary(ctx, func() error {
 		var err error
+		attempts++
 		m, err = imagecopy.Image(
 			ctx,
 			policyContext,
@@ -65,6 +70,12 @@ func (c *Copier) Copy(ctx context.Context) ([]byte, error) {
 		}
 		return nil
 	}, c.retryOptions)
+	c.retries = attempts - 1
 
 	return m, err
 }
+
+// Retries returns the number of the retries of the last copy.
+func (c *Copier) Retries() int {
+	return c.retries
+}
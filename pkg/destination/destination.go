@@ -8,19 +8,22 @@ import (
 	"path"
 	"strings"
 
+	_ "github.com/cnrancher/hangar/pkg/containerd" // Register containerd transport.
 	"github.com/cnrancher/hangar/pkg/hangar/archive"
 	"github.com/cnrancher/hangar/pkg/manifest"
+	"github.com/cnrancher/hangar/pkg/tracing"
 	"github.com/cnrancher/hangar/pkg/types"
 	imagemanifest "github.com/containers/image/v5/manifest"
 	"github.com/containers/image/v5/transports/alltransports"
 	imagetypes "github.com/containers/image/v5/types"
 	"github.com/opencontainers/go-digest"
 	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // Destination represents the destination of the image to be copied。
 // The type of the destination image can be:
-// docker, docker-daemon, oci or dir
+// docker, docker-daemon, oci, dir or containerd
 // (docker-archive won't be supported by hangar)
 type Destination struct {
 	// imageType
@@ -36,6 +39,8 @@ type Destination struct {
 	name string
 	// tag
 	tag string
+	// digest is the digest of the digest-pinned image
+	digest digest.Digest
 
 	// referenceName is the image reference with transport
 	referenceName string
@@ -68,6 +73,9 @@ type Option struct {
 	Name string
 	// Image Tag, need to provide if Type is docker / docker-daemon
 	Tag string
+	// Digest of the digest-pinned image, only available when Type is docker / containerd.
+	// The image will be stored by digest if the Tag is not provided.
+	Digest digest.Digest
 
 	SystemContext *imagetypes.SystemContext
 }
@@ -99,9 +107,18 @@ func NewDestination(o *Option) (*Destination, error) {
 		if err != nil {
 			return nil, err
 		}
+	case types.TypeContainerd:
+		d, err = newDestinationFromContainerd(o)
+		if err != nil {
+			return nil, err
+		}
 	default:
 		return nil, types.ErrInvalidType
 	}
+	// Init the reference name to be used before the manifest initialized.
+	if err = d.initReferenceName(); err != nil {
+		return nil, err
+	}
 
 	return d, nil
 }
@@ -111,8 +128,12 @@ func (d *Destination) Init(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
+	ctx, span := tracing.Start(ctx, "init destination",
+		attribute.String("image", d.ReferenceNameWithoutTransport()))
+	err = d.initManifest(ctx)
+	tracing.End(span, err)
 	// Ignore other error
-	if err = d.initManifest(ctx); err != nil {
+	if err != nil {
 		if errors.Is(err, context.Canceled) ||
 			errors.Is(err, context.DeadlineExceeded) ||
 			strings.Contains(err.Error(), "timeout") {
@@ -131,6 +152,28 @@ func (d *Destination) Directory() string {
 	return d.directory
 }
 
+func (d *Destination) Registry() string {
+	return d.registry
+}
+
+func (d *Destination) Project() string {
+	return d.project
+}
+
+func (d *Destination) Name() string {
+	return d.name
+}
+
+func (d *Destination) Tag() string {
+	return d.tag
+}
+
+// Digest returns the digest of the digest-pinned destination image,
+// returns empty string if the destination image is not digest-pinned.
+func (d *Destination) Digest() digest.Digest {
+	return d.digest
+}
+
 // ReferenceName returns the reference name with transport of the source image.
 //
 //	Example:
@@ -156,8 +199,10 @@ func (d *Destination) MultiArchTag(os, osVersion, arch, variant string) string {
 //		docker://docker.io/library/hello-world:latest-linux-amd64
 //		docker://docker.io/library/example:latest-windows-10.0.14393.1066-amd64
 //		docker-daemon://docker.io/library/nginx:1.23-linux-arm64
+//		docker://docker.io/library/nginx@sha256:<sha256sum> (digest-pinned)
 //		oci:./path/to/oci-image/<sha256sum>
 //		dir:./path/to/image/<sha256sum>
+//		containerd:docker.io/library/nginx@sha256:<sha256sum>
 func (d *Destination) ReferenceNameMultiArch(
 	os, osVersion, arch, variant, sha256sum string,
 ) string {
@@ -165,6 +210,19 @@ func (d *Destination) ReferenceNameMultiArch(
 	case types.TypeDir,
 		types.TypeOci:
 		return path.Join(d.referenceName, sha256sum)
+	case types.TypeDocker:
+		if d.tag == "" && d.digest != "" {
+			// The digest-pinned image does not have tag,
+			// store the images by digest.
+			return d.ReferenceNameDigest(digest.NewDigestFromEncoded(
+				digest.SHA256, sha256sum))
+		}
+		return d.MultiArchTag(os, osVersion, arch, variant)
+	case types.TypeContainerd:
+		// Store the platform images by digest to avoid creating the
+		// image records of the multi-arch tags in containerd.
+		return d.ReferenceNameDigest(digest.NewDigestFromEncoded(
+			digest.SHA256, sha256sum))
 	default:
 		return d.MultiArchTag(os, osVersion, arch, variant)
 	}
@@ -191,8 +249,13 @@ func (d *Destination) ReferenceNameWithoutTransport() string {
 }
 
 func (d *Destination) ReferenceNameDigest(dig digest.Digest) string {
-	return fmt.Sprintf("%s@%s",
-		strings.TrimSuffix(d.referenceName, ":"+d.tag), dig.String())
+	name := d.referenceName
+	if d.tag == "" && d.digest != "" {
+		name = strings.TrimSuffix(name, "@"+d.digest.String())
+	} else {
+		name = strings.TrimSuffix(name, ":"+d.tag)
+	}
+	return fmt.Sprintf("%s@%s", name, dig.String())
 }
 
 func (d *Destination) MIME() string {
@@ -228,8 +291,16 @@ func (d *Destination) SystemContext() *imagetypes.SystemContext {
 
 func (d *Destination) initReferenceName() error {
 	switch d.imageType {
-	case types.TypeDocker:
+	case types.TypeDocker, types.TypeContainerd:
 		// docker://docker-reference
+		// containerd:docker-reference
+		if d.tag == "" && d.digest != "" {
+			// example: docker://docker.io/library/nginx@sha256:abcdef...
+			d.referenceName = fmt.Sprintf("%s%s/%s/%s@%s",
+				d.imageType.Transport(),
+				d.registry, d.project, d.name, d.digest)
+			break
+		}
 		// example: docker://docker.io/library/nginx:1.23
 		d.referenceName = fmt.Sprintf("%s%s/%s/%s:%s",
 			d.imageType.Transport(),
@@ -337,9 +408,36 @@ func newDestinationFromDocker(o *Option) (*Destination, error) {
 		project:   o.Project,
 		name:      o.Name,
 		tag:       o.Tag,
+		digest:    o.Digest,
 		systemCtx: o.SystemContext,
 	}
-	if d.tag == "" {
+	if d.tag == "" && d.digest == "" {
+		d.tag = "latest"
+	}
+	if d.project == "" {
+		d.project = "library"
+	}
+	if d.registry == "" {
+		d.registry = "docker.io"
+	}
+
+	return d, nil
+}
+
+func newDestinationFromContainerd(o *Option) (*Destination, error) {
+	if o.Type != types.TypeContainerd {
+		return nil, types.ErrInvalidType
+	}
+	d := &Destination{
+		imageType: o.Type,
+		registry:  o.Registry,
+		project:   o.Project,
+		name:      o.Name,
+		tag:       o.Tag,
+		digest:    o.Digest,
+		systemCtx: o.SystemContext,
+	}
+	if d.tag == "" && d.digest == "" {
 		d.tag = "latest"
 	}
 	if d.project == "" {
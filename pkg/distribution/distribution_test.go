@@ -0,0 +1,141 @@
+package distribution
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+)
+
+const testManifest = `{
+	"schemaVersion": 2,
+	"mediaType": "application/vnd.docker.distribution.manifest.v2+json",
+	"config": {
+		"mediaType": "application/vnd.docker.container.image.v1+json",
+		"size": 2,
+		"digest": "%s"
+	},
+	"layers": []
+}`
+
+func Test_Handler(t *testing.T) {
+	root := t.TempDir()
+	s, err := NewServer(root)
+	if !assert.NoError(t, err) {
+		return
+	}
+	server := httptest.NewServer(s.Handler())
+	defer server.Close()
+
+	do := func(method, path string, body []byte) *http.Response {
+		req, _ := http.NewRequest(method, server.URL+path, bytes.NewReader(body))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		return resp
+	}
+
+	config := []byte("{}")
+	configDigest := digest.FromBytes(config)
+	resp := do(http.MethodHead, "/v2/library/hello/blobs/"+configDigest.String(), nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// Chunked upload: POST, PATCH, PUT.
+	resp = do(http.MethodPost, "/v2/library/hello/blobs/uploads/", nil)
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+	location := resp.Header.Get("Location")
+	resp = do(http.MethodPatch, location, config[:1])
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+	assert.Equal(t, "0-0", resp.Header.Get("Range"))
+	resp = do(http.MethodPut, location+"?digest=sha256:invalid", config[1:])
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp = do(http.MethodPost, "/v2/library/hello/blobs/uploads/", nil)
+	location = resp.Header.Get("Location")
+	do(http.MethodPatch, location, config[:1])
+	resp = do(http.MethodPut, location+"?digest="+configDigest.String(), config[1:])
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Equal(t, configDigest.String(), resp.Header.Get("Docker-Content-Digest"))
+
+	resp = do(http.MethodHead, "/v2/library/hello/blobs/"+configDigest.String(), nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	// Cross repository mount.
+	resp = do(http.MethodPost, fmt.Sprintf(
+		"/v2/library/world/blobs/uploads/?mount=%s&from=library/hello", configDigest), nil)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	manifest := []byte(fmt.Sprintf(testManifest, configDigest))
+	manifestDigest := digest.FromBytes(manifest)
+	resp = do(http.MethodPut, "/v2/library/hello/manifests/latest", manifest)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Equal(t, manifestDigest.String(), resp.Header.Get("Docker-Content-Digest"))
+	resp = do(http.MethodGet, "/v2/library/hello/manifests/latest", nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/vnd.docker.distribution.manifest.v2+json",
+		resp.Header.Get("Content-Type"))
+	resp = do(http.MethodHead, "/v2/library/hello/manifests/"+manifestDigest.String(), nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp = do(http.MethodGet, "/v2/library/world/manifests/latest", nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	v2 := filepath.Join(root, "docker", "registry", "v2")
+	e := configDigest.Encoded()
+	b, err := os.ReadFile(filepath.Join(v2, "blobs", "sha256", e[:2], e, "data"))
+	assert.NoError(t, err)
+	assert.Equal(t, config, b)
+	for _, p := range []string{
+		"repositories/library/hello/_layers/sha256/" + e + "/link",
+		"repositories/library/world/_layers/sha256/" + e + "/link",
+		"repositories/library/hello/_manifests/revisions/sha256/" + manifestDigest.Encoded() + "/link",
+		"repositories/library/hello/_manifests/tags/latest/current/link",
+		"repositories/library/hello/_manifests/tags/latest/index/sha256/" + manifestDigest.Encoded() + "/link",
+	} {
+		b, err := os.ReadFile(filepath.Join(v2, filepath.FromSlash(p)))
+		if assert.NoError(t, err, p) {
+			assert.True(t, strings.HasPrefix(string(b), "sha256:"))
+		}
+	}
+	// Unfinished uploads are removed.
+	entries, _ := os.ReadDir(filepath.Join(v2, "repositories", "library", "hello", "_uploads"))
+	assert.Empty(t, entries)
+}
+
+func Test_Start(t *testing.T) {
+	s, err := NewServer(t.TempDir())
+	if !assert.NoError(t, err) {
+		return
+	}
+	addr, err := s.Start()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer s.Close()
+
+	ca, err := os.ReadFile(filepath.Join(s.CertDir(), addr, "ca.crt"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	pool := x509.NewCertPool()
+	assert.True(t, pool.AppendCertsFromPEM(ca))
+	client := &http.Client{
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}},
+	}
+	resp, err := client.Get(fmt.Sprintf("https://%s/v2/", addr))
+	if assert.NoError(t, err) {
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+}
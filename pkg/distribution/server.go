@@ -0,0 +1,438 @@
+package distribution
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/sirupsen/logrus"
+)
+
+// Server is the embedded registry server which implements the push and pull
+// API of the distribution spec, and writes the images into the storage.
+//
+// The server only listens on the loopback address with the self-signed
+// certificate, the certificate is written into the 'CertDir/ADDR/ca.crt'
+// to be trusted by the 'DockerCertPath' of the system context.
+type Server struct {
+	storage *Storage
+	server  *http.Server
+	addr    string
+	certDir string
+
+	mu      sync.Mutex
+	uploads map[string]string
+}
+
+// NewServer creates the embedded registry server of the storage directory.
+func NewServer(root string) (*Server, error) {
+	storage, err := NewStorage(root)
+	if err != nil {
+		return nil, err
+	}
+	return &Server{
+		storage: storage,
+		uploads: map[string]string{},
+	}, nil
+}
+
+// Start listens on a random port of the loopback address and serves the
+// registry API in background, returns the registry address.
+func (s *Server) Start() (string, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", fmt.Errorf("failed to listen: %w", err)
+	}
+	s.addr = l.Addr().String()
+	certPEM, keyPEM, err := selfSignedCert()
+	if err != nil {
+		l.Close()
+		return "", err
+	}
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		l.Close()
+		return "", fmt.Errorf("failed to load certificate: %w", err)
+	}
+	s.certDir, err = os.MkdirTemp("", "hangar-distribution-*")
+	if err != nil {
+		l.Close()
+		return "", fmt.Errorf("failed to create cert directory: %w", err)
+	}
+	// Per host cert directory format: CERT_DIR/HOST:PORT/ca.crt
+	hostCertDir := filepath.Join(s.certDir, s.addr)
+	if err := os.MkdirAll(hostCertDir, 0755); err != nil {
+		l.Close()
+		return "", fmt.Errorf("failed to create cert directory: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(hostCertDir, "ca.crt"), certPEM, 0644); err != nil {
+		l.Close()
+		return "", fmt.Errorf("failed to write certificate: %w", err)
+	}
+
+	s.server = &http.Server{
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: time.Second * 30,
+		TLSConfig: &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS12,
+		},
+	}
+	go func() {
+		err := s.server.ServeTLS(l, "", "")
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logrus.Errorf("Embedded registry server stopped: %v", err)
+		}
+	}()
+	logrus.Debugf("Embedded registry of storage %q serving on %q",
+		s.storage.Root(), s.addr)
+	return s.addr, nil
+}
+
+// Addr returns the registry address after the server started.
+func (s *Server) Addr() string {
+	return s.addr
+}
+
+// CertDir returns the per host certificate directory of the server.
+func (s *Server) CertDir() string {
+	return s.certDir
+}
+
+// Close stops the server and removes the unfinished uploads.
+func (s *Server) Close() error {
+	var err error
+	if s.server != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		defer cancel()
+		err = s.server.Shutdown(ctx)
+	}
+	if s.certDir != "" {
+		os.RemoveAll(s.certDir)
+	}
+	s.mu.Lock()
+	for _, p := range s.uploads {
+		os.RemoveAll(filepath.Dir(p))
+	}
+	s.uploads = map[string]string{}
+	s.mu.Unlock()
+	return err
+}
+
+// Handler returns the HTTP handler of the registry API.
+func (s *Server) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logrus.Debugf("%s %s", r.Method, r.URL.Path)
+		w.Header().Set("Docker-Distribution-API-Version", "registry/2.0")
+		p := r.URL.Path
+		switch {
+		case p == "/v2/" || p == "/v2":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte("{}"))
+			return
+		case !strings.HasPrefix(p, "/v2/"):
+			writeError(w, http.StatusNotFound, "NOT_FOUND", "not found")
+			return
+		}
+
+		// Path format: /v2/<name>/manifests/<reference>, /v2/<name>/blobs/<digest>,
+		// /v2/<name>/blobs/uploads/<id>
+		p = strings.TrimPrefix(p, "/v2/")
+		if i := strings.LastIndex(p, "/blobs/uploads"); i >= 0 {
+			id := strings.TrimPrefix(p[i+len("/blobs/uploads"):], "/")
+			s.serveUpload(w, r, p[:i], id)
+			return
+		}
+		for _, kind := range []string{"/manifests/", "/blobs/"} {
+			i := strings.LastIndex(p, kind)
+			if i < 0 {
+				continue
+			}
+			name, reference := p[:i], p[i+len(kind):]
+			if kind == "/manifests/" {
+				s.serveManifest(w, r, name, reference)
+			} else {
+				s.serveBlob(w, r, name, reference)
+			}
+			return
+		}
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "not found")
+	})
+}
+
+func (s *Server) serveManifest(
+	w http.ResponseWriter, r *http.Request, name, reference string,
+) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		b, d, mime, err := s.storage.GetManifest(name, reference)
+		if err != nil {
+			writeError(w, http.StatusNotFound, "MANIFEST_UNKNOWN",
+				fmt.Sprintf("manifest %q not found in repository %q", reference, name))
+			return
+		}
+		w.Header().Set("Content-Type", mime)
+		w.Header().Set("Docker-Content-Digest", d.String())
+		w.Header().Set("Content-Length", fmt.Sprintf("%d", len(b)))
+		if r.Method == http.MethodHead {
+			return
+		}
+		w.Write(b)
+	case http.MethodPut:
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "MANIFEST_INVALID", err.Error())
+			return
+		}
+		d, err := s.storage.PutManifest(name, reference, b)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "MANIFEST_INVALID", err.Error())
+			return
+		}
+		w.Header().Set("Location", fmt.Sprintf("/v2/%s/manifests/%s", name, d))
+		w.Header().Set("Docker-Content-Digest", d.String())
+		w.WriteHeader(http.StatusCreated)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "UNSUPPORTED", "unsupported method")
+	}
+}
+
+func (s *Server) serveBlob(
+	w http.ResponseWriter, r *http.Request, name, reference string,
+) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeError(w, http.StatusMethodNotAllowed, "UNSUPPORTED", "unsupported method")
+		return
+	}
+	d, err := digest.Parse(reference)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "DIGEST_INVALID", err.Error())
+		return
+	}
+	f, err := s.storage.OpenBlob(name, d)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "BLOB_UNKNOWN",
+			fmt.Sprintf("blob %q not found in repository %q", reference, name))
+		return
+	}
+	defer f.Close()
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Docker-Content-Digest", d.String())
+	w.Header().Set("Etag", fmt.Sprintf("%q", d.String()))
+	http.ServeContent(w, r, "", time.Time{}, f)
+}
+
+func (s *Server) serveUpload(
+	w http.ResponseWriter, r *http.Request, name, id string,
+) {
+	if id == "" {
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, "UNSUPPORTED", "unsupported method")
+			return
+		}
+		s.startUpload(w, r, name)
+		return
+	}
+
+	s.mu.Lock()
+	p, ok := s.uploads[id]
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, "BLOB_UPLOAD_UNKNOWN",
+			fmt.Sprintf("upload %q not found", id))
+		return
+	}
+	switch r.Method {
+	case http.MethodPatch:
+		size, err := appendUpload(p, r.Body)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "BLOB_UPLOAD_INVALID", err.Error())
+			return
+		}
+		writeUploadStatus(w, name, id, size)
+	case http.MethodPut:
+		if _, err := appendUpload(p, r.Body); err != nil {
+			writeError(w, http.StatusInternalServerError, "BLOB_UPLOAD_INVALID", err.Error())
+			return
+		}
+		s.commitUpload(w, r, name, id)
+	case http.MethodDelete:
+		s.removeUpload(id)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "UNSUPPORTED", "unsupported method")
+	}
+}
+
+func (s *Server) startUpload(w http.ResponseWriter, r *http.Request, name string) {
+	q := r.URL.Query()
+	// Cross repository blob mount.
+	if mount := q.Get("mount"); mount != "" {
+		if d, err := digest.Parse(mount); err == nil && s.storage.LinkBlob(name, d) == nil {
+			w.Header().Set("Location", fmt.Sprintf("/v2/%s/blobs/%s", name, d))
+			w.Header().Set("Docker-Content-Digest", d.String())
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+	}
+
+	id, err := uploadID()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "UNKNOWN", err.Error())
+		return
+	}
+	p := s.storage.uploadPath(name, id)
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		writeError(w, http.StatusInternalServerError, "UNKNOWN", err.Error())
+		return
+	}
+	if err := os.WriteFile(p, nil, 0644); err != nil {
+		writeError(w, http.StatusInternalServerError, "UNKNOWN", err.Error())
+		return
+	}
+	s.mu.Lock()
+	s.uploads[id] = p
+	s.mu.Unlock()
+
+	// Monolithic upload: POST with the digest query.
+	if q.Get("digest") != "" {
+		if _, err := appendUpload(p, r.Body); err != nil {
+			writeError(w, http.StatusInternalServerError, "BLOB_UPLOAD_INVALID", err.Error())
+			return
+		}
+		s.commitUpload(w, r, name, id)
+		return
+	}
+	writeUploadStatus(w, name, id, 0)
+}
+
+func (s *Server) commitUpload(w http.ResponseWriter, r *http.Request, name, id string) {
+	s.mu.Lock()
+	p := s.uploads[id]
+	s.mu.Unlock()
+	defer s.removeUpload(id)
+
+	d, err := digest.Parse(r.URL.Query().Get("digest"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "DIGEST_INVALID", err.Error())
+		return
+	}
+	if err := s.storage.CommitBlob(name, p, d); err != nil {
+		writeError(w, http.StatusBadRequest, "DIGEST_INVALID", err.Error())
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("/v2/%s/blobs/%s", name, d))
+	w.Header().Set("Docker-Content-Digest", d.String())
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (s *Server) removeUpload(id string) {
+	s.mu.Lock()
+	p, ok := s.uploads[id]
+	delete(s.uploads, id)
+	s.mu.Unlock()
+	if ok {
+		os.RemoveAll(filepath.Dir(p))
+	}
+}
+
+func uploadID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate upload ID: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func appendUpload(p string, r io.Reader) (int64, error) {
+	f, err := os.OpenFile(p, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	if _, err := io.Copy(f, r); err != nil {
+		return 0, fmt.Errorf("failed to write upload: %w", err)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return fi.Size(), nil
+}
+
+func writeUploadStatus(w http.ResponseWriter, name, id string, size int64) {
+	w.Header().Set("Location", fmt.Sprintf("/v2/%s/blobs/uploads/%s", name, id))
+	w.Header().Set("Docker-Upload-UUID", id)
+	end := size - 1
+	if end < 0 {
+		end = 0
+	}
+	w.Header().Set("Range", fmt.Sprintf("0-%d", end))
+	w.Header().Set("Content-Length", "0")
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{
+		"errors": []map[string]string{
+			{"code": code, "message": message},
+		},
+	})
+}
+
+// selfSignedCert generates the self-signed certificate of the loopback
+// address, returns the certificate and key in PEM format.
+func selfSignedCert() ([]byte, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate serial number: %w", err)
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "hangar-distribution"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(time.Hour * 24 * 7),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create certificate: %w", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal key: %w", err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM, nil
+}
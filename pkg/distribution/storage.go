@@ -0,0 +1,220 @@
+// Package distribution writes the images into the filesystem storage of the
+// distribution registry (registry:2), the storage directory can be mounted
+// to the registry container as '/var/lib/registry' directly.
+package distribution
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	imagemanifest "github.com/containers/image/v5/manifest"
+	"github.com/opencontainers/go-digest"
+)
+
+var (
+	ErrBlobUnknown     = errors.New("blob unknown")
+	ErrManifestUnknown = errors.New("manifest unknown")
+)
+
+// Storage is the filesystem storage of the distribution registry, layout:
+//
+//	docker/registry/v2/blobs/sha256/ab/abcdef.../data
+//	docker/registry/v2/repositories/NAME/_layers/sha256/abcdef.../link
+//	docker/registry/v2/repositories/NAME/_manifests/revisions/sha256/abcdef.../link
+//	docker/registry/v2/repositories/NAME/_manifests/tags/TAG/current/link
+//	docker/registry/v2/repositories/NAME/_manifests/tags/TAG/index/sha256/abcdef.../link
+type Storage struct {
+	root string
+}
+
+// NewStorage creates the storage of the root directory.
+func NewStorage(root string) (*Storage, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absolute path of %q: %w", root, err)
+	}
+	if err := os.MkdirAll(filepath.Join(root, "docker", "registry", "v2"), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+	return &Storage{root: root}, nil
+}
+
+// Root returns the root directory of the storage.
+func (s *Storage) Root() string {
+	return s.root
+}
+
+func (s *Storage) v2(elem ...string) string {
+	return filepath.Join(append([]string{s.root, "docker", "registry", "v2"}, elem...)...)
+}
+
+func (s *Storage) blobPath(d digest.Digest) string {
+	e := d.Encoded()
+	return s.v2("blobs", string(d.Algorithm()), e[:2], e, "data")
+}
+
+func (s *Storage) repositoryPath(name string, elem ...string) string {
+	return s.v2(append([]string{"repositories", filepath.FromSlash(name)}, elem...)...)
+}
+
+func (s *Storage) layerLinkPath(name string, d digest.Digest) string {
+	return s.repositoryPath(name, "_layers", string(d.Algorithm()), d.Encoded(), "link")
+}
+
+func (s *Storage) revisionLinkPath(name string, d digest.Digest) string {
+	return s.repositoryPath(name, "_manifests", "revisions",
+		string(d.Algorithm()), d.Encoded(), "link")
+}
+
+func (s *Storage) tagCurrentLinkPath(name, tag string) string {
+	return s.repositoryPath(name, "_manifests", "tags", tag, "current", "link")
+}
+
+func (s *Storage) tagIndexLinkPath(name, tag string, d digest.Digest) string {
+	return s.repositoryPath(name, "_manifests", "tags", tag, "index",
+		string(d.Algorithm()), d.Encoded(), "link")
+}
+
+// uploadPath returns the path of the blob upload session.
+func (s *Storage) uploadPath(name, id string) string {
+	return s.repositoryPath(name, "_uploads", id, "data")
+}
+
+// Stat returns the size of the blob, returns ErrBlobUnknown if the blob
+// not exists in storage.
+func (s *Storage) Stat(d digest.Digest) (int64, error) {
+	fi, err := os.Stat(s.blobPath(d))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, ErrBlobUnknown
+		}
+		return 0, err
+	}
+	return fi.Size(), nil
+}
+
+// OpenBlob opens the blob linked to the repository.
+func (s *Storage) OpenBlob(name string, d digest.Digest) (*os.File, error) {
+	if !s.linked(s.layerLinkPath(name, d), d) && !s.linked(s.revisionLinkPath(name, d), d) {
+		return nil, ErrBlobUnknown
+	}
+	f, err := os.Open(s.blobPath(d))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrBlobUnknown
+		}
+		return nil, err
+	}
+	return f, nil
+}
+
+// LinkBlob links the existing blob to the repository.
+func (s *Storage) LinkBlob(name string, d digest.Digest) error {
+	if _, err := s.Stat(d); err != nil {
+		return err
+	}
+	return writeLink(s.layerLinkPath(name, d), d)
+}
+
+// CommitBlob moves the uploaded file to the blob storage after verifying
+// the digest, and links the blob to the repository.
+func (s *Storage) CommitBlob(name, upload string, d digest.Digest) error {
+	if err := d.Validate(); err != nil {
+		return fmt.Errorf("invalid digest %q: %w", d, err)
+	}
+	f, err := os.Open(upload)
+	if err != nil {
+		return err
+	}
+	verifier := d.Verifier()
+	_, err = io.Copy(verifier, f)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read upload: %w", err)
+	}
+	if !verifier.Verified() {
+		return fmt.Errorf("digest %q mismatch", d)
+	}
+	p := s.blobPath(d)
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(upload, p); err != nil {
+		return fmt.Errorf("failed to move upload to blob storage: %w", err)
+	}
+	return writeLink(s.layerLinkPath(name, d), d)
+}
+
+// PutManifest writes the manifest to the repository, the reference is the
+// tag or digest of the manifest.
+func (s *Storage) PutManifest(name, reference string, b []byte) (digest.Digest, error) {
+	d := digest.FromBytes(b)
+	if rd, err := digest.Parse(reference); err == nil && rd != d {
+		return "", fmt.Errorf("digest %q mismatch", reference)
+	}
+	p := s.blobPath(d)
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(p, b, 0644); err != nil {
+		return "", fmt.Errorf("failed to write manifest: %w", err)
+	}
+	if err := writeLink(s.revisionLinkPath(name, d), d); err != nil {
+		return "", err
+	}
+	if _, err := digest.Parse(reference); err == nil {
+		return d, nil
+	}
+	if err := writeLink(s.tagIndexLinkPath(name, reference, d), d); err != nil {
+		return "", err
+	}
+	if err := writeLink(s.tagCurrentLinkPath(name, reference), d); err != nil {
+		return "", err
+	}
+	return d, nil
+}
+
+// GetManifest returns the manifest of the tag or digest and its MIME type.
+func (s *Storage) GetManifest(name, reference string) ([]byte, digest.Digest, string, error) {
+	d, err := digest.Parse(reference)
+	if err != nil {
+		b, err := os.ReadFile(s.tagCurrentLinkPath(name, reference))
+		if err != nil {
+			return nil, "", "", ErrManifestUnknown
+		}
+		if d, err = digest.Parse(strings.TrimSpace(string(b))); err != nil {
+			return nil, "", "", ErrManifestUnknown
+		}
+	}
+	if !s.linked(s.revisionLinkPath(name, d), d) {
+		return nil, "", "", ErrManifestUnknown
+	}
+	b, err := os.ReadFile(s.blobPath(d))
+	if err != nil {
+		return nil, "", "", ErrManifestUnknown
+	}
+	return b, d, imagemanifest.GuessMIMEType(b), nil
+}
+
+// linked checks the link file exists and points to the digest.
+func (s *Storage) linked(p string, d digest.Digest) bool {
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return false
+	}
+	return strings.TrimSpace(string(b)) == d.String()
+}
+
+func writeLink(p string, d digest.Digest) error {
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(p, []byte(d.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write link %q: %w", p, err)
+	}
+	return nil
+}
@@ -22,3 +22,15 @@ func Test_CompareIndexVersion(t *testing.T) {
 	err = CompareIndexVersion(index)
 	assert.Nil(t, err)
 }
+
+func Test_ImageReference(t *testing.T) {
+	img := &Image{
+		Source: "docker.io/library/nginx",
+		Tag:    "1.25",
+	}
+	assert.Equal(t, "docker.io/library/nginx:1.25", img.Reference())
+	img.Digest = "sha256:abcd"
+	assert.Equal(t, "docker.io/library/nginx:1.25@sha256:abcd", img.Reference())
+	img.Tag = ""
+	assert.Equal(t, "docker.io/library/nginx@sha256:abcd", img.Reference())
+}
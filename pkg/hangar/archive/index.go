@@ -23,13 +23,33 @@ type Index struct {
 }
 
 type Image struct {
-	Source   string      `json:"source,omitempty" yaml:"source,omitempty"`
-	Tag      string      `json:"tag,omitempty" yaml:"tag,omitempty"`
+	Source string `json:"source,omitempty" yaml:"source,omitempty"`
+	Tag    string `json:"tag,omitempty" yaml:"tag,omitempty"`
+	// Digest is the manifest digest of the digest-pinned image.
+	Digest digest.Digest `json:"digest,omitempty" yaml:"digest,omitempty"`
+	// Manifest is the raw manifest (index) of the digest-pinned image,
+	// used to push the image by digest without changing the digest.
+	Manifest string      `json:"manifest,omitempty" yaml:"manifest,omitempty"`
 	ArchList []string    `json:"archList,omitempty" yaml:"archList,omitempty"`
 	OsList   []string    `json:"osList,omitempty" yaml:"osList,omitempty"`
 	Images   []ImageSpec `json:"images,omitempty" yaml:"images,omitempty"`
 }
 
+// Reference returns the image reference name (without transport), example:
+//
+//	docker.io/library/nginx:1.25
+//	docker.io/library/nginx@sha256:abcdef...
+//	docker.io/library/nginx:1.25@sha256:abcdef...
+func (img *Image) Reference() string {
+	switch {
+	case img.Digest != "" && img.Tag != "":
+		return fmt.Sprintf("%s:%s@%s", img.Source, img.Tag, img.Digest)
+	case img.Digest != "":
+		return fmt.Sprintf("%s@%s", img.Source, img.Digest)
+	}
+	return fmt.Sprintf("%s:%s", img.Source, img.Tag)
+}
+
 type ImageSpec struct {
 	Arch       string          `json:"arch,omitempty" yaml:"arch,omitempty"`
 	OS         string          `json:"os,omitempty" yaml:"os,omitempty"`
@@ -1,6 +1,7 @@
 package archive
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"io/fs"
@@ -15,8 +16,9 @@ import (
 )
 
 type Reader struct {
-	f  *os.File
-	zr *zip.Reader
+	f     *os.File
+	zr    *zip.Reader
+	files map[string]*zip.File
 }
 
 // NewReader constructs a new Archive Reader object.
@@ -38,6 +40,10 @@ func NewReader(name string) (*Reader, error) {
 		f.Close()
 		return nil, fmt.Errorf("failed to create zip reader: %w", err)
 	}
+	reader.files = make(map[string]*zip.File, len(reader.zr.File))
+	for _, file := range reader.zr.File {
+		reader.files[file.Name] = file
+	}
 	if err := reader.validateIndex(); err != nil {
 		f.Close()
 		return nil, err
@@ -85,6 +91,35 @@ func (r *Reader) Index() ([]byte, error) {
 	return b, nil
 }
 
+// OpenFile returns the random access reader of the file in archive, the
+// stored (not compressed) file is read from the archive file directly.
+// Multiple files can be read concurrently.
+func (r *Reader) OpenFile(name string) (*io.SectionReader, error) {
+	file, ok := r.files[name]
+	if !ok || file.Mode().IsDir() {
+		return nil, os.ErrNotExist
+	}
+	if file.Method == zip.Store {
+		raw, err := file.OpenRaw()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %q in zip: %w", name, err)
+		}
+		if sr, ok := raw.(*io.SectionReader); ok {
+			return sr, nil
+		}
+	}
+	rc, err := file.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %q in zip: %w", name, err)
+	}
+	defer rc.Close()
+	b, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %q in zip: %w", name, err)
+	}
+	return io.NewSectionReader(bytes.NewReader(b), 0, int64(len(b))), nil
+}
+
 // Decompress decompresses the file/directory in archive.
 func (r *Reader) Decompress(name string, destination string) error {
 	var file *zip.File
@@ -182,6 +217,7 @@ func (r *Reader) Close() error {
 	}
 	if r.zr != nil {
 		r.zr = nil
+		r.files = nil
 	}
 	if r.f != nil {
 		if err := r.f.Close(); err != nil {
@@ -2,17 +2,30 @@ package hangar
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path"
+	"slices"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/cnrancher/hangar/pkg/destination"
 	"github.com/cnrancher/hangar/pkg/hangar/archive"
+	"github.com/cnrancher/hangar/pkg/hangar/imagelist"
+	"github.com/cnrancher/hangar/pkg/hangar/report"
+	"github.com/cnrancher/hangar/pkg/manifest"
+	"github.com/cnrancher/hangar/pkg/metrics"
+	"github.com/cnrancher/hangar/pkg/progress"
+	"github.com/cnrancher/hangar/pkg/registries"
 	"github.com/cnrancher/hangar/pkg/utils"
 	"github.com/containers/image/v5/signature"
 	"github.com/containers/image/v5/types"
+	"github.com/opencontainers/go-digest"
 	"github.com/sirupsen/logrus"
+	"sigs.k8s.io/yaml"
 )
 
 const (
@@ -23,6 +36,8 @@ const (
 type common struct {
 	// images is the image list.
 	images []string
+	// entries is the structured image list.
+	entries []*imagelist.Entry
 	// imageSpecSet example: map["os"]map["linux"]true
 	imageSpecSet map[string]map[string]bool
 	// timeout when copy image
@@ -37,24 +52,56 @@ type common struct {
 	objectCh chan any
 	// objectCtx is the context for handle object
 	objectCtx context.Context
+	// abort cancels the objectCtx when aborted by the failure policy
+	abort context.CancelCauseFunc
 	// errorCh is a channel to receive error message
 	errorCh chan error
 	// errorCtx is the context for handle error message
 	errorCtx context.Context
 	// failedImageList stores the images failed to copy (thread-unsafe)
+	failedImageList []*failedImage
+	// failedImageSet is the set of the failed images to avoid duplicate
+	// records: map[registry "\x00" destination "\x00" tag "\x00" line]true
+	// (thread-unsafe)
 	failedImageSet map[string]bool
+	// failedImageCount is the set of the distinct failed images counted by
+	// the failure policy: map[id "\x00" line]true (thread-unsafe)
+	failedImageCount map[string]bool
 	// failedImageListMutex is a mutex for read/write of failedImageList
 	failedImageListMutex *sync.RWMutex
 	// failedImageListName is the file name of the failed image list
 	failedImageListName string
+	// failurePolicy decides how to handle the failed images (optional)
+	failurePolicy *FailurePolicy
 	// systemContext
 	systemContext *types.SystemContext
+	// srcSystemContext is the system context of the source registries
+	srcSystemContext *types.SystemContext
+	// destSystemContext is the system context of the destination registries
+	destSystemContext *types.SystemContext
 	// policy
 	policy *signature.Policy
+	// progress reports the copy progress of the images (optional)
+	progress *progress.Tracker
+	// report is the run report of the images
+	report *report.Report
+	// reportName is the file name of the run report (optional)
+	reportName string
+	// reportFormat is the file format of the run report
+	reportFormat report.Format
+	// metrics exposes the metrics of the images copy (optional)
+	metrics *metrics.Metrics
+	// registries is the per-registry settings (optional)
+	registries *registries.Settings
+	// registryNames is the names of the destination registries in the
+	// failed image list and the run report (optional)
+	registryNames map[string]string
 }
 
 type CommonOpts struct {
-	Images              []string
+	Images []string
+	// Entries is the structured image list, mutually exclusive with Images.
+	Entries             []*imagelist.Entry
 	Arch                []string
 	OS                  []string
 	Variant             []string
@@ -62,12 +109,36 @@ type CommonOpts struct {
 	Workers             int
 	FailedImageListName string
 	SystemContext       *types.SystemContext
-	Policy              *signature.Policy
+	// SourceSystemContext is the system context of the source registries
+	// (optional, default SystemContext).
+	SourceSystemContext *types.SystemContext
+	// DestinationSystemContext is the system context of the destination
+	// registries (optional, default SystemContext).
+	DestinationSystemContext *types.SystemContext
+	Policy                   *signature.Policy
+	// Progress reports the copy progress of the images (optional).
+	Progress *progress.Tracker
+	// ReportName is the file name of the run report (optional).
+	ReportName string
+	// ReportFormat is the file format of the run report (default JSON).
+	ReportFormat report.Format
+	// FailurePolicy decides how to handle the failed images (optional).
+	FailurePolicy *FailurePolicy
+	// Metrics exposes the metrics of the images copy (optional).
+	Metrics *metrics.Metrics
+	// Registries is the per-registry settings of the TLS certificates,
+	// mirrors and concurrency limits (optional).
+	Registries *registries.Settings
+	// RegistryNames is the names of the destination registries written into
+	// the failed image list and the run report instead of the registry
+	// address, e.g. the storage directory of the embedded registry (optional).
+	RegistryNames map[string]string
 }
 
 func newCommon(o *CommonOpts) (*common, error) {
 	c := &common{
-		images: make([]string, len(o.Images)),
+		images:  make([]string, len(o.Images)),
+		entries: make([]*imagelist.Entry, len(o.Entries)),
 
 		imageSpecSet: map[string]map[string]bool{
 			"os":      make(map[string]bool),
@@ -83,12 +154,36 @@ func newCommon(o *CommonOpts) (*common, error) {
 		objectCh: make(chan any),
 		errorCh:  make(chan error),
 
+		failedImageList:      make([]*failedImage, 0),
 		failedImageSet:       make(map[string]bool),
+		failedImageCount:     make(map[string]bool),
 		failedImageListMutex: &sync.RWMutex{},
 		failedImageListName:  o.FailedImageListName,
+		failurePolicy:        o.FailurePolicy,
 
 		systemContext: utils.CopySystemContext(o.SystemContext),
 		policy:        nil,
+		progress:      o.Progress,
+		reportName:    o.ReportName,
+		reportFormat:  o.ReportFormat,
+		metrics:       o.Metrics,
+		registries:    o.Registries,
+		registryNames: o.RegistryNames,
+	}
+	if c.progress == nil {
+		// Collect the bytes transferred of the images for the report.
+		c.progress = progress.NewTracker(&progress.Options{
+			Format: progress.FormatNone,
+		})
+	}
+	c.metrics.SetTracker(c.progress)
+	c.srcSystemContext = c.systemContext
+	if o.SourceSystemContext != nil {
+		c.srcSystemContext = utils.CopySystemContext(o.SourceSystemContext)
+	}
+	c.destSystemContext = c.systemContext
+	if o.DestinationSystemContext != nil {
+		c.destSystemContext = utils.CopySystemContext(o.DestinationSystemContext)
 	}
 	var err error
 	policy, err := utils.CopyPolicy(o.Policy)
@@ -97,6 +192,7 @@ func newCommon(o *CommonOpts) (*common, error) {
 	}
 	c.policy = policy
 	copy(c.images, o.Images)
+	copy(c.entries, o.Entries)
 	for i := 0; i < len(o.OS); i++ {
 		c.imageSpecSet["os"][o.OS[i]] = true
 	}
@@ -110,8 +206,63 @@ func newCommon(o *CommonOpts) (*common, error) {
 	return c, nil
 }
 
+// failedImage is the image failed to copy.
+type failedImage struct {
+	// id is the line number of the image in image list
+	id int
+	// line is the original image list line, can be used to retry directly
+	line string
+	// registry is the destination registry failed to copy, only output if
+	// the image failed on some of the destinations but not all (optional)
+	registry string
+	// source is the source image reference, written into the structured
+	// failed image list of the plain image list lines (optional)
+	source string
+	// destination is the destination image name (without tag) failed to
+	// copy, written into the structured failed image list (optional)
+	destination string
+	// tag is the destination tag failed to copy (optional)
+	tag string
+	// destinations is the number of the destination images & tags of the
+	// image, to check whether the image failed on all destinations (optional)
+	destinations int
+	// err is the error of the failed image (optional)
+	err error
+}
+
+// category returns the error category of the failed image.
+func (f *failedImage) category() ErrorCategory {
+	if f.err == nil {
+		return ErrorCategoryUnknown
+	}
+	return ClassifyError(f.err)
+}
+
+// sortedFailedImages returns the failed images in image list order.
+func (c *common) sortedFailedImages() []*failedImage {
+	c.failedImageListMutex.RLock()
+	defer c.failedImageListMutex.RUnlock()
+	v := make([]*failedImage, len(c.failedImageList))
+	copy(v, c.failedImageList)
+	sort.SliceStable(v, func(i, j int) bool {
+		return v[i].id < v[j].id
+	})
+	return v
+}
+
+// failedImageReasonListName returns the file name of the failed image
+// reason list, example: mirror-failed.txt => mirror-failed-reason.txt
+func failedImageReasonListName(name string) string {
+	ext := path.Ext(name)
+	return strings.TrimSuffix(name, ext) + "-reason" + ext
+}
+
+// SaveFailedImages writes the failed images into the failed image list in
+// the image list order with the original image list format, the error
+// category of each image is written into the failed image reason list.
 func (c *common) SaveFailedImages() error {
-	if len(c.failedImageSet) == 0 {
+	images := c.sortedFailedImages()
+	if len(images) == 0 {
 		return nil
 	}
 	file, err := os.Create(c.failedImageListName)
@@ -120,21 +271,277 @@ func (c *common) SaveFailedImages() error {
 			c.failedImageListName, err)
 	}
 	defer file.Close()
-	for i := range c.failedImageSet {
-		_, err = file.WriteString(fmt.Sprintf("%s\n", i))
+	if len(c.entries) > 0 {
+		err = c.writeFailedEntries(file, images)
+	} else {
+		err = c.writeFailedLines(file, images)
+	}
+	if err != nil {
+		return err
+	}
+	logrus.Infof("Failed image list exported to %q", c.failedImageListName)
+
+	reasonName := failedImageReasonListName(c.failedImageListName)
+	reasonFile, err := os.Create(reasonName)
+	if err != nil {
+		return fmt.Errorf("failed to create file %q: %w", reasonName, err)
+	}
+	defer reasonFile.Close()
+	// Tab separated: CATEGORY	IMAGE_LIST_LINE	DESTINATION_REGISTRY	ERROR
+	for _, img := range images {
+		var msg string
+		if img.err != nil {
+			msg = strings.ReplaceAll(img.err.Error(), "\n", " ")
+		}
+		_, err = reasonFile.WriteString(fmt.Sprintf("%s\t%s\t%s\t%s\n",
+			img.category(), img.line, img.registry, msg))
 		if err != nil {
 			return fmt.Errorf("failed to write file: %w", err)
 		}
 	}
-	logrus.Infof("Failed image list exported to %q", c.failedImageListName)
+	logrus.Infof("Failed image reason list exported to %q", reasonName)
+	return nil
+}
+
+// partialFailures returns the ids of the images failed to copy to some
+// of the destinations but not all.
+func partialFailures(images []*failedImage) map[int]bool {
+	var (
+		failed = map[int]map[string]bool{}
+		total  = map[int]int{}
+		whole  = map[int]bool{}
+	)
+	for _, img := range images {
+		if img.destination == "" {
+			whole[img.id] = true
+			continue
+		}
+		if failed[img.id] == nil {
+			failed[img.id] = map[string]bool{}
+		}
+		failed[img.id][img.destination+":"+img.tag] = true
+		total[img.id] = max(total[img.id], img.destinations)
+	}
+	partial := map[int]bool{}
+	for id, dests := range failed {
+		if !whole[id] && len(dests) < total[id] {
+			partial[id] = true
+		}
+	}
+	return partial
+}
+
+// addFailedDestination adds the failed destination image and tag of the
+// image into the entry.
+func addFailedDestination(e *imagelist.Entry, img *failedImage) {
+	if img.destination == "" {
+		return
+	}
+	if !slices.Contains(e.Destinations, img.destination) {
+		e.Destinations = append(e.Destinations, img.destination)
+	}
+	if img.tag != "" && img.tag != utils.GetImageTag(e.Source) &&
+		!slices.Contains(e.Tags, img.tag) {
+		e.Tags = append(e.Tags, img.tag)
+	}
+}
+
+// writeFailedEntries writes the failed images as the structured image list,
+// the entry failed to copy to some of the destinations only keeps the failed
+// destination images and tags.
+func (c *common) writeFailedEntries(file *os.File, images []*failedImage) error {
+	var (
+		list    = &imagelist.List{}
+		entries = map[int]*imagelist.Entry{}
+		all     = map[int]bool{}
+	)
+	for _, img := range images {
+		if img.id < 1 || img.id > len(c.entries) {
+			continue
+		}
+		e, ok := entries[img.id]
+		if !ok {
+			copied := *c.entries[img.id-1]
+			e = &copied
+			e.Destination = ""
+			e.Destinations = nil
+			e.Tags = nil
+			entries[img.id] = e
+			list.Images = append(list.Images, e)
+		}
+		if img.destination == "" {
+			all[img.id] = true
+			continue
+		}
+		addFailedDestination(e, img)
+	}
+	// The entry failed before copying to the destinations is kept as it is.
+	for id := range all {
+		*entries[id] = *c.entries[id-1]
+	}
+	return writeStructuredList(file, list)
+}
+
+// writeFailedSources writes the failed images of the image list lines as
+// the structured image list with the failed destination images, since the
+// image list line cannot specify the destination registries.
+func writeFailedSources(file *os.File, images []*failedImage) error {
+	var (
+		list    = &imagelist.List{}
+		entries = map[string]*imagelist.Entry{}
+	)
+	for _, img := range images {
+		key := fmt.Sprintf("%d\x00%s", img.id, img.line)
+		e, ok := entries[key]
+		if !ok {
+			e = &imagelist.Entry{Source: img.source}
+			if e.Source == "" {
+				e.Source = img.line
+			}
+			entries[key] = e
+			list.Images = append(list.Images, e)
+		}
+		addFailedDestination(e, img)
+	}
+	return writeStructuredList(file, list)
+}
+
+// writeStructuredList writes the structured image list with the header.
+func writeStructuredList(file *os.File, list *imagelist.List) error {
+	b, err := yaml.Marshal(list)
+	if err != nil {
+		return fmt.Errorf("failed to marshal failed image list: %w", err)
+	}
+	if _, err = file.WriteString(imagelist.StructuredHeader + "\n"); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if _, err = file.Write(b); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
 	return nil
 }
 
+// writeFailedLines writes the failed images as the image list lines, each
+// line is written once. The structured image list is written instead if
+// any image failed on some of the destinations but not all, since the
+// image list line cannot specify the destination registries.
+func (c *common) writeFailedLines(file *os.File, images []*failedImage) error {
+	if len(partialFailures(images)) > 0 {
+		logrus.Warnf("Some images failed to copy to some of the destinations, "+
+			"retry %q without the destination registries option",
+			c.failedImageListName)
+		return writeFailedSources(file, images)
+	}
+	written := map[string]bool{}
+	for _, img := range images {
+		key := fmt.Sprintf("%d\x00%s", img.id, img.line)
+		if written[key] {
+			continue
+		}
+		written[key] = true
+		if _, err := file.WriteString(img.line + "\n"); err != nil {
+			return fmt.Errorf("failed to write file: %w", err)
+		}
+	}
+	return nil
+}
+
+// startReport creates the run report of the operation.
+func (c *common) startReport(operation string) {
+	c.report = report.New(operation)
+}
+
+// SaveReport writes the run report to the report file if specified.
+func (c *common) SaveReport() error {
+	if c.reportName == "" || c.report == nil {
+		return nil
+	}
+	c.report.Finish()
+	if err := c.report.Save(c.reportName, c.reportFormat); err != nil {
+		return err
+	}
+	logrus.Infof("Report exported to %q", c.reportName)
+	return nil
+}
+
+// reportImage records the result of the image in the run report, the
+// failed optional image is recorded as skipped.
+func (c *common) reportImage(
+	r *report.Image, start time.Time, p *progress.Image, err error, optional bool,
+) {
+	r.Duration = time.Since(start).Seconds()
+	r.BytesTransferred = p.Transferred()
+	r.Retries = p.Retries()
+	switch {
+	case err == nil:
+		r.Status = report.StatusSucceeded
+	case optional || c.failurePolicy.isWarning(err):
+		r.Status = report.StatusSkipped
+	default:
+		r.Status = report.StatusFailed
+	}
+	if err != nil {
+		r.Error = err.Error()
+		r.ErrorCategory = string(ClassifyError(err))
+	}
+	c.report.Add(r)
+}
+
+// reportFailure records the image failed before copying in the run report.
+func (c *common) reportFailure(id int, image string, err error, optional bool) {
+	c.reportImage(&report.Image{
+		ID:     id,
+		Source: image,
+	}, time.Now(), nil, err, optional)
+}
+
+// reportPlatforms returns the copied and the skipped platform images of
+// the image, the platform images not selected are skipped.
+func reportPlatforms(
+	all, selected []archive.ImageSpec,
+) ([]report.Platform, []report.Platform) {
+	var copied, skipped []report.Platform
+	selectedSet := map[digest.Digest]bool{}
+	for _, img := range selected {
+		selectedSet[img.Digest] = true
+	}
+	allSet := map[digest.Digest]bool{}
+	for _, img := range all {
+		allSet[img.Digest] = true
+		p := report.Platform{
+			OS:      img.OS,
+			Arch:    img.Arch,
+			Variant: img.Variant,
+			Digest:  img.Digest,
+		}
+		if selectedSet[img.Digest] {
+			copied = append(copied, p)
+		} else {
+			skipped = append(skipped, p)
+		}
+	}
+	for _, img := range selected {
+		if allSet[img.Digest] {
+			continue
+		}
+		copied = append(copied, report.Platform{
+			OS:      img.OS,
+			Arch:    img.Arch,
+			Variant: img.Variant,
+			Digest:  img.Digest,
+		})
+	}
+	return copied, skipped
+}
+
 func (c *common) initWorker(ctx context.Context, f func(context.Context, any)) {
-	c.objectCtx = ctx
+	// The bandwidth of the images copied by workers are limited by the
+	// registry settings carried by the context.
+	ctx = registries.WithSettings(ctx, c.registries)
+	c.objectCtx, c.abort = context.WithCancelCause(ctx)
 	maxWorkerNum := c.workers
-	if len(c.images) > 0 && len(c.images) < maxWorkerNum {
-		maxWorkerNum = len(c.images)
+	if n := len(c.images) + len(c.entries); n > 0 && n < maxWorkerNum {
+		maxWorkerNum = n
 		logrus.Debugf("Reset worker num %d", maxWorkerNum)
 	}
 	for i := 0; i < maxWorkerNum; i++ {
@@ -146,11 +553,12 @@ func (c *common) initWorker(ctx context.Context, f func(context.Context, any)) {
 
 func (c *common) workerFunc(id int, f func(context.Context, any)) {
 	defer c.waitGroup.Done()
+	defer c.metrics.WorkerStarted()()
 	for {
 		select {
 		case <-c.objectCtx.Done():
 			logrus.Infof("Worker [%d] stopped gracefully: %v",
-				id, c.objectCtx.Err())
+				id, context.Cause(c.objectCtx))
 			return
 		case obj, ok := <-c.objectCh:
 			if !ok {
@@ -160,11 +568,67 @@ func (c *common) workerFunc(id int, f func(context.Context, any)) {
 			if obj == nil {
 				continue
 			}
+			release, err := c.acquireRegistries(obj)
+			if err != nil {
+				logrus.Infof("Worker [%d] stopped gracefully: %v",
+					id, context.Cause(c.objectCtx))
+				return
+			}
+			idle := c.metrics.WorkerBusy()
 			f(c.objectCtx, obj)
+			idle()
+			release()
 		}
 	}
 }
 
+// registryObject is the object copied from or to the registries.
+type registryObject interface {
+	registries() []string
+}
+
+// acquireRegistries waits until the object can be copied within the
+// concurrency limits of its registries.
+func (c *common) acquireRegistries(obj any) (func(), error) {
+	r, ok := obj.(registryObject)
+	if !ok {
+		return func() {}, nil
+	}
+	return c.registries.Acquire(c.objectCtx, r.registries()...)
+}
+
+// sourceSystemContext returns the system context of the source registry.
+func (c *common) sourceSystemContext(registry string) *types.SystemContext {
+	return c.registries.SystemContext(c.srcSystemContext, registry)
+}
+
+// destinationSystemContext returns the system context of the destination
+// registry.
+func (c *common) destinationSystemContext(registry string) *types.SystemContext {
+	return c.registries.SystemContext(c.destSystemContext, registry)
+}
+
+// registryName returns the name of the destination registry in the failed
+// image list and the run report.
+func (c *common) registryName(registry string) string {
+	if name, ok := c.registryNames[registry]; ok {
+		return name
+	}
+	return registry
+}
+
+// destinationName returns the destination image name in the run report,
+// the registry is replaced by its name.
+func (c *common) destinationName(dest *destination.Destination) string {
+	ref := dest.ReferenceNameWithoutTransport()
+	registry := utils.GetRegistryName(ref)
+	name, ok := c.registryNames[registry]
+	if !ok {
+		return ref
+	}
+	return name + "/" + strings.TrimPrefix(ref, registry+"/")
+}
+
 func (c *common) initErrorHandler(ctx context.Context) {
 	c.errorCtx = ctx
 	c.errorWaitGroup.Add(errorHandlerWorkerNum)
@@ -181,23 +645,153 @@ func (c *common) initErrorHandler(ctx context.Context) {
 						logrus.Debugf("Error handler channel closed")
 						return
 					}
-					logrus.Error(err)
+					logrus.WithFields(logFields(err)).Error(err)
 				}
 			}
 		}()
 	}
 }
 
-func (c *common) recordFailedImage(name string) {
+// entryImageSpecSet returns the image spec set of the structured image list
+// entry, returns nil if the entry does not override the arch, os or variant.
+func (c *common) entryImageSpecSet(e *imagelist.Entry) map[string]map[string]bool {
+	if len(e.Arch) == 0 && len(e.OS) == 0 && len(e.Variant) == 0 {
+		return nil
+	}
+	set := make(map[string]map[string]bool, len(c.imageSpecSet))
+	for k, v := range c.imageSpecSet {
+		set[k] = make(map[string]bool, len(v))
+		for kk, vv := range v {
+			set[k][kk] = vv
+		}
+	}
+	override := map[string][]string{
+		"arch":    e.Arch,
+		"os":      e.OS,
+		"variant": e.Variant,
+	}
+	for k, v := range override {
+		if len(v) == 0 {
+			continue
+		}
+		set[k] = make(map[string]bool, len(v))
+		for _, s := range v {
+			set[k][s] = true
+		}
+	}
+	return set
+}
+
+// specSet returns the object image spec set if specified,
+// otherwise returns the global image spec set.
+func (c *common) specSet(set map[string]map[string]bool) map[string]map[string]bool {
+	if set != nil {
+		return set
+	}
+	return c.imageSpecSet
+}
+
+// recordFailedImage records the image failed to copy, the line is the
+// original image list line of the image.
+func (c *common) recordFailedImage(id int, line string, err error) {
+	c.recordFailed(&failedImage{
+		id:   id,
+		line: line,
+		err:  err,
+	})
+}
+
+// failedDestination returns the destination image name (without tag)
+// recorded in the structured failed image list, the registry started by
+// the command (e.g. the embedded registry of the storage directory) is
+// omitted.
+func (c *common) failedDestination(registry, project, name string) string {
+	if _, ok := c.registryNames[registry]; ok || registry == "" {
+		return project + "/" + name
+	}
+	return registry + "/" + project + "/" + name
+}
+
+// recordFailed records the failed image by the failure policy, the image
+// failed with the warning error or canceled after the run aborted is not
+// recorded. The run will be aborted if the failure policy decides to abort,
+// the max errors count the distinct images regardless of the destinations.
+func (c *common) recordFailed(img *failedImage) {
+	if c.failurePolicy.isWarning(img.err) || c.abortedError(img.err) {
+		return
+	}
 	c.failedImageListMutex.Lock()
-	c.failedImageSet[name] = true
-	c.failedImageListMutex.Unlock()
+	defer c.failedImageListMutex.Unlock()
+	key := strings.Join([]string{img.registry, img.destination, img.tag, img.line}, "\x00")
+	if c.failedImageSet[key] {
+		return
+	}
+	c.failedImageSet[key] = true
+	c.failedImageList = append(c.failedImageList, img)
+	c.failedImageCount[fmt.Sprintf("%d\x00%s", img.id, img.line)] = true
+
+	err := c.failurePolicy.abortError(img.err, len(c.failedImageCount))
+	if err == nil || c.abort == nil || c.objectCtx.Err() != nil {
+		return
+	}
+	logrus.Errorf("Abort: %v", err)
+	c.abort(err)
+}
+
+// abortedError returns true if the error is caused by the run aborted by
+// the failure policy.
+func (c *common) abortedError(err error) bool {
+	if errors.Is(err, ErrAborted) {
+		return true
+	}
+	if c.objectCtx == nil || !errors.Is(err, context.Canceled) {
+		return false
+	}
+	return errors.Is(context.Cause(c.objectCtx), ErrAborted)
+}
+
+// failedError returns the abort error if the run was aborted by the
+// failure policy, otherwise returns err.
+func (c *common) failedError(err error) error {
+	if c.objectCtx == nil {
+		return err
+	}
+	if cause := context.Cause(c.objectCtx); errors.Is(cause, ErrAborted) {
+		return cause
+	}
+	return err
+}
+
+// failedImages returns the failed image list for output in image list
+// order, images failed of the destination registry are in
+// "IMAGE [REGISTRY]" format.
+func (c *common) failedImages() []string {
+	images := c.sortedFailedImages()
+	partial := partialFailures(images)
+	v := make([]string, 0, len(images))
+	added := map[string]bool{}
+	for _, img := range images {
+		line := img.line
+		if partial[img.id] && img.registry != "" {
+			line = fmt.Sprintf("%s [%s]", img.line, img.registry)
+		}
+		if added[line] {
+			continue
+		}
+		added[line] = true
+		v = append(v, line)
+	}
+	return v
 }
 
 func (c *common) handleError(err error) error {
 	if err == nil {
 		return nil
 	}
+	if c.failurePolicy.isWarning(err) {
+		logrus.WithFields(logFields(err)).Warn(err)
+		return nil
+	}
 	select {
 	case c.errorCh <- err:
 	case <-c.errorCtx.Done():
@@ -214,6 +808,7 @@ func (c *common) handleObject(obj any) error {
 	case c.objectCh <- obj:
 	case <-c.objectCtx.Done():
 		// If context canceled, skip sending object to worker.
+		return c.objectCtx.Err()
 	}
 	return c.errorCtx.Err()
 }
@@ -225,6 +820,32 @@ func (c *common) waitWorkers() {
 	close(c.errorCh)
 	// Waiting for all error messages were handled properly
 	c.errorWaitGroup.Wait()
+	c.progress.Stop()
+}
+
+// pushDigestPinnedManifest pushes the raw manifest of the digest-pinned image
+// to the destination by digest, and also by tag if the tag is specified.
+func pushDigestPinnedManifest(
+	ctx context.Context, dest *destination.Destination, raw []byte,
+) error {
+	refs := []string{dest.ReferenceNameDigest(dest.Digest())}
+	if dest.Tag() != "" {
+		refs = append(refs, dest.ReferenceName())
+	}
+	for _, ref := range refs {
+		if err := manifest.PushRaw(ctx, ref, dest.SystemContext(), raw); err != nil {
+			return fmt.Errorf("failed to push manifest to [%v]: %w", ref, err)
+		}
+	}
+	return nil
+}
+
+// errDigestNotPreserved returns the error when the digest-pinned image
+// without tag could not be copied without changing its digest.
+func errDigestNotPreserved(d digest.Digest) error {
+	return fmt.Errorf("unable to preserve digest [%v]: "+
+		"some images were skipped by the arch/os filter, "+
+		"specify the image tag or copy all the images", d)
 }
 
 // layerManager is for managing image layer cache.
@@ -0,0 +1,178 @@
+package hangar
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/cnrancher/hangar/pkg/hangar/imagelist"
+	"github.com/containers/image/v5/signature"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_SaveFailedEntries(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "mirror-failed.txt")
+	c, err := newCommon(&CommonOpts{
+		Entries: []*imagelist.Entry{
+			{
+				Source:       "docker.io/library/nginx:1.25",
+				Destinations: []string{"a.io/library/nginx", "b.io/mirrored/nginx"},
+				Tags:         []string{"stable", "latest"},
+				Arch:         []string{"amd64"},
+			},
+			{Source: "docker.io/library/busybox:latest"},
+			{
+				Source:   "docker.io/library/mysql:8",
+				Tags:     []string{"stable"},
+				OS:       []string{"linux"},
+				Optional: true,
+			},
+		},
+		FailedImageListName: name,
+		Policy: &signature.Policy{
+			Default: []signature.PolicyRequirement{
+				signature.NewPRInsecureAcceptAnything(),
+			},
+			Transports: map[string]signature.PolicyTransportScopes{},
+		},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	failed := errors.New("failed")
+	for _, img := range []*failedImage{
+		{id: 1, line: "docker.io/library/nginx:1.25", destination: "b.io/mirrored/nginx", tag: "1.25"},
+		{id: 1, line: "docker.io/library/nginx:1.25", destination: "b.io/mirrored/nginx", tag: "stable"},
+		{id: 3, line: "docker.io/library/mysql:8", destination: "c.io/library/mysql", tag: "8"},
+		// The image failed before copying to the destinations.
+		{id: 3, line: "docker.io/library/mysql:8"},
+	} {
+		img.err = failed
+		c.recordFailed(img)
+	}
+	if !assert.NoError(t, c.SaveFailedImages()) {
+		return
+	}
+
+	b, err := os.ReadFile(name)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.True(t, imagelist.IsStructured(name, b))
+	list, err := imagelist.ParseStructured(b)
+	if !assert.NoError(t, err) || !assert.Len(t, list.Images, 2) {
+		return
+	}
+	assert.Equal(t, &imagelist.Entry{
+		Source:       "docker.io/library/nginx:1.25",
+		Destinations: []string{"b.io/mirrored/nginx"},
+		Tags:         []string{"stable"},
+		Arch:         []string{"amd64"},
+	}, list.Images[0])
+	assert.Equal(t, &imagelist.Entry{
+		Source:   "docker.io/library/mysql:8",
+		Tags:     []string{"stable"},
+		OS:       []string{"linux"},
+		Optional: true,
+	}, list.Images[1])
+}
+
+func Test_SaveFailedLines(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "mirror-failed.txt")
+	newCommonWithFailed := func(images ...*failedImage) *common {
+		c, err := newCommon(&CommonOpts{
+			FailedImageListName: name,
+			Policy: &signature.Policy{
+				Default: []signature.PolicyRequirement{
+					signature.NewPRInsecureAcceptAnything(),
+				},
+				Transports: map[string]signature.PolicyTransportScopes{},
+			},
+		})
+		if !assert.NoError(t, err) {
+			return nil
+		}
+		for _, img := range images {
+			img.err = errors.New("failed")
+			c.recordFailed(img)
+		}
+		return c
+	}
+
+	// The image failed on all destinations is written once.
+	c := newCommonWithFailed(
+		&failedImage{id: 1, line: "nginx:1.25", registry: "a.io", source: "docker.io/library/nginx:1.25",
+			destination: "a.io/library/nginx", tag: "1.25", destinations: 2},
+		&failedImage{id: 1, line: "nginx:1.25", registry: "b.io", source: "docker.io/library/nginx:1.25",
+			destination: "b.io/library/nginx", tag: "1.25", destinations: 2},
+		&failedImage{id: 2, line: "busybox"},
+	)
+	if c == nil || !assert.NoError(t, c.SaveFailedImages()) {
+		return
+	}
+	b, err := os.ReadFile(name)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.False(t, imagelist.IsStructured(name, b))
+	assert.Equal(t, "nginx:1.25\nbusybox\n", string(b))
+	assert.Equal(t, []string{"nginx:1.25", "busybox"}, c.failedImages())
+
+	// The image failed on some of the destinations only targets the failed
+	// destinations.
+	c = newCommonWithFailed(
+		&failedImage{id: 1, line: "nginx:1.25", registry: "b.io", source: "docker.io/library/nginx:1.25",
+			destination: "b.io/library/nginx", tag: "1.25", destinations: 2},
+		&failedImage{id: 2, line: "busybox"},
+	)
+	if c == nil || !assert.NoError(t, c.SaveFailedImages()) {
+		return
+	}
+	b, err = os.ReadFile(name)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.True(t, imagelist.IsStructured(name, b))
+	list, err := imagelist.ParseStructured(b)
+	if !assert.NoError(t, err) || !assert.Len(t, list.Images, 2) {
+		return
+	}
+	assert.Equal(t, &imagelist.Entry{
+		Source:       "docker.io/library/nginx:1.25",
+		Destinations: []string{"b.io/library/nginx"},
+	}, list.Images[0])
+	assert.Equal(t, &imagelist.Entry{Source: "busybox"}, list.Images[1])
+	assert.Equal(t, []string{"nginx:1.25 [b.io]", "busybox"}, c.failedImages())
+}
+
+func Test_RecordFailedMaxErrors(t *testing.T) {
+	c := &common{
+		failedImageListMutex: &sync.RWMutex{},
+		failedImageSet:       make(map[string]bool),
+		failedImageCount:     make(map[string]bool),
+		failurePolicy:        &FailurePolicy{MaxErrors: 2},
+	}
+	c.objectCtx, c.abort = context.WithCancelCause(context.Background())
+	failed := errors.New("failed")
+
+	// The image failed to copy to multiple destinations is counted once.
+	for _, registry := range []string{"a.io", "b.io", "c.io"} {
+		c.recordFailed(&failedImage{id: 1, line: "nginx", registry: registry, err: failed})
+	}
+	assert.NoError(t, c.objectCtx.Err())
+	c.recordFailed(&failedImage{id: 2, line: "busybox", err: failed})
+	assert.ErrorIs(t, context.Cause(c.objectCtx), ErrAborted)
+	assert.Len(t, c.failedImageList, 4)
+
+	// The images canceled after the run aborted are not recorded.
+	c.recordFailed(&failedImage{id: 3, line: "mysql", err: context.Canceled})
+	c.recordFailed(&failedImage{
+		id: 4, line: "redis", err: fmt.Errorf("copy: %w", context.Cause(c.objectCtx)),
+	})
+	assert.Len(t, c.failedImageList, 4)
+	assert.Len(t, c.failedImageCount, 2)
+}
@@ -1,10 +1,19 @@
 package hangar
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net"
+	"strings"
 
 	"github.com/cnrancher/hangar/pkg/destination"
+	"github.com/cnrancher/hangar/pkg/logging"
 	"github.com/cnrancher/hangar/pkg/source"
+	"github.com/cnrancher/hangar/pkg/utils"
+	"github.com/containers/image/v5/docker"
+	"github.com/docker/distribution/registry/api/errcode"
+	"github.com/sirupsen/logrus"
 )
 
 type Error struct {
@@ -38,3 +47,170 @@ func (e *Error) Error() string {
 		e.destination.ReferenceNameWithoutTransport(),
 		e.e)
 }
+
+func (e *Error) Unwrap() error {
+	return e.e
+}
+
+// logFields returns the structured log fields of the image of the error.
+func logFields(err error) logrus.Fields {
+	var e *Error
+	if !errors.As(err, &e) {
+		return logrus.Fields{}
+	}
+	fields := logrus.Fields{logging.FieldImageID: e.id}
+	if e.source != nil {
+		fields[logging.FieldSource] = e.source.ReferenceNameWithoutTransport()
+	}
+	if e.destination != nil {
+		fields[logging.FieldDestination] = e.destination.ReferenceNameWithoutTransport()
+	}
+	return fields
+}
+
+// Category returns the category of the error.
+func (e *Error) Category() ErrorCategory {
+	return ClassifyError(e.e)
+}
+
+var (
+	// ErrImageNotFound is the error when the image does not exist in the
+	// destination registry or the archive.
+	ErrImageNotFound = errors.New("image not found")
+	// ErrDigestMismatch is the error when the image digests of the source
+	// and destination are mismatched.
+	ErrDigestMismatch = errors.New("digest mismatch")
+)
+
+// ErrorCategory is the category of the image copy error.
+type ErrorCategory string
+
+const (
+	// ErrorCategoryAuth is the authentication or authorization denied error.
+	ErrorCategoryAuth ErrorCategory = "auth"
+	// ErrorCategoryNotFound is the manifest (image) unknown error.
+	ErrorCategoryNotFound            ErrorCategory = "not-found"
+	ErrorCategoryRateLimited         ErrorCategory = "rate-limited"
+	ErrorCategoryTimeout             ErrorCategory = "timeout"
+	ErrorCategoryCanceled            ErrorCategory = "canceled"
+	ErrorCategoryManifestUnsupported ErrorCategory = "manifest-unsupported"
+	ErrorCategoryDigestMismatch      ErrorCategory = "digest-mismatch"
+	// ErrorCategoryPlatformMissing is the error when no platform image
+	// matches the arch/os filter.
+	ErrorCategoryPlatformMissing ErrorCategory = "platform-missing"
+	// ErrorCategoryQuota is the storage quota exceeded error of the
+	// destination registry.
+	ErrorCategoryQuota   ErrorCategory = "quota"
+	ErrorCategoryNetwork ErrorCategory = "network"
+	ErrorCategoryUnknown ErrorCategory = "unknown"
+)
+
+// ErrorCategories is the list of the available error categories.
+var ErrorCategories = []ErrorCategory{
+	ErrorCategoryAuth,
+	ErrorCategoryNotFound,
+	ErrorCategoryRateLimited,
+	ErrorCategoryTimeout,
+	ErrorCategoryCanceled,
+	ErrorCategoryManifestUnsupported,
+	ErrorCategoryDigestMismatch,
+	ErrorCategoryPlatformMissing,
+	ErrorCategoryQuota,
+	ErrorCategoryNetwork,
+	ErrorCategoryUnknown,
+}
+
+// ParseErrorCategory parses the error category string.
+func ParseErrorCategory(s string) (ErrorCategory, error) {
+	for _, c := range ErrorCategories {
+		if string(c) == strings.ToLower(strings.TrimSpace(s)) {
+			return c, nil
+		}
+	}
+	available := make([]string, 0, len(ErrorCategories))
+	for _, c := range ErrorCategories {
+		available = append(available, string(c))
+	}
+	return "", fmt.Errorf("invalid error category %q, available: %v",
+		s, strings.Join(available, ", "))
+}
+
+// ClassifyError returns the category of the error, returns empty string
+// if the error is nil.
+func ClassifyError(err error) ErrorCategory {
+	if err == nil {
+		return ""
+	}
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrorCategoryTimeout
+	case errors.Is(err, context.Canceled):
+		return ErrorCategoryCanceled
+	case errors.Is(err, utils.ErrNoAvailableImage):
+		return ErrorCategoryPlatformMissing
+	case errors.Is(err, ErrImageNotFound):
+		return ErrorCategoryNotFound
+	case errors.Is(err, ErrDigestMismatch):
+		return ErrorCategoryDigestMismatch
+	case errors.Is(err, docker.ErrTooManyRequests):
+		return ErrorCategoryRateLimited
+	case errors.As(err, &docker.ErrUnauthorizedForCredentials{}):
+		return ErrorCategoryAuth
+	}
+	// The quota exceeded error of Harbor is the DENIED error code.
+	s := strings.ToLower(err.Error())
+	if strings.Contains(s, "quota") ||
+		strings.Contains(s, "insufficient storage") ||
+		strings.Contains(s, "exceed the configured upper limit") {
+		return ErrorCategoryQuota
+	}
+	var ec errcode.Error
+	if errors.As(err, &ec) {
+		switch ec.Code.String() {
+		case "UNAUTHORIZED", "DENIED":
+			return ErrorCategoryAuth
+		case "MANIFEST_UNKNOWN", "NAME_UNKNOWN", "BLOB_UNKNOWN":
+			return ErrorCategoryNotFound
+		case "TOOMANYREQUESTS":
+			return ErrorCategoryRateLimited
+		}
+	}
+	var ne net.Error
+	if errors.As(err, &ne) {
+		if ne.Timeout() {
+			return ErrorCategoryTimeout
+		}
+		return ErrorCategoryNetwork
+	}
+
+	// Some errors of the registry client are not typed.
+	switch {
+	case strings.Contains(s, "too many requests"),
+		strings.Contains(s, "toomanyrequests"),
+		strings.Contains(s, "rate limit"):
+		return ErrorCategoryRateLimited
+	case strings.Contains(s, "digest did not match"),
+		strings.Contains(s, "digest mismatch"),
+		strings.Contains(s, "does not match digest"):
+		return ErrorCategoryDigestMismatch
+	case strings.Contains(s, "unauthorized"),
+		strings.Contains(s, "authentication required"),
+		strings.Contains(s, "denied"):
+		return ErrorCategoryAuth
+	case strings.Contains(s, "manifest unknown"),
+		strings.Contains(s, "not found"),
+		strings.Contains(s, "not exists"):
+		return ErrorCategoryNotFound
+	case strings.Contains(s, "unsupported mime type"),
+		strings.Contains(s, "unsupported manifest"),
+		strings.Contains(s, "unknown media type"):
+		return ErrorCategoryManifestUnsupported
+	case strings.Contains(s, "timeout"):
+		return ErrorCategoryTimeout
+	case strings.Contains(s, "connection refused"),
+		strings.Contains(s, "no such host"),
+		strings.Contains(s, "connection reset"):
+		return ErrorCategoryNetwork
+	}
+	return ErrorCategoryUnknown
+}
@@ -0,0 +1,468 @@
+package hangar
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/cnrancher/hangar/pkg/hangar/archive"
+	"github.com/cnrancher/hangar/pkg/logging"
+	"github.com/cnrancher/hangar/pkg/rancher/kdmimages"
+	"github.com/cnrancher/hangar/pkg/utils"
+	imagecopy "github.com/containers/image/v5/copy"
+	dockerarchive "github.com/containers/image/v5/docker/archive"
+	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/oci/layout"
+	"github.com/containers/image/v5/signature"
+	"github.com/klauspost/compress/zstd"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// ExportFormatRKE2Airgap is the multi-image docker-archive tarball format
+	// consumed by RKE2/K3s from the agent/images directory.
+	ExportFormatRKE2Airgap = "rke2-airgap"
+
+	CompressionZstd = "zstd"
+	CompressionNone = "none"
+)
+
+// Exporter exports images of the Hangar archive file to other formats.
+type Exporter struct {
+	*common
+
+	// ar is the archive reader.
+	ar *archive.Reader
+	// index is the archive index.
+	index *archive.Index
+	// indexImageSet is map[image name]*archive.Image .
+	indexImageSet map[string]*archive.Image
+	// layerManager manages the layers
+	layerManager *layerManager
+
+	// Format is the export format, only rke2-airgap is supported.
+	Format string
+	// Distribution is the Kubernetes distribution (rke2 or k3s) used for
+	// the tarball file name (optional).
+	Distribution string
+	// Compression of the tarball, available values are zstd (default)
+	// and none.
+	Compression string
+	// OutputDir is the directory to write the tarballs.
+	OutputDir string
+	// ArchiveName is the archive file name to be exported.
+	ArchiveName string
+}
+
+// exportObject is the archive image selected by the image list.
+type exportObject struct {
+	image *archive.Image
+	// id is the id of the image list line or the structured entry
+	id int
+	// line is the image list line or the structured entry source
+	line string
+}
+
+type ExporterOpts struct {
+	CommonOpts
+
+	// Format is the export format, only rke2-airgap is supported.
+	Format string
+	// Distribution is the Kubernetes distribution (rke2 or k3s) used for
+	// the tarball file name (optional).
+	Distribution string
+	// Compression of the tarball, available values are zstd (default)
+	// and none.
+	Compression string
+	// OutputDir is the directory to write the tarballs.
+	OutputDir string
+	// ArchiveName is the archive file name to be exported.
+	ArchiveName string
+}
+
+func NewExporter(o *ExporterOpts) (*Exporter, error) {
+	e := &Exporter{
+		index:         archive.NewIndex(),
+		indexImageSet: make(map[string]*archive.Image),
+
+		Format:       o.Format,
+		Distribution: o.Distribution,
+		Compression:  o.Compression,
+		OutputDir:    o.OutputDir,
+		ArchiveName:  o.ArchiveName,
+	}
+	if e.Format == "" {
+		e.Format = ExportFormatRKE2Airgap
+	}
+	if e.Format != ExportFormatRKE2Airgap {
+		return nil, fmt.Errorf("unsupported export format %q", e.Format)
+	}
+	switch e.Compression {
+	case "":
+		e.Compression = CompressionZstd
+	case CompressionZstd, CompressionNone:
+	default:
+		return nil, fmt.Errorf("unsupported compression %q", e.Compression)
+	}
+	switch e.Distribution {
+	case "", kdmimages.RKE2, kdmimages.K3S:
+	default:
+		return nil, fmt.Errorf("unsupported distribution %q", e.Distribution)
+	}
+	if e.OutputDir == "" {
+		e.OutputDir = "."
+	}
+
+	var err error
+	e.common, err = newCommon(&o.CommonOpts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create common: %w", err)
+	}
+	e.ar, err = archive.NewReader(e.ArchiveName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create archive reader: %w", err)
+	}
+	b, err := e.ar.Index()
+	if err != nil {
+		return nil, fmt.Errorf("ar.Index: %w", err)
+	}
+	if err = e.index.Unmarshal(b); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal index data: %w", err)
+	}
+	if len(e.index.List) == 0 {
+		logrus.Warnf("No images in %q", o.ArchiveName)
+	}
+	for _, img := range e.index.List {
+		if img.Tag != "" {
+			e.indexImageSet[img.Source+":"+img.Tag] = img
+		}
+		if img.Digest != "" {
+			e.indexImageSet[img.Source+"@"+img.Digest.String()] = img
+		}
+	}
+	e.layerManager, err = newLayerManager(e.index)
+	if err != nil {
+		return nil, fmt.Errorf("failed to init layer manager: %w", err)
+	}
+	return e, nil
+}
+
+// Filename returns the tarball file name of the arch, example:
+// rke2-images.linux-amd64.tar.zst
+func (e *Exporter) Filename(arch string) string {
+	var name string
+	switch e.Distribution {
+	case kdmimages.RKE2:
+		name = fmt.Sprintf("rke2-images.linux-%s.tar", arch)
+	case kdmimages.K3S:
+		name = fmt.Sprintf("k3s-airgap-images-%s.tar", arch)
+	default:
+		name = fmt.Sprintf("hangar-images.linux-%s.tar", arch)
+	}
+	if e.Compression == CompressionZstd {
+		name += ".zst"
+	}
+	return name
+}
+
+// selectImages returns the archive images of the image list, all images of
+// the archive will be selected if the image list not provided.
+func (e *Exporter) selectImages() []*exportObject {
+	if len(e.images) == 0 && len(e.entries) == 0 {
+		objects := make([]*exportObject, 0, len(e.index.List))
+		for i, img := range e.index.List {
+			objects = append(objects, &exportObject{
+				image: img,
+				id:    i + 1,
+				line:  img.Reference(),
+			})
+		}
+		return objects
+	}
+
+	var (
+		objects  []*exportObject
+		selected = map[*archive.Image]bool{}
+	)
+	selectImage := func(id int, line string) {
+		name := fmt.Sprintf("%s/%s/%s:%s",
+			utils.GetRegistryName(line), utils.GetProjectName(line),
+			utils.GetImageName(line), utils.GetImageTag(line))
+		if dig := utils.GetImageDigest(line); dig != "" {
+			name = fmt.Sprintf("%s/%s/%s@%s",
+				utils.GetRegistryName(line), utils.GetProjectName(line),
+				utils.GetImageName(line), dig)
+		}
+		img, ok := e.indexImageSet[name]
+		if !ok {
+			logrus.WithFields(logrus.Fields{logging.FieldImageID: id}).
+				Errorf("Image [%v] not exists in archive", name)
+			e.recordFailedImage(id, line,
+				fmt.Errorf("image [%v] not exists in archive", name))
+			return
+		}
+		if selected[img] {
+			return
+		}
+		selected[img] = true
+		objects = append(objects, &exportObject{image: img, id: id, line: line})
+	}
+	for i, line := range e.images {
+		selectImage(i+1, line)
+	}
+	for i, entry := range e.entries {
+		if entry.Skip {
+			logrus.Infof("Skip image %q", entry.Source)
+			continue
+		}
+		selectImage(i+1, entry.Source)
+	}
+	return objects
+}
+
+// arches returns the arch list to export, defaults to all the linux arches
+// of the images if the arch list not provided.
+func (e *Exporter) arches(objects []*exportObject) []string {
+	var arches []string
+	for arch := range e.imageSpecSet["arch"] {
+		arches = append(arches, arch)
+	}
+	if len(arches) == 0 {
+		set := map[string]bool{}
+		for _, obj := range objects {
+			for _, spec := range obj.image.Images {
+				if spec.OS == "linux" && !set[spec.Arch] {
+					set[spec.Arch] = true
+					arches = append(arches, spec.Arch)
+				}
+			}
+		}
+	}
+	sort.Strings(arches)
+	return arches
+}
+
+// imageSpec returns the linux image of the arch, returns nil if not found.
+func (e *Exporter) imageSpec(img *archive.Image, arch string) *archive.ImageSpec {
+	variants := e.imageSpecSet["variant"]
+	for i := range img.Images {
+		spec := &img.Images[i]
+		if spec.OS != "linux" || spec.Arch != arch || spec.Digest == "" {
+			continue
+		}
+		if len(variants) > 0 && spec.Variant != "" && !variants[spec.Variant] {
+			continue
+		}
+		return spec
+	}
+	return nil
+}
+
+// Run exports the images of the archive to the tarballs of each arch.
+func (e *Exporter) Run(ctx context.Context) error {
+	defer func() {
+		e.layerManager.cleanAll()
+		if err := e.ar.Close(); err != nil {
+			logrus.Errorf("failed to close archive reader: %v", err)
+		}
+	}()
+
+	objects := e.selectImages()
+	if err := os.MkdirAll(e.OutputDir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	for _, arch := range e.arches(objects) {
+		if err := e.export(ctx, arch, objects); err != nil {
+			return fmt.Errorf("failed to export %v images: %w", arch, err)
+		}
+	}
+	if v := e.failedImages(); len(v) != 0 {
+		logrus.Errorf("Export failed image list: \n%v", strings.Join(v, "\n"))
+		return e.failedError(ErrCopyFailed)
+	}
+	return nil
+}
+
+// export writes the images of the arch into the docker-archive tarball.
+func (e *Exporter) export(
+	ctx context.Context, arch string, objects []*exportObject,
+) error {
+	tarball := filepath.Join(e.OutputDir, e.Filename(arch))
+	tarball = strings.TrimSuffix(tarball, ".zst")
+	// docker-archive doesn't support modifying the existing tarball.
+	if err := os.RemoveAll(tarball); err != nil {
+		return fmt.Errorf("failed to remove %q: %w", tarball, err)
+	}
+	writer, err := dockerarchive.NewWriter(e.systemContext, tarball)
+	if err != nil {
+		return fmt.Errorf("failed to create docker-archive writer: %w", err)
+	}
+	policyContext, err := signature.NewPolicyContext(e.policy)
+	if err != nil {
+		writer.Close()
+		return fmt.Errorf("failed to create policy context: %w", err)
+	}
+	defer policyContext.Destroy()
+
+	var exported int
+	for _, obj := range objects {
+		if err = ctx.Err(); err != nil {
+			break
+		}
+		img := obj.image
+		spec := e.imageSpec(img, arch)
+		if spec == nil {
+			logrus.WithFields(logrus.Fields{logging.FieldImageID: obj.id}).
+				Warnf("Skip [%v]: no [%v] image", img.Reference(), arch)
+			continue
+		}
+		logrus.WithFields(logrus.Fields{logging.FieldImageID: obj.id}).
+			Infof("Exporting [%v] [%v]", img.Reference(), arch)
+		if err = e.exportImage(ctx, policyContext, writer, img, spec); err != nil {
+			logrus.WithFields(logrus.Fields{logging.FieldImageID: obj.id}).
+				Errorf("failed to export [%v] [%v]: %v", img.Reference(), arch, err)
+			e.recordFailedImage(obj.id, obj.line, err)
+			continue
+		}
+		exported++
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to close docker-archive writer: %w", err)
+	}
+	if ctx.Err() != nil {
+		os.RemoveAll(tarball)
+		return ctx.Err()
+	}
+	if exported == 0 {
+		logrus.Warnf("No [%v] images exported", arch)
+		return os.RemoveAll(tarball)
+	}
+	if e.Compression == CompressionZstd {
+		if err := compressZstd(tarball, tarball+".zst"); err != nil {
+			return err
+		}
+		if err := os.Remove(tarball); err != nil {
+			return fmt.Errorf("failed to remove %q: %w", tarball, err)
+		}
+		tarball += ".zst"
+	}
+	logrus.Infof("Exported %d [%v] images to %q", exported, arch, tarball)
+	return nil
+}
+
+// exportImage copies the single platform image from archive to the
+// docker-archive writer.
+func (e *Exporter) exportImage(
+	ctx context.Context,
+	policyContext *signature.PolicyContext,
+	writer *dockerarchive.Writer,
+	img *archive.Image,
+	spec *archive.ImageSpec,
+) error {
+	tmpDir, err := e.ar.DecompressImageTmp(spec, nil)
+	defer func() {
+		if tmpDir != "" {
+			os.RemoveAll(tmpDir)
+		}
+		e.layerManager.clean(spec)
+	}()
+	if err != nil {
+		return fmt.Errorf("failed to decompress image: %w", err)
+	}
+	if err = e.layerManager.decompressLayer(spec, e.ar); err != nil {
+		return err
+	}
+
+	// The digest-only image will be exported without the image name.
+	var named reference.NamedTagged
+	if img.Tag != "" {
+		n, err := reference.ParseNormalizedNamed(img.Source)
+		if err != nil {
+			return fmt.Errorf("failed to parse %q: %w", img.Source, err)
+		}
+		if named, err = reference.WithTag(n, img.Tag); err != nil {
+			return fmt.Errorf("failed to parse %q: %w", img.Reference(), err)
+		}
+	}
+	destRef, err := writer.NewReference(named)
+	if err != nil {
+		return fmt.Errorf("failed to create docker-archive reference: %w", err)
+	}
+	srcRef, err := layout.NewReference(tmpDir, "")
+	if err != nil {
+		return fmt.Errorf("failed to create oci reference: %w", err)
+	}
+	_, err = imagecopy.Image(ctx, policyContext, destRef, srcRef, &imagecopy.Options{
+		SourceCtx: utils.SystemContextWithSharedBlobDir(
+			e.systemContext, e.layerManager.sharedBlobDir()),
+		DestinationCtx: e.systemContext,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to copy image: %w", err)
+	}
+	return nil
+}
+
+// Validate checks the images of the image list exist in the archive.
+func (e *Exporter) Validate(ctx context.Context) error {
+	defer func() {
+		e.layerManager.cleanAll()
+		if err := e.ar.Close(); err != nil {
+			logrus.Errorf("failed to close archive reader: %v", err)
+		}
+	}()
+
+	objects := e.selectImages()
+	for _, arch := range e.arches(objects) {
+		for _, obj := range objects {
+			if e.imageSpec(obj.image, arch) == nil {
+				logrus.WithFields(logrus.Fields{logging.FieldImageID: obj.id}).
+					Warnf("Image [%v] has no [%v] image", obj.image.Reference(), arch)
+				continue
+			}
+			logrus.WithFields(logrus.Fields{logging.FieldImageID: obj.id}).
+				Infof("PASS: [%v] [%v]", obj.image.Reference(), arch)
+		}
+	}
+	if v := e.failedImages(); len(v) != 0 {
+		logrus.Errorf("Validate failed image list: \n%v", strings.Join(v, "\n"))
+		return e.failedError(ErrValidateFailed)
+	}
+	return nil
+}
+
+// compressZstd compresses the src file into the zstd compressed dest file.
+func compressZstd(src, dest string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to open %q: %w", src, err)
+	}
+	defer in.Close()
+	out, err := os.Create(dest)
+	if err != nil {
+		return fmt.Errorf("failed to create %q: %w", dest, err)
+	}
+
+	w, err := zstd.NewWriter(out)
+	if err != nil {
+		out.Close()
+		return fmt.Errorf("failed to create zstd writer: %w", err)
+	}
+	if _, err = io.Copy(w, in); err != nil {
+		w.Close()
+		out.Close()
+		return fmt.Errorf("failed to compress %q: %w", src, err)
+	}
+	if err = w.Close(); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to compress %q: %w", src, err)
+	}
+	if err = out.Close(); err != nil {
+		return fmt.Errorf("failed to write %q: %w", dest, err)
+	}
+	return nil
+}
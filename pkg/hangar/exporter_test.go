@@ -0,0 +1,289 @@
+package hangar
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cnrancher/hangar/pkg/hangar/archive"
+	"github.com/containers/image/v5/signature"
+	"github.com/klauspost/compress/zstd"
+	"github.com/opencontainers/go-digest"
+	"github.com/opencontainers/image-spec/specs-go"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+// writeFixtureBlob writes the blob into the shared blob directory of the
+// archive fixture and returns its descriptor.
+func writeFixtureBlob(dir, mediaType string, v any) (imgspecv1.Descriptor, error) {
+	b, ok := v.([]byte)
+	if !ok {
+		var err error
+		if b, err = json.Marshal(v); err != nil {
+			return imgspecv1.Descriptor{}, err
+		}
+	}
+	d := digest.FromBytes(b)
+	name := filepath.Join(dir, archive.SharedBlobDir, "sha256", d.Encoded())
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return imgspecv1.Descriptor{}, err
+	}
+	if err := os.WriteFile(name, b, 0644); err != nil {
+		return imgspecv1.Descriptor{}, err
+	}
+	return imgspecv1.Descriptor{
+		MediaType: mediaType,
+		Digest:    d,
+		Size:      int64(len(b)),
+	}, nil
+}
+
+// writeFixtureImage writes the single layer linux image of the arch into
+// the archive fixture directory in the OCI layout of its digest, the layer
+// contains the file of the image source name.
+func writeFixtureImage(dir, source, arch string) (archive.ImageSpec, error) {
+	layer := &bytes.Buffer{}
+	tw := tar.NewWriter(layer)
+	tw.WriteHeader(&tar.Header{Name: arch, Mode: 0644, Size: int64(len(source))})
+	tw.Write([]byte(source))
+	tw.Close()
+	layerDesc, err := writeFixtureBlob(dir, imgspecv1.MediaTypeImageLayer, layer.Bytes())
+	if err != nil {
+		return archive.ImageSpec{}, err
+	}
+	configDesc, err := writeFixtureBlob(dir, imgspecv1.MediaTypeImageConfig, imgspecv1.Image{
+		Platform: imgspecv1.Platform{Architecture: arch, OS: "linux"},
+		RootFS: imgspecv1.RootFS{
+			Type:    "layers",
+			DiffIDs: []digest.Digest{layerDesc.Digest},
+		},
+	})
+	if err != nil {
+		return archive.ImageSpec{}, err
+	}
+	manifestDesc, err := writeFixtureBlob(dir, imgspecv1.MediaTypeImageManifest, imgspecv1.Manifest{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: imgspecv1.MediaTypeImageManifest,
+		Config:    configDesc,
+		Layers:    []imgspecv1.Descriptor{layerDesc},
+	})
+	if err != nil {
+		return archive.ImageSpec{}, err
+	}
+	index, err := json.Marshal(imgspecv1.Index{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		Manifests: []imgspecv1.Descriptor{manifestDesc},
+	})
+	if err != nil {
+		return archive.ImageSpec{}, err
+	}
+	layout := filepath.Join(dir, manifestDesc.Digest.Encoded())
+	files := map[string][]byte{
+		imgspecv1.ImageLayoutFile: []byte(`{"imageLayoutVersion":"1.0.0"}`),
+		"index.json":              index,
+	}
+	if err := os.MkdirAll(layout, 0755); err != nil {
+		return archive.ImageSpec{}, err
+	}
+	for name, b := range files {
+		if err := os.WriteFile(filepath.Join(layout, name), b, 0644); err != nil {
+			return archive.ImageSpec{}, err
+		}
+	}
+	return archive.ImageSpec{
+		Arch:      arch,
+		OS:        "linux",
+		MediaType: imgspecv1.MediaTypeImageManifest,
+		Layers:    []digest.Digest{layerDesc.Digest},
+		Config:    configDesc.Digest,
+		Digest:    manifestDesc.Digest,
+	}, nil
+}
+
+// writeFixtureArchive writes the Hangar archive of the images
+// with their arch list.
+func writeFixtureArchive(name, dir string, images []*archive.Image) error {
+	index := archive.NewIndex()
+	for _, img := range images {
+		for _, arch := range img.ArchList {
+			spec, err := writeFixtureImage(dir, img.Source, arch)
+			if err != nil {
+				return err
+			}
+			img.Images = append(img.Images, spec)
+		}
+		index.Append(img)
+	}
+	w, err := archive.NewWriter(name)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	if err := w.Write(dir); err != nil {
+		return err
+	}
+	if err := w.WriteIndex(index); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+// readTarballRepoTags returns the RepoTags of the docker-archive
+// manifest.json in the zstd compressed tarball.
+func readTarballRepoTags(name string) ([][]string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	zr, err := zstd.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	tr := tar.NewReader(zr)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("manifest.json not found in %q", name)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if h.Name != "manifest.json" {
+			continue
+		}
+		var items []struct {
+			RepoTags []string `json:"RepoTags"`
+		}
+		if err := json.NewDecoder(tr).Decode(&items); err != nil {
+			return nil, err
+		}
+		var tags [][]string
+		for _, item := range items {
+			tags = append(tags, item.RepoTags)
+		}
+		return tags, nil
+	}
+}
+
+func Test_Exporter(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "images.zip")
+	err := writeFixtureArchive(name, t.TempDir(), []*archive.Image{
+		{
+			Source:   "docker.io/library/nginx",
+			Tag:      "latest",
+			ArchList: []string{"amd64", "arm64"},
+			OsList:   []string{"linux"},
+		},
+		{
+			Source:   "docker.io/library/busybox",
+			Tag:      "latest",
+			ArchList: []string{"amd64"},
+			OsList:   []string{"linux"},
+		},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	output := t.TempDir()
+	e, err := NewExporter(&ExporterOpts{
+		CommonOpts: CommonOpts{
+			Workers: 1,
+			Policy: &signature.Policy{
+				Default: []signature.PolicyRequirement{
+					signature.NewPRInsecureAcceptAnything(),
+				},
+				Transports: map[string]signature.PolicyTransportScopes{},
+			},
+		},
+		Distribution: "rke2",
+		OutputDir:    output,
+		ArchiveName:  name,
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	if !assert.NoError(t, e.Run(context.Background())) {
+		return
+	}
+
+	entries, err := os.ReadDir(output)
+	assert.NoError(t, err)
+	var files []string
+	for _, entry := range entries {
+		files = append(files, entry.Name())
+	}
+	assert.Equal(t, []string{
+		"rke2-images.linux-amd64.tar.zst",
+		"rke2-images.linux-arm64.tar.zst",
+	}, files)
+
+	tags, err := readTarballRepoTags(filepath.Join(output, "rke2-images.linux-amd64.tar.zst"))
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"docker.io/library/nginx:latest"},
+		{"docker.io/library/busybox:latest"},
+	}, tags)
+	tags, err = readTarballRepoTags(filepath.Join(output, "rke2-images.linux-arm64.tar.zst"))
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"docker.io/library/nginx:latest"},
+	}, tags)
+}
+
+func Test_ExporterFilename(t *testing.T) {
+	e := &Exporter{Compression: CompressionZstd}
+	assert.Equal(t, "hangar-images.linux-amd64.tar.zst", e.Filename("amd64"))
+	e.Distribution = "k3s"
+	e.Compression = CompressionNone
+	assert.Equal(t, "k3s-airgap-images-arm64.tar", e.Filename("arm64"))
+}
+
+func Test_ExporterSelectImages(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "images.zip")
+	err := writeFixtureArchive(name, t.TempDir(), []*archive.Image{
+		{
+			Source:   "docker.io/library/nginx",
+			Tag:      "latest",
+			ArchList: []string{"amd64"},
+			OsList:   []string{"linux"},
+		},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	e, err := NewExporter(&ExporterOpts{
+		CommonOpts: CommonOpts{
+			Images: []string{"redis:7", "nginx", "docker.io/library/nginx:latest"},
+			Policy: &signature.Policy{
+				Default: []signature.PolicyRequirement{
+					signature.NewPRInsecureAcceptAnything(),
+				},
+				Transports: map[string]signature.PolicyTransportScopes{},
+			},
+		},
+		ArchiveName: name,
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer e.ar.Close()
+
+	// The objects keep the id and the line of the image list.
+	objects := e.selectImages()
+	if !assert.Len(t, objects, 1) {
+		return
+	}
+	assert.Equal(t, 2, objects[0].id)
+	assert.Equal(t, "nginx", objects[0].line)
+	assert.Equal(t, []string{"redis:7"}, e.failedImages())
+}
@@ -0,0 +1,57 @@
+package hangar
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrAborted is the error when the run was aborted by the failure policy.
+var ErrAborted = errors.New("aborted by the failure policy")
+
+// FailurePolicy decides how to handle the failed images by the error
+// category, all failed images are recorded as failed by default.
+type FailurePolicy struct {
+	// Warn is the error categories treated as warning, the images failed
+	// with these errors are skipped instead of recorded as failed.
+	Warn []ErrorCategory
+	// Abort is the error categories to abort the whole run on the first
+	// error of these categories.
+	Abort []ErrorCategory
+	// MaxErrors aborts the whole run after the number of images failed,
+	// the image failed to copy to multiple destinations is counted once,
+	// 0 for unlimited.
+	MaxErrors int
+}
+
+// isWarning checks whether the error should be treated as warning.
+func (p *FailurePolicy) isWarning(err error) bool {
+	if p == nil || err == nil {
+		return false
+	}
+	category := ClassifyError(err)
+	for _, c := range p.Warn {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
+
+// abortError returns the abort error if the run should be aborted after
+// the image failed with the error, failed is the number of failed images.
+func (p *FailurePolicy) abortError(err error, failed int) error {
+	if p == nil || err == nil {
+		return nil
+	}
+	category := ClassifyError(err)
+	for _, c := range p.Abort {
+		if c == category {
+			return fmt.Errorf("%w: %s error occurred: %w",
+				ErrAborted, category, err)
+		}
+	}
+	if p.MaxErrors > 0 && failed >= p.MaxErrors {
+		return fmt.Errorf("%w: %d images failed", ErrAborted, failed)
+	}
+	return nil
+}
@@ -14,4 +14,5 @@ type Hangar interface {
 	Run(ctx context.Context) error
 	Validate(ctx context.Context) error
 	SaveFailedImages() error
+	SaveReport() error
 }
@@ -1,6 +1,12 @@
 package imagelist
 
-import "strings"
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cnrancher/hangar/pkg/utils"
+	"github.com/opencontainers/go-digest"
+)
 
 type ListType string
 
@@ -15,14 +21,19 @@ const (
 	// Example:
 	//  docker.io/library/mysql docker.io/username/mirrored-mysql latest
 	//  quay.io/skopeo/stable docker.io/username/mirrored-skopeo-stable 1.22
+	//  docker.io/library/nginx docker.io/username/nginx 1.25@sha256:abcdef...
+	//  docker.io/library/nginx docker.io/username/nginx sha256:abcdef...
 	TypeMirror ListType = "mirror"
 
 	// TypeDefault:
 	//
 	//  [REGISTRY]/[PROJECT]/[NAME]:[TAG]
+	//  [REGISTRY]/[PROJECT]/[NAME]@[DIGEST]
+	//  [REGISTRY]/[PROJECT]/[NAME]:[TAG]@[DIGEST]
 	//
 	// Example:
 	//  docker.io/library/nginx:1.22
+	//  docker.io/library/nginx@sha256:abcdef...
 	TypeDefault ListType = "default"
 )
 
@@ -35,6 +46,28 @@ func GetMirrorSpec(line string) ([]string, bool) {
 	return getMirrorSpec(line)
 }
 
+// GetTagDigest splits the [TAG] field of the mirror format line
+// into tag and digest, example:
+//
+//	1.25 -> "1.25", ""
+//	1.25@sha256:abcdef... -> "1.25", "sha256:abcdef..."
+//	sha256:abcdef... -> "", "sha256:abcdef..."
+func GetTagDigest(s string) (string, digest.Digest, error) {
+	if strings.Contains(s, "@") {
+		return utils.SplitImageDigest(s)
+	}
+	s = strings.TrimSpace(s)
+	if !strings.Contains(s, ":") {
+		return s, "", nil
+	}
+	// The tag does not contain ':', so the value is the 'algo:encoded' digest.
+	d, err := digest.Parse(s)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid digest %q: %w", s, err)
+	}
+	return "", d, nil
+}
+
 func IsDefaultFormat(line string) bool {
 	return isDefaultFormat(line)
 }
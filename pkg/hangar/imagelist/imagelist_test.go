@@ -74,6 +74,12 @@ func Test_Detect(t *testing.T) {
 	if !assert.Equal(imagelist.TypeDefault, imagelist.Detect("docker.io/library/nginx:1.22")) {
 		return
 	}
+	if !assert.Equal(imagelist.TypeDefault, imagelist.Detect("docker.io/library/nginx@sha256:abcd")) {
+		return
+	}
+	if !assert.Equal(imagelist.TypeDefault, imagelist.Detect("nginx:1.25@sha256:abcd")) {
+		return
+	}
 	if !assert.Equal(imagelist.TypeMirror, imagelist.Detect("a b c")) {
 		return
 	}
@@ -101,3 +107,25 @@ func Test_GetMirrorSpec(t *testing.T) {
 	assert.Equal("b", spec[1])
 	assert.Equal("c", spec[2])
 }
+
+func Test_GetTagDigest(t *testing.T) {
+	assert := assert.New(t)
+	const d = "sha256:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	tag, dig, err := imagelist.GetTagDigest("1.25")
+	assert.NoError(err)
+	assert.Equal("1.25", tag)
+	assert.Empty(dig)
+	tag, dig, err = imagelist.GetTagDigest("1.25@" + d)
+	assert.NoError(err)
+	assert.Equal("1.25", tag)
+	assert.Equal(d, dig.String())
+	tag, dig, err = imagelist.GetTagDigest(d)
+	assert.NoError(err)
+	assert.Equal("", tag)
+	assert.Equal(d, dig.String())
+	// The malformed digest is not regarded as tag.
+	_, _, err = imagelist.GetTagDigest("sha256:abcd")
+	assert.Error(err)
+	_, _, err = imagelist.GetTagDigest("1.25@sha256:abcd")
+	assert.Error(err)
+}
@@ -0,0 +1,117 @@
+package imagelist
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// TypeStructured is the YAML/JSON image list format with per-image options.
+	//
+	// Example:
+	//
+	//  images:
+	//  - source: docker.io/library/nginx:1.25
+	//    destinations:
+	//    - registry.example.io/library/nginx
+	//    tags: [ "1.25-stable" ]
+	//    arch: [ "amd64", "arm64" ]
+	//  - source: mcr.microsoft.com/windows/nanoserver:ltsc2022
+	//    os: [ "windows" ]
+	//    optional: true
+	TypeStructured ListType = "structured"
+
+	// StructuredHeader is the header line to identify the structured image
+	// list file if the file extension is not '.yaml', '.yml' or '.json'.
+	StructuredHeader = "# hangar-image-list"
+)
+
+// List is the structured image list.
+type List struct {
+	Images []*Entry `json:"images,omitempty" yaml:"images,omitempty"`
+}
+
+// Entry is the image entry of the structured image list.
+type Entry struct {
+	// Source is the source image reference, example:
+	// docker.io/library/nginx:1.25, docker.io/library/nginx@sha256:abcdef...
+	Source string `json:"source,omitempty" yaml:"source,omitempty"`
+	// Destination is the destination image name (without tag).
+	Destination string `json:"destination,omitempty" yaml:"destination,omitempty"`
+	// Destinations is the destination image names (without tag).
+	Destinations []string `json:"destinations,omitempty" yaml:"destinations,omitempty"`
+	// Tags is the additional tags of the destination image.
+	Tags []string `json:"tags,omitempty" yaml:"tags,omitempty"`
+	// Arch overrides the architecture list of this image.
+	Arch []string `json:"arch,omitempty" yaml:"arch,omitempty"`
+	// OS overrides the OS list of this image.
+	OS []string `json:"os,omitempty" yaml:"os,omitempty"`
+	// Variant overrides the variant list of this image.
+	Variant []string `json:"variant,omitempty" yaml:"variant,omitempty"`
+	// Optional image will not be recorded as failed if failed to copy.
+	Optional bool `json:"optional,omitempty" yaml:"optional,omitempty"`
+	// Skip this image.
+	Skip bool `json:"skip,omitempty" yaml:"skip,omitempty"`
+}
+
+// DestinationImages returns all the destination image names of the entry.
+func (e *Entry) DestinationImages() []string {
+	var names []string
+	if e.Destination != "" {
+		names = append(names, e.Destination)
+	}
+	for _, d := range e.Destinations {
+		if d == "" || d == e.Destination {
+			continue
+		}
+		names = append(names, d)
+	}
+	return names
+}
+
+// IsStructured checks whether the image list file is the structured format,
+// by the file extension name or the header of the file content.
+func IsStructured(name string, b []byte) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".yaml", ".yml", ".json":
+		return true
+	}
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	sc.Split(bufio.ScanLines)
+	for sc.Scan() {
+		l := strings.TrimSpace(sc.Text())
+		if l == "" {
+			continue
+		}
+		return strings.HasPrefix(l, StructuredHeader) ||
+			strings.HasPrefix(l, "{") ||
+			strings.HasPrefix(l, "images:")
+	}
+	return false
+}
+
+// ParseStructured parses the YAML/JSON structured image list.
+func ParseStructured(b []byte) (*List, error) {
+	list := &List{}
+	if err := yaml.UnmarshalStrict(b, list); err != nil {
+		return nil, fmt.Errorf("failed to parse image list: %w", err)
+	}
+	for i, e := range list.Images {
+		if e == nil {
+			return nil, fmt.Errorf("invalid image list: image %d is empty", i+1)
+		}
+		e.Source = strings.TrimSpace(e.Source)
+		if e.Source == "" {
+			return nil, fmt.Errorf("invalid image list: source of image %d not provided", i+1)
+		}
+		if !IsDefaultFormat(e.Source) {
+			return nil, fmt.Errorf("invalid image list: invalid source %q", e.Source)
+		}
+	}
+	return list, nil
+}
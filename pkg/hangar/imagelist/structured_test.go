@@ -0,0 +1,61 @@
+package imagelist_test
+
+import (
+	"testing"
+
+	"github.com/cnrancher/hangar/pkg/hangar/imagelist"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_IsStructured(t *testing.T) {
+	assert := assert.New(t)
+	assert.True(imagelist.IsStructured("list.yaml", nil))
+	assert.True(imagelist.IsStructured("list.JSON", nil))
+	assert.True(imagelist.IsStructured("list.txt", []byte("\n# hangar-image-list\nimages: []")))
+	assert.True(imagelist.IsStructured("list.txt", []byte("images:\n- source: nginx")))
+	assert.False(imagelist.IsStructured("list.txt", []byte("# comment\nnginx:latest")))
+	assert.False(imagelist.IsStructured("list.txt", nil))
+}
+
+func Test_ParseStructured(t *testing.T) {
+	assert := assert.New(t)
+	list, err := imagelist.ParseStructured([]byte(`
+images:
+- source: docker.io/library/nginx:1.25
+  destination: registry.example.io/library/nginx
+  destinations:
+  - registry.example.io/library/nginx
+  - registry.example.io/mirrored/nginx
+  tags: [ "stable" ]
+  arch: [ "amd64" ]
+- source: mcr.microsoft.com/windows/nanoserver:ltsc2022
+  os: [ "windows" ]
+  optional: true
+`))
+	if !assert.Nil(err) {
+		return
+	}
+	if !assert.Equal(2, len(list.Images)) {
+		return
+	}
+	assert.Equal([]string{
+		"registry.example.io/library/nginx",
+		"registry.example.io/mirrored/nginx",
+	}, list.Images[0].DestinationImages())
+	assert.Equal([]string{"stable"}, list.Images[0].Tags)
+	assert.Equal([]string{"amd64"}, list.Images[0].Arch)
+	assert.Equal([]string{"windows"}, list.Images[1].OS)
+	assert.True(list.Images[1].Optional)
+
+	list, err = imagelist.ParseStructured([]byte(
+		`{"images":[{"source":"nginx@sha256:abcd","skip":true}]}`))
+	if !assert.Nil(err) {
+		return
+	}
+	assert.True(list.Images[0].Skip)
+
+	_, err = imagelist.ParseStructured([]byte("images:\n- tags: [a]"))
+	assert.NotNil(err)
+	_, err = imagelist.ParseStructured([]byte("images:\n- source: nginx\n  unknown: a"))
+	assert.NotNil(err)
+}
@@ -12,14 +12,16 @@ import (
 	"github.com/cnrancher/hangar/pkg/destination"
 	"github.com/cnrancher/hangar/pkg/hangar/archive"
 	"github.com/cnrancher/hangar/pkg/hangar/imagelist"
-	"github.com/cnrancher/hangar/pkg/harbor"
+	"github.com/cnrancher/hangar/pkg/hangar/report"
+	"github.com/cnrancher/hangar/pkg/logging"
 	"github.com/cnrancher/hangar/pkg/manifest"
 	"github.com/cnrancher/hangar/pkg/source"
+	"github.com/cnrancher/hangar/pkg/tracing"
 	"github.com/cnrancher/hangar/pkg/types"
 	"github.com/cnrancher/hangar/pkg/utils"
-	"github.com/containers/image/v5/pkg/docker/config"
 	"github.com/opencontainers/go-digest"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // loadObject is the object sending to worker pool when loading image
@@ -27,6 +29,31 @@ type loadObject struct {
 	image   *archive.Image
 	timeout time.Duration
 	id      int
+	// line is the image list line of the image (optional)
+	line string
+
+	// imageSpecSet overrides the arch/os/variant set of the image (optional)
+	imageSpecSet map[string]map[string]bool
+	// optional image will not be recorded as failed
+	optional bool
+	// destination overrides the destination image name without tag (optional)
+	destination string
+	// tag overrides the destination image tag (optional)
+	tag string
+	// registry is the destination registry of the image
+	registry string
+}
+
+func (o *loadObject) registries() []string {
+	return []string{o.registry}
+}
+
+// failedLine returns the image list line recorded in failed image list.
+func (o *loadObject) failedLine() string {
+	if o.line != "" {
+		return o.line
+	}
+	return o.image.Reference()
 }
 
 // Loader loads images from hangar archive file to registry server.
@@ -52,12 +79,17 @@ type Loader struct {
 	DestinationRegistry string
 	// Specify the destination image project.
 	DestinationProject string
+	// DestinationType is the destination image type, available types are
+	// docker (default) and containerd.
+	DestinationType types.ImageType
 	// Directory is the source archive directory
 	Directory string
 	// SharedBlobDirPath is the directory to save the shared blobs
 	SharedBlobDirPath string
 	// ArchiveName is the archive file name to be load
 	ArchiveName string
+
+	ProjectOpts
 }
 
 type LoaderOpts struct {
@@ -71,12 +103,17 @@ type LoaderOpts struct {
 	DestinationRegistry string
 	// Specify the destination image project.
 	DestinationProject string
+	// DestinationType is the destination image type, available types are
+	// docker (default) and containerd.
+	DestinationType types.ImageType
 	// Directory is the source archive directory
 	Directory string
 	// SharedBlobDirPath is the directory to save the shared blobs
 	SharedBlobDirPath string
 	// ArchiveName is the archive file name to be load
 	ArchiveName string
+
+	ProjectOpts
 }
 
 func NewLoader(o *LoaderOpts) (*Loader, error) {
@@ -91,9 +128,14 @@ func NewLoader(o *LoaderOpts) (*Loader, error) {
 		SourceProject:       o.SourceProject,
 		DestinationRegistry: o.DestinationRegistry,
 		DestinationProject:  o.DestinationProject,
+		DestinationType:     o.DestinationType,
 		Directory:           o.Directory,
 		SharedBlobDirPath:   o.SharedBlobDirPath,
 		ArchiveName:         o.ArchiveName,
+		ProjectOpts:         o.ProjectOpts,
+	}
+	if l.DestinationType == types.TypeUndefined {
+		l.DestinationType = types.TypeDocker
 	}
 	if l.SharedBlobDirPath == "" {
 		l.SharedBlobDirPath = archive.SharedBlobDir
@@ -122,7 +164,13 @@ func NewLoader(o *LoaderOpts) (*Loader, error) {
 	for i := 0; i < len(l.index.List); i++ {
 		source := l.index.List[i].Source
 		tag := l.index.List[i].Tag
-		l.indexImageSet[source+":"+tag] = l.index.List[i]
+		dig := l.index.List[i].Digest
+		if tag != "" {
+			l.indexImageSet[source+":"+tag] = l.index.List[i]
+		}
+		if dig != "" {
+			l.indexImageSet[source+"@"+dig.String()] = l.index.List[i]
+		}
 	}
 	lm, err := newLayerManager(l.index)
 	if err != nil {
@@ -133,58 +181,216 @@ func NewLoader(o *LoaderOpts) (*Loader, error) {
 	return l, nil
 }
 
-func (l *Loader) copy(ctx context.Context) {
-	l.common.initErrorHandler(ctx)
-	l.common.initWorker(ctx, l.worker)
-	if len(l.common.images) > 0 {
-		// Load images according to image list specified by user.
-		for i, line := range l.common.images {
-			switch imagelist.Detect(line) {
-			case imagelist.TypeDefault:
-			default:
-				logrus.Warnf("Ignore image list line %q: invalid format", line)
-				continue
-			}
-			registry := utils.GetRegistryName(line)
-			if l.SourceRegistry != "" {
-				registry = l.SourceRegistry
-			}
-			project := utils.GetProjectName(line)
-			if l.SourceProject != "" {
-				project = l.SourceProject
-			}
-			name := utils.GetImageName(line)
-			tag := utils.GetImageTag(line)
-			imageName := fmt.Sprintf("%s/%s/%s:%s",
-				registry, project, name, tag)
-			image, ok := l.indexImageSet[imageName]
-			if !ok {
-				l.recordFailedImage(line)
-				l.handleError(
-					NewError(
-						i+1,
-						fmt.Errorf("image [%v] not exists in archive", imageName),
-						nil,
-						nil,
-					),
-				)
+// indexImageName returns the key of the image in indexImageSet.
+func (l *Loader) indexImageName(registry, project, line string) string {
+	name := utils.GetImageName(line)
+	if dig := utils.GetImageDigest(line); dig != "" {
+		return fmt.Sprintf("%s/%s/%s@%s", registry, project, name, dig)
+	}
+	return fmt.Sprintf("%s/%s/%s:%s",
+		registry, project, name, utils.GetImageTag(line))
+}
+
+// entryObjects returns the load objects of the structured image list
+// entries, the invalid entries are recorded as failed.
+func (l *Loader) entryObjects() []*loadObject {
+	var objects []*loadObject
+	for i, e := range l.common.entries {
+		if e.Skip {
+			logrus.Infof("Skip image %q", e.Source)
+			continue
+		}
+		entryObjects, err := l.loadObjectsImageListTypeStructured(e)
+		if err != nil {
+			l.reportFailure(i+1, e.Source, err, e.Optional)
+			if e.Optional {
+				logrus.Warnf("Skip optional image %q: %v", e.Source, err)
 				continue
 			}
-			object := &loadObject{
-				id:    i + 1,
-				image: image,
-			}
-			l.handleObject(object)
+			l.recordFailedImage(i+1, e.Source, err)
+			l.handleError(NewError(i+1, err, nil, nil))
+			continue
 		}
-	} else {
+		for _, object := range entryObjects {
+			object.id = i + 1
+			objects = append(objects, object)
+		}
+	}
+	return objects
+}
+
+// loadObjectsImageListTypeStructured returns the load objects of the
+// structured image list entry, one object for each destination & tag.
+func (l *Loader) loadObjectsImageListTypeStructured(
+	e *imagelist.Entry,
+) ([]*loadObject, error) {
+	registry := utils.GetRegistryName(e.Source)
+	if l.SourceRegistry != "" {
+		registry = l.SourceRegistry
+	}
+	project := utils.GetProjectName(e.Source)
+	if l.SourceProject != "" {
+		project = l.SourceProject
+	}
+	imageName := l.indexImageName(registry, project, e.Source)
+	image, ok := l.indexImageSet[imageName]
+	if !ok {
+		return nil, fmt.Errorf("image [%v] not exists in archive", imageName)
+	}
+	tags := []string{image.Tag}
+	for _, t := range e.Tags {
+		if t != "" && t != image.Tag {
+			tags = append(tags, t)
+		}
+	}
+	destImages := e.DestinationImages()
+	if len(destImages) == 0 {
+		destImages = []string{""}
+	}
+	var objects []*loadObject
+	for _, destImage := range destImages {
+		for _, tag := range tags {
+			objects = append(objects, &loadObject{
+				image:        image,
+				line:         e.Source,
+				imageSpecSet: l.common.entryImageSpecSet(e),
+				optional:     e.Optional,
+				destination:  destImage,
+				tag:          tag,
+			})
+		}
+	}
+	return objects, nil
+}
+
+// destinationRegistry returns the destination registry of the load object.
+func (l *Loader) destinationRegistry(obj *loadObject) string {
+	switch {
+	case l.DestinationRegistry != "":
+		return l.DestinationRegistry
+	case obj.destination != "":
+		return utils.GetRegistryName(obj.destination)
+	}
+	return utils.GetRegistryName(obj.image.Reference())
+}
+
+// handleObject sends the load object to the workers.
+func (l *Loader) handleObject(obj *loadObject) error {
+	obj.registry = l.destinationRegistry(obj)
+	return l.common.handleObject(obj)
+}
+
+// destinationImage returns the destination registry, project and name
+// of the load object.
+func (l *Loader) destinationImage(obj *loadObject) (string, string, string) {
+	imageName := obj.image.Reference()
+	project := utils.GetProjectName(imageName)
+	name := utils.GetImageName(imageName)
+	if obj.destination != "" {
+		project = utils.GetProjectName(obj.destination)
+		name = utils.GetImageName(obj.destination)
+	}
+	if l.DestinationProject != "" {
+		project = l.DestinationProject
+	}
+	return l.destinationRegistry(obj), project, name
+}
+
+// newDestination creates the destination image of the load object.
+func (l *Loader) newDestination(obj *loadObject) (*destination.Destination, error) {
+	destinationRegistry, destinationProject, destinationName := l.destinationImage(obj)
+	tag := obj.image.Tag
+	if obj.tag != "" {
+		tag = obj.tag
+	}
+	return destination.NewDestination(&destination.Option{
+		Type:          l.DestinationType,
+		Registry:      destinationRegistry,
+		Project:       destinationProject,
+		Name:          destinationName,
+		Tag:           tag,
+		Digest:        obj.image.Digest,
+		SystemContext: l.destinationSystemContext(destinationRegistry),
+	})
+}
+
+// recordFailedObject records the load object failed to load to its
+// destination image.
+func (l *Loader) recordFailedObject(obj *loadObject, err error) {
+	registry, project, name := l.destinationImage(obj)
+	tag := obj.image.Tag
+	if obj.tag != "" {
+		tag = obj.tag
+	}
+	l.recordFailed(&failedImage{
+		id:          obj.id,
+		line:        obj.failedLine(),
+		destination: l.failedDestination(registry, project, name),
+		tag:         tag,
+		err:         err,
+	})
+}
+
+// loadObjects returns the load objects of the image list specified by user,
+// or all images of the archive if no image list specified, the invalid
+// images are recorded as failed.
+func (l *Loader) loadObjects() []*loadObject {
+	var objects []*loadObject
+	if len(l.common.images) == 0 && len(l.common.entries) == 0 {
 		// Load all images from archive file.
 		for i, image := range l.index.List {
-			object := &loadObject{
+			objects = append(objects, &loadObject{
 				id:    i + 1,
 				image: image,
-			}
-			l.handleObject(object)
+			})
+		}
+		return objects
+	}
+
+	// Load images according to image list specified by user.
+	for i, line := range l.common.images {
+		switch imagelist.Detect(line) {
+		case imagelist.TypeDefault:
+		default:
+			logrus.Warnf("Ignore image list line %q: invalid format", line)
+			continue
+		}
+		registry := utils.GetRegistryName(line)
+		if l.SourceRegistry != "" {
+			registry = l.SourceRegistry
+		}
+		project := utils.GetProjectName(line)
+		if l.SourceProject != "" {
+			project = l.SourceProject
+		}
+		imageName := l.indexImageName(registry, project, line)
+		image, ok := l.indexImageSet[imageName]
+		if !ok {
+			err := fmt.Errorf("image [%v] not exists in archive", imageName)
+			l.recordFailedImage(i+1, line, err)
+			l.reportFailure(i+1, line, err, false)
+			l.handleError(NewError(i+1, err, nil, nil))
+			continue
 		}
+		objects = append(objects, &loadObject{
+			id:    i + 1,
+			image: image,
+			line:  line,
+		})
+	}
+	return append(objects, l.entryObjects()...)
+}
+
+func (l *Loader) copy(ctx context.Context) {
+	l.common.initErrorHandler(ctx)
+	l.common.initWorker(ctx, l.worker)
+	objects := l.loadObjects()
+	if err := l.initProjects(ctx, objects); err != nil {
+		l.handleError(fmt.Errorf("initProjects: %w", err))
+	}
+	l.progress.Start(len(objects))
+	for _, object := range objects {
+		l.handleObject(object)
 	}
 	l.waitWorkers()
 	l.layerManager.cleanAll()
@@ -194,65 +400,33 @@ func (l *Loader) copy(ctx context.Context) {
 }
 
 // Run loads images from hangar archive to destination image registry
-func (l *Loader) Run(ctx context.Context) error {
-	if err := l.initHarborProject(ctx); err != nil {
-		return fmt.Errorf("initHarborProject: %w", err)
-	}
+func (l *Loader) Run(ctx context.Context) (err error) {
+	ctx, span := tracing.Start(ctx, "load")
+	defer func() { tracing.End(span, err) }()
+	l.startReport("load")
 	l.copy(ctx)
-	if len(l.failedImageSet) != 0 {
-		v := make([]string, 0, len(l.failedImageSet))
-		for i := range l.failedImageSet {
-			v = append(v, i)
-		}
+	if v := l.failedImages(); len(v) != 0 {
 		logrus.Errorf("Copy failed image list: \n%v", strings.Join(v, "\n"))
-		return ErrCopyFailed
+		return l.failedError(ErrCopyFailed)
 	}
 	return nil
 }
 
-func (l *Loader) initHarborProject(ctx context.Context) error {
-	harborURL, err := harbor.GetRegistryURL(ctx, l.DestinationRegistry,
-		!l.systemContext.OCIInsecureSkipTLSVerify)
-	if err != nil {
-		if errors.Is(err, harbor.ErrRegistryIsNotHarbor) {
-			return nil
-		}
-		return err
-	}
-	credential, err := config.GetCredentials(nil, l.DestinationRegistry)
-	if err != nil {
-		return fmt.Errorf("failed to get credential of %q: %w",
-			l.DestinationRegistry, err)
-	}
-
-	projectSet := map[string]bool{}
-	if len(l.DestinationProject) > 0 {
-		projectSet[l.DestinationProject] = true
+// initProjects creates the projects of the destination images by the
+// registry provider before loading.
+func (l *Loader) initProjects(ctx context.Context, objects []*loadObject) error {
+	if l.DestinationType != types.TypeDocker {
+		return nil
 	}
-	for i := 0; len(l.DestinationProject) == 0 && i < len(l.index.List); i++ {
-		project := utils.GetProjectName(l.index.List[i].Source)
-		projectSet[project] = true
-	}
-	for project := range projectSet {
-		exists, err := harbor.ProjectExists(
-			ctx, project, harborURL, &credential,
-			!l.systemContext.OCIInsecureSkipTLSVerify)
-		if err != nil {
-			return err
-		}
-		if exists {
+	projects := projectSet{}
+	for _, object := range objects {
+		registry, project, name := l.destinationImage(object)
+		if registry == "" || registry == utils.DockerHubRegistry || project == "" {
 			continue
 		}
-		err = harbor.CreateProject(
-			ctx, project, harborURL, &credential,
-			!l.systemContext.OCIInsecureSkipTLSVerify)
-		if err != nil {
-			return err
-		}
-		logrus.Infof("Created Harbor V2 project %q for registry %q",
-			project, l.DestinationRegistry)
+		projects.add(registry, project, name)
 	}
-	return nil
+	return l.createProjects(ctx, projects, l.destinationSystemContext)
 }
 
 func (l *Loader) worker(ctx context.Context, o any) {
@@ -275,49 +449,71 @@ func (l *Loader) worker(ctx context.Context, o any) {
 	} else {
 		copyContext, cancel = context.WithCancel(ctx)
 	}
-	imageName := obj.image.Source + ":" + obj.image.Tag
+	imageName := obj.image.Reference()
+	copyContext, span := tracing.Start(copyContext, "load image",
+		attribute.Int("id", obj.id),
+		attribute.String("source", imageName))
+	copyContext = logging.WithFields(copyContext, logrus.Fields{
+		logging.FieldImageID: obj.id,
+		logging.FieldSource:  imageName,
+		logging.FieldPhase:   logging.PhaseCopy,
+	})
+	imageSpecSet := l.specSet(obj.imageSpecSet)
+	p := l.progress.NewImage(imageName)
+	r := &report.Image{
+		ID:     obj.id,
+		Source: imageName,
+		Digest: obj.image.Digest,
+	}
+	start := time.Now()
+	// loaded is the platform images loaded to the destination
+	var loaded []archive.ImageSpec
 	// Use defer to handle error message.
 	defer func() {
-		if err != nil {
+		p.Done(err)
+		tracing.End(span, err)
+		r.Platforms, r.SkippedPlatforms = reportPlatforms(obj.image.Images, loaded)
+		l.reportImage(r, start, p, err, obj.optional)
+		if err != nil && obj.optional {
+			logging.FromContext(copyContext).
+				Warnf("Skip optional image [%v]: %v", imageName, err)
+		} else if err != nil {
 			l.handleError(NewError(obj.id, err, nil, nil))
-			l.recordFailedImage(imageName)
+			l.recordFailedObject(obj, err)
 		}
 		cancel()
 	}()
 
 	// Init destination image spec.
-	destinationRegistry := utils.GetRegistryName(imageName)
-	if l.DestinationRegistry != "" {
-		destinationRegistry = l.DestinationRegistry
-	}
-	destinationProject := utils.GetProjectName(imageName)
-	if l.DestinationProject != "" {
-		destinationProject = l.DestinationProject
-	}
-	dest, err := destination.NewDestination(&destination.Option{
-		Type:          types.TypeDocker,
-		Registry:      destinationRegistry,
-		Project:       destinationProject,
-		Name:          utils.GetImageName(imageName),
-		Tag:           obj.image.Tag,
-		SystemContext: l.systemContext,
-	})
+	dest, err := l.newDestination(obj)
 	if err != nil {
 		err = fmt.Errorf("failed to create destination image: %w", err)
 		return
 	}
+	r.Destinations = []string{l.destinationName(dest)}
+	copyContext = logging.WithFields(copyContext, logrus.Fields{
+		logging.FieldDestination: dest.ReferenceNameWithoutTransport(),
+	})
 	if err = dest.Init(copyContext); err != nil {
 		err = fmt.Errorf("failed to init destination image: %w", err)
 		return
 	}
 
-	var manifestImages = make(manifest.Images, 0)
-	logrus.WithFields(logrus.Fields{"IMG": obj.id}).
+	var (
+		manifestImages = make(manifest.Images, 0)
+		// skipped is true if some images were skipped by arch/os filter
+		skipped bool
+	)
+	logging.FromContext(copyContext).
 		Infof("Loading [%v] => [%v]",
 			imageName, dest.ReferenceNameWithoutTransport())
 	for _, img := range obj.image.Images {
+		platformContext := logging.WithFields(copyContext, logrus.Fields{
+			logging.FieldPlatform: logging.Platform(img.OS, img.Arch, img.Variant),
+			logging.FieldDigest:   img.Digest,
+		})
 		if img.Digest == "" {
-			logrus.WithFields(logrus.Fields{"IMG": obj.id}).
+			logging.FromContext(platformContext).
 				Warnf("Skip invalid image [%v] [%v] [%v]",
 					imageName, img.Arch, img.OS)
 			continue
@@ -329,7 +525,7 @@ func (l *Loader) worker(ctx context.Context, o any) {
 		)
 		imgRef = dest.ReferenceNameDigest(img.Digest)
 		l.arMutex.Lock()
-		tmpDir, err = l.ar.DecompressImageTmp(&img, l.common.imageSpecSet)
+		tmpDir, err = l.ar.DecompressImageTmp(&img, imageSpecSet)
 		l.arMutex.Unlock()
 		// Register defer function to clean-up cache.
 		defer func(d string, img archive.ImageSpec) {
@@ -346,15 +542,16 @@ func (l *Loader) worker(ctx context.Context, o any) {
 			}
 			refName := fmt.Sprintf("%s@%s", obj.image.Source, img.Digest)
 			if img.OSVersion != "" {
-				logrus.WithFields(logrus.Fields{"IMG": obj.id}).
+				logging.FromContext(platformContext).
 					Infof("Skip [%s] [%s%s] [%s] [%s]",
 						refName, img.Arch, img.Variant, img.OS, img.OSVersion)
 			} else {
-				logrus.WithFields(logrus.Fields{"IMG": obj.id}).
+				logging.FromContext(platformContext).
 					Infof("Skip [%s] [%s%s] [%s]",
 						refName, img.Arch, img.Variant, img.OS)
 			}
 			err = nil
+			skipped = true
 			continue
 		}
 
@@ -377,22 +574,26 @@ func (l *Loader) worker(ctx context.Context, o any) {
 			err = fmt.Errorf("failed to create source image: %w", err)
 			return
 		}
-		if err = src.Init(copyContext); err != nil {
+		src.SetProgress(p)
+		if err = src.Init(platformContext); err != nil {
 			err = fmt.Errorf("failed to init [%v]: %w",
 				src.ReferenceName(), err)
 			return
 		}
-		err = src.Copy(copyContext, dest, l.common.imageSpecSet, l.policy)
+		err = src.Copy(platformContext, dest, imageSpecSet, l.policy)
 		if err != nil {
 			if errors.Is(err, utils.ErrNoAvailableImage) {
-				logrus.WithFields(logrus.Fields{"IMG": obj.id}).
+				logging.FromContext(platformContext).
 					Warnf("Skip saving image [%v]: %v", imageName, err)
 				err = nil
+				skipped = true
 			} else {
 				err = fmt.Errorf("failed to copy [%v] to [%v]: %w",
 					src.ReferenceName(), dest.ReferenceName(), err)
 				return
 			}
+		} else {
+			loaded = append(loaded, img)
 		}
 
 		var mi *manifest.Image
@@ -408,6 +609,25 @@ func (l *Loader) worker(ctx context.Context, o any) {
 		manifestImages = append(manifestImages, mi)
 	}
 
+	copyContext = logging.WithFields(copyContext, logrus.Fields{
+		logging.FieldPhase: logging.PhaseManifest,
+	})
+	if obj.image.Digest != "" {
+		if obj.image.Manifest != "" && !skipped {
+			err = pushDigestPinnedManifest(
+				copyContext, dest, []byte(obj.image.Manifest))
+			return
+		}
+		if dest.Tag() == "" {
+			err = errDigestNotPreserved(obj.image.Digest)
+			return
+		}
+		logging.FromContext(copyContext).
+			Warnf("Digest [%v] of [%v] will not be preserved: "+
+				"some images were skipped by the arch/os filter",
+				obj.image.Digest, imageName)
+	}
+
 	destManifestImages := dest.ManifestImages()
 	if len(destManifestImages) > 0 {
 		// If no new image copied to destination registry, skip re-create
@@ -420,8 +640,9 @@ func (l *Loader) worker(ctx context.Context, o any) {
 			}
 		}
 		if skipBuildManifest {
-			logrus.Debugf("skip build manifest for image [%v]: already exists",
-				dest.ReferenceName())
+			logging.FromContext(copyContext).
+				Debugf("skip build manifest for image [%v]: already exists",
+					dest.ReferenceName())
 			return
 		}
 	}
@@ -453,15 +674,14 @@ func (l *Loader) worker(ctx context.Context, o any) {
 	}
 }
 
-func (l *Loader) Validate(ctx context.Context) error {
+func (l *Loader) Validate(ctx context.Context) (err error) {
+	ctx, span := tracing.Start(ctx, "load-validate")
+	defer func() { tracing.End(span, err) }()
+	l.startReport("load-validate")
 	l.validate(ctx)
-	if len(l.failedImageSet) != 0 {
-		v := make([]string, 0, len(l.failedImageSet))
-		for i := range l.failedImageSet {
-			v = append(v, i)
-		}
+	if v := l.failedImages(); len(v) != 0 {
 		logrus.Errorf("Validate failed image list: \n%v", strings.Join(v, "\n"))
-		return ErrValidateFailed
+		return l.failedError(ErrValidateFailed)
 	}
 	return nil
 }
@@ -469,49 +689,8 @@ func (l *Loader) Validate(ctx context.Context) error {
 func (l *Loader) validate(ctx context.Context) {
 	l.common.initErrorHandler(ctx)
 	l.common.initWorker(ctx, l.validateWorker)
-	if len(l.common.images) > 0 {
-		// Validate images according to image list specified by user.
-		for i, line := range l.common.images {
-			registry := utils.GetRegistryName(line)
-			if l.SourceRegistry != "" {
-				registry = l.SourceRegistry
-			}
-			project := utils.GetProjectName(line)
-			if l.SourceProject != "" {
-				project = l.SourceProject
-			}
-			name := utils.GetImageName(line)
-			tag := utils.GetImageTag(line)
-			imageName := fmt.Sprintf("%s/%s/%s:%s",
-				registry, project, name, tag)
-			image, ok := l.indexImageSet[imageName]
-			if !ok {
-				l.recordFailedImage(line)
-				l.handleError(
-					NewError(
-						i+1,
-						fmt.Errorf("image [%v] not exists in archive", imageName),
-						nil,
-						nil,
-					),
-				)
-				continue
-			}
-			object := &loadObject{
-				id:    i + 1,
-				image: image,
-			}
-			l.handleObject(object)
-		}
-	} else {
-		// Validate all images from archive file.
-		for i, image := range l.index.List {
-			object := &loadObject{
-				id:    i + 1,
-				image: image,
-			}
-			l.handleObject(object)
-		}
+	for _, object := range l.loadObjects() {
+		l.handleObject(object)
 	}
 	l.waitWorkers()
 	l.layerManager.cleanAll()
@@ -540,16 +719,37 @@ func (l *Loader) validateWorker(ctx context.Context, o any) {
 	} else {
 		validateContext, cancel = context.WithCancel(ctx)
 	}
-	imageName := obj.image.Source + ":" + obj.image.Tag
+	imageName := obj.image.Reference()
+	validateContext = logging.WithFields(validateContext, logrus.Fields{
+		logging.FieldImageID: obj.id,
+		logging.FieldSource:  imageName,
+		logging.FieldPhase:   logging.PhaseValidate,
+	})
+	imageSpecSet := l.specSet(obj.imageSpecSet)
+	r := &report.Image{
+		ID:     obj.id,
+		Source: imageName,
+		Digest: obj.image.Digest,
+	}
+	start := time.Now()
+	// selected is the platform images selected by the arch/os filter
+	var selected []archive.ImageSpec
 	// Use defer to handle error message.
 	defer func() {
 		cancel()
+		r.Platforms, r.SkippedPlatforms = reportPlatforms(obj.image.Images, selected)
+		l.reportImage(r, start, nil, err, obj.optional)
+		if err != nil && obj.optional {
+			logging.FromContext(validateContext).
+				Warnf("Skip optional image [%v]: %v", imageName, err)
+			return
+		}
 		if err != nil {
 			l.handleError(NewError(obj.id, err, nil, nil))
-			l.recordFailedImage(imageName)
+			l.recordFailedObject(obj, err)
 		}
 	}()
-	logrus.Debugf("Validating [%v]", imageName)
+	logging.FromContext(validateContext).Debugf("Validating [%v]", imageName)
 
 	// Init source image.
 	if len(obj.image.Images) == 0 {
@@ -557,65 +757,55 @@ func (l *Loader) validateWorker(ctx context.Context, o any) {
 	}
 	sourceDigestSet := map[digest.Digest]bool{}
 	for _, img := range obj.image.Images {
-		if len(l.imageSpecSet["arch"]) > 0 && !l.imageSpecSet["arch"][img.Arch] {
+		if len(imageSpecSet["arch"]) > 0 && !imageSpecSet["arch"][img.Arch] {
 			continue
 		}
-		if len(l.imageSpecSet["os"]) > 0 && !l.imageSpecSet["os"][img.OS] {
+		if len(imageSpecSet["os"]) > 0 && !imageSpecSet["os"][img.OS] {
 			continue
 		}
 		sourceDigestSet[img.Digest] = true
+		selected = append(selected, img)
 	}
 	if len(sourceDigestSet) == 0 {
 		return
 	}
 
 	// Init destination image.
-	destinationRegistry := utils.GetRegistryName(imageName)
-	if l.DestinationRegistry != "" {
-		destinationRegistry = l.DestinationRegistry
-	}
-	destinationProject := utils.GetProjectName(imageName)
-	if l.DestinationProject != "" {
-		destinationProject = l.DestinationProject
-	}
-	dest, err := destination.NewDestination(&destination.Option{
-		Type:          types.TypeDocker,
-		Registry:      destinationRegistry,
-		Project:       destinationProject,
-		Name:          utils.GetImageName(imageName),
-		Tag:           obj.image.Tag,
-		SystemContext: l.systemContext,
-	})
+	dest, err := l.newDestination(obj)
 	if err != nil {
 		err = fmt.Errorf("failed to create destination image: %w", err)
 		return
 	}
+	r.Destinations = []string{l.destinationName(dest)}
+	validateContext = logging.WithFields(validateContext, logrus.Fields{
+		logging.FieldDestination: dest.ReferenceNameWithoutTransport(),
+	})
 	if err = dest.Init(validateContext); err != nil {
 		err = fmt.Errorf("failed to init destination image: %w", err)
 		return
 	}
 	if !dest.Exists() {
-		logrus.WithFields(logrus.Fields{"IMG": obj.id}).
+		logging.FromContext(validateContext).
 			Errorf("Image [%v] does not exists in destination registry server",
 				dest.ReferenceNameWithoutTransport())
-		err = fmt.Errorf("FAILED: [%v]", imageName)
+		err = fmt.Errorf("FAILED: [%v]: %w", imageName, ErrImageNotFound)
 		return
 	}
-	destImage := dest.ImageBySet(l.imageSpecSet)
+	destImage := dest.ImageBySet(imageSpecSet)
 	destDigestSet := map[digest.Digest]bool{}
 	for _, img := range destImage.Images {
 		destDigestSet[img.Digest] = true
 	}
 	for d := range sourceDigestSet {
 		if !destDigestSet[d] {
-			logrus.WithFields(logrus.Fields{"IMG": obj.id}).
+			logging.FromContext(validateContext).
 				Errorf("Image [%v] digest [%v] does not exists in destination registry",
 					dest.ReferenceNameWithoutTransport(), d)
-			err = fmt.Errorf("FAILED: [%v]", imageName)
+			err = fmt.Errorf("FAILED: [%v]: %w", imageName, ErrDigestMismatch)
 			return
 		}
 	}
 
-	logrus.WithFields(logrus.Fields{"IMG": obj.id}).
+	logging.FromContext(validateContext).
 		Infof("PASS: [%v]", imageName)
 }
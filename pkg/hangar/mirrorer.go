@@ -5,26 +5,44 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/cnrancher/hangar/pkg/destination"
 	"github.com/cnrancher/hangar/pkg/hangar/imagelist"
+	"github.com/cnrancher/hangar/pkg/hangar/report"
+	"github.com/cnrancher/hangar/pkg/logging"
 	"github.com/cnrancher/hangar/pkg/manifest"
 	"github.com/cnrancher/hangar/pkg/source"
+	"github.com/cnrancher/hangar/pkg/tracing"
 	"github.com/cnrancher/hangar/pkg/types"
 	"github.com/cnrancher/hangar/pkg/utils"
 	imagemanifest "github.com/containers/image/v5/manifest"
 	"github.com/opencontainers/go-digest"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // mirrorObject is the object sending to worker pool when copying image
 type mirrorObject struct {
-	image       string
-	source      *source.Source
-	destination *destination.Destination
-	timeout     time.Duration
-	id          int
+	image        string
+	source       *source.Source
+	destinations []*destination.Destination
+	timeout      time.Duration
+	id           int
+
+	// imageSpecSet overrides the arch/os/variant set of the image (optional)
+	imageSpecSet map[string]map[string]bool
+	// optional image will not be recorded as failed
+	optional bool
+}
+
+func (o *mirrorObject) registries() []string {
+	registries := []string{o.source.Registry()}
+	for _, dest := range o.destinations {
+		registries = append(registries, dest.Registry())
+	}
+	return registries
 }
 
 // Mirrorer mirrors multipule images between image registries.
@@ -35,10 +53,15 @@ type Mirrorer struct {
 	SourceRegistry string
 	// Override the registry of the copied destination image
 	DestinationRegistry string
+	// DestinationRegistries is the destination registries to mirror images
+	// in one pass (fan-out), the DestinationRegistry is the first one.
+	DestinationRegistries []string
 	// Override the project of source image to be copied
 	SourceProject string
 	// Override the project of the copied destination image
 	DestinationProject string
+
+	ProjectOpts
 }
 
 type MirrorerOpts struct {
@@ -46,8 +69,14 @@ type MirrorerOpts struct {
 
 	SourceRegistry      string
 	DestinationRegistry string
-	SourceProject       string
-	DestinationProject  string
+	// DestinationRegistries is the additional destination registries,
+	// the source blobs are pulled once and pushed to all destinations in
+	// parallel.
+	DestinationRegistries []string
+	SourceProject         string
+	DestinationProject    string
+
+	ProjectOpts
 }
 
 func NewMirrorer(o *MirrorerOpts) (*Mirrorer, error) {
@@ -56,6 +85,18 @@ func NewMirrorer(o *MirrorerOpts) (*Mirrorer, error) {
 		DestinationRegistry: o.DestinationRegistry,
 		SourceProject:       o.SourceProject,
 		DestinationProject:  o.DestinationProject,
+		ProjectOpts:         o.ProjectOpts,
+	}
+	registrySet := map[string]bool{}
+	for _, r := range append([]string{o.DestinationRegistry}, o.DestinationRegistries...) {
+		if r == "" || registrySet[r] {
+			continue
+		}
+		registrySet[r] = true
+		m.DestinationRegistries = append(m.DestinationRegistries, r)
+	}
+	if m.DestinationRegistry == "" && len(m.DestinationRegistries) > 0 {
+		m.DestinationRegistry = m.DestinationRegistries[0]
 	}
 	var err error
 	m.common, err = newCommon(&o.CommonOpts)
@@ -65,9 +106,32 @@ func NewMirrorer(o *MirrorerOpts) (*Mirrorer, error) {
 	return m, nil
 }
 
+// destinationRegistries returns the destination registries to mirror.
+func (m *Mirrorer) destinationRegistries() []string {
+	if len(m.DestinationRegistries) == 0 {
+		return []string{m.DestinationRegistry}
+	}
+	return m.DestinationRegistries
+}
+
 func (m *Mirrorer) copy(ctx context.Context) {
 	m.common.initErrorHandler(ctx)
 	m.common.initWorker(ctx, m.worker)
+	objects := m.mirrorObjects()
+	if err := m.initProjects(ctx, objects); err != nil {
+		m.handleError(fmt.Errorf("initProjects: %w", err))
+	}
+	m.progress.Start(len(objects))
+	for _, object := range objects {
+		m.handleObject(object)
+	}
+	m.waitWorkers()
+}
+
+// mirrorObjects returns the mirror objects of the image list and the
+// structured image list entries, the invalid images are recorded as failed.
+func (m *Mirrorer) mirrorObjects() []*mirrorObject {
+	var objects []*mirrorObject
 	for i, line := range m.common.images {
 		var (
 			object *mirrorObject
@@ -83,26 +147,63 @@ func (m *Mirrorer) copy(ctx context.Context) {
 			continue
 		}
 		if err != nil {
-			m.common.recordFailedImage(line)
+			m.common.recordFailedImage(i+1, line, err)
+			m.reportFailure(i+1, line, err, false)
 			m.handleError(err)
 			continue
 		}
 		object.id = i + 1
-		m.handleObject(object)
+		objects = append(objects, object)
 	}
-	m.waitWorkers()
+	for i, e := range m.common.entries {
+		if e.Skip {
+			logrus.Infof("Skip image %q", e.Source)
+			continue
+		}
+		object, err := m.mirrorObjectImageListTypeStructured(e)
+		if err != nil {
+			m.reportFailure(i+1, e.Source, err, e.Optional)
+			if e.Optional {
+				logrus.WithField(logging.FieldImageID, i+1).
+					Warnf("Skip optional image %q: %v", e.Source, err)
+				continue
+			}
+			m.common.recordFailedImage(i+1, e.Source, err)
+			m.handleError(NewError(i+1, err, nil, nil))
+			continue
+		}
+		object.id = i + 1
+		objects = append(objects, object)
+	}
+	return objects
+}
+
+// initProjects creates the projects of the destination images by the
+// registry provider before mirroring.
+func (m *Mirrorer) initProjects(ctx context.Context, objects []*mirrorObject) error {
+	projects := projectSet{}
+	for _, object := range objects {
+		for _, dest := range object.destinations {
+			registry := dest.Registry()
+			if registry == "" || registry == utils.DockerHubRegistry ||
+				dest.Project() == "" {
+				continue
+			}
+			projects.add(registry, dest.Project(), dest.Name())
+		}
+	}
+	return m.createProjects(ctx, projects, m.destinationSystemContext)
 }
 
 // Run mirror images from source to destination registry.
-func (m *Mirrorer) Run(ctx context.Context) error {
+func (m *Mirrorer) Run(ctx context.Context) (err error) {
+	ctx, span := tracing.Start(ctx, "mirror")
+	defer func() { tracing.End(span, err) }()
+	m.startReport("mirror")
 	m.copy(ctx)
-	if len(m.failedImageSet) != 0 {
-		v := make([]string, 0, len(m.failedImageSet))
-		for i := range m.failedImageSet {
-			v = append(v, i)
-		}
+	if v := m.failedImages(); len(v) != 0 {
 		logrus.Errorf("Copy failed image list: \n%v", strings.Join(v, "\n"))
-		return ErrCopyFailed
+		return m.failedError(ErrCopyFailed)
 	}
 	return nil
 }
@@ -111,6 +212,10 @@ func (m *Mirrorer) mirrorObjectImageListTypeDefault(line string) (*mirrorObject,
 	object := &mirrorObject{
 		image: line,
 	}
+	_, dig, err := utils.SplitImageDigest(line)
+	if err != nil {
+		return nil, err
+	}
 	sourceRegistry := utils.GetRegistryName(line)
 	if m.SourceRegistry != "" {
 		sourceRegistry = m.SourceRegistry
@@ -125,7 +230,8 @@ func (m *Mirrorer) mirrorObjectImageListTypeDefault(line string) (*mirrorObject,
 		Project:       sourceProject,
 		Name:          utils.GetImageName(line),
 		Tag:           utils.GetImageTag(line),
-		SystemContext: m.systemContext,
+		Digest:        dig,
+		SystemContext: m.sourceSystemContext(sourceRegistry),
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to init source image: %v", err)
@@ -135,18 +241,21 @@ func (m *Mirrorer) mirrorObjectImageListTypeDefault(line string) (*mirrorObject,
 	if m.DestinationProject != "" {
 		destProject = m.DestinationProject
 	}
-	dest, err := destination.NewDestination(&destination.Option{
-		Type:          types.TypeDocker,
-		Registry:      m.DestinationRegistry,
-		Project:       destProject,
-		Name:          utils.GetImageName(line),
-		Tag:           utils.GetImageTag(line),
-		SystemContext: m.systemContext,
-	})
-	if err != nil {
-		return nil, fmt.Errorf("failed to init dest image: %v", err)
+	for _, registry := range m.destinationRegistries() {
+		dest, err := destination.NewDestination(&destination.Option{
+			Type:          types.TypeDocker,
+			Registry:      registry,
+			Project:       destProject,
+			Name:          utils.GetImageName(line),
+			Tag:           utils.GetImageTag(line),
+			Digest:        dig,
+			SystemContext: m.destinationSystemContext(registry),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to init dest image: %v", err)
+		}
+		object.destinations = append(object.destinations, dest)
 	}
-	object.destination = dest
 	return object, nil
 }
 
@@ -159,6 +268,10 @@ func (m *Mirrorer) mirrorObjectImageListTypeMirror(line string) (*mirrorObject,
 	if len(spec) != 3 {
 		return nil, fmt.Errorf("ignore line %q in image list: invalid format", line)
 	}
+	tag, dig, err := imagelist.GetTagDigest(spec[2])
+	if err != nil {
+		return nil, err
+	}
 	sourceRegistry := utils.GetRegistryName(spec[0])
 	if m.SourceRegistry != "" {
 		sourceRegistry = m.SourceRegistry
@@ -172,8 +285,9 @@ func (m *Mirrorer) mirrorObjectImageListTypeMirror(line string) (*mirrorObject,
 		Registry:      sourceRegistry,
 		Project:       sourceProject,
 		Name:          utils.GetImageName(spec[0]),
-		Tag:           spec[2],
-		SystemContext: m.systemContext,
+		Tag:           tag,
+		Digest:        dig,
+		SystemContext: m.sourceSystemContext(sourceRegistry),
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to init source image: %v", err)
@@ -183,18 +297,104 @@ func (m *Mirrorer) mirrorObjectImageListTypeMirror(line string) (*mirrorObject,
 	if m.DestinationProject != "" {
 		destProject = m.DestinationProject
 	}
-	dest, err := destination.NewDestination(&destination.Option{
+	for _, registry := range m.destinationRegistries() {
+		dest, err := destination.NewDestination(&destination.Option{
+			Type:          types.TypeDocker,
+			Registry:      registry,
+			Project:       destProject,
+			Name:          utils.GetImageName(spec[1]),
+			Tag:           tag,
+			Digest:        dig,
+			SystemContext: m.destinationSystemContext(registry),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to init dest image: %v", err)
+		}
+		object.destinations = append(object.destinations, dest)
+	}
+	return object, nil
+}
+
+// mirrorObjectImageListTypeStructured returns the mirror object of the
+// structured image list entry, the image will be copied to all the
+// destination images & tags.
+func (m *Mirrorer) mirrorObjectImageListTypeStructured(
+	e *imagelist.Entry,
+) (*mirrorObject, error) {
+	_, dig, err := utils.SplitImageDigest(e.Source)
+	if err != nil {
+		return nil, err
+	}
+	sourceRegistry := utils.GetRegistryName(e.Source)
+	if m.SourceRegistry != "" {
+		sourceRegistry = m.SourceRegistry
+	}
+	sourceProject := utils.GetProjectName(e.Source)
+	if m.SourceProject != "" {
+		sourceProject = m.SourceProject
+	}
+	sourceTag := utils.GetImageTag(e.Source)
+	tags := []string{sourceTag}
+	for _, t := range e.Tags {
+		if t != "" && t != sourceTag {
+			tags = append(tags, t)
+		}
+	}
+	src, err := source.NewSource(&source.Option{
 		Type:          types.TypeDocker,
-		Registry:      m.DestinationRegistry,
-		Project:       destProject,
-		Name:          utils.GetImageName(spec[1]),
-		Tag:           spec[2],
-		SystemContext: m.systemContext,
+		Registry:      sourceRegistry,
+		Project:       sourceProject,
+		Name:          utils.GetImageName(e.Source),
+		Tag:           sourceTag,
+		Digest:        dig,
+		SystemContext: m.sourceSystemContext(sourceRegistry),
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to init dest image: %v", err)
+		return nil, fmt.Errorf("failed to init source image: %v", err)
+	}
+	object := &mirrorObject{
+		image:        e.Source,
+		source:       src,
+		imageSpecSet: m.common.entryImageSpecSet(e),
+		optional:     e.Optional,
+	}
+
+	destImages := e.DestinationImages()
+	if len(destImages) == 0 {
+		destImages = []string{""}
+	}
+	for _, destImage := range destImages {
+		for _, registry := range m.destinationRegistries() {
+			destRegistry := registry
+			destProject := utils.GetProjectName(e.Source)
+			destName := utils.GetImageName(e.Source)
+			if destImage != "" {
+				if destRegistry == "" {
+					destRegistry = utils.GetRegistryName(destImage)
+				}
+				destProject = utils.GetProjectName(destImage)
+				destName = utils.GetImageName(destImage)
+			}
+			if m.DestinationProject != "" {
+				destProject = m.DestinationProject
+			}
+			for _, tag := range tags {
+				dest, err := destination.NewDestination(&destination.Option{
+					Type:          types.TypeDocker,
+					Registry:      destRegistry,
+					Project:       destProject,
+					Name:          destName,
+					Tag:           tag,
+					Digest:        dig,
+					SystemContext: m.destinationSystemContext(destRegistry),
+				})
+				if err != nil {
+					return nil, fmt.Errorf("failed to init dest image: %v", err)
+				}
+				object.destinations = append(object.destinations, dest)
+			}
+		}
 	}
-	object.destination = dest
 	return object, nil
 }
 
@@ -211,73 +411,203 @@ func (m *Mirrorer) worker(ctx context.Context, o any) {
 	var (
 		copyContext context.Context
 		cancel      context.CancelFunc
-		err         error
 	)
 	if obj.timeout > 0 {
 		copyContext, cancel = context.WithTimeout(ctx, obj.timeout)
 	} else {
 		copyContext, cancel = context.WithCancel(ctx)
 	}
+	defer cancel()
+	copyContext, span := tracing.Start(copyContext, "mirror image",
+		attribute.Int("id", obj.id),
+		attribute.String("source", obj.source.ReferenceNameWithoutTransport()))
+	copyContext = logging.WithFields(copyContext, logrus.Fields{
+		logging.FieldImageID: obj.id,
+		logging.FieldSource:  obj.source.ReferenceNameWithoutTransport(),
+		logging.FieldPhase:   logging.PhaseCopy,
+	})
+	imageSpecSet := m.specSet(obj.imageSpecSet)
+	p := m.progress.NewImage(obj.source.ReferenceNameWithoutTransport())
+	r := m.newReportImage(obj)
+	start := time.Now()
+	var failed error
 	defer func() {
-		cancel()
+		p.Done(failed)
+		tracing.End(span, failed)
+		m.reportImage(r, start, p, failed, obj.optional)
+	}()
+
+	// The source image is initialized once and copied to all destinations in
+	// parallel, the blobs are cached locally to read from the source registry
+	// only once if mirroring to multiple destinations.
+	initContext := logging.WithFields(copyContext, logrus.Fields{
+		logging.FieldPhase: logging.PhaseInit,
+	})
+	if err := obj.source.Init(initContext); err != nil {
+		failed = fmt.Errorf("failed to init [%v]: %w", obj.source.ReferenceName(), err)
+		m.handleDestinationsError(copyContext, obj, obj.destinations, failed)
+		return
+	}
+	if len(obj.destinations) > 1 {
+		cache, err := source.NewBlobCache()
 		if err != nil {
-			m.handleError(fmt.Errorf("error occurred when copy [%v] to [%v]: %w",
-				obj.source.ReferenceNameWithoutTransport(),
-				obj.destination.ReferenceNameWithoutTransport(), err))
-			m.common.recordFailedImage(obj.source.ReferenceNameWithoutTransport())
+			failed = err
+			m.handleDestinationsError(copyContext, obj, obj.destinations, failed)
+			return
 		}
-	}()
+		defer cache.Close()
+		copyContext = source.WithBlobCache(copyContext, cache)
+	}
 
-	err = obj.source.Init(copyContext)
-	if err != nil {
-		err = fmt.Errorf("failed to init [%v]: %w",
-			obj.source.ReferenceName(), err)
+	var (
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		copied *source.Source
+	)
+	for _, dest := range obj.destinations {
+		wg.Add(1)
+		go func(dest *destination.Destination) {
+			defer wg.Done()
+			src := obj.source.Clone()
+			src.SetProgress(p)
+			destContext := logging.WithFields(copyContext, logrus.Fields{
+				logging.FieldDestination: dest.ReferenceNameWithoutTransport(),
+			})
+			err := m.mirror(destContext, src, dest, imageSpecSet)
+			if err != nil {
+				m.handleWorkerError(destContext, obj, dest,
+					NewError(obj.id, err, obj.source, dest))
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				failed = err
+			} else if copied == nil {
+				copied = src
+			}
+		}(dest)
+	}
+	wg.Wait()
+	if copied != nil {
+		r.Digest = copied.ManifestDigest()
+		r.Platforms, r.SkippedPlatforms = reportPlatforms(
+			copied.Platforms(), copied.GetCopiedImage().Images)
+	}
+}
+
+// handleDestinationsError handles the error of the mirror object failed
+// before copying to the destinations.
+func (m *Mirrorer) handleDestinationsError(
+	ctx context.Context, obj *mirrorObject, dests []*destination.Destination, err error,
+) {
+	for _, dest := range dests {
+		m.handleWorkerError(ctx, obj, dest, NewError(obj.id, err, obj.source, dest))
+	}
+}
+
+// newReportImage creates the report image of the mirror object.
+func (m *Mirrorer) newReportImage(obj *mirrorObject) *report.Image {
+	r := &report.Image{
+		ID:     obj.id,
+		Source: obj.source.ReferenceNameWithoutTransport(),
+	}
+	for _, dest := range obj.destinations {
+		r.Destinations = append(r.Destinations, m.destinationName(dest))
+	}
+	return r
+}
+
+// handleWorkerError handles the error of the mirror object, the failed
+// images are recorded by destination.
+func (m *Mirrorer) handleWorkerError(
+	ctx context.Context, obj *mirrorObject, dest *destination.Destination, err error,
+) {
+	if obj.optional {
+		logging.FromContext(ctx).
+			Warnf("Skip optional image [%v]: %v",
+				obj.source.ReferenceNameWithoutTransport(), err)
 		return
 	}
-	err = obj.destination.Init(copyContext)
+	m.handleError(err)
+	src := obj.source
+	source := fmt.Sprintf("%s/%s/%s:%s", src.Registry(), src.Project(), src.Name(), src.Tag())
+	if src.Digest() != "" {
+		source += "@" + src.Digest().String()
+	}
+	m.common.recordFailed(&failedImage{
+		id:           obj.id,
+		line:         obj.image,
+		registry:     m.registryName(dest.Registry()),
+		source:       source,
+		destination:  m.failedDestination(dest.Registry(), dest.Project(), dest.Name()),
+		tag:          dest.Tag(),
+		destinations: len(obj.destinations),
+		err:          err,
+	})
+}
+
+// mirror copies the initialized source image to the destination and
+// creates the manifest index of the destination image.
+func (m *Mirrorer) mirror(
+	ctx context.Context,
+	src *source.Source,
+	dest *destination.Destination,
+	imageSpecSet map[string]map[string]bool,
+) error {
+	initContext := logging.WithFields(ctx, logrus.Fields{
+		logging.FieldPhase: logging.PhaseInit,
+	})
+	err := dest.Init(initContext)
 	if err != nil {
-		err = fmt.Errorf("failed to init [%v]: %w",
-			obj.destination.ReferenceName(), err)
-		return
+		return fmt.Errorf("failed to init [%v]: %w", dest.ReferenceName(), err)
 	}
-	logrus.WithFields(logrus.Fields{
-		"IMG": obj.id,
-	}).Infof("Copying [%v] => [%v]",
-		obj.source.ReferenceNameWithoutTransport(),
-		obj.destination.ReferenceNameWithoutTransport())
-	err = obj.source.Copy(copyContext, obj.destination, m.imageSpecSet, m.policy)
+	logging.FromContext(ctx).Infof("Copying [%v] => [%v]",
+		src.ReferenceNameWithoutTransport(),
+		dest.ReferenceNameWithoutTransport())
+	err = src.Copy(ctx, dest, imageSpecSet, m.policy)
 	if err != nil {
-		if errors.Is(err, utils.ErrNoAvailableImage) {
-			logrus.WithFields(logrus.Fields{"IMG": obj.id}).
-				Warnf("Skip copy image [%v]: %v",
-					obj.source.ReferenceNameWithoutTransport(), err)
-			err = nil
-		} else {
-			return
+		if !errors.Is(err, utils.ErrNoAvailableImage) {
+			return err
 		}
+		logging.FromContext(ctx).
+			Warnf("Skip copy image [%v]: %v",
+				src.ReferenceNameWithoutTransport(), err)
 	}
 
-	copiedImage := obj.source.GetCopiedImage()
+	copiedImage := src.GetCopiedImage()
 	if len(copiedImage.Images) == 0 {
-		return
+		return nil
 	}
+	ctx = logging.WithFields(ctx, logrus.Fields{
+		logging.FieldPhase: logging.PhaseManifest,
+	})
 	var manifestImages = make(manifest.Images, 0)
 	for _, image := range copiedImage.Images {
-		var mi *manifest.Image
-		mi, err = manifest.NewImageByInspect(
-			copyContext,
-			obj.destination.ReferenceNameDigest(image.Digest),
-			obj.destination.SystemContext(),
+		mi, err := manifest.NewImageByInspect(
+			ctx,
+			dest.ReferenceNameDigest(image.Digest),
+			dest.SystemContext(),
 		)
 		if err != nil {
-			err = fmt.Errorf("failed to create manifest image: %w", err)
-			return
+			return fmt.Errorf("failed to create manifest image: %w", err)
 		}
 		mi.UpdatePlatform(
 			image.Arch, image.Variant, image.OS, image.OSVersion, image.OSFeatures)
 		manifestImages = append(manifestImages, mi)
 	}
-	destManifestImages := obj.destination.ManifestImages()
+	if src.Digest() != "" {
+		if src.CanPreserveDigest(imageSpecSet) {
+			return pushDigestPinnedManifest(ctx, dest, src.ManifestRaw())
+		}
+		if dest.Tag() == "" {
+			return errDigestNotPreserved(src.Digest())
+		}
+		logging.FromContext(ctx).
+			Warnf("Digest [%v] of [%v] will not be preserved: "+
+				"some images were skipped by the arch/os filter",
+				src.Digest(), src.ReferenceNameWithoutTransport())
+	}
+	destManifestImages := dest.ManifestImages()
 	if len(destManifestImages) > 0 {
 		// If no new image copied to the destination registry, skip re-create
 		// manifest index for destination image.
@@ -289,19 +619,19 @@ func (m *Mirrorer) worker(ctx context.Context, o any) {
 			}
 		}
 		if skipBuildManifest {
-			logrus.Debugf("skip build manifest for image [%v]: already exists",
-				obj.destination.ReferenceName())
-			return
+			logging.FromContext(ctx).
+				Debugf("skip build manifest for image [%v]: already exists",
+					dest.ReferenceName())
+			return nil
 		}
 	}
 
 	builder, err := manifest.NewBuilder(&manifest.BuilderOpts{
-		ReferenceName: obj.destination.ReferenceName(),
-		SystemContext: obj.destination.SystemContext(),
+		ReferenceName: dest.ReferenceName(),
+		SystemContext: dest.SystemContext(),
 	})
 	if err != nil {
-		err = fmt.Errorf("failed to create mafiest builder: %w", err)
-		return
+		return fmt.Errorf("failed to create mafiest builder: %w", err)
 	}
 	// Merge new added images with destination manifest index.
 	// Add images already exists on destination registry into builder firstly.
@@ -313,23 +643,22 @@ func (m *Mirrorer) worker(ctx context.Context, o any) {
 		builder.Add(img)
 	}
 	if builder.Images() == 0 {
-		return
+		return nil
 	}
 	if err = builder.Push(ctx); err != nil {
-		err = fmt.Errorf("failed to push manifest: %w", err)
-		return
+		return fmt.Errorf("failed to push manifest: %w", err)
 	}
+	return nil
 }
 
-func (m *Mirrorer) Validate(ctx context.Context) error {
+func (m *Mirrorer) Validate(ctx context.Context) (err error) {
+	ctx, span := tracing.Start(ctx, "mirror-validate")
+	defer func() { tracing.End(span, err) }()
+	m.startReport("mirror-validate")
 	m.validate(ctx)
-	if len(m.failedImageSet) != 0 {
-		v := make([]string, 0, len(m.failedImageSet))
-		for i := range m.failedImageSet {
-			v = append(v, i)
-		}
+	if v := m.failedImages(); len(v) != 0 {
 		logrus.Errorf("Copy failed image list: \n%v", strings.Join(v, "\n"))
-		return ErrCopyFailed
+		return m.failedError(ErrCopyFailed)
 	}
 	return nil
 }
@@ -337,26 +666,7 @@ func (m *Mirrorer) Validate(ctx context.Context) error {
 func (m *Mirrorer) validate(ctx context.Context) {
 	m.common.initErrorHandler(ctx)
 	m.initWorker(ctx, m.validateWorker)
-	for i, line := range m.common.images {
-		var (
-			object *mirrorObject
-			err    error
-		)
-		switch imagelist.Detect(line) {
-		case imagelist.TypeDefault:
-			object, err = m.mirrorObjectImageListTypeDefault(line)
-		case imagelist.TypeMirror:
-			object, err = m.mirrorObjectImageListTypeMirror(line)
-		default:
-			logrus.Warnf("Ignore image list line %q: invalid format", line)
-			continue
-		}
-		if err != nil {
-			m.common.recordFailedImage(line)
-			m.handleError(err)
-			continue
-		}
-		object.id = i + 1
+	for _, object := range m.mirrorObjects() {
 		m.handleObject(object)
 	}
 	m.waitWorkers()
@@ -375,36 +685,65 @@ func (m *Mirrorer) validateWorker(ctx context.Context, o any) {
 	var (
 		validateContext context.Context
 		cancel          context.CancelFunc
-		err             error
 	)
 	if obj.timeout > 0 {
 		validateContext, cancel = context.WithTimeout(ctx, obj.timeout)
 	} else {
 		validateContext, cancel = context.WithCancel(ctx)
 	}
+	defer cancel()
+	validateContext = logging.WithFields(validateContext, logrus.Fields{
+		logging.FieldImageID: obj.id,
+		logging.FieldSource:  obj.source.ReferenceNameWithoutTransport(),
+		logging.FieldPhase:   logging.PhaseValidate,
+	})
+	imageSpecSet := m.specSet(obj.imageSpecSet)
+	r := m.newReportImage(obj)
+	start := time.Now()
+	var failed error
 	defer func() {
-		cancel()
-		if err != nil {
-			m.handleError(NewError(obj.id, err, obj.source, obj.destination))
-			m.common.recordFailedImage(obj.source.ReferenceNameWithoutTransport())
-		}
+		m.reportImage(r, start, nil, failed, obj.optional)
 	}()
-	err = obj.source.Init(validateContext)
-	if err != nil {
+	if err := obj.source.Init(validateContext); err != nil {
+		failed = err
+		for _, dest := range obj.destinations {
+			m.handleWorkerError(validateContext, obj, dest,
+				NewError(obj.id, err, obj.source, dest))
+		}
 		return
 	}
-	err = obj.destination.Init(validateContext)
-	if err != nil {
-		return
+	r.Digest = obj.source.ManifestDigest()
+	r.Platforms, r.SkippedPlatforms = reportPlatforms(
+		obj.source.Platforms(), obj.source.ImageBySet(imageSpecSet).Images)
+	for _, dest := range obj.destinations {
+		destContext := logging.WithFields(validateContext, logrus.Fields{
+			logging.FieldDestination: dest.ReferenceNameWithoutTransport(),
+		})
+		if err := m.validateDestination(destContext, obj, dest, imageSpecSet); err != nil {
+			failed = err
+			m.handleWorkerError(destContext, obj, dest,
+				NewError(obj.id, err, obj.source, dest))
+		}
 	}
-	if !obj.destination.Exists() {
-		logrus.WithFields(logrus.Fields{"IMG": obj.id}).
+}
+
+// validateDestination ensures the source image was mirrored to destination.
+func (m *Mirrorer) validateDestination(
+	ctx context.Context,
+	obj *mirrorObject,
+	dest *destination.Destination,
+	imageSpecSet map[string]map[string]bool,
+) error {
+	if err := dest.Init(ctx); err != nil {
+		return err
+	}
+	if !dest.Exists() {
+		logging.FromContext(ctx).
 			Errorf("[%v] does not exists",
-				obj.destination.ReferenceNameWithoutTransport())
-		err = fmt.Errorf("FAILED: [%v] != [%v]",
+				dest.ReferenceNameWithoutTransport())
+		return fmt.Errorf("FAILED: [%v] != [%v]: %w",
 			obj.source.ReferenceNameWithoutTransport(),
-			obj.destination.ReferenceNameWithoutTransport())
-		return
+			dest.ReferenceNameWithoutTransport(), ErrImageNotFound)
 	}
 
 	switch obj.source.MIME() {
@@ -413,27 +752,27 @@ func (m *Mirrorer) validateWorker(ctx context.Context, o any) {
 		// Could not compare image digest since the destination mediaType
 		// was changed during copy.
 	default:
-		destImages := obj.destination.ImageBySet(m.imageSpecSet)
+		destImages := dest.ImageBySet(imageSpecSet)
 		destDigestSet := map[digest.Digest]bool{}
 		for _, img := range destImages.Images {
 			destDigestSet[img.Digest] = true
 		}
-		sourceImages := obj.source.ImageBySet(m.imageSpecSet)
+		sourceImages := obj.source.ImageBySet(imageSpecSet)
 		for _, img := range sourceImages.Images {
 			if !destDigestSet[img.Digest] {
-				logrus.WithFields(logrus.Fields{"IMG": obj.id}).
+				logging.FromContext(ctx).WithField(logging.FieldDigest, img.Digest).
 					Errorf("Image [%v] does not exists in destination registry",
-						obj.destination.ReferenceNameDigest(img.Digest))
-				err = fmt.Errorf("FAILED: [%v] != [%v]",
+						dest.ReferenceNameDigest(img.Digest))
+				return fmt.Errorf("FAILED: [%v] != [%v]: %w",
 					obj.source.ReferenceNameWithoutTransport(),
-					obj.destination.ReferenceNameWithoutTransport())
-				return
+					dest.ReferenceNameWithoutTransport(), ErrDigestMismatch)
 			}
 		}
 	}
 
-	logrus.WithFields(logrus.Fields{"IMG": obj.id}).
+	logging.FromContext(ctx).
 		Infof("PASS: [%v] == [%v]",
 			obj.source.ReferenceNameWithoutTransport(),
-			obj.destination.ReferenceNameWithoutTransport())
+			dest.ReferenceNameWithoutTransport())
+	return nil
 }
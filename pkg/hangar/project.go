@@ -0,0 +1,221 @@
+package hangar
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+
+	"github.com/cnrancher/hangar/pkg/harbor"
+	"github.com/cnrancher/hangar/pkg/provider"
+	"github.com/containers/image/v5/pkg/docker/config"
+	"github.com/containers/image/v5/types"
+	"github.com/sirupsen/logrus"
+)
+
+// ProjectOpts is the option to create the projects of the destination
+// registry automatically.
+type ProjectOpts struct {
+	// HarborProject is the spec of the Harbor V2 projects created
+	// automatically, the project name is ignored (optional).
+	HarborProject *harbor.Project
+	// HarborRobot creates the robot account for the Harbor V2 projects
+	// if specified (optional).
+	HarborRobot *harbor.RobotOptions
+	// HarborRobotFile is the file name to save the created robot accounts
+	// in docker config JSON format, the robot account names and secrets are
+	// printed to stdout if not provided (optional).
+	HarborRobotFile string
+	// HarborToken is the bearer token (e.g. OIDC ID token) for the Harbor V2
+	// API, the registry username and password is used if not provided, the
+	// identity token of the registry credential is never used (optional).
+	HarborToken string
+	// RegistryProvider is the provider type of the destination registry
+	// to create the projects, detect automatically if not provided (optional).
+	RegistryProvider provider.Type
+	// RegistryProviderURL is the API server URL of the registry provider,
+	// the destination registry is used if not provided (optional).
+	RegistryProviderURL string
+	// SkipRegistries are the registries which do not need to create the
+	// projects, e.g. the embedded registry of the storage directory (optional).
+	SkipRegistries []string
+}
+
+// projectSet is the image names of each project of the destination
+// registries: map[registry][project][name]bool.
+type projectSet map[string]map[string]map[string]bool
+
+// add adds the image name of the project of the registry.
+func (s projectSet) add(registry, project, name string) {
+	if s[registry] == nil {
+		s[registry] = map[string]map[string]bool{}
+	}
+	if s[registry][project] == nil {
+		s[registry][project] = map[string]bool{}
+	}
+	s[registry][project][name] = true
+}
+
+// createProjects creates the projects of the destination registries by the
+// registry provider, the projectSet is the projects of each registry and the
+// sysCtx returns the system context of each registry.
+func (o *ProjectOpts) createProjects(
+	ctx context.Context,
+	projectSet projectSet,
+	sysCtx func(registry string) *types.SystemContext,
+) error {
+	registries := make([]string, 0, len(projectSet))
+	for r := range projectSet {
+		if slices.Contains(o.SkipRegistries, r) {
+			continue
+		}
+		registries = append(registries, r)
+	}
+	sort.Strings(registries)
+
+	var robots []*harbor.Robot
+	for _, registry := range registries {
+		r, err := o.createRegistryProjects(
+			ctx, registry, projectSet[registry], sysCtx(registry))
+		if err != nil {
+			return err
+		}
+		robots = append(robots, r...)
+	}
+	if len(robots) == 0 {
+		return nil
+	}
+	if o.HarborRobotFile == "" {
+		// The secrets are printed to stdout only, not to the log.
+		logrus.Infof("Created %d robot accounts, secrets printed to stdout", len(robots))
+		for _, r := range robots {
+			fmt.Fprintf(os.Stdout, "%s\t%s\n", r.Name, r.Secret)
+		}
+		return nil
+	}
+	if err := harbor.SaveRobotAccounts(o.HarborRobotFile, "", robots); err != nil {
+		return err
+	}
+	logrus.Infof("Robot accounts exported to %q", o.HarborRobotFile)
+	return nil
+}
+
+// createRegistryProjects creates the projects of the registry and returns
+// the created Harbor V2 robot accounts, the repositories of the image names
+// are also created if required by the provider.
+func (o *ProjectOpts) createRegistryProjects(
+	ctx context.Context,
+	registry string,
+	projectNames map[string]map[string]bool,
+	sysCtx *types.SystemContext,
+) ([]*harbor.Robot, error) {
+	projects := make([]string, 0, len(projectNames))
+	for p := range projectNames {
+		projects = append(projects, p)
+	}
+	sort.Strings(projects)
+	registryCredential, err := config.GetCredentials(sysCtx, registry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get credential of %q: %w",
+			registry, err)
+	}
+	credential := harbor.APICredential(registryCredential, o.HarborToken)
+	p, err := provider.New(ctx, &provider.Options{
+		Type:          o.RegistryProvider,
+		Registry:      registry,
+		URL:           o.RegistryProviderURL,
+		Credential:    credential,
+		SystemContext: sysCtx,
+		HarborProject: o.HarborProject,
+	})
+	if err != nil {
+		if errors.Is(err, provider.ErrUnknownProvider) {
+			logrus.Debugf("Skip creating projects: %v", err)
+			return nil, nil
+		}
+		return nil, err
+	}
+	if p == nil {
+		return nil, nil
+	}
+
+	var robots []*harbor.Robot
+	for _, project := range projects {
+		exists, err := p.NamespaceExists(ctx, project)
+		if err != nil {
+			if errors.Is(err, provider.ErrPermissionDenied) {
+				logrus.Warnf("Skip creating %v project %q: %v",
+					p.Type(), project, err)
+				continue
+			}
+			return nil, err
+		}
+		if !exists {
+			if err = p.CreateNamespace(ctx, project); err != nil {
+				if errors.Is(err, provider.ErrPermissionDenied) {
+					logrus.Warnf("Skip creating %v project %q: %v",
+						p.Type(), project, err)
+					continue
+				}
+				return nil, err
+			}
+			logrus.Infof("Created %v project %q for registry %q",
+				p.Type(), project, registry)
+		}
+		if err := createRepositories(ctx, p, project, projectNames[project]); err != nil {
+			return nil, err
+		}
+		if o.HarborRobot == nil || p.Type() != provider.TypeHarbor {
+			continue
+		}
+		robot, err := harbor.CreateRobotAccount(
+			ctx, project, o.HarborRobot, p.URL(), credential, sysCtx)
+		if err != nil {
+			logrus.Warnf("Failed to create robot account of project %q: %v",
+				project, err)
+			continue
+		}
+		logrus.Infof("Created robot account %q of project %q",
+			robot.Name, project)
+		robot.Registry = registry
+		robots = append(robots, robot)
+	}
+	return robots, nil
+}
+
+// createRepositories creates the repositories of the image names in the
+// project if required by the provider.
+func createRepositories(
+	ctx context.Context, p provider.Provider, project string, names map[string]bool,
+) error {
+	rc, ok := p.(provider.RepositoryCreator)
+	if !ok {
+		return nil
+	}
+	repositories := make([]string, 0, len(names))
+	for n := range names {
+		repositories = append(repositories, n)
+	}
+	sort.Strings(repositories)
+	for _, name := range repositories {
+		exists, err := rc.RepositoryExists(ctx, project, name)
+		if err == nil && !exists {
+			err = rc.CreateRepository(ctx, project, name)
+			if err == nil {
+				logrus.Infof("Created %v repository %q of project %q",
+					p.Type(), name, project)
+			}
+		}
+		if err != nil {
+			if errors.Is(err, provider.ErrPermissionDenied) {
+				logrus.Warnf("Skip creating %v repository %q of project %q: %v",
+					p.Type(), name, project, err)
+				continue
+			}
+			return err
+		}
+	}
+	return nil
+}
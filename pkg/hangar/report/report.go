@@ -0,0 +1,266 @@
+// Package report provides the machine-readable run report of the hangar
+// commands, the report can be exported in JSON or JUnit XML format.
+package report
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+)
+
+// Status is the result status of the image.
+type Status string
+
+const (
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+	// StatusSkipped is the status of the failed optional image.
+	StatusSkipped Status = "skipped"
+)
+
+// Format is the file format of the report.
+type Format string
+
+const (
+	FormatJSON  Format = "json"
+	FormatJUnit Format = "junit"
+)
+
+// ParseFormat parses the report format, the format is detected by the
+// file name extension if not provided (.xml for JUnit, otherwise JSON).
+func ParseFormat(s, name string) (Format, error) {
+	switch f := Format(strings.ToLower(s)); f {
+	case "":
+		if strings.EqualFold(filepath.Ext(name), ".xml") {
+			return FormatJUnit, nil
+		}
+		return FormatJSON, nil
+	case FormatJSON, FormatJUnit:
+		return f, nil
+	}
+	return "", fmt.Errorf("invalid report format %q, available: json, junit", s)
+}
+
+// Platform is the platform image of the image.
+type Platform struct {
+	OS      string        `json:"os,omitempty"`
+	Arch    string        `json:"arch,omitempty"`
+	Variant string        `json:"variant,omitempty"`
+	Digest  digest.Digest `json:"digest,omitempty"`
+}
+
+func (p Platform) String() string {
+	s := p.OS + "/" + p.Arch
+	if p.Variant != "" {
+		s += "/" + p.Variant
+	}
+	return s
+}
+
+// Image is the result of a single image.
+type Image struct {
+	// ID is the line number of the image in image list.
+	ID           int      `json:"id"`
+	Source       string   `json:"source"`
+	Destinations []string `json:"destinations,omitempty"`
+	Status       Status   `json:"status"`
+	// Digest is the manifest digest of the source image.
+	Digest digest.Digest `json:"digest,omitempty"`
+	// Platforms are the platform images copied.
+	Platforms []Platform `json:"platforms,omitempty"`
+	// SkippedPlatforms are the platform images skipped by the filter.
+	SkippedPlatforms []Platform `json:"skippedPlatforms,omitempty"`
+	BytesTransferred int64      `json:"bytesTransferred"`
+	// Duration is the duration in seconds.
+	Duration      float64 `json:"duration"`
+	Retries       int     `json:"retries"`
+	Error         string  `json:"error,omitempty"`
+	ErrorCategory string  `json:"errorCategory,omitempty"`
+}
+
+// Name returns the name of the image used as the JUnit test case name.
+func (i *Image) Name() string {
+	if len(i.Destinations) == 0 {
+		return i.Source
+	}
+	return fmt.Sprintf("%s => %s", i.Source, strings.Join(i.Destinations, ", "))
+}
+
+// Report is the run report of the images. The methods of the nil Report
+// do nothing.
+type Report struct {
+	mutex *sync.Mutex
+
+	// Operation is the command name, example: mirror, load-validate
+	Operation string    `json:"operation"`
+	StartTime time.Time `json:"startTime"`
+	EndTime   time.Time `json:"endTime"`
+	// Duration is the duration in seconds.
+	Duration         float64  `json:"duration"`
+	Total            int      `json:"total"`
+	Succeeded        int      `json:"succeeded"`
+	Failed           int      `json:"failed"`
+	Skipped          int      `json:"skipped"`
+	BytesTransferred int64    `json:"bytesTransferred"`
+	Images           []*Image `json:"images"`
+}
+
+func New(operation string) *Report {
+	return &Report{
+		mutex:     &sync.Mutex{},
+		Operation: operation,
+		StartTime: time.Now(),
+		Images:    make([]*Image, 0),
+	}
+}
+
+// Add adds the result of the image into the report (thread-safe).
+func (r *Report) Add(img *Image) {
+	if r == nil || img == nil {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.Images = append(r.Images, img)
+}
+
+// Finish sorts the images by ID and updates the summary of the report.
+func (r *Report) Finish() {
+	if r == nil {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.EndTime = time.Now()
+	r.Duration = r.EndTime.Sub(r.StartTime).Seconds()
+	sort.SliceStable(r.Images, func(i, j int) bool {
+		return r.Images[i].ID < r.Images[j].ID
+	})
+	r.Total, r.Succeeded, r.Failed, r.Skipped = len(r.Images), 0, 0, 0
+	r.BytesTransferred = 0
+	for _, img := range r.Images {
+		switch img.Status {
+		case StatusSucceeded:
+			r.Succeeded++
+		case StatusFailed:
+			r.Failed++
+		case StatusSkipped:
+			r.Skipped++
+		}
+		r.BytesTransferred += img.BytesTransferred
+	}
+}
+
+// WriteJSON writes the report in JSON format.
+func (r *Report) WriteJSON(w io.Writer) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	return e.Encode(r)
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr,omitempty"`
+}
+
+// WriteJUnit writes the report in JUnit XML format, each image is a test
+// case of the test suite.
+func (r *Report) WriteJUnit(w io.Writer) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	suite := junitTestSuite{
+		Name:      "hangar " + r.Operation,
+		Tests:     len(r.Images),
+		Time:      fmt.Sprintf("%.3f", r.Duration),
+		Timestamp: r.StartTime.Format(time.RFC3339),
+	}
+	for _, img := range r.Images {
+		c := junitTestCase{
+			Name:      img.Name(),
+			ClassName: "hangar." + r.Operation,
+			Time:      fmt.Sprintf("%.3f", img.Duration),
+		}
+		var platforms []string
+		for _, p := range img.Platforms {
+			platforms = append(platforms, fmt.Sprintf("%s %s", p, p.Digest))
+		}
+		c.SystemOut = strings.Join(platforms, "\n")
+		switch img.Status {
+		case StatusFailed:
+			suite.Failures++
+			c.Failure = &junitMessage{Message: img.Error, Type: img.ErrorCategory}
+		case StatusSkipped:
+			suite.Skipped++
+			c.Skipped = &junitMessage{Message: img.Error}
+		}
+		suite.Cases = append(suite.Cases, c)
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	e := xml.NewEncoder(w)
+	e.Indent("", "  ")
+	if err := e.Encode(junitTestSuites{Suites: []junitTestSuite{suite}}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// Save writes the report into the file.
+func (r *Report) Save(name string, format Format) error {
+	if r == nil {
+		return nil
+	}
+	f, err := os.Create(name)
+	if err != nil {
+		return fmt.Errorf("failed to create file %q: %w", name, err)
+	}
+	defer f.Close()
+	switch format {
+	case FormatJUnit:
+		err = r.WriteJUnit(f)
+	default:
+		err = r.WriteJSON(f)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write report %q: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package report_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cnrancher/hangar/pkg/hangar/report"
+	"github.com/stretchr/testify/assert"
+)
+
+func newReport() *report.Report {
+	r := report.New("mirror")
+	r.Add(&report.Image{
+		ID:            2,
+		Source:        "docker.io/library/nginx:latest",
+		Destinations:  []string{"registry.io/library/nginx:latest"},
+		Status:        report.StatusFailed,
+		Error:         "unauthorized",
+		ErrorCategory: "auth",
+	})
+	r.Add(&report.Image{
+		ID:           1,
+		Source:       "docker.io/library/busybox:latest",
+		Destinations: []string{"registry.io/library/busybox:latest"},
+		Status:       report.StatusSucceeded,
+		Platforms: []report.Platform{
+			{OS: "linux", Arch: "arm", Variant: "v7", Digest: "sha256:aaa"},
+		},
+		BytesTransferred: 1024,
+		Retries:          1,
+	})
+	r.Add(&report.Image{
+		ID:               3,
+		Source:           "docker.io/library/alpine:latest",
+		Status:           report.StatusSkipped,
+		BytesTransferred: 10,
+	})
+	r.Add(nil)
+	return r
+}
+
+func Test_ParseFormat(t *testing.T) {
+	assert := assert.New(t)
+	f, err := report.ParseFormat("", "report.json")
+	assert.NoError(err)
+	assert.Equal(report.FormatJSON, f)
+	f, err = report.ParseFormat("", "report.XML")
+	assert.NoError(err)
+	assert.Equal(report.FormatJUnit, f)
+	f, err = report.ParseFormat("JUnit", "report.json")
+	assert.NoError(err)
+	assert.Equal(report.FormatJUnit, f)
+	_, err = report.ParseFormat("yaml", "")
+	assert.Error(err)
+}
+
+func Test_NilReport(t *testing.T) {
+	var r *report.Report
+	r.Add(&report.Image{})
+	r.Finish()
+	assert.NoError(t, r.Save("", report.FormatJSON))
+}
+
+func Test_Finish(t *testing.T) {
+	assert := assert.New(t)
+	r := newReport()
+	r.Finish()
+	assert.Equal(3, r.Total)
+	assert.Equal(1, r.Succeeded)
+	assert.Equal(1, r.Failed)
+	assert.Equal(1, r.Skipped)
+	assert.Equal(int64(1034), r.BytesTransferred)
+	for i, img := range r.Images {
+		assert.Equal(i+1, img.ID)
+	}
+}
+
+func Test_WriteJSON(t *testing.T) {
+	assert := assert.New(t)
+	r := newReport()
+	r.Finish()
+	b := &bytes.Buffer{}
+	assert.NoError(r.WriteJSON(b))
+
+	decoded := &report.Report{}
+	assert.NoError(json.Unmarshal(b.Bytes(), decoded))
+	assert.Equal("mirror", decoded.Operation)
+	assert.Equal(3, len(decoded.Images))
+	assert.Equal("linux/arm/v7", decoded.Images[0].Platforms[0].String())
+	assert.Equal("auth", decoded.Images[1].ErrorCategory)
+}
+
+func Test_WriteJUnit(t *testing.T) {
+	assert := assert.New(t)
+	r := newReport()
+	r.Finish()
+	b := &bytes.Buffer{}
+	assert.NoError(r.WriteJUnit(b))
+	s := b.String()
+	assert.True(strings.HasPrefix(s, "<?xml"))
+	assert.Contains(s, `<testsuite name="hangar mirror" tests="3" failures="1" skipped="1"`)
+	assert.Contains(s, `<failure message="unauthorized" type="auth"></failure>`)
+	assert.Contains(s, "docker.io/library/busybox:latest =&gt; registry.io/library/busybox:latest")
+}
+
+func Test_Save(t *testing.T) {
+	assert := assert.New(t)
+	name := filepath.Join(t.TempDir(), "report.xml")
+	r := newReport()
+	r.Finish()
+	assert.NoError(r.Save(name, report.FormatJUnit))
+	b, err := os.ReadFile(name)
+	assert.NoError(err)
+	assert.Contains(string(b), "<testsuites>")
+}
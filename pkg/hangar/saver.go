@@ -13,12 +13,16 @@ import (
 	"github.com/cnrancher/hangar/pkg/destination"
 	"github.com/cnrancher/hangar/pkg/hangar/archive"
 	"github.com/cnrancher/hangar/pkg/hangar/imagelist"
+	"github.com/cnrancher/hangar/pkg/hangar/report"
+	"github.com/cnrancher/hangar/pkg/logging"
 	"github.com/cnrancher/hangar/pkg/source"
+	"github.com/cnrancher/hangar/pkg/tracing"
 	"github.com/cnrancher/hangar/pkg/types"
 	"github.com/cnrancher/hangar/pkg/utils"
 	imagemanifest "github.com/containers/image/v5/manifest"
 	"github.com/opencontainers/go-digest"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // saveObject is the object for sending to worker pool when saving image
@@ -28,6 +32,17 @@ type saveObject struct {
 	destination *destination.Destination
 	timeout     time.Duration
 	id          int
+
+	// imageSpecSet overrides the arch/os/variant set of the image (optional)
+	imageSpecSet map[string]map[string]bool
+	// optional image will not be recorded as failed
+	optional bool
+	// tags is the additional tags of the image saved into archive index
+	tags []string
+}
+
+func (o *saveObject) registries() []string {
+	return []string{o.source.Registry()}
 }
 
 type Saver struct {
@@ -42,6 +57,9 @@ type Saver struct {
 	SourceRegistry string
 	// Override the project of source image to be copied
 	SourceProject string
+	// SourceType is the source image type, available types are
+	// docker (default) and containerd.
+	SourceType types.ImageType
 	// SharedBlobDirPath is the directory to save the shared blobs
 	SharedBlobDirPath string
 	// ArchiveName is the saved archive file name
@@ -55,6 +73,9 @@ type SaverOpts struct {
 	SourceRegistry string
 	// Override the project of source image to be copied
 	SourceProject string
+	// SourceType is the source image type, available types are
+	// docker (default) and containerd.
+	SourceType types.ImageType
 	// SharedBlobDirPath is the directory to save the shared blobs
 	SharedBlobDirPath string
 	// ArchiveName is the saved archive file name
@@ -69,9 +90,13 @@ func NewSaver(o *SaverOpts) (*Saver, error) {
 
 		SourceRegistry:    o.SourceRegistry,
 		SourceProject:     o.SourceProject,
+		SourceType:        o.SourceType,
 		SharedBlobDirPath: o.SharedBlobDirPath,
 		ArchiveName:       o.ArchiveName,
 	}
+	if s.SourceType == types.TypeUndefined {
+		s.SourceType = types.TypeDocker
+	}
 	if s.SharedBlobDirPath == "" {
 		s.SharedBlobDirPath = archive.SharedBlobDir
 	}
@@ -86,6 +111,7 @@ func NewSaver(o *SaverOpts) (*Saver, error) {
 func (s *Saver) copy(ctx context.Context) {
 	s.common.initErrorHandler(ctx)
 	s.common.initWorker(ctx, s.worker)
+	s.progress.Start(len(s.common.images) + len(s.common.entries))
 	for i, img := range s.common.images {
 		switch imagelist.Detect(img) {
 		case imagelist.TypeDefault:
@@ -93,57 +119,40 @@ func (s *Saver) copy(ctx context.Context) {
 			logrus.Warnf("Ignore image list line %q: invalid format", img)
 			continue
 		}
-		object := &saveObject{
-			id:    i + 1,
-			image: img,
-		}
-		sourceRegistry := utils.GetRegistryName(img)
-		if s.SourceRegistry != "" {
-			sourceRegistry = s.SourceRegistry
-		}
-		sourceProject := utils.GetProjectName(img)
-		if s.SourceProject != "" {
-			sourceProject = s.SourceProject
-		}
-		src, err := source.NewSource(&source.Option{
-			Type:          types.TypeDocker,
-			Registry:      sourceRegistry,
-			Project:       sourceProject,
-			Name:          utils.GetImageName(img),
-			Tag:           utils.GetImageTag(img),
-			SystemContext: s.systemContext,
-		})
+		object, err := s.newSaveObject(img, true)
 		if err != nil {
-			s.handleError(fmt.Errorf("failed to init source image: %w", err))
-			s.recordFailedImage(img)
+			s.reportFailure(i+1, img, err, false)
+			s.handleError(NewError(i+1, err, nil, nil))
+			s.recordFailedImage(i+1, img, err)
 			continue
 		}
-		object.source = src
-
-		cd, err := s.newSaveCacheDir()
-		if err != nil {
-			s.handleError(fmt.Errorf("failed to create cache dir: %w", err))
-			os.RemoveAll(cd)
-			s.recordFailedImage(img)
+		object.id = i + 1
+		if err = s.handleObject(object); err != nil {
+			os.RemoveAll(object.destination.Directory())
+		}
+	}
+	for i, e := range s.common.entries {
+		if e.Skip {
+			logrus.Infof("Skip image %q", e.Source)
 			continue
 		}
-		sd := path.Join(cd, s.SharedBlobDirPath)
-		dest, err := destination.NewDestination(&destination.Option{
-			Type:          types.TypeOci,
-			Directory:     cd,
-			Name:          utils.GetImageName(img),
-			Tag:           utils.GetImageTag(img),
-			SystemContext: utils.SystemContextWithSharedBlobDir(s.systemContext, sd),
-		})
+		object, err := s.newSaveObject(e.Source, true)
 		if err != nil {
-			s.handleError(fmt.Errorf("failed to init dest image: %w", err))
-			os.RemoveAll(cd)
-			s.recordFailedImage(img)
+			s.reportFailure(i+1, e.Source, err, e.Optional)
+			if e.Optional {
+				logrus.Warnf("Skip optional image %q: %v", e.Source, err)
+				continue
+			}
+			s.handleError(NewError(i+1, err, nil, nil))
+			s.recordFailedImage(i+1, e.Source, err)
 			continue
 		}
-		object.destination = dest
+		object.id = i + 1
+		object.imageSpecSet = s.entryImageSpecSet(e)
+		object.optional = e.Optional
+		object.tags = e.Tags
 		if err = s.handleObject(object); err != nil {
-			os.RemoveAll(cd)
+			os.RemoveAll(object.destination.Directory())
 		}
 	}
 	s.waitWorkers()
@@ -164,12 +173,70 @@ func (s *Saver) newSaveCacheDir() (string, error) {
 	return cd, nil
 }
 
+// newSaveObject creates the saveObject of the image, the cache directory and the
+// OCI destination image will be created if withDestination is true.
+func (s *Saver) newSaveObject(img string, withDestination bool) (*saveObject, error) {
+	object := &saveObject{
+		image: img,
+	}
+	_, dig, err := utils.SplitImageDigest(img)
+	if err != nil {
+		return nil, err
+	}
+	sourceRegistry := utils.GetRegistryName(img)
+	if s.SourceRegistry != "" {
+		sourceRegistry = s.SourceRegistry
+	}
+	sourceProject := utils.GetProjectName(img)
+	if s.SourceProject != "" {
+		sourceProject = s.SourceProject
+	}
+	src, err := source.NewSource(&source.Option{
+		Type:          s.SourceType,
+		Registry:      sourceRegistry,
+		Project:       sourceProject,
+		Name:          utils.GetImageName(img),
+		Tag:           utils.GetImageTag(img),
+		Digest:        dig,
+		SystemContext: s.sourceSystemContext(sourceRegistry),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to init source image: %w", err)
+	}
+	object.source = src
+	if !withDestination {
+		return object, nil
+	}
+
+	cd, err := s.newSaveCacheDir()
+	if err != nil {
+		os.RemoveAll(cd)
+		return nil, fmt.Errorf("failed to create cache dir: %w", err)
+	}
+	sd := path.Join(cd, s.SharedBlobDirPath)
+	dest, err := destination.NewDestination(&destination.Option{
+		Type:          types.TypeOci,
+		Directory:     cd,
+		Name:          utils.GetImageName(img),
+		Tag:           utils.GetImageTag(img),
+		SystemContext: utils.SystemContextWithSharedBlobDir(s.systemContext, sd),
+	})
+	if err != nil {
+		os.RemoveAll(cd)
+		return nil, fmt.Errorf("failed to init dest image: %w", err)
+	}
+	object.destination = dest
+	return object, nil
+}
+
 func (s *Saver) writeIndex() error {
 	return s.aw.WriteIndex(s.index)
 }
 
 // Run save images from registry server into local directory / hangar archive.
-func (s *Saver) Run(ctx context.Context) error {
+func (s *Saver) Run(ctx context.Context) (err error) {
+	ctx, span := tracing.Start(ctx, "save")
+	defer func() { tracing.End(span, err) }()
 	// Init Archive Writer.
 	aw, err := archive.NewWriter(s.ArchiveName)
 	if err != nil {
@@ -177,14 +244,11 @@ func (s *Saver) Run(ctx context.Context) error {
 	}
 	s.aw = aw
 
+	s.startReport("save")
 	s.copy(ctx)
-	if len(s.failedImageSet) != 0 {
-		v := make([]string, 0, len(s.failedImageSet))
-		for i := range s.failedImageSet {
-			v = append(v, i)
-		}
+	if v := s.failedImages(); len(v) != 0 {
 		logrus.Errorf("Save failed image list: \n%v", strings.Join(v, "\n"))
-		return ErrCopyFailed
+		return s.failedError(ErrCopyFailed)
 	}
 	return nil
 }
@@ -209,10 +273,37 @@ func (s *Saver) worker(ctx context.Context, o any) {
 	} else {
 		copyContext, cancel = context.WithCancel(ctx)
 	}
+	copyContext, span := tracing.Start(copyContext, "save image",
+		attribute.Int("id", obj.id),
+		attribute.String("source", obj.source.ReferenceNameWithoutTransport()))
+	copyContext = logging.WithFields(copyContext, logrus.Fields{
+		logging.FieldImageID:     obj.id,
+		logging.FieldSource:      obj.source.ReferenceNameWithoutTransport(),
+		logging.FieldDestination: s.ArchiveName,
+		logging.FieldPhase:       logging.PhaseCopy,
+	})
+	p := s.progress.NewImage(obj.source.ReferenceNameWithoutTransport())
+	obj.source.SetProgress(p)
+	r := &report.Image{
+		ID:           obj.id,
+		Source:       obj.source.ReferenceNameWithoutTransport(),
+		Destinations: []string{s.ArchiveName},
+	}
+	start := time.Now()
 	defer func() {
-		if err != nil {
+		p.Done(err)
+		tracing.End(span, err)
+		r.Digest = obj.source.ManifestDigest()
+		r.Platforms, r.SkippedPlatforms = reportPlatforms(
+			obj.source.Platforms(), obj.source.GetCopiedImage().Images)
+		s.reportImage(r, start, p, err, obj.optional)
+		if err != nil && obj.optional {
+			logging.FromContext(copyContext).
+				Warnf("Skip optional image [%v]: %v",
+					obj.source.ReferenceNameWithoutTransport(), err)
+		} else if err != nil {
 			s.handleError(NewError(obj.id, err, obj.source, obj.destination))
-			s.recordFailedImage(obj.image)
+			s.recordFailedImage(obj.id, obj.image, err)
 		}
 		cancel()
 		// Delete cache dir.
@@ -227,17 +318,17 @@ func (s *Saver) worker(ctx context.Context, o any) {
 		err = fmt.Errorf("failed to init source: %w", err)
 		return
 	}
-	logrus.WithFields(logrus.Fields{"IMG": obj.id}).
+	logging.FromContext(copyContext).
 		Infof("Saving [%v]", obj.source.ReferenceNameWithoutTransport())
 	err = obj.destination.Init(copyContext)
 	if err != nil {
 		err = fmt.Errorf("failed to init destination: %w", err)
 		return
 	}
-	err = obj.source.Copy(copyContext, obj.destination, s.imageSpecSet, s.policy)
+	err = obj.source.Copy(copyContext, obj.destination, s.specSet(obj.imageSpecSet), s.policy)
 	if err != nil {
 		if errors.Is(err, utils.ErrNoAvailableImage) {
-			logrus.WithFields(logrus.Fields{"IMG": obj.id}).
+			logging.FromContext(copyContext).
 				Warnf("Skip save image [%v]: %v",
 					obj.source.ReferenceNameWithoutTransport(), err)
 			err = nil
@@ -252,7 +343,7 @@ func (s *Saver) worker(ctx context.Context, o any) {
 	s.awMutex.Lock()
 	defer s.awMutex.Unlock()
 
-	logrus.WithFields(logrus.Fields{"IMG": obj.id}).
+	logging.FromContext(copyContext).
 		Debugf("Compressing [%v]", obj.destination.ReferenceNameWithoutTransport())
 
 	destDir := obj.destination.Directory()
@@ -302,9 +393,19 @@ func (s *Saver) worker(ctx context.Context, o any) {
 		return
 	}
 	s.index.Append(copiedImage)
+	for _, t := range obj.tags {
+		if t == "" || t == copiedImage.Tag {
+			continue
+		}
+		img := *copiedImage
+		img.Tag = t
+		s.index.Append(&img)
+	}
 }
 
-func (s *Saver) Validate(ctx context.Context) error {
+func (s *Saver) Validate(ctx context.Context) (err error) {
+	ctx, span := tracing.Start(ctx, "save-validate")
+	defer func() { tracing.End(span, err) }()
 	ar, err := archive.NewReader(s.ArchiveName)
 	if err != nil {
 		return fmt.Errorf("failed to create archive reader: %w", err)
@@ -319,15 +420,12 @@ func (s *Saver) Validate(ctx context.Context) error {
 	if err := s.index.Unmarshal(b); err != nil {
 		return fmt.Errorf("failed to read archive index: %w", err)
 	}
+	s.startReport("save-validate")
 	s.validate(ctx)
 
-	if len(s.failedImageSet) != 0 {
-		v := make([]string, 0, len(s.failedImageSet))
-		for i := range s.failedImageSet {
-			v = append(v, i)
-		}
+	if v := s.failedImages(); len(v) != 0 {
 		logrus.Errorf("Validate failed image list: \n%v", strings.Join(v, "\n"))
-		return ErrValidateFailed
+		return s.failedError(ErrValidateFailed)
 	}
 	return nil
 }
@@ -342,32 +440,34 @@ func (s *Saver) validate(ctx context.Context) {
 			logrus.Warnf("Ignore image list line %q: invalid format", img)
 			continue
 		}
-		object := &saveObject{
-			id:    i + 1,
-			image: img,
-		}
-		sourceRegistry := utils.GetRegistryName(img)
-		if s.SourceRegistry != "" {
-			sourceRegistry = s.SourceRegistry
+		object, err := s.newSaveObject(img, false)
+		if err != nil {
+			s.reportFailure(i+1, img, err, false)
+			s.handleError(NewError(i+1, err, nil, nil))
+			s.recordFailedImage(i+1, img, err)
+			continue
 		}
-		sourceProject := utils.GetProjectName(img)
-		if s.SourceProject != "" {
-			sourceProject = s.SourceProject
+		object.id = i + 1
+		s.handleObject(object)
+	}
+	for i, e := range s.common.entries {
+		if e.Skip {
+			continue
 		}
-		src, err := source.NewSource(&source.Option{
-			Type:          types.TypeDocker,
-			Registry:      sourceRegistry,
-			Project:       sourceProject,
-			Name:          utils.GetImageName(img),
-			Tag:           utils.GetImageTag(img),
-			SystemContext: s.systemContext,
-		})
+		object, err := s.newSaveObject(e.Source, false)
 		if err != nil {
-			s.handleError(fmt.Errorf("failed to init source image: %w", err))
-			s.recordFailedImage(img)
+			s.reportFailure(i+1, e.Source, err, e.Optional)
+			if e.Optional {
+				logrus.Warnf("Skip optional image %q: %v", e.Source, err)
+				continue
+			}
+			s.handleError(NewError(i+1, err, nil, nil))
+			s.recordFailedImage(i+1, e.Source, err)
 			continue
 		}
-		object.source = src
+		object.id = i + 1
+		object.imageSpecSet = s.entryImageSpecSet(e)
+		object.optional = e.Optional
 		s.handleObject(object)
 	}
 	s.waitWorkers()
@@ -393,12 +493,35 @@ func (s *Saver) validateWorker(ctx context.Context, o any) {
 	} else {
 		validateContext, cancel = context.WithCancel(ctx)
 	}
-
+	validateContext = logging.WithFields(validateContext, logrus.Fields{
+		logging.FieldImageID:     obj.id,
+		logging.FieldSource:      obj.source.ReferenceNameWithoutTransport(),
+		logging.FieldDestination: s.ArchiveName,
+		logging.FieldPhase:       logging.PhaseValidate,
+	})
+
+	r := &report.Image{
+		ID:           obj.id,
+		Source:       obj.source.ReferenceNameWithoutTransport(),
+		Destinations: []string{s.ArchiveName},
+	}
+	start := time.Now()
 	defer func() {
 		cancel()
+		r.Digest = obj.source.ManifestDigest()
+		r.Platforms, r.SkippedPlatforms = reportPlatforms(
+			obj.source.Platforms(),
+			obj.source.ImageBySet(s.specSet(obj.imageSpecSet)).Images)
+		s.reportImage(r, start, nil, err, obj.optional)
+		if err != nil && obj.optional {
+			logging.FromContext(validateContext).
+				Warnf("Skip optional image [%v]: %v",
+					obj.source.ReferenceNameWithoutTransport(), err)
+			return
+		}
 		if err != nil {
 			s.handleError(NewError(obj.id, err, nil, nil))
-			s.recordFailedImage(obj.image)
+			s.recordFailedImage(obj.id, obj.image, err)
 		}
 	}()
 
@@ -417,21 +540,21 @@ func (s *Saver) validateWorker(ctx context.Context, o any) {
 			fail = true
 		}
 	default:
-		image := obj.source.ImageBySet(s.imageSpecSet)
+		image := obj.source.ImageBySet(s.specSet(obj.imageSpecSet))
 		if !s.index.Has(image) {
 			fail = true
 		}
 	}
 
 	if fail {
-		logrus.WithFields(logrus.Fields{"IMG": obj.id}).
+		logging.FromContext(validateContext).
 			Errorf("Image [%v] does not exists in archive index",
 				obj.source.ReferenceNameWithoutTransport())
-		err = fmt.Errorf("FAILED: [%v]",
-			obj.source.ReferenceNameWithoutTransport())
+		err = fmt.Errorf("FAILED: [%v]: %w",
+			obj.source.ReferenceNameWithoutTransport(), ErrImageNotFound)
 		return
 	}
 
-	logrus.WithFields(logrus.Fields{"IMG": obj.id}).
+	logging.FromContext(validateContext).
 		Infof("PASS: [%v]", obj.source.ReferenceNameWithoutTransport())
 }
@@ -13,12 +13,16 @@ import (
 	"github.com/cnrancher/hangar/pkg/destination"
 	"github.com/cnrancher/hangar/pkg/hangar/archive"
 	"github.com/cnrancher/hangar/pkg/hangar/imagelist"
+	"github.com/cnrancher/hangar/pkg/hangar/report"
+	"github.com/cnrancher/hangar/pkg/logging"
 	"github.com/cnrancher/hangar/pkg/source"
+	"github.com/cnrancher/hangar/pkg/tracing"
 	"github.com/cnrancher/hangar/pkg/types"
 	"github.com/cnrancher/hangar/pkg/utils"
 	imagemanifest "github.com/containers/image/v5/manifest"
 	"github.com/opencontainers/go-digest"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // syncObject is the object for sending to worker pool when syncing image
@@ -28,6 +32,17 @@ type syncObject struct {
 	destination *destination.Destination
 	timeout     time.Duration
 	id          int
+
+	// imageSpecSet overrides the arch/os/variant set of the image (optional)
+	imageSpecSet map[string]map[string]bool
+	// optional image will not be recorded as failed
+	optional bool
+	// tags is the additional tags of the image saved into archive index
+	tags []string
+}
+
+func (o *syncObject) registries() []string {
+	return []string{o.source.Registry()}
 }
 
 type Syncer struct {
@@ -86,6 +101,7 @@ func NewSyncer(o *SyncerOpts) (*Syncer, error) {
 func (s *Syncer) copy(ctx context.Context) {
 	s.common.initErrorHandler(ctx)
 	s.common.initWorker(ctx, s.worker)
+	s.progress.Start(len(s.common.images) + len(s.common.entries))
 	for i, img := range s.common.images {
 		switch imagelist.Detect(img) {
 		case imagelist.TypeDefault:
@@ -93,57 +109,40 @@ func (s *Syncer) copy(ctx context.Context) {
 			logrus.Warnf("Ignore image list line %q: invalid format", img)
 			continue
 		}
-		object := &syncObject{
-			id:    i + 1,
-			image: img,
-		}
-		sourceRegistry := utils.GetRegistryName(img)
-		if s.SourceRegistry != "" {
-			sourceRegistry = s.SourceRegistry
-		}
-		sourceProject := utils.GetProjectName(img)
-		if s.SourceProject != "" {
-			sourceProject = s.SourceProject
-		}
-		src, err := source.NewSource(&source.Option{
-			Type:          types.TypeDocker,
-			Registry:      sourceRegistry,
-			Project:       sourceProject,
-			Name:          utils.GetImageName(img),
-			Tag:           utils.GetImageTag(img),
-			SystemContext: s.systemContext,
-		})
+		object, err := s.newSyncObject(img, true)
 		if err != nil {
-			s.handleError(fmt.Errorf("failed to init source image: %w", err))
-			s.recordFailedImage(img)
+			s.reportFailure(i+1, img, err, false)
+			s.handleError(NewError(i+1, err, nil, nil))
+			s.recordFailedImage(i+1, img, err)
 			continue
 		}
-		object.source = src
-
-		cd, err := s.newSaveCacheDir()
-		if err != nil {
-			s.handleError(fmt.Errorf("failed to create cache dir: %w", err))
-			s.recordFailedImage(img)
+		object.id = i + 1
+		if err = s.handleObject(object); err != nil {
+			os.RemoveAll(object.destination.Directory())
+		}
+	}
+	for i, e := range s.common.entries {
+		if e.Skip {
+			logrus.Infof("Skip image %q", e.Source)
 			continue
 		}
-		sd := path.Join(cd, s.SharedBlobDirPath)
-		dest, err := destination.NewDestination(&destination.Option{
-			Type:      types.TypeOci,
-			Directory: cd,
-			Name:      utils.GetImageName(img),
-			Tag:       utils.GetImageTag(img),
-			SystemContext: utils.SystemContextWithSharedBlobDir(
-				s.systemContext, sd),
-		})
+		object, err := s.newSyncObject(e.Source, true)
 		if err != nil {
-			s.handleError(fmt.Errorf("failed to init dest image: %w", err))
-			os.RemoveAll(cd)
-			s.recordFailedImage(img)
+			s.reportFailure(i+1, e.Source, err, e.Optional)
+			if e.Optional {
+				logrus.Warnf("Skip optional image %q: %v", e.Source, err)
+				continue
+			}
+			s.handleError(NewError(i+1, err, nil, nil))
+			s.recordFailedImage(i+1, e.Source, err)
 			continue
 		}
-		object.destination = dest
+		object.id = i + 1
+		object.imageSpecSet = s.entryImageSpecSet(e)
+		object.optional = e.Optional
+		object.tags = e.Tags
 		if err = s.handleObject(object); err != nil {
-			os.RemoveAll(cd)
+			os.RemoveAll(object.destination.Directory())
 		}
 	}
 	s.waitWorkers()
@@ -164,13 +163,71 @@ func (s *Syncer) newSaveCacheDir() (string, error) {
 	return cd, nil
 }
 
+// newSyncObject creates the syncObject of the image, the cache directory and the
+// OCI destination image will be created if withDestination is true.
+func (s *Syncer) newSyncObject(img string, withDestination bool) (*syncObject, error) {
+	object := &syncObject{
+		image: img,
+	}
+	_, dig, err := utils.SplitImageDigest(img)
+	if err != nil {
+		return nil, err
+	}
+	sourceRegistry := utils.GetRegistryName(img)
+	if s.SourceRegistry != "" {
+		sourceRegistry = s.SourceRegistry
+	}
+	sourceProject := utils.GetProjectName(img)
+	if s.SourceProject != "" {
+		sourceProject = s.SourceProject
+	}
+	src, err := source.NewSource(&source.Option{
+		Type:          types.TypeDocker,
+		Registry:      sourceRegistry,
+		Project:       sourceProject,
+		Name:          utils.GetImageName(img),
+		Tag:           utils.GetImageTag(img),
+		Digest:        dig,
+		SystemContext: s.sourceSystemContext(sourceRegistry),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to init source image: %w", err)
+	}
+	object.source = src
+	if !withDestination {
+		return object, nil
+	}
+
+	cd, err := s.newSaveCacheDir()
+	if err != nil {
+		os.RemoveAll(cd)
+		return nil, fmt.Errorf("failed to create cache dir: %w", err)
+	}
+	sd := path.Join(cd, s.SharedBlobDirPath)
+	dest, err := destination.NewDestination(&destination.Option{
+		Type:          types.TypeOci,
+		Directory:     cd,
+		Name:          utils.GetImageName(img),
+		Tag:           utils.GetImageTag(img),
+		SystemContext: utils.SystemContextWithSharedBlobDir(s.systemContext, sd),
+	})
+	if err != nil {
+		os.RemoveAll(cd)
+		return nil, fmt.Errorf("failed to init dest image: %w", err)
+	}
+	object.destination = dest
+	return object, nil
+}
+
 func (s *Syncer) updateIndex() error {
 	s.au.SetIndex(s.index)
 	return s.au.UpdateIndex()
 }
 
 // Run append images from registry server into local directory / hangar archive.
-func (s *Syncer) Run(ctx context.Context) error {
+func (s *Syncer) Run(ctx context.Context) (err error) {
+	ctx, span := tracing.Start(ctx, "sync")
+	defer func() { tracing.End(span, err) }()
 	// Init Archive Updater.
 	au, err := archive.NewUpdater(s.ArchiveName)
 	if err != nil {
@@ -191,14 +248,11 @@ func (s *Syncer) Run(ctx context.Context) error {
 		}
 	}
 
+	s.startReport("sync")
 	s.copy(ctx)
-	if len(s.failedImageSet) != 0 {
-		v := make([]string, 0, len(s.failedImageSet))
-		for i := range s.failedImageSet {
-			v = append(v, i)
-		}
+	if v := s.failedImages(); len(v) != 0 {
 		logrus.Errorf("Sync failed image list: \n%v", strings.Join(v, "\n"))
-		return ErrCopyFailed
+		return s.failedError(ErrCopyFailed)
 	}
 	return nil
 }
@@ -223,10 +277,37 @@ func (s *Syncer) worker(ctx context.Context, o any) {
 	} else {
 		copyContext, cancel = context.WithCancel(ctx)
 	}
+	copyContext, span := tracing.Start(copyContext, "sync image",
+		attribute.Int("id", obj.id),
+		attribute.String("source", obj.source.ReferenceNameWithoutTransport()))
+	copyContext = logging.WithFields(copyContext, logrus.Fields{
+		logging.FieldImageID:     obj.id,
+		logging.FieldSource:      obj.source.ReferenceNameWithoutTransport(),
+		logging.FieldDestination: s.ArchiveName,
+		logging.FieldPhase:       logging.PhaseCopy,
+	})
+	p := s.progress.NewImage(obj.source.ReferenceNameWithoutTransport())
+	obj.source.SetProgress(p)
+	r := &report.Image{
+		ID:           obj.id,
+		Source:       obj.source.ReferenceNameWithoutTransport(),
+		Destinations: []string{s.ArchiveName},
+	}
+	start := time.Now()
 	defer func() {
-		if err != nil {
+		p.Done(err)
+		tracing.End(span, err)
+		r.Digest = obj.source.ManifestDigest()
+		r.Platforms, r.SkippedPlatforms = reportPlatforms(
+			obj.source.Platforms(), obj.source.GetCopiedImage().Images)
+		s.reportImage(r, start, p, err, obj.optional)
+		if err != nil && obj.optional {
+			logging.FromContext(copyContext).
+				Warnf("Skip optional image [%v]: %v",
+					obj.source.ReferenceNameWithoutTransport(), err)
+		} else if err != nil {
 			s.handleError(NewError(obj.id, err, obj.source, obj.destination))
-			s.recordFailedImage(obj.image)
+			s.recordFailedImage(obj.id, obj.image, err)
 		}
 		cancel()
 		// Delete cache dir.
@@ -241,17 +322,17 @@ func (s *Syncer) worker(ctx context.Context, o any) {
 		err = fmt.Errorf("failed to init source: %w", err)
 		return
 	}
-	logrus.WithFields(logrus.Fields{"IMG": obj.id}).
+	logging.FromContext(copyContext).
 		Infof("Syncing [%v]", obj.source.ReferenceNameWithoutTransport())
 	err = obj.destination.Init(copyContext)
 	if err != nil {
 		err = fmt.Errorf("failed to init destination: %w", err)
 		return
 	}
-	err = obj.source.Copy(copyContext, obj.destination, s.imageSpecSet, s.policy)
+	err = obj.source.Copy(copyContext, obj.destination, s.specSet(obj.imageSpecSet), s.policy)
 	if err != nil {
 		if errors.Is(err, utils.ErrNoAvailableImage) {
-			logrus.WithFields(logrus.Fields{"IMG": obj.id}).
+			logging.FromContext(copyContext).
 				Warnf("Skip copy image [%v]: %v",
 					obj.source.ReferenceNameWithoutTransport(), err)
 			err = nil
@@ -266,7 +347,7 @@ func (s *Syncer) worker(ctx context.Context, o any) {
 	s.auMutex.Lock()
 	defer s.auMutex.Unlock()
 
-	logrus.WithFields(logrus.Fields{"IMG": obj.id}).
+	logging.FromContext(copyContext).
 		Debugf("Compressing [%v]", obj.destination.ReferenceNameWithoutTransport())
 
 	destDir := obj.destination.ReferenceNameWithoutTransport()
@@ -315,9 +396,19 @@ func (s *Syncer) worker(ctx context.Context, o any) {
 		return
 	}
 	s.index.Append(copiedImage)
+	for _, t := range obj.tags {
+		if t == "" || t == copiedImage.Tag {
+			continue
+		}
+		img := *copiedImage
+		img.Tag = t
+		s.index.Append(&img)
+	}
 }
 
-func (s *Syncer) Validate(ctx context.Context) error {
+func (s *Syncer) Validate(ctx context.Context) (err error) {
+	ctx, span := tracing.Start(ctx, "sync-validate")
+	defer func() { tracing.End(span, err) }()
 	ar, err := archive.NewReader(s.ArchiveName)
 	if err != nil {
 		return fmt.Errorf("failed to create archive reader: %w", err)
@@ -333,14 +424,11 @@ func (s *Syncer) Validate(ctx context.Context) error {
 		return fmt.Errorf("failed to read archive index: %w", err)
 	}
 
+	s.startReport("sync-validate")
 	s.validate(ctx)
-	if len(s.failedImageSet) != 0 {
-		v := make([]string, 0, len(s.failedImageSet))
-		for i := range s.failedImageSet {
-			v = append(v, i)
-		}
+	if v := s.failedImages(); len(v) != 0 {
 		logrus.Errorf("Validate failed image list: \n%v", strings.Join(v, "\n"))
-		return ErrValidateFailed
+		return s.failedError(ErrValidateFailed)
 	}
 	return nil
 }
@@ -355,32 +443,34 @@ func (s *Syncer) validate(ctx context.Context) {
 			logrus.Warnf("Ignore image list line %q: invalid format", img)
 			continue
 		}
-		object := &syncObject{
-			id:    i + 1,
-			image: img,
-		}
-		sourceRegistry := utils.GetRegistryName(img)
-		if s.SourceRegistry != "" {
-			sourceRegistry = s.SourceRegistry
+		object, err := s.newSyncObject(img, false)
+		if err != nil {
+			s.reportFailure(i+1, img, err, false)
+			s.handleError(NewError(i+1, err, nil, nil))
+			s.recordFailedImage(i+1, img, err)
+			continue
 		}
-		sourceProject := utils.GetProjectName(img)
-		if s.SourceProject != "" {
-			sourceProject = s.SourceProject
+		object.id = i + 1
+		s.handleObject(object)
+	}
+	for i, e := range s.common.entries {
+		if e.Skip {
+			continue
 		}
-		src, err := source.NewSource(&source.Option{
-			Type:          types.TypeDocker,
-			Registry:      sourceRegistry,
-			Project:       sourceProject,
-			Name:          utils.GetImageName(img),
-			Tag:           utils.GetImageTag(img),
-			SystemContext: s.systemContext,
-		})
+		object, err := s.newSyncObject(e.Source, false)
 		if err != nil {
-			s.handleError(fmt.Errorf("failed to init source image: %w", err))
-			s.recordFailedImage(img)
+			s.reportFailure(i+1, e.Source, err, e.Optional)
+			if e.Optional {
+				logrus.Warnf("Skip optional image %q: %v", e.Source, err)
+				continue
+			}
+			s.handleError(NewError(i+1, err, nil, nil))
+			s.recordFailedImage(i+1, e.Source, err)
 			continue
 		}
-		object.source = src
+		object.id = i + 1
+		object.imageSpecSet = s.entryImageSpecSet(e)
+		object.optional = e.Optional
 		s.handleObject(object)
 	}
 	s.waitWorkers()
@@ -406,12 +496,35 @@ func (s *Syncer) validateWorker(ctx context.Context, o any) {
 	} else {
 		validateContext, cancel = context.WithCancel(ctx)
 	}
+	validateContext = logging.WithFields(validateContext, logrus.Fields{
+		logging.FieldImageID:     obj.id,
+		logging.FieldSource:      obj.source.ReferenceNameWithoutTransport(),
+		logging.FieldDestination: s.ArchiveName,
+		logging.FieldPhase:       logging.PhaseValidate,
+	})
 
+	r := &report.Image{
+		ID:           obj.id,
+		Source:       obj.source.ReferenceNameWithoutTransport(),
+		Destinations: []string{s.ArchiveName},
+	}
+	start := time.Now()
 	defer func() {
 		cancel()
+		r.Digest = obj.source.ManifestDigest()
+		r.Platforms, r.SkippedPlatforms = reportPlatforms(
+			obj.source.Platforms(),
+			obj.source.ImageBySet(s.specSet(obj.imageSpecSet)).Images)
+		s.reportImage(r, start, nil, err, obj.optional)
+		if err != nil && obj.optional {
+			logging.FromContext(validateContext).
+				Warnf("Skip optional image [%v]: %v",
+					obj.source.ReferenceNameWithoutTransport(), err)
+			return
+		}
 		if err != nil {
 			s.handleError(NewError(obj.id, err, nil, nil))
-			s.recordFailedImage(obj.image)
+			s.recordFailedImage(obj.id, obj.image, err)
 		}
 	}()
 
@@ -430,21 +543,21 @@ func (s *Syncer) validateWorker(ctx context.Context, o any) {
 			fail = true
 		}
 	default:
-		image := obj.source.ImageBySet(s.imageSpecSet)
+		image := obj.source.ImageBySet(s.specSet(obj.imageSpecSet))
 		if !s.index.Has(image) {
 			fail = true
 		}
 	}
 
 	if fail {
-		logrus.WithFields(logrus.Fields{"IMG": obj.id}).
+		logging.FromContext(validateContext).
 			Errorf("Image [%v] does not exists in archive index",
 				obj.source.ReferenceNameWithoutTransport())
-		err = fmt.Errorf("FAILED: [%v]",
-			obj.source.ReferenceNameWithoutTransport())
+		err = fmt.Errorf("FAILED: [%v]: %w",
+			obj.source.ReferenceNameWithoutTransport(), ErrImageNotFound)
 		return
 	}
 
-	logrus.WithFields(logrus.Fields{"IMG": obj.id}).
+	logging.FromContext(validateContext).
 		Infof("PASS: [%v]", obj.source.ReferenceNameWithoutTransport())
 }
@@ -1,9 +1,7 @@
 package harbor
 
 import (
-	"bytes"
 	"context"
-	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -13,24 +11,30 @@ import (
 	"strings"
 	"time"
 
+	"github.com/cnrancher/hangar/pkg/tracing"
 	"github.com/cnrancher/hangar/pkg/utils"
 	"github.com/containers/common/pkg/retry"
 	"github.com/containers/image/v5/types"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 var (
 	ErrRegistryIsNotHarbor = errors.New("registry server is not harbor V2")
+	// ErrPermissionDenied is returned if the credential does not have the
+	// permission of the harbor API, e.g. the project level robot account.
+	ErrPermissionDenied = errors.New("permission denied")
+	// ErrUnauthorized is returned if the credential is not accepted by the
+	// harbor API.
+	ErrUnauthorized = errors.New("unauthorized")
 )
 
 func GetRegistryURL(
-	ctx context.Context, registry string, tlsVerify bool,
+	ctx context.Context, registry string, sysCtx *types.SystemContext,
 ) (string, error) {
-	client := &http.Client{
-		Timeout: time.Second * 5,
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: !tlsVerify},
-		},
+	client, err := utils.NewHTTPClient(sysCtx, registry, time.Second*5)
+	if err != nil {
+		return "", fmt.Errorf("harbor.GetRegistryURL: %w", err)
 	}
 	// Try ping registry using HTTPS protocol.
 	registry = strings.TrimSuffix(registry, "/")
@@ -42,7 +46,7 @@ func GetRegistryURL(
 	}
 	resp, err := httpClientDoWithRetry(ctx, client, req)
 	if err != nil {
-		if tlsVerify {
+		if utils.TLSVerify(sysCtx) {
 			return "", fmt.Errorf("harbor.GetRegistryURL: %w", err)
 		}
 
@@ -89,13 +93,11 @@ func ProjectExists(
 	ctx context.Context,
 	name, u string,
 	credential *types.DockerAuthConfig,
-	tlsVerify bool,
+	sysCtx *types.SystemContext,
 ) (bool, error) {
-	client := &http.Client{
-		Timeout: time.Second * 5,
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: !tlsVerify},
-		},
+	client, err := newClient(sysCtx, u, time.Second*5)
+	if err != nil {
+		return false, fmt.Errorf("harbor.ProjectExists: %w", err)
 	}
 
 	u = strings.TrimSuffix(u, "/")
@@ -104,8 +106,7 @@ func ProjectExists(
 	if err != nil {
 		return false, fmt.Errorf("harbor.ProjectExists: %w", err)
 	}
-	auth := fmt.Sprintf("%s:%s", credential.Username, credential.Password)
-	r.Header.Add("Authorization", "Basic "+utils.Base64(auth))
+	setAuthorization(r, credential)
 	r.Header.Add("Accept", "application/json")
 	resp, err := httpClientDoWithRetry(ctx, client, r)
 	if err != nil {
@@ -122,6 +123,12 @@ func ProjectExists(
 		return true, nil
 	case http.StatusNotFound:
 		logrus.Debugf("harbor project %q not found", name)
+	case http.StatusUnauthorized:
+		return false, fmt.Errorf("harbor.ProjectExists: %w: %q response: %v",
+			ErrUnauthorized, u, resp.Status)
+	case http.StatusForbidden:
+		return false, fmt.Errorf("harbor.ProjectExists: %w: %q response: %v",
+			ErrPermissionDenied, u, resp.Status)
 	default:
 		return false, fmt.Errorf("harbor.ProjectExists: %q response: %v",
 			u, resp.Status)
@@ -135,61 +142,66 @@ func CreateProject(
 	ctx context.Context,
 	name, u string,
 	credential *types.DockerAuthConfig,
-	tlsVerify bool,
+	sysCtx *types.SystemContext,
 ) error {
-	data := struct {
-		ProjectName string `json:"project_name"`
-		Metadata    struct {
-			Public string `json:"public"`
-		} `json:"metadata"`
-	}{
-		ProjectName: name,
-		Metadata: struct {
-			Public string `json:"public"`
-		}{
-			Public: "false",
-		},
-	}
-	b, err := json.Marshal(data)
-	if err != nil {
-		return fmt.Errorf("harbor.CreateHarborProject: json.Marshal: %w", err)
-	}
+	return CreateProjectWithSpec(ctx, &Project{Name: name}, u, credential, sysCtx)
+}
 
-	client := &http.Client{
-		Timeout: time.Second * 5,
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: !tlsVerify},
-		},
-	}
-	u = strings.TrimSuffix(u, "/")
-	u = fmt.Sprintf("%s/api/v2.0/projects", u)
-	r, err := http.NewRequestWithContext(
-		ctx, http.MethodPost, u, bytes.NewReader(b))
+// ListRepositories lists the repository names of the project on harbor v2,
+// the repository name is in "PROJECT/NAME" format.
+func ListRepositories(
+	ctx context.Context,
+	project, u string,
+	credential *types.DockerAuthConfig,
+	sysCtx *types.SystemContext,
+) ([]string, error) {
+	client, err := newClient(sysCtx, u, time.Second*30)
 	if err != nil {
-		return fmt.Errorf("harbor.CreateProject: %w", err)
+		return nil, fmt.Errorf("harbor.ListRepositories: %w", err)
 	}
-	auth := fmt.Sprintf("%s:%s", credential.Username, credential.Password)
-	r.Header.Add("Authorization", "Basic "+utils.Base64(auth))
-	r.Header.Add("Content-Type", "application/json")
-	resp, err := httpClientDoWithRetry(ctx, client, r)
+	u = strings.TrimSuffix(u, "/")
+	u = fmt.Sprintf("%s/api/v2.0/projects/%s/repositories", u, url.PathEscape(project))
+	var repositories []string
+	err = listPages(ctx, client, u, credential,
+		func(b []byte) (int, error) {
+			var data []struct {
+				Name string `json:"name"`
+			}
+			if err := json.Unmarshal(b, &data); err != nil {
+				return 0, err
+			}
+			for _, d := range data {
+				repositories = append(repositories, d.Name)
+			}
+			return len(data), nil
+		})
 	if err != nil {
-		return fmt.Errorf("harbor.CreateProject: %w", err)
+		return nil, fmt.Errorf("harbor.ListRepositories: %w", err)
 	}
-	defer func() {
-		if resp != nil && resp.Body != nil {
-			resp.Body.Close()
-		}
-	}()
-	switch resp.StatusCode {
-	case http.StatusCreated:
-	case http.StatusConflict:
-		logrus.Debugf("already created project %q, response: %s",
-			name, resp.Status)
-	default:
-		return fmt.Errorf("failed to create project %q, response: %s",
-			name, resp.Status)
+	return repositories, nil
+}
+
+// APICredential returns the credential of the harbor API from the registry
+// credential. The identity token of the registry credential is the OAuth
+// refresh token of the registry and is not accepted by the harbor API, so it
+// is replaced by the bearer token (e.g. OIDC ID token) provided explicitly.
+func APICredential(credential types.DockerAuthConfig, token string) *types.DockerAuthConfig {
+	credential.IdentityToken = token
+	return &credential
+}
+
+// setAuthorization sets the authorization header of the harbor API request.
+// The bearer token is used if the identity token of the credential created
+// by APICredential is provided, otherwise the basic auth of the username and
+// password (or the robot account, OIDC CLI secret) is used.
+func setAuthorization(r *http.Request, credential *types.DockerAuthConfig) {
+	switch {
+	case credential == nil:
+	case credential.IdentityToken != "":
+		r.Header.Set("Authorization", "Bearer "+credential.IdentityToken)
+	case credential.Username != "":
+		r.SetBasicAuth(credential.Username, credential.Password)
 	}
-	return nil
 }
 
 func httpClientDoWithRetry(
@@ -197,6 +209,15 @@ func httpClientDoWithRetry(
 ) (*http.Response, error) {
 	var resp *http.Response
 	var err error
+	ctx, span := tracing.Start(ctx, "harbor API",
+		attribute.String("http.method", req.Method),
+		attribute.String("http.url", req.URL.Redacted()))
+	defer func() {
+		if resp != nil {
+			span.SetAttributes(attribute.Int("http.status_code", resp.StatusCode))
+		}
+		tracing.End(span, err)
+	}()
 	err = retry.IfNecessary(ctx, func() error {
 		logrus.Debugf("client.Do: %v", req.URL.String())
 		resp, err = client.Do(req)
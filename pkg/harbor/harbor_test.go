@@ -0,0 +1,59 @@
+package harbor
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/containers/image/v5/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ProjectExists(t *testing.T) {
+	const token = "oidc-id-token"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Query().Get("project_name") {
+		case "library":
+			w.WriteHeader(http.StatusOK)
+		case "private":
+			w.WriteHeader(http.StatusForbidden)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	credential := &types.DockerAuthConfig{IdentityToken: token}
+	exists, err := ProjectExists(context.TODO(), "library", server.URL, credential, nil)
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	exists, err = ProjectExists(context.TODO(), "not-exists", server.URL, credential, nil)
+	assert.NoError(t, err)
+	assert.False(t, exists)
+	_, err = ProjectExists(context.TODO(), "private", server.URL, credential, nil)
+	assert.True(t, errors.Is(err, ErrPermissionDenied))
+
+	// Username & password is not accepted by the fake server.
+	_, err = ProjectExists(context.TODO(), "library", server.URL,
+		&types.DockerAuthConfig{Username: "robot$test", Password: "secret"}, nil)
+	assert.True(t, errors.Is(err, ErrUnauthorized))
+	assert.False(t, errors.Is(err, ErrPermissionDenied))
+	err = CreateProjectWithSpec(context.TODO(), &Project{Name: "library"}, server.URL,
+		&types.DockerAuthConfig{Username: "robot$test", Password: "secret"}, nil)
+	assert.True(t, errors.Is(err, ErrUnauthorized))
+
+	// The identity token of the registry credential is not the bearer token.
+	credential = APICredential(types.DockerAuthConfig{IdentityToken: token}, "")
+	_, err = ProjectExists(context.TODO(), "library", server.URL, credential, nil)
+	assert.True(t, errors.Is(err, ErrUnauthorized))
+	credential = APICredential(types.DockerAuthConfig{Username: "admin"}, token)
+	exists, err = ProjectExists(context.TODO(), "library", server.URL, credential, nil)
+	assert.NoError(t, err)
+	assert.True(t, exists)
+}
@@ -0,0 +1,329 @@
+package harbor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cnrancher/hangar/pkg/utils"
+	"github.com/containers/image/v5/types"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// pageSize is the page size when listing resources from harbor API.
+	pageSize = 100
+)
+
+// Project is the project of harbor v2.
+type Project struct {
+	// Name is the project name.
+	Name string
+	// Public is the visibility of the project.
+	Public bool
+	// StorageLimit is the storage quota (in bytes) of the project,
+	// -1 means unlimited, 0 means use the harbor default quota.
+	StorageLimit int64
+	// AutoScan scans the images automatically when pushed.
+	AutoScan bool
+	// CVEAllowlist is the CVE IDs ignored when scanning images of the
+	// project, the system CVE allowlist is used if not provided.
+	CVEAllowlist []string
+}
+
+// Artifact is the artifact of the harbor v2 repository.
+type Artifact struct {
+	// Digest is the manifest digest of the artifact.
+	Digest string
+	// Tags is the tag list of the artifact.
+	Tags []string
+}
+
+type projectData struct {
+	ProjectName  string            `json:"project_name,omitempty"`
+	Name         string            `json:"name,omitempty"`
+	Metadata     map[string]string `json:"metadata,omitempty"`
+	StorageLimit *int64            `json:"storage_limit,omitempty"`
+	CVEAllowlist *cveAllowlistData `json:"cve_allowlist,omitempty"`
+}
+
+type cveAllowlistData struct {
+	Items []cveAllowlistItem `json:"items"`
+}
+
+type cveAllowlistItem struct {
+	CVEID string `json:"cve_id"`
+}
+
+// CreateProjectWithSpec creates project with the visibility and quota
+// specified for harbor v2.
+func CreateProjectWithSpec(
+	ctx context.Context,
+	project *Project,
+	u string,
+	credential *types.DockerAuthConfig,
+	sysCtx *types.SystemContext,
+) error {
+	data := projectData{
+		ProjectName: project.Name,
+		Metadata: map[string]string{
+			"public": strconv.FormatBool(project.Public),
+		},
+	}
+	if project.StorageLimit != 0 {
+		data.StorageLimit = &project.StorageLimit
+	}
+	if project.AutoScan {
+		data.Metadata["auto_scan"] = "true"
+	}
+	if len(project.CVEAllowlist) > 0 {
+		data.Metadata["reuse_sys_cve_allowlist"] = "false"
+		data.CVEAllowlist = &cveAllowlistData{}
+		for _, id := range project.CVEAllowlist {
+			data.CVEAllowlist.Items = append(data.CVEAllowlist.Items,
+				cveAllowlistItem{CVEID: id})
+		}
+	}
+	b, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("harbor.CreateProject: json.Marshal: %w", err)
+	}
+
+	client, err := newClient(sysCtx, u, time.Second*30)
+	if err != nil {
+		return fmt.Errorf("harbor.CreateProject: %w", err)
+	}
+	u = strings.TrimSuffix(u, "/")
+	u = fmt.Sprintf("%s/api/v2.0/projects", u)
+	resp, err := doRequest(ctx, client, http.MethodPost, u,
+		credential, bytes.NewReader(b))
+	if err != nil {
+		return fmt.Errorf("harbor.CreateProject: %w", err)
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusCreated:
+	case http.StatusConflict:
+		logrus.Debugf("already created project %q, response: %s",
+			project.Name, resp.Status)
+	case http.StatusUnauthorized:
+		return fmt.Errorf("failed to create project %q: %w, response: %s",
+			project.Name, ErrUnauthorized, resp.Status)
+	case http.StatusForbidden:
+		return fmt.Errorf("failed to create project %q: %w, response: %s",
+			project.Name, ErrPermissionDenied, resp.Status)
+	default:
+		return fmt.Errorf("failed to create project %q, response: %s",
+			project.Name, resp.Status)
+	}
+	return nil
+}
+
+// ListProjects lists all the projects with metadata and quota on harbor v2.
+func ListProjects(
+	ctx context.Context,
+	u string,
+	credential *types.DockerAuthConfig,
+	sysCtx *types.SystemContext,
+) ([]*Project, error) {
+	client, err := newClient(sysCtx, u, time.Second*30)
+	if err != nil {
+		return nil, fmt.Errorf("harbor.ListProjects: %w", err)
+	}
+	u = strings.TrimSuffix(u, "/")
+	var projects []*Project
+	err = listPages(ctx, client, u+"/api/v2.0/projects", credential,
+		func(b []byte) (int, error) {
+			var data []projectData
+			if err := json.Unmarshal(b, &data); err != nil {
+				return 0, err
+			}
+			for _, d := range data {
+				p := &Project{
+					Name:     d.Name,
+					Public:   d.Metadata["public"] == "true",
+					AutoScan: d.Metadata["auto_scan"] == "true",
+				}
+				if d.CVEAllowlist != nil && d.Metadata["reuse_sys_cve_allowlist"] != "true" {
+					for _, item := range d.CVEAllowlist.Items {
+						p.CVEAllowlist = append(p.CVEAllowlist, item.CVEID)
+					}
+				}
+				projects = append(projects, p)
+			}
+			return len(data), nil
+		})
+	if err != nil {
+		return nil, fmt.Errorf("harbor.ListProjects: %w", err)
+	}
+	for _, p := range projects {
+		p.StorageLimit, err = projectStorageLimit(ctx, client, u, p.Name, credential)
+		if err != nil {
+			return nil, fmt.Errorf("harbor.ListProjects: %w", err)
+		}
+	}
+	return projects, nil
+}
+
+// projectStorageLimit gets the storage quota of the project,
+// returns 0 if the quota is not available.
+func projectStorageLimit(
+	ctx context.Context,
+	client *http.Client,
+	u, name string,
+	credential *types.DockerAuthConfig,
+) (int64, error) {
+	u = fmt.Sprintf("%s/api/v2.0/projects/%s/summary", u, url.PathEscape(name))
+	resp, err := doRequest(ctx, client, http.MethodGet, u, credential, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusForbidden, http.StatusNotFound:
+		// The quota is only available for the project admin.
+		logrus.Debugf("failed to get quota of project %q: %v", name, resp.Status)
+		return 0, nil
+	default:
+		return 0, fmt.Errorf("%q response: %v", u, resp.Status)
+	}
+	data := struct {
+		Quota *struct {
+			Hard map[string]int64 `json:"hard"`
+		} `json:"quota"`
+	}{}
+	if err = json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return 0, err
+	}
+	if data.Quota == nil {
+		return 0, nil
+	}
+	return data.Quota.Hard["storage"], nil
+}
+
+// ListArtifacts lists the artifacts of the repository on harbor v2,
+// the repository name should not contain the project name.
+func ListArtifacts(
+	ctx context.Context,
+	project, repository, u string,
+	credential *types.DockerAuthConfig,
+	sysCtx *types.SystemContext,
+) ([]*Artifact, error) {
+	client, err := newClient(sysCtx, u, time.Second*30)
+	if err != nil {
+		return nil, fmt.Errorf("harbor.ListArtifacts: %w", err)
+	}
+	u = strings.TrimSuffix(u, "/")
+	// The repository name should be double URL-encoded if it contains '/'.
+	u = fmt.Sprintf("%s/api/v2.0/projects/%s/repositories/%s/artifacts?with_tag=true",
+		u, url.PathEscape(project), url.PathEscape(url.PathEscape(repository)))
+	var artifacts []*Artifact
+	err = listPages(ctx, client, u, credential,
+		func(b []byte) (int, error) {
+			var data []struct {
+				Digest string `json:"digest"`
+				Tags   []struct {
+					Name string `json:"name"`
+				} `json:"tags"`
+			}
+			if err := json.Unmarshal(b, &data); err != nil {
+				return 0, err
+			}
+			for _, d := range data {
+				a := &Artifact{Digest: d.Digest}
+				for _, t := range d.Tags {
+					a.Tags = append(a.Tags, t.Name)
+				}
+				artifacts = append(artifacts, a)
+			}
+			return len(data), nil
+		})
+	if err != nil {
+		return nil, fmt.Errorf("harbor.ListArtifacts: %w", err)
+	}
+	return artifacts, nil
+}
+
+// newClient returns the HTTP client of the harbor API URL, the TLS settings
+// of the system context are applied.
+func newClient(
+	sysCtx *types.SystemContext, u string, timeout time.Duration,
+) (*http.Client, error) {
+	pu, err := url.Parse(u)
+	if err != nil {
+		return nil, err
+	}
+	return utils.NewHTTPClient(sysCtx, pu.Host, timeout)
+}
+
+func doRequest(
+	ctx context.Context,
+	client *http.Client,
+	method, u string,
+	credential *types.DockerAuthConfig,
+	body io.ReadSeeker,
+) (*http.Response, error) {
+	r, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	setAuthorization(r, credential)
+	r.Header.Add("Accept", "application/json")
+	if body != nil {
+		r.Header.Add("Content-Type", "application/json")
+	}
+	// The project name may be numeric.
+	r.Header.Add("X-Is-Resource-Name", "true")
+	return httpClientDoWithRetry(ctx, client, r)
+}
+
+// listPages requests the paginated harbor API until the last page,
+// the handler returns the item number of the page.
+func listPages(
+	ctx context.Context,
+	client *http.Client,
+	u string,
+	credential *types.DockerAuthConfig,
+	handler func([]byte) (int, error),
+) error {
+	sep := "?"
+	if strings.Contains(u, "?") {
+		sep = "&"
+	}
+	for page := 1; ; page++ {
+		pu := fmt.Sprintf("%s%spage=%d&page_size=%d", u, sep, page, pageSize)
+		resp, err := doRequest(ctx, client, http.MethodGet, pu, credential, nil)
+		if err != nil {
+			return err
+		}
+		b, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+		switch resp.StatusCode {
+		case http.StatusOK:
+		case http.StatusUnauthorized:
+			return fmt.Errorf("%w: %q response: %v", ErrUnauthorized, pu, resp.Status)
+		case http.StatusForbidden:
+			return fmt.Errorf("%w: %q response: %v", ErrPermissionDenied, pu, resp.Status)
+		default:
+			return fmt.Errorf("%q response: %v", pu, resp.Status)
+		}
+		n, err := handler(b)
+		if err != nil {
+			return err
+		}
+		if n < pageSize {
+			return nil
+		}
+	}
+}
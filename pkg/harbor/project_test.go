@@ -0,0 +1,112 @@
+package harbor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/containers/image/v5/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ListProjects(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "admin", user)
+		assert.Equal(t, "password", pass)
+		switch r.URL.Path {
+		case "/api/v2.0/projects":
+			if r.URL.Query().Get("page") != "1" {
+				w.Write([]byte("[]"))
+				return
+			}
+			w.Write([]byte(`[
+				{"project_id":1,"name":"library","metadata":{"public":"true"}},
+				{"project_id":2,"name":"private","metadata":{"public":"false"}}
+			]`))
+		case "/api/v2.0/projects/library/summary":
+			w.Write([]byte(`{"quota":{"hard":{"storage":-1},"used":{"storage":10}}}`))
+		case "/api/v2.0/projects/private/summary":
+			w.Write([]byte(`{"quota":{"hard":{"storage":1073741824}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	projects, err := ListProjects(context.TODO(), server.URL,
+		&types.DockerAuthConfig{Username: "admin", Password: "password"}, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []*Project{
+		{Name: "library", Public: true, StorageLimit: -1},
+		{Name: "private", Public: false, StorageLimit: 1073741824},
+	}, projects)
+}
+
+func Test_CreateProjectWithSpec(t *testing.T) {
+	var data map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/api/v2.0/projects", r.URL.Path)
+		data = nil
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&data))
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	err := CreateProjectWithSpec(context.TODO(), &Project{
+		Name:         "library",
+		Public:       true,
+		StorageLimit: 1024,
+	}, server.URL, &types.DockerAuthConfig{}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "library", data["project_name"])
+	assert.Equal(t, map[string]any{"public": "true"}, data["metadata"])
+	assert.Equal(t, float64(1024), data["storage_limit"])
+	assert.Nil(t, data["cve_allowlist"])
+
+	err = CreateProjectWithSpec(context.TODO(), &Project{
+		Name:         "private",
+		AutoScan:     true,
+		CVEAllowlist: []string{"CVE-2023-1234"},
+	}, server.URL, &types.DockerAuthConfig{}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"public":                  "false",
+		"auto_scan":               "true",
+		"reuse_sys_cve_allowlist": "false",
+	}, data["metadata"])
+	assert.Nil(t, data["storage_limit"])
+	assert.Equal(t, map[string]any{
+		"items": []any{map[string]any{"cve_id": "CVE-2023-1234"}},
+	}, data["cve_allowlist"])
+}
+
+func Test_ListArtifacts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The nested repository name is double URL-encoded.
+		assert.Equal(t, "/api/v2.0/projects/library/repositories/a%252Fb/artifacts",
+			r.URL.EscapedPath())
+		fmt.Fprint(w, `[
+			{"digest":"sha256:aaa","tags":[{"name":"v1"},{"name":"latest"}]},
+			{"digest":"sha256:bbb","tags":null}
+		]`)
+	}))
+	defer server.Close()
+
+	artifacts, err := ListArtifacts(context.TODO(), "library", "a/b",
+		server.URL, &types.DockerAuthConfig{}, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []*Artifact{
+		{Digest: "sha256:aaa", Tags: []string{"v1", "latest"}},
+		{Digest: "sha256:bbb"},
+	}, artifacts)
+}
@@ -0,0 +1,164 @@
+package harbor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cnrancher/hangar/pkg/utils"
+	"github.com/containers/image/v5/types"
+	"github.com/sirupsen/logrus"
+)
+
+// RobotOptions is the option to create the project robot account.
+type RobotOptions struct {
+	// Name is the robot account name, the full name created by harbor is
+	// in "robot$PROJECT+NAME" format.
+	Name string
+	// Description of the robot account (optional).
+	Description string
+	// Duration is the expiration days of the robot account,
+	// -1 means never expire.
+	Duration int
+	// Push grants the push permission to the robot account,
+	// the robot account only has the pull permission by default.
+	Push bool
+}
+
+// Robot is the created robot account.
+type Robot struct {
+	// Registry of the robot account (optional).
+	Registry string `json:"-"`
+	// Project of the robot account.
+	Project string `json:"-"`
+	// Name is the full name of the robot account.
+	Name string `json:"name"`
+	// Secret is the password of the robot account.
+	Secret string `json:"secret"`
+}
+
+type robotAccess struct {
+	Resource string `json:"resource"`
+	Action   string `json:"action"`
+}
+
+type robotPermission struct {
+	Kind      string        `json:"kind"`
+	Namespace string        `json:"namespace"`
+	Access    []robotAccess `json:"access"`
+}
+
+// CreateRobotAccount creates the project level robot account for harbor v2,
+// returns the name and secret of the created robot account.
+func CreateRobotAccount(
+	ctx context.Context,
+	project string,
+	o *RobotOptions,
+	u string,
+	credential *types.DockerAuthConfig,
+	sysCtx *types.SystemContext,
+) (*Robot, error) {
+	duration := o.Duration
+	if duration == 0 {
+		duration = -1
+	}
+	access := []robotAccess{
+		{Resource: "repository", Action: "pull"},
+	}
+	if o.Push {
+		access = append(access, robotAccess{Resource: "repository", Action: "push"})
+	}
+	data := struct {
+		Name        string            `json:"name"`
+		Description string            `json:"description,omitempty"`
+		Level       string            `json:"level"`
+		Duration    int               `json:"duration"`
+		Permissions []robotPermission `json:"permissions"`
+	}{
+		Name:        o.Name,
+		Description: o.Description,
+		Level:       "project",
+		Duration:    duration,
+		Permissions: []robotPermission{
+			{Kind: "project", Namespace: project, Access: access},
+		},
+	}
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("harbor.CreateRobotAccount: json.Marshal: %w", err)
+	}
+
+	client, err := newClient(sysCtx, u, time.Second*30)
+	if err != nil {
+		return nil, fmt.Errorf("harbor.CreateRobotAccount: %w", err)
+	}
+	u = strings.TrimSuffix(u, "/")
+	u = fmt.Sprintf("%s/api/v2.0/robots", u)
+	resp, err := doRequest(ctx, client, http.MethodPost, u,
+		credential, bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("harbor.CreateRobotAccount: %w", err)
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusCreated:
+	case http.StatusConflict:
+		return nil, fmt.Errorf("robot account %q of project %q already exists, "+
+			"the secret can only be retrieved when created", o.Name, project)
+	case http.StatusUnauthorized:
+		return nil, fmt.Errorf("failed to create robot account %q of project %q: %w, response: %s",
+			o.Name, project, ErrUnauthorized, resp.Status)
+	case http.StatusForbidden:
+		return nil, fmt.Errorf("failed to create robot account %q of project %q: %w, response: %s",
+			o.Name, project, ErrPermissionDenied, resp.Status)
+	default:
+		return nil, fmt.Errorf("failed to create robot account %q of project %q, response: %s",
+			o.Name, project, resp.Status)
+	}
+	robot := &Robot{Project: project}
+	if err = json.NewDecoder(resp.Body).Decode(robot); err != nil {
+		return nil, fmt.Errorf("harbor.CreateRobotAccount: %w", err)
+	}
+	logrus.Debugf("created robot account %q", robot.Name)
+	return robot, nil
+}
+
+// SaveRobotAccounts writes the robot accounts to file in docker config JSON
+// format, which can be used to create the kubernetes image pull secret,
+// the registry is used if the registry of the robot account not specified.
+func SaveRobotAccounts(name, registry string, robots []*Robot) error {
+	type auth struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+		Auth     string `json:"auth"`
+	}
+	data := struct {
+		Auths map[string]auth `json:"auths"`
+	}{
+		Auths: map[string]auth{},
+	}
+	for _, r := range robots {
+		server := registry
+		if r.Registry != "" {
+			server = r.Registry
+		}
+		data.Auths[fmt.Sprintf("%s/%s", server, r.Project)] = auth{
+			Username: r.Name,
+			Password: r.Secret,
+			Auth:     utils.Base64(r.Name + ":" + r.Secret),
+		}
+	}
+	b, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal robot accounts: %w", err)
+	}
+	if err = os.WriteFile(name, b, 0600); err != nil {
+		return fmt.Errorf("failed to write %q: %w", name, err)
+	}
+	return nil
+}
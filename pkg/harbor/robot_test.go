@@ -0,0 +1,70 @@
+package harbor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/containers/image/v5/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_CreateRobotAccount(t *testing.T) {
+	var data map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v2.0/robots", r.URL.Path)
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&data))
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":1,"name":"robot$library+puller","secret":"abc"}`))
+	}))
+	defer server.Close()
+
+	robot, err := CreateRobotAccount(context.TODO(), "library", &RobotOptions{
+		Name: "puller",
+	}, server.URL, &types.DockerAuthConfig{}, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, &Robot{
+		Project: "library",
+		Name:    "robot$library+puller",
+		Secret:  "abc",
+	}, robot)
+	assert.Equal(t, "project", data["level"])
+	assert.Equal(t, float64(-1), data["duration"])
+	assert.Equal(t, []any{map[string]any{
+		"kind":      "project",
+		"namespace": "library",
+		"access": []any{
+			map[string]any{"resource": "repository", "action": "pull"},
+		},
+	}}, data["permissions"])
+}
+
+func Test_SaveRobotAccounts(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "config.json")
+	err := SaveRobotAccounts(name, "harbor.example.io", []*Robot{
+		{Project: "library", Name: "robot$library+puller", Secret: "abc"},
+		{Registry: "harbor2.example.io", Project: "rancher", Name: "robot$rancher+puller", Secret: "def"},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	b, err := os.ReadFile(name)
+	if !assert.NoError(t, err) {
+		return
+	}
+	var data map[string]map[string]map[string]string
+	assert.NoError(t, json.Unmarshal(b, &data))
+	assert.Equal(t, map[string]string{
+		"username": "robot$library+puller",
+		"password": "abc",
+		"auth":     "cm9ib3QkbGlicmFyeStwdWxsZXI6YWJj",
+	}, data["auths"]["harbor.example.io/library"])
+	assert.Equal(t, "robot$rancher+puller",
+		data["auths"]["harbor2.example.io/rancher"]["username"])
+}
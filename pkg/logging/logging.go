@@ -0,0 +1,203 @@
+// Package logging provides the structured log fields of the images copy
+// jobs and the text/JSON log formatter of the hangar commands.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	nested "github.com/antonfisher/nested-logrus-formatter"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// The field keys of the structured logs.
+const (
+	FieldCommand     = "command"
+	FieldImageID     = "image_id"
+	FieldSource      = "source"
+	FieldDestination = "destination"
+	FieldPlatform    = "platform"
+	FieldDigest      = "digest"
+	FieldPhase       = "phase"
+)
+
+// The phases of the image copy job.
+const (
+	PhaseInit     = "init"
+	PhaseCopy     = "copy"
+	PhaseManifest = "manifest"
+	PhaseValidate = "validate"
+)
+
+// Platform returns the platform string of the image for the log field,
+// example: linux/arm64/v8
+func Platform(os, arch, variant string) string {
+	s := os + "/" + arch
+	if variant != "" {
+		s += "/" + variant
+	}
+	return s
+}
+
+// textImageIDKey is the key of the image ID displayed in text format.
+const textImageIDKey = "IMG"
+
+// Format is the output format of the logs.
+type Format string
+
+const (
+	FormatText Format = "text"
+	FormatJSON Format = "json"
+)
+
+// ParseFormat parses the log format, default is text.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(s)); f {
+	case "":
+		return FormatText, nil
+	case FormatText, FormatJSON:
+		return f, nil
+	}
+	return "", fmt.Errorf("invalid log format %q, available: text, json", s)
+}
+
+type contextKey struct{}
+
+// WithFields returns the context carrying the log fields, the fields are
+// merged with the fields of the parent context.
+func WithFields(ctx context.Context, fields logrus.Fields) context.Context {
+	merged := logrus.Fields{}
+	if parent, ok := ctx.Value(contextKey{}).(logrus.Fields); ok {
+		for k, v := range parent {
+			merged[k] = v
+		}
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, contextKey{}, merged)
+}
+
+// FromContext returns the log entry with the fields of the context.
+func FromContext(ctx context.Context) *logrus.Entry {
+	if ctx != nil {
+		if fields, ok := ctx.Value(contextKey{}).(logrus.Fields); ok {
+			return logrus.WithFields(fields)
+		}
+	}
+	return logrus.NewEntry(logrus.StandardLogger())
+}
+
+// NewTextFormatter returns the text formatter of the logs, only the image
+// ID field is displayed as 'IMG' to keep the text logs readable.
+func NewTextFormatter(colors bool) logrus.Formatter {
+	return &formatter{
+		Formatter: &nested.Formatter{
+			HideKeys:        false,
+			TimestampFormat: "[15:04:05]", // hour, time, sec only
+			FieldsOrder:     []string{textImageIDKey},
+			NoColors:        !colors,
+		},
+	}
+}
+
+// NewJSONFormatter returns the JSON formatter of the logs, the command
+// field is added to every line if provided.
+func NewJSONFormatter(command string) logrus.Formatter {
+	return &formatter{
+		Formatter: &logrus.JSONFormatter{},
+		command:   command,
+		json:      true,
+	}
+}
+
+// NewFormatter returns the formatter of the format.
+func NewFormatter(format Format, command string, colors bool) logrus.Formatter {
+	if format == FormatJSON {
+		return NewJSONFormatter(command)
+	}
+	return NewTextFormatter(colors)
+}
+
+// formatter formats the structured fields before writing the log entry.
+type formatter struct {
+	logrus.Formatter
+
+	command string
+	json    bool
+}
+
+func (f *formatter) Format(entry *logrus.Entry) ([]byte, error) {
+	e := *entry
+	e.Data = make(logrus.Fields, len(entry.Data)+1)
+	if f.json {
+		if f.command != "" {
+			e.Data[FieldCommand] = f.command
+		}
+		for k, v := range entry.Data {
+			e.Data[k] = v
+		}
+		return f.Formatter.Format(&e)
+	}
+	for k, v := range entry.Data {
+		switch k {
+		case FieldImageID:
+			e.Data[textImageIDKey] = v
+		case FieldCommand, FieldSource, FieldDestination,
+			FieldPlatform, FieldDigest, FieldPhase:
+			// The structured fields are already in the message.
+		default:
+			e.Data[k] = v
+		}
+	}
+	return f.Formatter.Format(&e)
+}
+
+// FileOptions is the options to write the logs into the file.
+type FileOptions struct {
+	Name string
+	// MaxSize is the maximum size in megabytes before rotated.
+	MaxSize int
+	// MaxBackups is the maximum number of the rotated files to retain.
+	MaxBackups int
+}
+
+// FileHook writes the logs of all levels into the rotated file.
+type FileHook struct {
+	writer    io.WriteCloser
+	formatter logrus.Formatter
+}
+
+// NewFileHook creates the hook writing the logs into the file by the
+// formatter, the file is rotated when reached the maximum size.
+func NewFileHook(o *FileOptions, formatter logrus.Formatter) *FileHook {
+	return &FileHook{
+		writer: &lumberjack.Logger{
+			Filename:   o.Name,
+			MaxSize:    o.MaxSize,
+			MaxBackups: o.MaxBackups,
+		},
+		formatter: formatter,
+	}
+}
+
+func (h *FileHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (h *FileHook) Fire(entry *logrus.Entry) error {
+	b, err := h.formatter.Format(entry)
+	if err != nil {
+		return err
+	}
+	_, err = h.writer.Write(b)
+	return err
+}
+
+// Close closes the log file.
+func (h *FileHook) Close() error {
+	return h.writer.Close()
+}
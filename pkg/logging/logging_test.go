@@ -0,0 +1,104 @@
+package logging
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ParseFormat(t *testing.T) {
+	f, err := ParseFormat("")
+	assert.NoError(t, err)
+	assert.Equal(t, FormatText, f)
+	f, err = ParseFormat("JSON")
+	assert.NoError(t, err)
+	assert.Equal(t, FormatJSON, f)
+	_, err = ParseFormat("xml")
+	assert.Error(t, err)
+}
+
+func Test_Platform(t *testing.T) {
+	assert.Equal(t, "linux/amd64", Platform("linux", "amd64", ""))
+	assert.Equal(t, "linux/arm64/v8", Platform("linux", "arm64", "v8"))
+}
+
+func Test_WithFields(t *testing.T) {
+	assert.Empty(t, FromContext(context.Background()).Data)
+
+	ctx := WithFields(context.Background(), logrus.Fields{
+		FieldImageID: 1,
+		FieldPhase:   PhaseCopy,
+	})
+	child := WithFields(ctx, logrus.Fields{
+		FieldPhase:    PhaseManifest,
+		FieldPlatform: "linux/amd64",
+	})
+	assert.Equal(t, logrus.Fields{
+		FieldImageID: 1,
+		FieldPhase:   PhaseCopy,
+	}, FromContext(ctx).Data)
+	assert.Equal(t, logrus.Fields{
+		FieldImageID:  1,
+		FieldPhase:    PhaseManifest,
+		FieldPlatform: "linux/amd64",
+	}, FromContext(child).Data)
+}
+
+func newEntry() *logrus.Entry {
+	e := logrus.NewEntry(logrus.StandardLogger()).WithFields(logrus.Fields{
+		FieldImageID: 3,
+		FieldSource:  "docker.io/library/nginx:latest",
+		FieldPhase:   PhaseCopy,
+		"other":      "value",
+	})
+	e.Level = logrus.InfoLevel
+	e.Message = "Copying"
+	return e
+}
+
+func Test_JSONFormatter(t *testing.T) {
+	b, err := NewJSONFormatter("mirror").Format(newEntry())
+	assert.NoError(t, err)
+	data := map[string]any{}
+	assert.NoError(t, json.Unmarshal(b, &data))
+	assert.Equal(t, "mirror", data[FieldCommand])
+	assert.Equal(t, float64(3), data[FieldImageID])
+	assert.Equal(t, "docker.io/library/nginx:latest", data[FieldSource])
+	assert.Equal(t, PhaseCopy, data[FieldPhase])
+	assert.Equal(t, "value", data["other"])
+	assert.Equal(t, "Copying", data["msg"])
+	assert.Equal(t, "info", data["level"])
+}
+
+func Test_TextFormatter(t *testing.T) {
+	b, err := NewTextFormatter(false).Format(newEntry())
+	assert.NoError(t, err)
+	s := string(b)
+	assert.Contains(t, s, "[IMG:3]")
+	assert.Contains(t, s, "[other:value]")
+	assert.Contains(t, s, "Copying")
+	assert.NotContains(t, s, "nginx")
+	assert.NotContains(t, s, PhaseCopy)
+}
+
+func Test_FileHook(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "hangar.log")
+	h := NewFileHook(&FileOptions{Name: name, MaxSize: 1, MaxBackups: 1},
+		NewJSONFormatter("save"))
+	assert.Equal(t, logrus.AllLevels, h.Levels())
+	assert.NoError(t, h.Fire(newEntry()))
+	assert.NoError(t, h.Fire(newEntry()))
+	assert.NoError(t, h.Close())
+
+	b, err := os.ReadFile(name)
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	assert.Len(t, lines, 2)
+	assert.Contains(t, lines[0], `"command":"save"`)
+}
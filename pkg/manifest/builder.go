@@ -6,10 +6,12 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/cnrancher/hangar/pkg/tracing"
 	"github.com/containers/common/pkg/retry"
 	"github.com/containers/image/v5/manifest"
 	"github.com/containers/image/v5/transports/alltransports"
 	"github.com/containers/image/v5/types"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // Builder is the builder to build DockerV2ListMediaType manifest.
@@ -76,10 +78,14 @@ func (b *Builder) Images() int {
 	return len(b.images)
 }
 
-func (b *Builder) Push(ctx context.Context) error {
+func (b *Builder) Push(ctx context.Context) (err error) {
 	if len(b.images) == 0 {
 		return fmt.Errorf("manifest builder: no images added to builder")
 	}
+	ctx, span := tracing.Start(ctx, "push manifest list",
+		attribute.String("image", b.reference.StringWithinTransport()),
+		attribute.Int("images", len(b.images)))
+	defer func() { tracing.End(span, err) }()
 	list := manifest.Schema2List{
 		SchemaVersion: 2,
 		MediaType:     manifest.DockerV2ListMediaType,
@@ -131,3 +137,45 @@ func (b *Builder) Push(ctx context.Context) error {
 	}
 	return nil
 }
+
+// PushRaw pushes the raw manifest to the destination image reference
+// without modification, the digest of the manifest will be preserved.
+func PushRaw(
+	ctx context.Context,
+	referenceName string,
+	sysCtx *types.SystemContext,
+	raw []byte,
+) (err error) {
+	ref, err := alltransports.ParseImageName(referenceName)
+	if err != nil {
+		return err
+	}
+	ctx, span := tracing.Start(ctx, "push manifest",
+		attribute.String("image", ref.StringWithinTransport()))
+	defer func() { tracing.End(span, err) }()
+	if sysCtx == nil {
+		sysCtx = &types.SystemContext{}
+	}
+	var (
+		dest types.ImageDestination
+	)
+	if err = retry.IfNecessary(ctx, func() error {
+		dest, err = ref.NewImageDestination(ctx, sysCtx)
+		return err
+	}, &retry.Options{
+		MaxRetry: defaultRetryTimes,
+		Delay:    defaultRetryDelay,
+	}); err != nil {
+		return fmt.Errorf("manifest push raw: %w", err)
+	}
+	defer dest.Close()
+	if err = retry.IfNecessary(ctx, func() error {
+		return dest.PutManifest(ctx, raw, nil)
+	}, &retry.Options{
+		MaxRetry: defaultRetryTimes,
+		Delay:    defaultRetryDelay,
+	}); err != nil {
+		return fmt.Errorf("manifest push raw: %w", err)
+	}
+	return nil
+}
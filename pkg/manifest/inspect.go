@@ -4,10 +4,12 @@ import (
 	"context"
 	"time"
 
+	"github.com/cnrancher/hangar/pkg/tracing"
 	"github.com/containers/common/pkg/retry"
 	"github.com/containers/image/v5/image"
 	"github.com/containers/image/v5/transports/alltransports"
 	"github.com/containers/image/v5/types"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 const (
@@ -90,6 +92,9 @@ func (ins *Inspector) Raw(ctx context.Context) ([]byte, string, error) {
 		mime string
 		err  error
 	)
+	ctx, span := tracing.Start(ctx, "inspect manifest", attribute.String(
+		"image", ins.source.Reference().StringWithinTransport()))
+	defer func() { tracing.End(span, err) }()
 	if err = retry.IfNecessary(ctx, func() error {
 		b, mime, err = ins.source.GetManifest(ctx, nil)
 		return err
@@ -139,7 +144,10 @@ func (ins *Inspector) ConfigInfo(ctx context.Context) (*types.BlobInfo, error) {
 	return &blobInfo, nil
 }
 
-func (ins *Inspector) Inspect(ctx context.Context) (*types.ImageInspectInfo, error) {
+func (ins *Inspector) Inspect(ctx context.Context) (_ *types.ImageInspectInfo, err error) {
+	ctx, span := tracing.Start(ctx, "inspect image", attribute.String(
+		"image", ins.source.Reference().StringWithinTransport()))
+	defer func() { tracing.End(span, err) }()
 	image, err := image.FromUnparsedImage(
 		ctx, ins.systemContext, image.UnparsedInstance(ins.source, nil))
 	if err != nil {
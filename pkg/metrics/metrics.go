@@ -0,0 +1,238 @@
+// Package metrics exposes the Prometheus metrics and the status page of the
+// running images copy jobs, which is useful to observe the long runs.
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/cnrancher/hangar/pkg/progress"
+	"github.com/cnrancher/hangar/pkg/utils"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
+)
+
+const namespace = "hangar"
+
+// Metrics collects the metrics of the images copy progress. The methods of
+// the nil Metrics do nothing, so it is safe to use nil Metrics if the
+// metrics is disabled.
+type Metrics struct {
+	tracker  atomic.Pointer[progress.Tracker]
+	registry *prometheus.Registry
+	server   *http.Server
+
+	workers     atomic.Int64
+	workersBusy atomic.Int64
+
+	imagesTotal    *prometheus.Desc
+	imagesDone     *prometheus.Desc
+	imagesFailed   *prometheus.Desc
+	imagesInFlight *prometheus.Desc
+	bytesCopied    *prometheus.Desc
+	cacheHits      *prometheus.Desc
+	retries        *prometheus.Desc
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		imagesTotal: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "images"),
+			"Number of the images to be copied.", nil, nil),
+		imagesDone: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "images_done_total"),
+			"Number of the images copied successfully.", nil, nil),
+		imagesFailed: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "images_failed_total"),
+			"Number of the images failed to copy.", nil, nil),
+		imagesInFlight: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "images_in_flight"),
+			"Number of the images being copied.", nil, nil),
+		bytesCopied: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "bytes_copied_total"),
+			"Bytes of the blobs copied by the source registry and the destination of the image.",
+			[]string{"registry", "destination"}, nil),
+		cacheHits: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "blob_cache_hits_total"),
+			"Number of the blobs skipped since already exist.", nil, nil),
+		retries: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "retries_total"),
+			"Number of the image copy retries.", nil, nil),
+	}
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "workers",
+			Help:      "Number of the running workers.",
+		}, func() float64 { return float64(m.workers.Load()) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "workers_busy",
+			Help:      "Number of the workers handling images.",
+		}, func() float64 { return float64(m.workersBusy.Load()) }),
+		m,
+	)
+	return m
+}
+
+// SetTracker sets the progress tracker to collect the image metrics.
+func (m *Metrics) SetTracker(t *progress.Tracker) {
+	if m == nil {
+		return
+	}
+	m.tracker.Store(t)
+}
+
+// WorkerStarted records the worker started, the returned function should
+// be called after the worker stopped.
+func (m *Metrics) WorkerStarted() func() {
+	if m == nil {
+		return func() {}
+	}
+	m.workers.Add(1)
+	return func() { m.workers.Add(-1) }
+}
+
+// WorkerBusy records the worker started handling the image, the returned
+// function should be called after the image finished.
+func (m *Metrics) WorkerBusy() func() {
+	if m == nil {
+		return func() {}
+	}
+	m.workersBusy.Add(1)
+	return func() { m.workersBusy.Add(-1) }
+}
+
+// Describe implements the prometheus.Collector interface.
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	ch <- m.imagesTotal
+	ch <- m.imagesDone
+	ch <- m.imagesFailed
+	ch <- m.imagesInFlight
+	ch <- m.bytesCopied
+	ch <- m.cacheHits
+	ch <- m.retries
+}
+
+// Collect implements the prometheus.Collector interface, the image metrics
+// are collected from the progress tracker.
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	t := m.tracker.Load()
+	stats := t.Stats()
+	var (
+		cacheHits int
+		retries   int
+		bytes     = map[[2]string]int64{}
+	)
+	for _, img := range t.Images() {
+		cacheHits += img.CacheHits
+		retries += img.Retries
+		registry := utils.GetRegistryName(img.Name)
+		for destination, n := range img.Destinations {
+			bytes[[2]string{registry, destination}] += n
+		}
+	}
+	ch <- prometheus.MustNewConstMetric(
+		m.imagesTotal, prometheus.GaugeValue, float64(stats.ImagesTotal))
+	ch <- prometheus.MustNewConstMetric(
+		m.imagesDone, prometheus.CounterValue, float64(stats.ImagesDone))
+	ch <- prometheus.MustNewConstMetric(
+		m.imagesFailed, prometheus.CounterValue, float64(stats.ImagesFailed))
+	ch <- prometheus.MustNewConstMetric(
+		m.imagesInFlight, prometheus.GaugeValue, float64(stats.ImagesActive))
+	for labels, n := range bytes {
+		ch <- prometheus.MustNewConstMetric(
+			m.bytesCopied, prometheus.CounterValue, float64(n), labels[0], labels[1])
+	}
+	ch <- prometheus.MustNewConstMetric(
+		m.cacheHits, prometheus.CounterValue, float64(cacheHits))
+	ch <- prometheus.MustNewConstMetric(
+		m.retries, prometheus.CounterValue, float64(retries))
+}
+
+// Status is the status page of the running images copy job.
+type Status struct {
+	Stats       progress.Stats         `json:"stats"`
+	Workers     int                    `json:"workers"`
+	WorkersBusy int                    `json:"workersBusy"`
+	InFlight    []progress.ImageStatus `json:"inFlight"`
+}
+
+// Status returns the current status of the images copy job, the in-flight
+// images are sorted by the start time.
+func (m *Metrics) Status() *Status {
+	t := m.tracker.Load()
+	s := &Status{
+		Stats:       t.Stats(),
+		Workers:     int(m.workers.Load()),
+		WorkersBusy: int(m.workersBusy.Load()),
+		InFlight:    make([]progress.ImageStatus, 0),
+	}
+	for _, img := range t.Images() {
+		if !img.Finished {
+			s.InFlight = append(s.InFlight, img)
+		}
+	}
+	sort.SliceStable(s.InFlight, func(i, j int) bool {
+		return s.InFlight[i].StartTime.Before(s.InFlight[j].StartTime)
+	})
+	return s
+}
+
+// Handler returns the HTTP handler of the '/metrics' and '/status' pages.
+func (m *Metrics) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{}))
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		e := json.NewEncoder(w)
+		e.SetIndent("", "  ")
+		if err := e.Encode(m.Status()); err != nil {
+			logrus.Debugf("failed to write status: %v", err)
+		}
+	})
+	return mux
+}
+
+// Start listens the TCP address and serves the metrics in background,
+// needs to call Close() method to stop the server.
+func (m *Metrics) Start(listen string) error {
+	l, err := net.Listen("tcp", listen)
+	if err != nil {
+		return fmt.Errorf("failed to listen %q: %w", listen, err)
+	}
+	m.server = &http.Server{
+		Handler:           m.Handler(),
+		ReadHeaderTimeout: time.Second * 30,
+	}
+	go func() {
+		err := m.server.Serve(l)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logrus.Errorf("Metrics server stopped: %v", err)
+		}
+	}()
+	logrus.Infof("Serving metrics on http://%s/metrics", l.Addr())
+	return nil
+}
+
+// Close stops the metrics server.
+func (m *Metrics) Close() error {
+	if m == nil || m.server == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	return m.server.Shutdown(ctx)
+}
@@ -0,0 +1,94 @@
+package metrics
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cnrancher/hangar/pkg/progress"
+	"github.com/containers/image/v5/types"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_NilMetrics(t *testing.T) {
+	var m *Metrics
+	m.SetTracker(nil)
+	m.WorkerStarted()()
+	m.WorkerBusy()()
+	assert.NoError(t, m.Close())
+}
+
+func Test_Metrics(t *testing.T) {
+	tracker := progress.NewTracker(&progress.Options{
+		Format:   progress.FormatNone,
+		Interval: time.Hour,
+	})
+	tracker.Start(2)
+	defer tracker.Stop()
+
+	m := New()
+	m.SetTracker(tracker)
+	stopWorker := m.WorkerStarted()
+	idle := m.WorkerBusy()
+
+	blob := types.BlobInfo{Digest: digest.FromString("layer"), Size: 100}
+	done := tracker.NewImage("docker.io/library/nginx:latest")
+	for _, destination := range []string{"a.example.io", "b.example.io"} {
+		ch, stop := done.Channel(destination)
+		ch <- types.ProgressProperties{Event: types.ProgressEventNewArtifact, Artifact: blob}
+		ch <- types.ProgressProperties{
+			Event: types.ProgressEventRead, Artifact: blob, Offset: 100, OffsetUpdate: 100}
+		ch <- types.ProgressProperties{Event: types.ProgressEventDone, Artifact: blob}
+		stop()
+	}
+	done.AddRetries(1)
+	done.Done(nil)
+	tracker.NewImage("quay.io/foo/bar:v1")
+
+	server := httptest.NewServer(m.Handler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/metrics")
+	assert.NoError(t, err)
+	b, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	body := string(b)
+	assert.Contains(t, body, "hangar_images 2")
+	assert.Contains(t, body, "hangar_images_done_total 1")
+	assert.Contains(t, body, "hangar_images_failed_total 0")
+	assert.Contains(t, body, "hangar_images_in_flight 1")
+	assert.Contains(t, body,
+		`hangar_bytes_copied_total{destination="a.example.io",registry="docker.io"} 100`)
+	assert.Contains(t, body,
+		`hangar_bytes_copied_total{destination="b.example.io",registry="docker.io"} 100`)
+	assert.Contains(t, body, "hangar_retries_total 1")
+	assert.Contains(t, body, "hangar_workers 1")
+	assert.Contains(t, body, "hangar_workers_busy 1")
+
+	resp, err = http.Get(server.URL + "/status")
+	assert.NoError(t, err)
+	status := &Status{}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(status))
+	resp.Body.Close()
+	assert.Equal(t, 1, status.Workers)
+	assert.Equal(t, 1, status.WorkersBusy)
+	if assert.Len(t, status.InFlight, 1) {
+		assert.Equal(t, "quay.io/foo/bar:v1", status.InFlight[0].Name)
+	}
+
+	idle()
+	stopWorker()
+	assert.Equal(t, 0, m.Status().Workers)
+	assert.Equal(t, 0, m.Status().WorkersBusy)
+}
+
+func Test_Start(t *testing.T) {
+	m := New()
+	assert.NoError(t, m.Start("127.0.0.1:0"))
+	assert.NoError(t, m.Close())
+	assert.Error(t, New().Start("invalid address"))
+}
@@ -0,0 +1,586 @@
+// Package progress collects the blob copy progress events of the
+// containers/image library and reports the aggregated progress of the
+// images, the progress is rendered as bars in terminal or printed as
+// periodic plain-text/JSON lines in CI logs.
+package progress
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/containers/image/v5/types"
+	"github.com/docker/go-units"
+	"github.com/opencontainers/go-digest"
+	"github.com/sirupsen/logrus"
+)
+
+// Format is the output format of the progress.
+type Format string
+
+const (
+	// FormatAuto renders bars if the output is a terminal, otherwise
+	// prints plain-text lines.
+	FormatAuto  Format = "auto"
+	FormatTTY   Format = "tty"
+	FormatPlain Format = "plain"
+	FormatJSON  Format = "json"
+	FormatNone  Format = "none"
+)
+
+const (
+	// defaultTTYInterval is the default refresh interval of the bars.
+	defaultTTYInterval = time.Millisecond * 500
+	// defaultLineInterval is the default interval of the plain/JSON lines.
+	defaultLineInterval = time.Second * 10
+	// rateWindow is the time window to calculate the transfer rate.
+	rateWindow = time.Second * 10
+	// barWidth is the width of the progress bar in terminal.
+	barWidth = 20
+)
+
+// ParseFormat parses the progress format string.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(s)); f {
+	case "":
+		return FormatAuto, nil
+	case FormatAuto, FormatTTY, FormatPlain, FormatJSON, FormatNone:
+		return f, nil
+	}
+	return "", fmt.Errorf("invalid progress format %q, available: "+
+		"auto, tty, plain, json, none", s)
+}
+
+type Options struct {
+	// Format is the output format, FormatAuto is not resolved by the
+	// tracker and will be treated as FormatPlain.
+	Format Format
+	// Output is the writer of the bars and the JSON lines (default
+	// os.Stderr), the plain-text lines are logged by the standard logger.
+	Output io.Writer
+	// Interval is the refresh interval of the bars or the interval of the
+	// plain/JSON lines (optional).
+	Interval time.Duration
+}
+
+// Tracker tracks the copy progress of images. The methods of the nil
+// Tracker do nothing, so it is safe to use nil Tracker if the progress
+// reporting is disabled.
+type Tracker struct {
+	mutex    *sync.Mutex
+	format   Format
+	out      io.Writer
+	interval time.Duration
+
+	// images is the list of the images started to copy.
+	images []*Image
+	// total is the number of the images to be copied.
+	total int
+	// transferred is the number of bytes transferred.
+	transferred uint64
+	// samples are used for calculating the transfer rate.
+	samples []sample
+	// rate is the current transfer rate in bytes/s.
+	rate float64
+	// lines is the number of the lines rendered in terminal.
+	lines   int
+	startAt time.Time
+	started bool
+	stopCh  chan struct{}
+	doneCh  chan struct{}
+}
+
+type sample struct {
+	time        time.Time
+	transferred uint64
+}
+
+// Image is the copy progress of a single image, which may contain
+// multiple platform images.
+type Image struct {
+	tracker  *Tracker
+	name     string
+	blobs    map[digest.Digest]*blob
+	finished bool
+	failed   bool
+	// transferred is the number of bytes transferred of the image.
+	transferred uint64
+	// destinations is the number of bytes transferred to each destination.
+	destinations map[string]uint64
+	// retries is the number of the copy retries of the image.
+	retries int
+	// cacheHits is the number of the blobs skipped since already exist.
+	cacheHits int
+	startAt   time.Time
+}
+
+type blob struct {
+	size   int64
+	offset uint64
+	done   bool
+}
+
+// Stats is the aggregated progress of all the images.
+type Stats struct {
+	ImagesTotal  int `json:"imagesTotal"`
+	ImagesDone   int `json:"imagesDone"`
+	ImagesFailed int `json:"imagesFailed"`
+	ImagesActive int `json:"imagesActive"`
+	// BytesDone is the bytes copied or skipped of the known blobs.
+	BytesDone int64 `json:"bytesDone"`
+	// BytesTotal is the total size of the known blobs.
+	BytesTotal int64 `json:"bytesTotal"`
+	// Rate is the transfer rate in bytes/s.
+	Rate float64 `json:"rate"`
+	// ETA is the estimated remaining time, -1 if unknown.
+	ETA     time.Duration `json:"eta"`
+	Elapsed time.Duration `json:"elapsed"`
+}
+
+func NewTracker(o *Options) *Tracker {
+	t := &Tracker{
+		mutex:    &sync.Mutex{},
+		format:   o.Format,
+		out:      o.Output,
+		interval: o.Interval,
+	}
+	if t.out == nil {
+		t.out = os.Stderr
+	}
+	if t.format == FormatAuto || t.format == "" {
+		t.format = FormatPlain
+	}
+	if t.interval <= 0 {
+		t.interval = defaultLineInterval
+		if t.format == FormatTTY {
+			t.interval = defaultTTYInterval
+		}
+	}
+	return t
+}
+
+// Start starts reporting the progress, total is the number of the images
+// to be copied.
+func (t *Tracker) Start(total int) {
+	if t == nil {
+		return
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.total += total
+	if t.started || t.format == FormatNone {
+		return
+	}
+	t.started = true
+	t.startAt = time.Now()
+	t.stopCh = make(chan struct{})
+	t.doneCh = make(chan struct{})
+	go t.run()
+}
+
+func (t *Tracker) run() {
+	defer close(t.doneCh)
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.stopCh:
+			return
+		case <-ticker.C:
+			t.mutex.Lock()
+			t.report(false)
+			t.mutex.Unlock()
+		}
+	}
+}
+
+// Stop stops reporting and outputs the final progress.
+func (t *Tracker) Stop() {
+	if t == nil {
+		return
+	}
+	t.mutex.Lock()
+	if !t.started {
+		t.mutex.Unlock()
+		return
+	}
+	t.started = false
+	close(t.stopCh)
+	t.mutex.Unlock()
+	<-t.doneCh
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.report(true)
+	// Keep the final bars in terminal.
+	t.lines = 0
+}
+
+// Writer returns the writer of the log messages to w, the bars are cleared
+// before writing and re-rendered below the messages in terminal. The w is
+// returned directly if the bars are not rendered.
+func (t *Tracker) Writer(w io.Writer) io.Writer {
+	if t == nil || t.format != FormatTTY {
+		return w
+	}
+	return &logWriter{tracker: t, w: w}
+}
+
+type logWriter struct {
+	tracker *Tracker
+	w       io.Writer
+}
+
+func (l *logWriter) Write(p []byte) (int, error) {
+	t := l.tracker
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if !t.started {
+		return l.w.Write(p)
+	}
+	t.clear()
+	n, err := l.w.Write(p)
+	t.render()
+	return n, err
+}
+
+// NewImage starts tracking the copy progress of the image.
+func (t *Tracker) NewImage(name string) *Image {
+	if t == nil {
+		return nil
+	}
+	img := &Image{
+		tracker:      t,
+		name:         name,
+		blobs:        make(map[digest.Digest]*blob),
+		destinations: make(map[string]uint64),
+		startAt:      time.Now(),
+	}
+	t.mutex.Lock()
+	t.images = append(t.images, img)
+	if len(t.images) > t.total {
+		t.total = len(t.images)
+	}
+	t.mutex.Unlock()
+	return img
+}
+
+// Channel returns the progress channel for the copy options of the
+// containers/image copying to the destination (e.g. the registry name),
+// the stop function should be called after the copy finished to release
+// the channel.
+func (img *Image) Channel(destination string) (chan types.ProgressProperties, func()) {
+	if img == nil {
+		return nil, func() {}
+	}
+	ch := make(chan types.ProgressProperties)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for p := range ch {
+			img.update(p, destination)
+		}
+	}()
+	return ch, func() {
+		close(ch)
+		<-done
+	}
+}
+
+// Done marks the image finished, the image is failed if err is not nil.
+func (img *Image) Done(err error) {
+	if img == nil {
+		return
+	}
+	img.tracker.mutex.Lock()
+	defer img.tracker.mutex.Unlock()
+	img.finished = true
+	img.failed = err != nil
+}
+
+// AddRetries adds the number of the copy retries of the image.
+func (img *Image) AddRetries(n int) {
+	if img == nil {
+		return
+	}
+	img.tracker.mutex.Lock()
+	defer img.tracker.mutex.Unlock()
+	img.retries += n
+}
+
+// Transferred returns the number of bytes transferred of the image.
+func (img *Image) Transferred() int64 {
+	if img == nil {
+		return 0
+	}
+	img.tracker.mutex.Lock()
+	defer img.tracker.mutex.Unlock()
+	return int64(img.transferred)
+}
+
+// Retries returns the number of the copy retries of the image.
+func (img *Image) Retries() int {
+	if img == nil {
+		return 0
+	}
+	img.tracker.mutex.Lock()
+	defer img.tracker.mutex.Unlock()
+	return img.retries
+}
+
+func (img *Image) update(p types.ProgressProperties, destination string) {
+	t := img.tracker
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	b, ok := img.blobs[p.Artifact.Digest]
+	if !ok {
+		b = &blob{size: p.Artifact.Size}
+		img.blobs[p.Artifact.Digest] = b
+	}
+	if b.size <= 0 && p.Artifact.Size > 0 {
+		b.size = p.Artifact.Size
+	}
+	switch p.Event {
+	case types.ProgressEventNewArtifact:
+		// The blob may be re-copied when retrying.
+		b.offset = 0
+		b.done = false
+	case types.ProgressEventRead:
+		b.offset = p.Offset
+		t.transferred += p.OffsetUpdate
+		img.transferred += p.OffsetUpdate
+		img.destinations[destination] += p.OffsetUpdate
+	case types.ProgressEventDone:
+		b.offset = p.Offset
+		b.done = true
+		t.transferred += p.OffsetUpdate
+		img.transferred += p.OffsetUpdate
+		img.destinations[destination] += p.OffsetUpdate
+	case types.ProgressEventSkipped:
+		b.done = true
+		img.cacheHits++
+	}
+	if b.done && b.size > 0 {
+		b.offset = uint64(b.size)
+	}
+}
+
+// bytes returns the bytes done and the total size of the known blobs.
+func (img *Image) bytes() (int64, int64) {
+	var done, total int64
+	for _, b := range img.blobs {
+		done += int64(b.offset)
+		if b.size > 0 {
+			total += b.size
+		} else {
+			total += int64(b.offset)
+		}
+	}
+	return done, total
+}
+
+// ImageStatus is the copy status of a single image.
+type ImageStatus struct {
+	Name      string    `json:"name"`
+	StartTime time.Time `json:"startTime"`
+	Finished  bool      `json:"finished"`
+	Failed    bool      `json:"failed"`
+	// BytesDone is the bytes copied or skipped of the known blobs.
+	BytesDone int64 `json:"bytesDone"`
+	// BytesTotal is the total size of the known blobs.
+	BytesTotal int64 `json:"bytesTotal"`
+	// Transferred is the bytes transferred of the image.
+	Transferred int64 `json:"transferred"`
+	// Destinations is the bytes transferred to each destination.
+	Destinations map[string]int64 `json:"destinations,omitempty"`
+	// CacheHits is the number of the blobs skipped since already exist.
+	CacheHits int `json:"cacheHits"`
+	Retries   int `json:"retries"`
+}
+
+// Images returns the copy status of the images started to copy.
+func (t *Tracker) Images() []ImageStatus {
+	if t == nil {
+		return nil
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	images := make([]ImageStatus, 0, len(t.images))
+	for _, img := range t.images {
+		done, total := img.bytes()
+		destinations := make(map[string]int64, len(img.destinations))
+		for d, n := range img.destinations {
+			destinations[d] = int64(n)
+		}
+		images = append(images, ImageStatus{
+			Name:         img.name,
+			StartTime:    img.startAt,
+			Finished:     img.finished,
+			Failed:       img.failed,
+			BytesDone:    done,
+			BytesTotal:   total,
+			Transferred:  int64(img.transferred),
+			Destinations: destinations,
+			CacheHits:    img.cacheHits,
+			Retries:      img.retries,
+		})
+	}
+	return images
+}
+
+// Stats returns the aggregated progress of all the images.
+func (t *Tracker) Stats() Stats {
+	if t == nil {
+		return Stats{}
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.stats()
+}
+
+func (t *Tracker) stats() Stats {
+	s := Stats{
+		ImagesTotal: t.total,
+		Rate:        t.rate,
+		ETA:         -1,
+	}
+	if !t.startAt.IsZero() {
+		s.Elapsed = time.Since(t.startAt)
+	}
+	var finishedBytes int64
+	for _, img := range t.images {
+		done, total := img.bytes()
+		s.BytesDone += done
+		s.BytesTotal += total
+		switch {
+		case img.failed:
+			s.ImagesFailed++
+		case img.finished:
+			s.ImagesDone++
+			finishedBytes += total
+		default:
+			s.ImagesActive++
+		}
+	}
+	if s.Rate <= 0 {
+		return s
+	}
+	// Estimate the size of the images not started by the average size of
+	// the finished images.
+	estimated := s.BytesTotal
+	if pending := t.total - len(t.images); pending > 0 && s.ImagesDone > 0 {
+		estimated += finishedBytes / int64(s.ImagesDone) * int64(pending)
+	}
+	if remaining := estimated - s.BytesDone; remaining > 0 {
+		s.ETA = time.Duration(float64(remaining) / s.Rate * float64(time.Second))
+	} else {
+		s.ETA = 0
+	}
+	return s
+}
+
+// updateRate updates the transfer rate in the rate window.
+func (t *Tracker) updateRate() {
+	now := time.Now()
+	t.samples = append(t.samples, sample{time: now, transferred: t.transferred})
+	for len(t.samples) > 2 && now.Sub(t.samples[0].time) > rateWindow {
+		t.samples = t.samples[1:]
+	}
+	first := t.samples[0]
+	if d := now.Sub(first.time).Seconds(); d > 0 {
+		t.rate = float64(t.transferred-first.transferred) / d
+	}
+}
+
+// report outputs the progress, should be called with the mutex locked.
+func (t *Tracker) report(final bool) {
+	t.updateRate()
+	switch t.format {
+	case FormatTTY:
+		t.clear()
+		t.render()
+	case FormatJSON:
+		s := t.stats()
+		b, _ := json.Marshal(struct {
+			Time  time.Time `json:"time"`
+			Final bool      `json:"final,omitempty"`
+			Stats
+		}{
+			Time:  time.Now(),
+			Final: final,
+			Stats: s,
+		})
+		fmt.Fprintf(t.out, "%s\n", b)
+	case FormatPlain:
+		logrus.Info("Progress: " + summary(t.stats()))
+	}
+}
+
+// clear clears the bars rendered in terminal.
+func (t *Tracker) clear() {
+	for ; t.lines > 0; t.lines-- {
+		// Move cursor up and clear the line.
+		fmt.Fprint(t.out, "\x1b[1A\x1b[2K")
+	}
+}
+
+// render renders the bars of the active images and the summary line.
+func (t *Tracker) render() {
+	var lines []string
+	for _, img := range t.images {
+		if img.finished {
+			continue
+		}
+		done, total := img.bytes()
+		lines = append(lines, fmt.Sprintf("%s %s %s / %s",
+			bar(done, total), img.name,
+			units.BytesSize(float64(done)), units.BytesSize(float64(total))))
+	}
+	lines = append(lines, summary(t.stats()))
+	for _, l := range lines {
+		fmt.Fprintln(t.out, l)
+	}
+	t.lines = len(lines)
+}
+
+func bar(done, total int64) string {
+	var n int
+	if total > 0 {
+		n = int(done * barWidth / total)
+	}
+	if n > barWidth {
+		n = barWidth
+	}
+	return "[" + strings.Repeat("=", n) + strings.Repeat(" ", barWidth-n) + "]"
+}
+
+// summary returns the summary of the progress, example:
+// images 3/10 (1 failed, 2 running), 120MiB / 300MiB, 5.2MiB/s, ETA 35s
+func summary(s Stats) string {
+	var extra []string
+	if s.ImagesFailed > 0 {
+		extra = append(extra, fmt.Sprintf("%d failed", s.ImagesFailed))
+	}
+	if s.ImagesActive > 0 {
+		extra = append(extra, fmt.Sprintf("%d running", s.ImagesActive))
+	}
+	images := fmt.Sprintf("images %d/%d", s.ImagesDone+s.ImagesFailed, s.ImagesTotal)
+	if len(extra) > 0 {
+		images += " (" + strings.Join(extra, ", ") + ")"
+	}
+	eta := "unknown"
+	if s.ETA >= 0 {
+		eta = s.ETA.Round(time.Second).String()
+	}
+	return fmt.Sprintf("%s, %s / %s, %s/s, ETA %s",
+		images,
+		units.BytesSize(float64(s.BytesDone)),
+		units.BytesSize(float64(s.BytesTotal)),
+		units.BytesSize(s.Rate),
+		eta)
+}
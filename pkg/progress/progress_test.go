@@ -0,0 +1,157 @@
+package progress
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/containers/image/v5/types"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ParseFormat(t *testing.T) {
+	f, err := ParseFormat("")
+	assert.NoError(t, err)
+	assert.Equal(t, FormatAuto, f)
+	f, err = ParseFormat("JSON")
+	assert.NoError(t, err)
+	assert.Equal(t, FormatJSON, f)
+	_, err = ParseFormat("invalid")
+	assert.Error(t, err)
+}
+
+func Test_NilTracker(t *testing.T) {
+	var tracker *Tracker
+	tracker.Start(1)
+	img := tracker.NewImage("nginx")
+	ch, stop := img.Channel("")
+	assert.Nil(t, ch)
+	stop()
+	img.Done(nil)
+	img.AddRetries(1)
+	assert.Equal(t, int64(0), img.Transferred())
+	assert.Equal(t, 0, img.Retries())
+	tracker.Stop()
+	assert.Equal(t, Stats{}, tracker.Stats())
+	assert.Nil(t, tracker.Images())
+}
+
+func Test_Tracker(t *testing.T) {
+	out := &bytes.Buffer{}
+	tracker := NewTracker(&Options{
+		Format:   FormatJSON,
+		Output:   out,
+		Interval: time.Hour,
+	})
+	tracker.Start(3)
+
+	blob := types.BlobInfo{Digest: digest.FromString("layer"), Size: 100}
+	img := tracker.NewImage("docker.io/library/nginx:latest")
+	ch, stop := img.Channel("a.example.io")
+	ch <- types.ProgressProperties{Event: types.ProgressEventNewArtifact, Artifact: blob}
+	ch <- types.ProgressProperties{
+		Event: types.ProgressEventRead, Artifact: blob, Offset: 60, OffsetUpdate: 60}
+	stop()
+	s := tracker.Stats()
+	assert.Equal(t, 3, s.ImagesTotal)
+	assert.Equal(t, 1, s.ImagesActive)
+	assert.Equal(t, int64(60), s.BytesDone)
+	assert.Equal(t, int64(100), s.BytesTotal)
+
+	ch, stop = img.Channel("b.example.io")
+	ch <- types.ProgressProperties{
+		Event: types.ProgressEventDone, Artifact: blob, Offset: 100, OffsetUpdate: 40}
+	ch <- types.ProgressProperties{
+		Event:    types.ProgressEventSkipped,
+		Artifact: types.BlobInfo{Digest: digest.FromString("config"), Size: 10},
+	}
+	stop()
+	img.AddRetries(2)
+	img.Done(nil)
+	assert.Equal(t, int64(100), img.Transferred())
+	assert.Equal(t, 2, img.Retries())
+	images := tracker.Images()
+	if assert.Len(t, images, 1) {
+		assert.Equal(t, "docker.io/library/nginx:latest", images[0].Name)
+		assert.True(t, images[0].Finished)
+		assert.Equal(t, 1, images[0].CacheHits)
+		assert.Equal(t, int64(100), images[0].Transferred)
+		assert.Equal(t, map[string]int64{
+			"a.example.io": 60,
+			"b.example.io": 40,
+		}, images[0].Destinations)
+	}
+	tracker.NewImage("docker.io/library/busybox:latest").Done(errors.New("failed"))
+
+	s = tracker.Stats()
+	assert.Equal(t, 1, s.ImagesDone)
+	assert.Equal(t, 1, s.ImagesFailed)
+	assert.Equal(t, 0, s.ImagesActive)
+	assert.Equal(t, int64(110), s.BytesDone)
+	assert.Equal(t, int64(110), s.BytesTotal)
+
+	tracker.Stop()
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if !assert.Len(t, lines, 1) {
+		return
+	}
+	data := map[string]any{}
+	assert.NoError(t, json.Unmarshal([]byte(lines[0]), &data))
+	assert.Equal(t, true, data["final"])
+	assert.Equal(t, float64(1), data["imagesDone"])
+	assert.Equal(t, float64(110), data["bytesDone"])
+}
+
+func Test_TrackerWriter(t *testing.T) {
+	out := &bytes.Buffer{}
+	log := &bytes.Buffer{}
+	plain := NewTracker(&Options{Format: FormatPlain, Output: out})
+	assert.Equal(t, log, plain.Writer(log))
+	var nilTracker *Tracker
+	assert.Equal(t, log, nilTracker.Writer(log))
+
+	tracker := NewTracker(&Options{
+		Format:   FormatTTY,
+		Output:   out,
+		Interval: time.Hour,
+	})
+	w := tracker.Writer(log)
+	w.Write([]byte("before start\n"))
+	assert.Equal(t, "before start\n", log.String())
+	assert.Empty(t, out.String())
+
+	tracker.Start(1)
+	tracker.NewImage("docker.io/library/nginx:latest")
+	tracker.mutex.Lock()
+	tracker.render()
+	tracker.mutex.Unlock()
+	out.Reset()
+	log.Reset()
+	// The bars are cleared and re-rendered below the log message.
+	w.Write([]byte("message\n"))
+	assert.Equal(t, "message\n", log.String())
+	assert.True(t, strings.HasPrefix(out.String(), strings.Repeat("\x1b[1A\x1b[2K", 2)))
+	assert.Contains(t, out.String(), "docker.io/library/nginx:latest")
+	tracker.Stop()
+}
+
+func Test_summary(t *testing.T) {
+	assert.Equal(t,
+		"images 3/10 (1 failed, 2 running), 1KiB / 2KiB, 512B/s, ETA 2s",
+		summary(Stats{
+			ImagesTotal:  10,
+			ImagesDone:   2,
+			ImagesFailed: 1,
+			ImagesActive: 2,
+			BytesDone:    1024,
+			BytesTotal:   2048,
+			Rate:         512,
+			ETA:          time.Second * 2,
+		}))
+	assert.Equal(t, "images 0/1, 0B / 0B, 0B/s, ETA unknown",
+		summary(Stats{ImagesTotal: 1, ETA: -1}))
+}
@@ -0,0 +1,239 @@
+package provider
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// ACRAccessKeyIDEnv and ACRAccessKeySecretEnv are the environment
+	// variables of the Alibaba Cloud AccessKey to manage the ACR namespaces.
+	ACRAccessKeyIDEnv     = "ALIBABA_CLOUD_ACCESS_KEY_ID"
+	ACRAccessKeySecretEnv = "ALIBABA_CLOUD_ACCESS_KEY_SECRET"
+
+	acrDomainSuffix = ".cr.aliyuncs.com"
+	acrAPIVersion   = "2018-12-01"
+)
+
+// acrProvider manages the namespaces of the Alibaba Cloud Container Registry
+// (ACR) Enterprise Edition instance by the OpenAPI, the registry should be
+// the instance domain '<instance>-registry[-vpc].<region>.cr.aliyuncs.com'.
+// The namespaces are created with the repositories created automatically
+// when pushing images.
+type acrProvider struct {
+	*client
+
+	accessKeyID     string
+	accessKeySecret string
+	region          string
+	instanceID      string
+	// now returns the timestamp of the request.
+	now func() time.Time
+}
+
+type acrResponse struct {
+	Code      string `json:"Code"`
+	Message   string `json:"Message"`
+	IsSuccess *bool  `json:"IsSuccess"`
+}
+
+// isACRRegistry returns true if the registry is the ACR instance domain.
+func isACRRegistry(registry string) bool {
+	host, _, _ := strings.Cut(registry, ":")
+	return strings.HasSuffix(strings.ToLower(host), acrDomainSuffix)
+}
+
+// parseACRRegistry returns the instance name and the region of the ACR
+// instance domain.
+func parseACRRegistry(registry string) (string, string, error) {
+	host, _, _ := strings.Cut(strings.ToLower(registry), ":")
+	labels := strings.Split(strings.TrimSuffix(host, acrDomainSuffix), ".")
+	if !isACRRegistry(registry) || len(labels) != 2 {
+		return "", "", fmt.Errorf("invalid ACR registry %q: should be "+
+			"'<instance>-registry.<region>.cr.aliyuncs.com'", registry)
+	}
+	instance := strings.TrimSuffix(labels[0], "-vpc")
+	if !strings.HasSuffix(instance, "-registry") {
+		return "", "", fmt.Errorf("invalid ACR registry %q: should be "+
+			"'<instance>-registry.<region>.cr.aliyuncs.com'", registry)
+	}
+	return strings.TrimSuffix(instance, "-registry"), labels[1], nil
+}
+
+func newACR(ctx context.Context, o *Options) (*acrProvider, error) {
+	instance, region, err := parseACRRegistry(o.Registry)
+	if err != nil {
+		return nil, err
+	}
+	p := &acrProvider{
+		accessKeyID:     os.Getenv(ACRAccessKeyIDEnv),
+		accessKeySecret: os.Getenv(ACRAccessKeySecretEnv),
+		region:          region,
+		now:             time.Now,
+	}
+	if p.accessKeyID == "" || p.accessKeySecret == "" {
+		return nil, fmt.Errorf("AccessKey of the ACR registry %q not provided: "+
+			"set the %s and %s environment variables",
+			o.Registry, ACRAccessKeyIDEnv, ACRAccessKeySecretEnv)
+	}
+	u := o.URL
+	if u == "" {
+		u = fmt.Sprintf("https://cr.%s.aliyuncs.com", region)
+	} else if !strings.Contains(u, "://") {
+		u = "https://" + u
+	}
+	o = &Options{URL: u, SystemContext: o.SystemContext}
+	if p.client, err = newClient(ctx, o); err != nil {
+		return nil, err
+	}
+
+	// Get the instance ID by the instance name.
+	var instances struct {
+		acrResponse
+		Instances []struct {
+			InstanceID   string `json:"InstanceId"`
+			InstanceName string `json:"InstanceName"`
+		} `json:"Instances"`
+	}
+	_, err = p.call(ctx, "ListInstance", map[string]string{
+		"InstanceName": instance,
+		"PageSize":     "100",
+	}, &instances, &instances.acrResponse)
+	if err != nil {
+		return nil, fmt.Errorf("provider.acr: failed to list instances: %w", err)
+	}
+	for _, i := range instances.Instances {
+		if strings.EqualFold(i.InstanceName, instance) {
+			p.instanceID = i.InstanceID
+			break
+		}
+	}
+	if p.instanceID == "" {
+		return nil, fmt.Errorf("provider.acr: instance %q not found in region %q",
+			instance, region)
+	}
+	return p, nil
+}
+
+func (p *acrProvider) Type() Type {
+	return TypeACR
+}
+
+func (p *acrProvider) URL() string {
+	return p.baseURL
+}
+
+func (p *acrProvider) NamespaceExists(ctx context.Context, name string) (bool, error) {
+	var namespace struct {
+		acrResponse
+		NamespaceName string `json:"NamespaceName"`
+	}
+	code, err := p.call(ctx, "GetNamespace", map[string]string{
+		"InstanceId":    p.instanceID,
+		"NamespaceName": name,
+	}, &namespace, &namespace.acrResponse)
+	if err != nil {
+		if code == http.StatusNotFound || strings.Contains(namespace.Code, "NOT_EXIST") {
+			return false, nil
+		}
+		return false, fmt.Errorf("provider.acr.NamespaceExists: %w", err)
+	}
+	return namespace.NamespaceName != "", nil
+}
+
+// CreateNamespace creates the namespace with the private repositories
+// created automatically.
+func (p *acrProvider) CreateNamespace(ctx context.Context, name string) error {
+	resp := acrResponse{}
+	_, err := p.call(ctx, "CreateNamespace", map[string]string{
+		"InstanceId":      p.instanceID,
+		"NamespaceName":   name,
+		"AutoCreateRepo":  "true",
+		"DefaultRepoType": "PRIVATE",
+	}, &resp, &resp)
+	if err != nil {
+		return fmt.Errorf("provider.acr.CreateNamespace: failed to create namespace %q: %w",
+			name, err)
+	}
+	return nil
+}
+
+// call calls the OpenAPI action and decodes the JSON response to out, the
+// resp is the common response fields of out, returns the status code.
+func (p *acrProvider) call(
+	ctx context.Context, action string, params map[string]string, out any, resp *acrResponse,
+) (int, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return 0, err
+	}
+	query := map[string]string{
+		"Action":           action,
+		"Format":           "JSON",
+		"Version":          acrAPIVersion,
+		"RegionId":         p.region,
+		"AccessKeyId":      p.accessKeyID,
+		"SignatureMethod":  "HMAC-SHA1",
+		"SignatureVersion": "1.0",
+		"SignatureNonce":   hex.EncodeToString(nonce),
+		"Timestamp":        p.now().UTC().Format("2006-01-02T15:04:05Z"),
+	}
+	for k, v := range params {
+		query[k] = v
+	}
+	canonical := acrCanonicalQuery(query)
+	signature := acrSignature(http.MethodGet, canonical, p.accessKeySecret)
+	code, err := p.do(ctx, http.MethodGet,
+		"/?"+canonical+"&Signature="+acrPercentEncode(signature), nil, out)
+	if err != nil {
+		return code, err
+	}
+	if code < 200 || code >= 300 {
+		return code, fmt.Errorf("%v: unexpected status code %v", action, code)
+	}
+	if resp.IsSuccess != nil && !*resp.IsSuccess {
+		return code, fmt.Errorf("%v: %v %v", action, resp.Code, resp.Message)
+	}
+	return code, nil
+}
+
+// acrCanonicalQuery returns the query string sorted by the keys.
+func acrCanonicalQuery(query map[string]string) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, acrPercentEncode(k)+"="+acrPercentEncode(query[k]))
+	}
+	return strings.Join(pairs, "&")
+}
+
+// acrSignature returns the signature of the canonical query string by the
+// Alibaba Cloud RPC signature algorithm (HMAC-SHA1).
+func acrSignature(method, canonical, secret string) string {
+	s := method + "&" + acrPercentEncode("/") + "&" + acrPercentEncode(canonical)
+	mac := hmac.New(sha1.New, []byte(secret+"&"))
+	mac.Write([]byte(s))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func acrPercentEncode(s string) string {
+	s = url.QueryEscape(s)
+	s = strings.ReplaceAll(s, "+", "%20")
+	s = strings.ReplaceAll(s, "*", "%2A")
+	return strings.ReplaceAll(s, "%7E", "~")
+}
@@ -0,0 +1,153 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/cnrancher/hangar/pkg/utils"
+	"github.com/containers/common/pkg/retry"
+	"github.com/containers/image/v5/types"
+	"github.com/sirupsen/logrus"
+)
+
+// client is the HTTP client of the provider API server.
+type client struct {
+	client     *http.Client
+	credential *types.DockerAuthConfig
+	// baseURL is the API server URL with scheme, example: https://example.io
+	baseURL string
+	// authorize sets the authorization header of the request.
+	authorize func(r *http.Request, credential *types.DockerAuthConfig)
+}
+
+func newClient(ctx context.Context, o *Options) (*client, error) {
+	c := &client{
+		credential: o.Credential,
+	}
+	if strings.Contains(o.URL, "://") {
+		u, err := url.Parse(o.URL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid URL %q: %w", o.URL, err)
+		}
+		if c.client, err = utils.NewHTTPClient(o.SystemContext, u.Host, time.Second*30); err != nil {
+			return nil, err
+		}
+		c.baseURL = strings.TrimSuffix(o.URL, "/")
+		return c, nil
+	}
+	server := strings.TrimSuffix(o.URL, "/")
+	if server == "" {
+		server = strings.TrimSuffix(o.Registry, "/")
+	}
+	var err error
+	if c.client, err = utils.NewHTTPClient(o.SystemContext, server, time.Second*30); err != nil {
+		return nil, err
+	}
+	// Try ping server using HTTPS protocol.
+	c.baseURL = fmt.Sprintf("https://%s", server)
+	resp, err := c.request(ctx, http.MethodGet, "/", nil)
+	if err != nil {
+		if utils.TLSVerify(o.SystemContext) || !errors.Is(err, http.ErrSchemeMismatch) {
+			return nil, fmt.Errorf("failed to ping %q: %w", server, err)
+		}
+		logrus.Debugf("ping %s: %v", c.baseURL, err)
+		// The tlsVerify not enabled, try re-ping server using HTTP.
+		c.baseURL = fmt.Sprintf("http://%s", server)
+		resp, err = c.request(ctx, http.MethodGet, "/", nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to ping %q: %w", server, err)
+		}
+	}
+	resp.Body.Close()
+	return c, nil
+}
+
+// copy returns the copy of the client, the HTTP client is shared.
+func (c *client) copy() *client {
+	cc := *c
+	return &cc
+}
+
+// do sends the request to the API server and decodes the JSON response to
+// out if the response status is OK, returns the response status code.
+func (c *client) do(
+	ctx context.Context, method, path string, in, out any,
+) (int, error) {
+	var body []byte
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return 0, fmt.Errorf("failed to encode request body: %w", err)
+		}
+		body = b
+	}
+	u := c.baseURL + path
+	resp, err := c.request(ctx, method, path, body)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, fmt.Errorf("failed to read %q response: %w", u, err)
+	}
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized,
+		resp.StatusCode == http.StatusForbidden:
+		return resp.StatusCode, fmt.Errorf("%w: %s %q response: %v",
+			ErrPermissionDenied, method, u, resp.Status)
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		logrus.Debugf("%s %q response body: %v", method, u, string(b))
+		return resp.StatusCode, nil
+	}
+	if out != nil && len(b) > 0 {
+		if err := json.Unmarshal(b, out); err != nil {
+			return resp.StatusCode, fmt.Errorf("failed to decode %q response: %w", u, err)
+		}
+	}
+	return resp.StatusCode, nil
+}
+
+func (c *client) request(
+	ctx context.Context, method, path string, body []byte,
+) (*http.Response, error) {
+	var resp *http.Response
+	err := retry.IfNecessary(ctx, func() error {
+		req, err := http.NewRequestWithContext(
+			ctx, method, c.baseURL+path, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Add("Accept", "application/json")
+		if body != nil {
+			req.Header.Add("Content-Type", "application/json")
+		}
+		if c.authorize != nil && c.credential != nil {
+			c.authorize(req, c.credential)
+		}
+		logrus.Debugf("client.Do: %v", req.URL.String())
+		resp, err = c.client.Do(req)
+		return err
+	}, &retry.Options{
+		MaxRetry: 3,
+		Delay:    time.Microsecond * 100,
+	})
+	return resp, err
+}
+
+// token returns the access token of the credential, the password is used as
+// the access token if the identity token not provided.
+func token(credential *types.DockerAuthConfig) string {
+	if credential.IdentityToken != "" {
+		return credential.IdentityToken
+	}
+	return credential.Password
+}
@@ -0,0 +1,181 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/containers/image/v5/types"
+)
+
+// gitlabProvider manages the GitLab groups and projects, the personal
+// access token is used as the password of the registry credential.
+//
+// The images of the GitLab container registry are stored in the projects,
+// so the image 'group/name' requires the project 'name' in the group.
+type gitlabProvider struct {
+	*client
+}
+
+type gitlabGroup struct {
+	ID       int    `json:"id,omitempty"`
+	Name     string `json:"name"`
+	Path     string `json:"path"`
+	ParentID int    `json:"parent_id,omitempty"`
+	// Visibility of the group: private, internal or public.
+	Visibility string `json:"visibility,omitempty"`
+}
+
+type gitlabProject struct {
+	ID          int    `json:"id,omitempty"`
+	Name        string `json:"name"`
+	Path        string `json:"path"`
+	NamespaceID int    `json:"namespace_id,omitempty"`
+	// Visibility of the project: private, internal or public.
+	Visibility string `json:"visibility,omitempty"`
+	// ContainerRegistryAccessLevel is the access level of the container
+	// registry: disabled, private or enabled.
+	ContainerRegistryAccessLevel string `json:"container_registry_access_level,omitempty"`
+}
+
+func newGitLab(c *client) *gitlabProvider {
+	c = c.copy()
+	c.authorize = func(r *http.Request, credential *types.DockerAuthConfig) {
+		if t := token(credential); t != "" {
+			r.Header.Set("PRIVATE-TOKEN", t)
+		}
+	}
+	return &gitlabProvider{client: c}
+}
+
+func (p *gitlabProvider) Type() Type {
+	return TypeGitLab
+}
+
+func (p *gitlabProvider) URL() string {
+	return p.baseURL
+}
+
+func (p *gitlabProvider) detect(ctx context.Context) bool {
+	data := map[string]any{}
+	code, err := p.do(ctx, http.MethodGet, "/api/v4/version", nil, &data)
+	if err != nil || code != http.StatusOK {
+		return false
+	}
+	_, ok := data["revision"]
+	return ok
+}
+
+// group gets the group by full path, returns nil if not found.
+func (p *gitlabProvider) group(ctx context.Context, name string) (*gitlabGroup, error) {
+	g := &gitlabGroup{}
+	code, err := p.do(ctx, http.MethodGet,
+		"/api/v4/groups/"+url.PathEscape(name), nil, g)
+	if err != nil {
+		return nil, err
+	}
+	switch code {
+	case http.StatusOK:
+		return g, nil
+	case http.StatusNotFound:
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unexpected status code %v", code)
+}
+
+func (p *gitlabProvider) NamespaceExists(ctx context.Context, name string) (bool, error) {
+	g, err := p.group(ctx, name)
+	if err != nil {
+		return false, fmt.Errorf("provider.gitlab.NamespaceExists: %w", err)
+	}
+	return g != nil, nil
+}
+
+// CreateNamespace creates the group, the parent group of the subgroup
+// (example: parent/name) should exist.
+func (p *gitlabProvider) CreateNamespace(ctx context.Context, name string) error {
+	g := &gitlabGroup{
+		Name:       path.Base(name),
+		Path:       path.Base(name),
+		Visibility: "private",
+	}
+	if parent := path.Dir(name); parent != "." {
+		pg, err := p.group(ctx, parent)
+		if err != nil {
+			return fmt.Errorf("provider.gitlab.CreateNamespace: %w", err)
+		}
+		if pg == nil {
+			return fmt.Errorf("provider.gitlab.CreateNamespace: parent group %q not found",
+				parent)
+		}
+		g.ParentID = pg.ID
+	}
+	code, err := p.do(ctx, http.MethodPost, "/api/v4/groups", g, nil)
+	if err != nil {
+		return fmt.Errorf("provider.gitlab.CreateNamespace: %w", err)
+	}
+	switch code {
+	case http.StatusOK, http.StatusCreated:
+		return nil
+	}
+	return fmt.Errorf("provider.gitlab.CreateNamespace: failed to create group %q: status code %v",
+		name, code)
+}
+
+// gitlabProjectPath returns the path of the project storing the image, the
+// image 'group/name/sub' is stored in the project 'group/name'.
+func gitlabProjectPath(namespace, name string) string {
+	name, _, _ = strings.Cut(name, "/")
+	return namespace + "/" + name
+}
+
+func (p *gitlabProvider) RepositoryExists(
+	ctx context.Context, namespace, name string,
+) (bool, error) {
+	code, err := p.do(ctx, http.MethodGet,
+		"/api/v4/projects/"+url.PathEscape(gitlabProjectPath(namespace, name)), nil, nil)
+	if err != nil {
+		return false, fmt.Errorf("provider.gitlab.RepositoryExists: %w", err)
+	}
+	switch code {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	}
+	return false, fmt.Errorf("provider.gitlab.RepositoryExists: unexpected status code %v", code)
+}
+
+// CreateRepository creates the project with the container registry enabled
+// in the group to store the image.
+func (p *gitlabProvider) CreateRepository(
+	ctx context.Context, namespace, name string,
+) error {
+	g, err := p.group(ctx, namespace)
+	if err != nil {
+		return fmt.Errorf("provider.gitlab.CreateRepository: %w", err)
+	}
+	if g == nil {
+		return fmt.Errorf("provider.gitlab.CreateRepository: group %q not found", namespace)
+	}
+	name = path.Base(gitlabProjectPath(namespace, name))
+	code, err := p.do(ctx, http.MethodPost, "/api/v4/projects", &gitlabProject{
+		Name:                         name,
+		Path:                         name,
+		NamespaceID:                  g.ID,
+		Visibility:                   "private",
+		ContainerRegistryAccessLevel: "enabled",
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("provider.gitlab.CreateRepository: %w", err)
+	}
+	switch code {
+	case http.StatusOK, http.StatusCreated:
+		return nil
+	}
+	return fmt.Errorf("provider.gitlab.CreateRepository: failed to create project %q: status code %v",
+		namespace+"/"+name, code)
+}
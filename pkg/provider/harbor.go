@@ -0,0 +1,50 @@
+package provider
+
+import (
+	"context"
+
+	"github.com/cnrancher/hangar/pkg/harbor"
+	"github.com/containers/image/v5/types"
+)
+
+// harborProvider manages the Harbor V2 projects.
+type harborProvider struct {
+	url        string
+	credential *types.DockerAuthConfig
+	sysCtx     *types.SystemContext
+	spec       *harbor.Project
+}
+
+func newHarbor(ctx context.Context, o *Options) (*harborProvider, error) {
+	u, err := harbor.GetRegistryURL(ctx, o.Registry, o.SystemContext)
+	if err != nil {
+		return nil, err
+	}
+	return &harborProvider{
+		url:        u,
+		credential: o.Credential,
+		sysCtx:     o.SystemContext,
+		spec:       o.HarborProject,
+	}, nil
+}
+
+func (p *harborProvider) Type() Type {
+	return TypeHarbor
+}
+
+func (p *harborProvider) URL() string {
+	return p.url
+}
+
+func (p *harborProvider) NamespaceExists(ctx context.Context, name string) (bool, error) {
+	return harbor.ProjectExists(ctx, name, p.url, p.credential, p.sysCtx)
+}
+
+func (p *harborProvider) CreateNamespace(ctx context.Context, name string) error {
+	spec := harbor.Project{}
+	if p.spec != nil {
+		spec = *p.spec
+	}
+	spec.Name = name
+	return harbor.CreateProjectWithSpec(ctx, &spec, p.url, p.credential, p.sysCtx)
+}
@@ -0,0 +1,89 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/containers/image/v5/types"
+)
+
+// nexusBlobStore is the blob store of the created Nexus docker repositories.
+const nexusBlobStore = "default"
+
+// nexusProvider manages the Nexus hosted docker repositories.
+type nexusProvider struct {
+	*client
+}
+
+func newNexus(c *client) *nexusProvider {
+	c = c.copy()
+	c.authorize = func(r *http.Request, credential *types.DockerAuthConfig) {
+		if credential.Username != "" {
+			r.SetBasicAuth(credential.Username, credential.Password)
+		}
+	}
+	return &nexusProvider{client: c}
+}
+
+func (p *nexusProvider) Type() Type {
+	return TypeNexus
+}
+
+func (p *nexusProvider) URL() string {
+	return p.baseURL
+}
+
+func (p *nexusProvider) detect(ctx context.Context) bool {
+	resp, err := p.request(ctx, http.MethodGet, "/service/rest/v1/status", nil)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode == http.StatusOK &&
+		strings.HasPrefix(resp.Header.Get("Server"), "Nexus")
+}
+
+func (p *nexusProvider) NamespaceExists(ctx context.Context, name string) (bool, error) {
+	code, err := p.do(ctx, http.MethodGet,
+		"/service/rest/v1/repositories/"+url.PathEscape(name), nil, nil)
+	if err != nil {
+		return false, fmt.Errorf("provider.nexus.NamespaceExists: %w", err)
+	}
+	switch code {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	}
+	return false, fmt.Errorf("provider.nexus.NamespaceExists: unexpected status code %v", code)
+}
+
+func (p *nexusProvider) CreateNamespace(ctx context.Context, name string) error {
+	body := map[string]any{
+		"name":   name,
+		"online": true,
+		"storage": map[string]any{
+			"blobStoreName":               nexusBlobStore,
+			"strictContentTypeValidation": true,
+			"writePolicy":                 "allow",
+		},
+		"docker": map[string]any{
+			"v1Enabled":      false,
+			"forceBasicAuth": true,
+		},
+	}
+	code, err := p.do(ctx, http.MethodPost,
+		"/service/rest/v1/repositories/docker/hosted", body, nil)
+	if err != nil {
+		return fmt.Errorf("provider.nexus.CreateNamespace: %w", err)
+	}
+	switch code {
+	case http.StatusOK, http.StatusCreated:
+		return nil
+	}
+	return fmt.Errorf("provider.nexus.CreateNamespace: failed to create repository %q: status code %v",
+		name, code)
+}
@@ -0,0 +1,149 @@
+// Package provider creates the namespaces (Harbor projects, Quay
+// organizations, Nexus docker repositories, GitLab groups and projects, ACR
+// namespaces) of the destination registry server before pushing images.
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/cnrancher/hangar/pkg/harbor"
+	"github.com/containers/image/v5/types"
+	"github.com/sirupsen/logrus"
+)
+
+// Type is the type of the registry provider.
+type Type string
+
+const (
+	TypeAuto   Type = "auto"
+	TypeNone   Type = "none"
+	TypeHarbor Type = "harbor"
+	TypeQuay   Type = "quay"
+	TypeNexus  Type = "nexus"
+	TypeGitLab Type = "gitlab"
+	TypeACR    Type = "acr"
+)
+
+var (
+	// ErrUnknownProvider is returned if the provider of the registry
+	// server can not be detected automatically.
+	ErrUnknownProvider = errors.New("unable to detect the registry provider")
+	// ErrPermissionDenied is returned if the credential does not have the
+	// permission to manage the namespaces.
+	ErrPermissionDenied = harbor.ErrPermissionDenied
+)
+
+// Types returns the supported provider types.
+func Types() []Type {
+	return []Type{TypeAuto, TypeNone, TypeHarbor, TypeQuay, TypeNexus, TypeGitLab, TypeACR}
+}
+
+// Provider manages the namespaces of the registry server.
+type Provider interface {
+	// Type returns the type of the provider.
+	Type() Type
+	// URL returns the API server URL of the provider.
+	URL() string
+	// NamespaceExists checks the namespace exists or not.
+	NamespaceExists(ctx context.Context, name string) (bool, error)
+	// CreateNamespace creates the namespace.
+	CreateNamespace(ctx context.Context, name string) error
+}
+
+// RepositoryCreator is implemented by the providers requiring the
+// repositories created in the namespace before pushing images, for
+// example the GitLab projects.
+type RepositoryCreator interface {
+	// RepositoryExists checks the repository of the image name exists in
+	// the namespace or not.
+	RepositoryExists(ctx context.Context, namespace, name string) (bool, error)
+	// CreateRepository creates the repository of the image name in the
+	// namespace.
+	CreateRepository(ctx context.Context, namespace, name string) error
+}
+
+// Options is the option to create the registry provider.
+type Options struct {
+	// Type of the provider, detect automatically if empty or 'auto'.
+	Type Type
+	// Registry is the registry server, example: registry.example.io
+	Registry string
+	// URL is the API server URL of the provider, the registry server is used
+	// if not provided, example: https://gitlab.example.io
+	URL string
+	// Credential is the credential of the API server created by
+	// harbor.APICredential, the IdentityToken is used as the access token
+	// if provided.
+	Credential *types.DockerAuthConfig
+	// SystemContext is the system context of the registry, the certificates
+	// and the TLS verification are applied to the API server (optional).
+	SystemContext *types.SystemContext
+	// HarborProject is the spec of the Harbor V2 projects created (optional).
+	HarborProject *harbor.Project
+}
+
+// New creates the registry provider, returns ErrUnknownProvider if the
+// provider type is auto and not detected, returns nil if type is none.
+func New(ctx context.Context, o *Options) (Provider, error) {
+	t := Type(strings.ToLower(string(o.Type)))
+	switch t {
+	case TypeNone:
+		return nil, nil
+	case "", TypeAuto:
+		return detect(ctx, o)
+	case TypeHarbor:
+		return newHarbor(ctx, o)
+	case TypeACR:
+		return newACR(ctx, o)
+	}
+
+	c, err := newClient(ctx, o)
+	if err != nil {
+		return nil, err
+	}
+	switch t {
+	case TypeQuay:
+		return newQuay(c), nil
+	case TypeNexus:
+		return newNexus(c), nil
+	case TypeGitLab:
+		return newGitLab(c), nil
+	}
+	return nil, fmt.Errorf("unsupported registry provider %q", o.Type)
+}
+
+func detect(ctx context.Context, o *Options) (Provider, error) {
+	if isACRRegistry(o.Registry) {
+		return newACR(ctx, o)
+	}
+	p, err := newHarbor(ctx, o)
+	if err == nil {
+		return p, nil
+	}
+	if !errors.Is(err, harbor.ErrRegistryIsNotHarbor) {
+		logrus.Debugf("failed to detect harbor registry %q: %v", o.Registry, err)
+	}
+
+	c, err := newClient(ctx, o)
+	if err != nil {
+		return nil, err
+	}
+	providers := []interface {
+		Provider
+		detect(context.Context) bool
+	}{
+		newQuay(c),
+		newNexus(c),
+		newGitLab(c),
+	}
+	for _, p := range providers {
+		if p.detect(ctx) {
+			logrus.Debugf("Registry provider of %q: %v", o.Registry, p.Type())
+			return p, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnknownProvider, o.Registry)
+}
@@ -0,0 +1,317 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/containers/image/v5/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestProvider(
+	t *testing.T, handler http.HandlerFunc, typ Type, credential *types.DockerAuthConfig,
+) (Provider, func()) {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	p, err := New(context.TODO(), &Options{
+		Type:       typ,
+		Registry:   strings.TrimPrefix(server.URL, "http://"),
+		Credential: credential,
+		SystemContext: &types.SystemContext{
+			DockerInsecureSkipTLSVerify: types.OptionalBoolTrue,
+		},
+	})
+	if !assert.NoError(t, err) {
+		server.Close()
+		t.FailNow()
+	}
+	return p, server.Close
+}
+
+func Test_Quay(t *testing.T) {
+	orgs := map[string]bool{"library": true}
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer oauth-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.URL.Path == "/api/v1/discovery":
+			w.Write([]byte(`{"swagger":"2.0","paths":{}}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/api/v1/organization/":
+			data := map[string]string{}
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&data))
+			orgs[data["name"]] = true
+			w.WriteHeader(http.StatusCreated)
+		case strings.HasPrefix(r.URL.Path, "/api/v1/organization/"):
+			if !orgs[strings.TrimPrefix(r.URL.Path, "/api/v1/organization/")] {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write([]byte(`{}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}
+	p, cleanup := newTestProvider(t, handler, TypeAuto,
+		&types.DockerAuthConfig{IdentityToken: "oauth-token"})
+	defer cleanup()
+	assert.Equal(t, TypeQuay, p.Type())
+
+	exists, err := p.NamespaceExists(context.TODO(), "library")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	exists, err = p.NamespaceExists(context.TODO(), "rancher")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+	assert.NoError(t, p.CreateNamespace(context.TODO(), "rancher"))
+	assert.True(t, orgs["rancher"])
+}
+
+func Test_Nexus(t *testing.T) {
+	repos := map[string]bool{"library": true}
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/service/rest/v1/status" {
+			w.Header().Set("Server", "Nexus/3.61.0-02 (OSS)")
+			return
+		}
+		if user, _, _ := r.BasicAuth(); user != "admin" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch {
+		case r.Method == http.MethodPost &&
+			r.URL.Path == "/service/rest/v1/repositories/docker/hosted":
+			data := map[string]any{}
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&data))
+			assert.Equal(t, true, data["online"])
+			repos[data["name"].(string)] = true
+			w.WriteHeader(http.StatusCreated)
+		case strings.HasPrefix(r.URL.Path, "/service/rest/v1/repositories/"):
+			if !repos[strings.TrimPrefix(r.URL.Path, "/service/rest/v1/repositories/")] {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write([]byte(`{"name":"library","format":"docker"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}
+	p, cleanup := newTestProvider(t, handler, TypeAuto,
+		&types.DockerAuthConfig{Username: "admin", Password: "password"})
+	defer cleanup()
+	assert.Equal(t, TypeNexus, p.Type())
+
+	exists, err := p.NamespaceExists(context.TODO(), "library")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	assert.NoError(t, p.CreateNamespace(context.TODO(), "rancher"))
+	assert.True(t, repos["rancher"])
+
+	p, cleanup = newTestProvider(t, handler, TypeNexus,
+		&types.DockerAuthConfig{Username: "user", Password: "password"})
+	defer cleanup()
+	err = p.CreateNamespace(context.TODO(), "rancher")
+	assert.True(t, errors.Is(err, ErrPermissionDenied))
+}
+
+func Test_GitLab(t *testing.T) {
+	groups := map[string]int{"library": 1}
+	projects := map[string]bool{"library/mysql": true}
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("PRIVATE-TOKEN") != "glpat-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.URL.Path == "/api/v4/version":
+			w.Write([]byte(`{"version":"16.5.0","revision":"abcdef"}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/api/v4/groups":
+			g := gitlabGroup{}
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&g))
+			name := g.Path
+			if g.ParentID != 0 {
+				assert.Equal(t, 1, g.ParentID)
+				name = "library/" + name
+			}
+			groups[name] = len(groups) + 1
+			w.WriteHeader(http.StatusCreated)
+		case r.Method == http.MethodPost && r.URL.Path == "/api/v4/projects":
+			p := gitlabProject{}
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&p))
+			assert.Equal(t, 1, p.NamespaceID)
+			assert.Equal(t, "enabled", p.ContainerRegistryAccessLevel)
+			projects["library/"+p.Path] = true
+			w.WriteHeader(http.StatusCreated)
+		case strings.HasPrefix(r.URL.EscapedPath(), "/api/v4/projects/"):
+			if !projects[strings.TrimPrefix(r.URL.Path, "/api/v4/projects/")] {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write([]byte(`{}`))
+		case strings.HasPrefix(r.URL.EscapedPath(), "/api/v4/groups/"):
+			name := strings.TrimPrefix(r.URL.Path, "/api/v4/groups/")
+			id, ok := groups[name]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			json.NewEncoder(w).Encode(gitlabGroup{ID: id, Name: name, Path: name})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}
+	p, cleanup := newTestProvider(t, handler, TypeAuto,
+		&types.DockerAuthConfig{Username: "user", Password: "glpat-token"})
+	defer cleanup()
+	assert.Equal(t, TypeGitLab, p.Type())
+
+	exists, err := p.NamespaceExists(context.TODO(), "library")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	exists, err = p.NamespaceExists(context.TODO(), "library/rancher")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+	assert.NoError(t, p.CreateNamespace(context.TODO(), "library/rancher"))
+	assert.Equal(t, 2, groups["library/rancher"])
+	assert.Error(t, p.CreateNamespace(context.TODO(), "not-exists/rancher"))
+
+	// The image 'library/nginx' requires the project 'nginx' in the group.
+	rc, ok := p.(RepositoryCreator)
+	if !assert.True(t, ok) {
+		return
+	}
+	exists, err = rc.RepositoryExists(context.TODO(), "library", "mysql")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	exists, err = rc.RepositoryExists(context.TODO(), "library", "nginx")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+	assert.NoError(t, rc.CreateRepository(context.TODO(), "library", "nginx/sub"))
+	assert.True(t, projects["library/nginx"])
+	assert.Error(t, rc.CreateRepository(context.TODO(), "not-exists", "nginx"))
+}
+
+func Test_ACRSignature(t *testing.T) {
+	// The example of the Alibaba Cloud RPC signature document.
+	canonical := acrCanonicalQuery(map[string]string{
+		"Action":           "DescribeRegions",
+		"AccessKeyId":      "testid",
+		"Format":           "XML",
+		"SignatureMethod":  "HMAC-SHA1",
+		"SignatureNonce":   "3ee8c1b8-83d3-44af-a94f-4e0ad82fd6cf",
+		"SignatureVersion": "1.0",
+		"Timestamp":        "2016-02-23T12:46:24Z",
+		"Version":          "2014-05-26",
+	})
+	assert.Equal(t, "OLeaidS1JvxuMvnyHOwuJ+uX5qY=",
+		acrSignature(http.MethodGet, canonical, "testsecret"))
+}
+
+func Test_ACR(t *testing.T) {
+	namespaces := map[string]bool{"library": true}
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		params := map[string]string{}
+		for k := range q {
+			if k != "Signature" {
+				params[k] = q.Get(k)
+			}
+		}
+		if q.Get("AccessKeyId") != "ak" || q.Get("Signature") !=
+			acrSignature(http.MethodGet, acrCanonicalQuery(params), "secret") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		assert.Equal(t, "cn-hangzhou", q.Get("RegionId"))
+		switch q.Get("Action") {
+		case "ListInstance":
+			if q.Get("InstanceName") != "test" {
+				w.Write([]byte(`{"IsSuccess":true,"Instances":[]}`))
+				return
+			}
+			w.Write([]byte(`{"IsSuccess":true,"Instances":[` +
+				`{"InstanceId":"cri-test","InstanceName":"test"}]}`))
+		case "GetNamespace":
+			assert.Equal(t, "cri-test", q.Get("InstanceId"))
+			name := q.Get("NamespaceName")
+			if !namespaces[name] {
+				w.Write([]byte(`{"IsSuccess":false,"Code":"NAMESPACE_NOT_EXIST"}`))
+				return
+			}
+			w.Write([]byte(`{"IsSuccess":true,"NamespaceName":"` + name + `"}`))
+		case "CreateNamespace":
+			assert.Equal(t, "true", q.Get("AutoCreateRepo"))
+			namespaces[q.Get("NamespaceName")] = true
+			w.Write([]byte(`{"IsSuccess":true}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	o := &Options{
+		Registry: "test-registry-vpc.cn-hangzhou.cr.aliyuncs.com",
+		URL:      server.URL,
+	}
+	_, err := New(context.TODO(), o)
+	assert.Error(t, err)
+
+	t.Setenv(ACRAccessKeyIDEnv, "ak")
+	t.Setenv(ACRAccessKeySecretEnv, "secret")
+	p, err := New(context.TODO(), o)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, TypeACR, p.Type())
+	exists, err := p.NamespaceExists(context.TODO(), "library")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	exists, err = p.NamespaceExists(context.TODO(), "rancher")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+	assert.NoError(t, p.CreateNamespace(context.TODO(), "rancher"))
+	assert.True(t, namespaces["rancher"])
+
+	o.Registry = "other-registry.cn-hangzhou.cr.aliyuncs.com"
+	_, err = New(context.TODO(), o)
+	assert.Error(t, err)
+	t.Setenv(ACRAccessKeySecretEnv, "invalid")
+	o.Registry = "test-registry.cn-hangzhou.cr.aliyuncs.com"
+	_, err = New(context.TODO(), o)
+	assert.ErrorIs(t, err, ErrPermissionDenied)
+	_, _, err = parseACRRegistry("test.cn-hangzhou.cr.aliyuncs.com")
+	assert.Error(t, err)
+}
+
+func Test_New(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	o := &Options{
+		Registry:   strings.TrimPrefix(server.URL, "http://"),
+		Credential: &types.DockerAuthConfig{},
+		SystemContext: &types.SystemContext{
+			DockerInsecureSkipTLSVerify: types.OptionalBoolTrue,
+		},
+	}
+	_, err := New(context.TODO(), o)
+	assert.True(t, errors.Is(err, ErrUnknownProvider))
+
+	o.Type = TypeNone
+	p, err := New(context.TODO(), o)
+	assert.NoError(t, err)
+	assert.Nil(t, p)
+
+	o.Type = "unknown"
+	_, err = New(context.TODO(), o)
+	assert.Error(t, err)
+}
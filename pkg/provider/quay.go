@@ -0,0 +1,73 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/containers/image/v5/types"
+)
+
+// quayProvider manages the Quay organizations, the OAuth access token
+// is required for creating organizations.
+type quayProvider struct {
+	*client
+}
+
+func newQuay(c *client) *quayProvider {
+	c = c.copy()
+	c.authorize = func(r *http.Request, credential *types.DockerAuthConfig) {
+		if t := token(credential); t != "" {
+			r.Header.Set("Authorization", "Bearer "+t)
+		}
+	}
+	return &quayProvider{client: c}
+}
+
+func (p *quayProvider) Type() Type {
+	return TypeQuay
+}
+
+func (p *quayProvider) URL() string {
+	return p.baseURL
+}
+
+func (p *quayProvider) detect(ctx context.Context) bool {
+	data := map[string]any{}
+	code, err := p.do(ctx, http.MethodGet, "/api/v1/discovery", nil, &data)
+	if err != nil || code != http.StatusOK {
+		return false
+	}
+	_, ok := data["swagger"]
+	return ok
+}
+
+func (p *quayProvider) NamespaceExists(ctx context.Context, name string) (bool, error) {
+	code, err := p.do(ctx, http.MethodGet,
+		"/api/v1/organization/"+url.PathEscape(name), nil, nil)
+	if err != nil {
+		return false, fmt.Errorf("provider.quay.NamespaceExists: %w", err)
+	}
+	switch code {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	}
+	return false, fmt.Errorf("provider.quay.NamespaceExists: unexpected status code %v", code)
+}
+
+func (p *quayProvider) CreateNamespace(ctx context.Context, name string) error {
+	code, err := p.do(ctx, http.MethodPost, "/api/v1/organization/",
+		map[string]string{"name": name}, nil)
+	if err != nil {
+		return fmt.Errorf("provider.quay.CreateNamespace: %w", err)
+	}
+	switch code {
+	case http.StatusOK, http.StatusCreated:
+		return nil
+	}
+	return fmt.Errorf("provider.quay.CreateNamespace: failed to create organization %q: status code %v",
+		name, code)
+}
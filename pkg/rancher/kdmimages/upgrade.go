@@ -136,6 +136,13 @@ func (g *UpgradeImages) GetImages() ([]string, error) {
 	return externalImages, nil
 }
 
+// GetReleaseImages returns the Linux image list of the RKE2/K3s release,
+// example release: v1.27.8+rke2r1
+func GetReleaseImages(source, release string) ([]string, error) {
+	g := &UpgradeImages{Source: source}
+	return g.getExternalList(release)
+}
+
 func (g *UpgradeImages) getExternalList(release string) ([]string, error) {
 	switch g.Source {
 	case RKE2:
@@ -43,3 +43,10 @@ func Test_Upgrade_GetImages(t *testing.T) {
 	}
 	utils.SaveSlice("test/k3s-upgrade-images.txt", images)
 }
+
+func Test_GetReleaseImages(t *testing.T) {
+	_, err := kdmimages.GetReleaseImages("rke", "v1.27.8")
+	if err == nil {
+		t.Error("GetReleaseImages should fail on invalid source")
+	}
+}
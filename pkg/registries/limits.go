@@ -0,0 +1,369 @@
+package registries
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Limit is the bandwidth and concurrency limits applied in the time windows.
+type Limit struct {
+	// Bandwidth is the maximum throughput per second, example: '200Mbps',
+	// '200Mbit', '25MB' or '25MiB', the number without unit is in bytes.
+	Bandwidth string `json:"bandwidth,omitempty"`
+	// MaxConcurrency is the maximum number of images copied at the same time.
+	MaxConcurrency int `json:"maxConcurrency,omitempty"`
+	// Windows is the time windows in local time when the limit applies,
+	// example: 'Mon-Fri 08:00-18:00' or '22:00-06:00', the limit always
+	// applies if not set.
+	Windows []string `json:"windows,omitempty"`
+}
+
+// rule is the parsed limit.
+type rule struct {
+	// bandwidth is the bytes per second, 0 means no limit.
+	bandwidth      float64
+	maxConcurrency int
+	windows        []*window
+}
+
+func newRule(l *Limit) (*rule, error) {
+	r := &rule{
+		maxConcurrency: l.MaxConcurrency,
+	}
+	if l.MaxConcurrency < 0 {
+		return nil, fmt.Errorf("invalid maxConcurrency %d", l.MaxConcurrency)
+	}
+	if l.Bandwidth != "" {
+		b, err := ParseBandwidth(l.Bandwidth)
+		if err != nil {
+			return nil, err
+		}
+		r.bandwidth = b
+	}
+	for _, s := range l.Windows {
+		w, err := parseWindow(s)
+		if err != nil {
+			return nil, err
+		}
+		r.windows = append(r.windows, w)
+	}
+	return r, nil
+}
+
+// newRules parses the limits, the limits without any limit are ignored.
+func newRules(limits []*Limit) ([]*rule, error) {
+	var rules []*rule
+	for _, l := range limits {
+		if l == nil {
+			continue
+		}
+		r, err := newRule(l)
+		if err != nil {
+			return nil, err
+		}
+		if r.bandwidth == 0 && r.maxConcurrency == 0 {
+			continue
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// active returns true if the rule applies at the time.
+func (r *rule) active(t time.Time) bool {
+	if len(r.windows) == 0 {
+		return true
+	}
+	for _, w := range r.windows {
+		if w.contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseBandwidth parses the bandwidth string into bytes per second, the unit
+// is case-sensitive for bytes ('B', 'MB', 'MiB') and bits ('bit', 'Mbit',
+// 'Mbps'), the ambiguous unit like 'Mb' is rejected.
+func ParseBandwidth(s string) (float64, error) {
+	v := strings.TrimSuffix(strings.TrimSpace(s), "/s")
+	i := strings.IndexFunc(v, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	number, unit := v, ""
+	if i >= 0 {
+		number, unit = v[:i], strings.TrimSpace(v[i:])
+	}
+	n, err := strconv.ParseFloat(number, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid bandwidth %q", s)
+	}
+	if unit == "" {
+		return n, nil
+	}
+	prefixes := map[string]float64{"": 1, "k": 1e3, "m": 1e6, "g": 1e9}
+	binaryPrefixes := map[string]float64{"k": 1 << 10, "m": 1 << 20, "g": 1 << 30}
+	for _, u := range []struct {
+		suffix   string
+		prefixes map[string]float64
+		scale    float64
+	}{
+		{"iB", binaryPrefixes, 1},
+		{"B", prefixes, 1},
+		{"bit", prefixes, 1.0 / 8},
+		{"bps", prefixes, 1.0 / 8},
+	} {
+		prefix, ok := strings.CutSuffix(unit, u.suffix)
+		if !ok {
+			continue
+		}
+		m, ok := u.prefixes[strings.ToLower(prefix)]
+		if !ok {
+			break
+		}
+		return n * m * u.scale, nil
+	}
+	if prefix, ok := strings.CutSuffix(unit, "b"); ok {
+		if _, ok := prefixes[strings.ToLower(prefix)]; ok {
+			return 0, fmt.Errorf("invalid bandwidth %q: ambiguous unit %q, "+
+				"use %q for bytes or %q for bits", s, unit, prefix+"B", prefix+"bit")
+		}
+	}
+	return 0, fmt.Errorf("invalid bandwidth %q: unknown unit %q", s, unit)
+}
+
+// window is the time window of the week days.
+type window struct {
+	// days is the week days of the window start time.
+	days [7]bool
+	// start and end are the minutes of the day, the window passes midnight
+	// if end is less than start.
+	start int
+	end   int
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// parseWindow parses the window in format '[DAYS ]HH:MM-HH:MM', the DAYS is
+// the comma separated week days or ranges, example: 'Mon-Fri' or 'Sat,Sun'.
+func parseWindow(s string) (*window, error) {
+	w := &window{}
+	fields := strings.Fields(s)
+	var times string
+	switch len(fields) {
+	case 1:
+		times = fields[0]
+		for i := range w.days {
+			w.days[i] = true
+		}
+	case 2:
+		times = fields[1]
+		for _, d := range strings.Split(fields[0], ",") {
+			from, to, isRange := strings.Cut(d, "-")
+			start, ok1 := weekdays[strings.ToLower(from)]
+			end, ok2 := weekdays[strings.ToLower(to)]
+			if !isRange {
+				end, ok2 = start, ok1
+			}
+			if !ok1 || !ok2 {
+				return nil, fmt.Errorf("invalid window %q: invalid days %q", s, d)
+			}
+			for i := start; ; i = (i + 1) % 7 {
+				w.days[i] = true
+				if i == end {
+					break
+				}
+			}
+		}
+	default:
+		return nil, fmt.Errorf("invalid window %q: should be '[DAYS ]HH:MM-HH:MM'", s)
+	}
+	from, to, ok := strings.Cut(times, "-")
+	if !ok {
+		return nil, fmt.Errorf("invalid window %q: should be '[DAYS ]HH:MM-HH:MM'", s)
+	}
+	var err error
+	if w.start, err = parseMinutes(from); err != nil {
+		return nil, fmt.Errorf("invalid window %q: %w", s, err)
+	}
+	if w.end, err = parseMinutes(to); err != nil {
+		return nil, fmt.Errorf("invalid window %q: %w", s, err)
+	}
+	if w.start == w.end {
+		return nil, fmt.Errorf("invalid window %q: empty time range", s)
+	}
+	return w, nil
+}
+
+// parseMinutes parses 'HH:MM' into the minutes of the day.
+func parseMinutes(s string) (int, error) {
+	h, m, ok := strings.Cut(s, ":")
+	hour, err1 := strconv.Atoi(h)
+	minute, err2 := strconv.Atoi(m)
+	if !ok || err1 != nil || err2 != nil || hour < 0 || minute < 0 || minute > 59 ||
+		hour > 24 || (hour == 24 && minute != 0) {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	return hour*60 + minute, nil
+}
+
+func (w *window) contains(t time.Time) bool {
+	m := t.Hour()*60 + t.Minute()
+	day := t.Weekday()
+	if w.start < w.end {
+		return w.days[day] && m >= w.start && m < w.end
+	}
+	// The window passes midnight.
+	yesterday := (day + 6) % 7
+	return (w.days[day] && m >= w.start) || (w.days[yesterday] && m < w.end)
+}
+
+// limiter limits the bandwidth and the concurrency by the rules.
+type limiter struct {
+	rules []*rule
+	now   func() time.Time
+
+	mu sync.Mutex
+	// inUse is the number of images being copied.
+	inUse int
+	// released is closed when the image released to notify the waiters.
+	released chan struct{}
+	// bucket is the token bucket of the bandwidth.
+	bucket *rate.Limiter
+}
+
+func newLimiter(rules []*rule) *limiter {
+	return &limiter{
+		rules:    rules,
+		now:      time.Now,
+		released: make(chan struct{}),
+		bucket:   rate.NewLimiter(rate.Inf, 0),
+	}
+}
+
+// current returns the bandwidth and the concurrency limits at the time,
+// the most restrictive limits of the active rules are used.
+func (l *limiter) current(t time.Time) (bandwidth float64, maxConcurrency int) {
+	for _, r := range l.rules {
+		if !r.active(t) {
+			continue
+		}
+		if r.bandwidth > 0 && (bandwidth == 0 || r.bandwidth < bandwidth) {
+			bandwidth = r.bandwidth
+		}
+		if r.maxConcurrency > 0 && (maxConcurrency == 0 || r.maxConcurrency < maxConcurrency) {
+			maxConcurrency = r.maxConcurrency
+		}
+	}
+	return bandwidth, maxConcurrency
+}
+
+func (l *limiter) hasBandwidth() bool {
+	for _, r := range l.rules {
+		if r.bandwidth > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *limiter) hasConcurrency() bool {
+	for _, r := range l.rules {
+		if r.maxConcurrency > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// limiterRecheckInterval is the interval to recheck the concurrency limit
+// in case of the time window changed.
+var limiterRecheckInterval = time.Minute
+
+// acquire waits until the image can be copied within the concurrency limit.
+func (l *limiter) acquire(ctx context.Context) error {
+	for {
+		l.mu.Lock()
+		_, maxConcurrency := l.current(l.now())
+		if maxConcurrency == 0 || l.inUse < maxConcurrency {
+			l.inUse++
+			l.mu.Unlock()
+			return nil
+		}
+		released := l.released
+		l.mu.Unlock()
+
+		timer := time.NewTimer(limiterRecheckInterval)
+		select {
+		case <-released:
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+		timer.Stop()
+	}
+}
+
+func (l *limiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.inUse--
+	close(l.released)
+	l.released = make(chan struct{})
+}
+
+// minBurst is the minimum burst bytes of the token bucket.
+const minBurst = 32 * 1024
+
+// update updates the token bucket by the current bandwidth limit, returns
+// the burst bytes or 0 if no bandwidth limit.
+func (l *limiter) update() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	bandwidth, _ := l.current(now)
+	if bandwidth == 0 {
+		if l.bucket.Limit() != rate.Inf {
+			l.bucket.SetLimitAt(now, rate.Inf)
+		}
+		return 0
+	}
+	burst := int(math.Max(bandwidth, minBurst))
+	if l.bucket.Limit() != rate.Limit(bandwidth) || l.bucket.Burst() != burst {
+		l.bucket.SetLimitAt(now, rate.Limit(bandwidth))
+		l.bucket.SetBurstAt(now, burst)
+	}
+	return burst
+}
+
+// wait waits until the n bytes transferred are within the bandwidth limit.
+func (l *limiter) wait(ctx context.Context, n int) error {
+	for n > 0 {
+		burst := l.update()
+		if burst == 0 {
+			return nil
+		}
+		m := min(n, burst)
+		if err := l.bucket.WaitN(ctx, m); err != nil {
+			return err
+		}
+		n -= m
+	}
+	return nil
+}
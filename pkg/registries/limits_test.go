@@ -0,0 +1,209 @@
+package registries
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ParseBandwidth(t *testing.T) {
+	valid := map[string]float64{
+		"1024":     1024,
+		"25MB":     25e6,
+		"25 MiB":   25 << 20,
+		"1GB/s":    1e9,
+		"200Mbps":  25e6,
+		"200mbit":  25e6,
+		"8Kbit":    1e3,
+		"0.5KiB/s": 512,
+		"100 kB":   1e5,
+		"16B":      16,
+		"1Gbit/s":  1e9 / 8,
+	}
+	for s, expected := range valid {
+		b, err := ParseBandwidth(s)
+		assert.NoError(t, err, s)
+		assert.Equal(t, expected, b, s)
+	}
+	for _, s := range []string{
+		"", "0", "-1MB", "MB", "25XB", "1.2.3MB",
+		// The ambiguous units of bits or bytes.
+		"200Mb", "8kb", "1Gb", "16b", "1MBit", "1MBps",
+	} {
+		_, err := ParseBandwidth(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func Test_Window(t *testing.T) {
+	// 2024-01-01 is Monday.
+	at := func(day int, hour int, minute int) time.Time {
+		return time.Date(2024, 1, day, hour, minute, 0, 0, time.Local)
+	}
+
+	w, err := parseWindow("Mon-Fri 08:00-18:00")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.True(t, w.contains(at(1, 8, 0)))
+	assert.True(t, w.contains(at(5, 17, 59)))
+	assert.False(t, w.contains(at(1, 18, 0)))
+	assert.False(t, w.contains(at(6, 12, 0)))
+
+	// The window passes midnight.
+	w, err = parseWindow("Fri,Sat 22:00-06:00")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.True(t, w.contains(at(5, 23, 0)))
+	assert.True(t, w.contains(at(6, 5, 59)))
+	assert.True(t, w.contains(at(7, 1, 0)))
+	assert.False(t, w.contains(at(5, 1, 0)))
+	assert.False(t, w.contains(at(7, 22, 0)))
+
+	// The day range wraps the week.
+	w, err = parseWindow("Sat-Sun 00:00-24:00")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.True(t, w.contains(at(7, 23, 59)))
+	assert.False(t, w.contains(at(1, 0, 0)))
+
+	w, err = parseWindow("12:00-13:00")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.True(t, w.contains(at(3, 12, 30)))
+
+	invalid := []string{
+		"", "08:00", "Mon-Fri", "Mon-Xyz 08:00-18:00", "Mon 08:00-08:00",
+		"Mon 25:00-26:00", "Mon 08:60-09:00", "Mon 24:01-08:00", "a b c",
+	}
+	for _, s := range invalid {
+		_, err := parseWindow(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func Test_LimiterCurrent(t *testing.T) {
+	rules, err := newRules([]*Limit{
+		{Bandwidth: "100MB", MaxConcurrency: 4},
+		{Bandwidth: "10MB", Windows: []string{"Mon-Fri 08:00-18:00"}},
+		{MaxConcurrency: 1, Windows: []string{"Sat-Sun 00:00-24:00"}},
+		{},
+		nil,
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Len(t, rules, 3)
+	l := newLimiter(rules)
+
+	bandwidth, maxConcurrency := l.current(time.Date(2024, 1, 1, 9, 0, 0, 0, time.Local))
+	assert.Equal(t, 10e6, bandwidth)
+	assert.Equal(t, 4, maxConcurrency)
+	bandwidth, maxConcurrency = l.current(time.Date(2024, 1, 6, 9, 0, 0, 0, time.Local))
+	assert.Equal(t, 100e6, bandwidth)
+	assert.Equal(t, 1, maxConcurrency)
+
+	_, err = newRules([]*Limit{{MaxConcurrency: -1}})
+	assert.Error(t, err)
+	_, err = newRules([]*Limit{{Bandwidth: "1MB", Windows: []string{"Mon"}}})
+	assert.Error(t, err)
+}
+
+func Test_LimiterAcquireWindow(t *testing.T) {
+	rules, err := newRules([]*Limit{
+		{MaxConcurrency: 1, Windows: []string{"Mon-Fri 08:00-18:00"}},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	l := newLimiter(rules)
+	var mu sync.Mutex
+	now := time.Date(2024, 1, 1, 17, 59, 0, 0, time.Local)
+	l.now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+	interval := limiterRecheckInterval
+	limiterRecheckInterval = time.Millisecond * 10
+	defer func() { limiterRecheckInterval = interval }()
+
+	assert.NoError(t, l.acquire(context.Background()))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	assert.ErrorIs(t, l.acquire(ctx), context.DeadlineExceeded)
+
+	// The concurrency limit does not apply out of the window.
+	done := make(chan error)
+	go func() { done <- l.acquire(context.Background()) }()
+	mu.Lock()
+	now = now.Add(time.Minute)
+	mu.Unlock()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("acquire not finished out of the window")
+	}
+	l.release()
+	l.release()
+	assert.Equal(t, 0, l.inUse)
+}
+
+func Test_LimitedReader(t *testing.T) {
+	s, err := New(&Config{Limits: []*Limit{{Bandwidth: "1MB"}}})
+	if !assert.NoError(t, err) {
+		return
+	}
+	l := s.limiters[""]
+	assert.True(t, l.hasBandwidth())
+	assert.False(t, l.hasConcurrency())
+
+	// The token bucket starts empty, reading 0.2MB waits about 0.2 seconds.
+	data := make([]byte, 200_000)
+	r := &limitedReader{
+		ReadCloser: io.NopCloser(bytes.NewReader(data)),
+		ctx:        context.Background(),
+		limiters:   []*limiter{l},
+	}
+	start := time.Now()
+	b, err := io.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Len(t, b, len(data))
+	assert.GreaterOrEqual(t, time.Since(start), time.Millisecond*150)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r = &limitedReader{
+		ReadCloser: io.NopCloser(bytes.NewReader(data)),
+		ctx:        ctx,
+		limiters:   []*limiter{l},
+	}
+	_, err = io.ReadAll(r)
+	assert.Error(t, err)
+}
+
+func Test_LimitReference(t *testing.T) {
+	var s *Settings
+	assert.Nil(t, s.LimitReference(nil, nil))
+	assert.Nil(t, FromContext(WithSettings(context.Background(), s)))
+
+	s, err := New(&Config{Registries: map[string]*Registry{
+		"a.example.io": {MaxConcurrency: 1},
+		"b.example.io": {Limits: []*Limit{{Bandwidth: "10MB"}}},
+	}})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, s, FromContext(WithSettings(context.Background(), s)))
+	// No bandwidth limit of all registries.
+	assert.Nil(t, s.LimitReference(nil, nil))
+}
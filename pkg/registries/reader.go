@@ -0,0 +1,121 @@
+package registries
+
+import (
+	"context"
+	"io"
+
+	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/types"
+)
+
+type contextKey struct{}
+
+// WithSettings returns the context carrying the settings to limit the
+// bandwidth of the images copied within the context.
+func WithSettings(ctx context.Context, s *Settings) context.Context {
+	if s == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, contextKey{}, s)
+}
+
+// FromContext returns the settings of the context, returns nil if not set.
+func FromContext(ctx context.Context) *Settings {
+	s, _ := ctx.Value(contextKey{}).(*Settings)
+	return s
+}
+
+// LimitReference returns the source image reference whose blobs are read
+// within the bandwidth limits of all registries and the registries of the
+// source and destination images. The source reference is returned directly
+// if no bandwidth limit.
+func (s *Settings) LimitReference(
+	src, dest types.ImageReference,
+) types.ImageReference {
+	if s == nil {
+		return src
+	}
+	var limiters []*limiter
+	for _, l := range s.limitersOf([]string{registryOf(src), registryOf(dest)}) {
+		if l.hasBandwidth() {
+			limiters = append(limiters, l)
+		}
+	}
+	if len(limiters) == 0 {
+		return src
+	}
+	return &limitedReference{
+		ImageReference: src,
+		limiters:       limiters,
+	}
+}
+
+// registryOf returns the registry of the docker image reference, returns
+// empty string for the other transports.
+func registryOf(ref types.ImageReference) string {
+	if ref == nil || ref.DockerReference() == nil {
+		return ""
+	}
+	return reference.Domain(ref.DockerReference())
+}
+
+type limitedReference struct {
+	types.ImageReference
+
+	limiters []*limiter
+}
+
+func (r *limitedReference) NewImageSource(
+	ctx context.Context, sys *types.SystemContext,
+) (types.ImageSource, error) {
+	src, err := r.ImageReference.NewImageSource(ctx, sys)
+	if err != nil {
+		return nil, err
+	}
+	return &limitedSource{
+		ImageSource: src,
+		limiters:    r.limiters,
+	}, nil
+}
+
+type limitedSource struct {
+	types.ImageSource
+
+	limiters []*limiter
+}
+
+func (s *limitedSource) GetBlob(
+	ctx context.Context, info types.BlobInfo, cache types.BlobInfoCache,
+) (io.ReadCloser, int64, error) {
+	rc, size, err := s.ImageSource.GetBlob(ctx, info, cache)
+	if err != nil {
+		return nil, 0, err
+	}
+	return &limitedReader{
+		ReadCloser: rc,
+		ctx:        ctx,
+		limiters:   s.limiters,
+	}, size, nil
+}
+
+// limitedReader waits for the bandwidth limits after reading the blob.
+type limitedReader struct {
+	io.ReadCloser
+
+	ctx      context.Context
+	limiters []*limiter
+}
+
+func (r *limitedReader) Read(p []byte) (int, error) {
+	// Read in small chunks to keep the throughput smooth.
+	if len(p) > minBurst {
+		p = p[:minBurst]
+	}
+	n, err := r.ReadCloser.Read(p)
+	for _, l := range r.limiters {
+		if werr := l.wait(r.ctx, n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,428 @@
+// Package registries provides the per-registry settings of the TLS
+// certificates, insecure registries, mirrors, bandwidth and concurrency
+// limits, which are applied to each registry separately.
+package registries
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/cnrancher/hangar/pkg/utils"
+	"github.com/containers/image/v5/types"
+	"sigs.k8s.io/yaml"
+)
+
+// Config is the per-registry settings file.
+//
+// Example:
+//
+//	# containers-registries.conf(5) file (optional).
+//	registriesConf: /etc/containers/registries.conf
+//	# Directory containing the 'host[:port]' sub-directories of the
+//	# ca.crt, client.cert and client.key files (optional).
+//	certsDir: /etc/docker/certs.d
+//	# Limits of all registries, the most restrictive limits of the rules in
+//	# the time windows are applied.
+//	limits:
+//	- bandwidth: 200Mbps
+//	  windows: [ "Mon-Fri 08:00-18:00" ]
+//	registries:
+//	  harbor.example.io:
+//	    ca: /etc/hangar/harbor-ca.crt
+//	    cert: /etc/hangar/client.cert
+//	    key: /etc/hangar/client.key
+//	    maxConcurrency: 4
+//	  registry.lab.local:5000:
+//	    insecure: true
+//	  dr.example.io:
+//	    limits:
+//	    - bandwidth: 50MB
+//	      maxConcurrency: 2
+//	      windows: [ "Mon-Fri 08:00-18:00", "Sat 00:00-24:00" ]
+//	  docker.io:
+//	    mirrors:
+//	    - location: mirror.lab.local:5000
+//	      insecure: true
+type Config struct {
+	// RegistriesConf is the containers-registries.conf(5) file path.
+	RegistriesConf string `json:"registriesConf,omitempty"`
+	// CertsDir is the directory containing the 'host[:port]' sub-directories
+	// of the certificates, ignored by the registry with certificates set.
+	CertsDir string `json:"certsDir,omitempty"`
+	// Registries is the settings of each registry 'host[:port]'.
+	Registries map[string]*Registry `json:"registries,omitempty"`
+	// Limits is the bandwidth and concurrency limits of all registries.
+	Limits []*Limit `json:"limits,omitempty"`
+}
+
+// Registry is the settings of the registry.
+type Registry struct {
+	// CA is the CA certificate bundle file, the system CAs are also trusted.
+	CA string `json:"ca,omitempty"`
+	// Cert is the client certificate file of the mTLS.
+	Cert string `json:"cert,omitempty"`
+	// Key is the client key file of the mTLS.
+	Key string `json:"key,omitempty"`
+	// CertDir is the directory containing the ca.crt, client.cert and
+	// client.key files, mutually exclusive with CA, Cert and Key.
+	CertDir string `json:"certDir,omitempty"`
+	// Insecure skips verifying the certificate and allows the HTTP protocol,
+	// overrides the '--tls-verify' option if set.
+	Insecure *bool `json:"insecure,omitempty"`
+	// Mirrors is the mirrors to pull the images of the registry.
+	Mirrors []Mirror `json:"mirrors,omitempty"`
+	// Proxy is not supported since the containers/image has no proxy option
+	// of the registry, use the HTTP_PROXY, HTTPS_PROXY and NO_PROXY
+	// environment variables instead. It is rejected with the error if set.
+	Proxy string `json:"proxy,omitempty"`
+	// MaxConcurrency is the maximum number of images copied from or to the
+	// registry at the same time, 0 means no limit.
+	MaxConcurrency int `json:"maxConcurrency,omitempty"`
+	// Limits is the bandwidth and concurrency limits of the registry.
+	Limits []*Limit `json:"limits,omitempty"`
+}
+
+// Mirror is the pull mirror of the registry.
+type Mirror struct {
+	Location string `json:"location" toml:"location"`
+	Insecure bool   `json:"insecure,omitempty" toml:"insecure,omitempty"`
+}
+
+// LoadConfig reads the YAML/JSON settings file.
+func LoadConfig(name string) (*Config, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read registry settings %q: %w", name, err)
+	}
+	c := &Config{}
+	if err := yaml.UnmarshalStrict(b, c); err != nil {
+		return nil, fmt.Errorf("failed to parse registry settings %q: %w", name, err)
+	}
+	return c, nil
+}
+
+// Settings applies the per-registry settings to the system context.
+// The methods of the nil Settings do nothing, so it is safe to use nil
+// Settings if no settings provided.
+type Settings struct {
+	config *Config
+	// tmpDir stores the generated certificate directories and the
+	// registries.conf.d drop-in file.
+	tmpDir string
+	// certDirs is the certificate directory of each registry.
+	certDirs map[string]string
+	// confDir is the registries.conf.d directory of the mirrors.
+	confDir string
+	// limiters is the limiter of each registry with limits, the limiter of
+	// all registries is the empty key.
+	limiters map[string]*limiter
+}
+
+var (
+	// systemRegistriesConfDir is the system registries.conf.d directory.
+	systemRegistriesConfDir = "/etc/containers/registries.conf.d"
+	// userConfigDir returns the per-user containers configuration directory.
+	userConfigDir = func() string {
+		home, _ := os.UserHomeDir()
+		return filepath.Join(home, ".config", "containers")
+	}
+)
+
+// New validates the settings and prepares the certificate directories and
+// the mirrors configuration, needs to call Close() to remove them.
+func New(c *Config) (*Settings, error) {
+	if c == nil {
+		c = &Config{}
+	}
+	s := &Settings{
+		config:   c,
+		certDirs: make(map[string]string),
+		limiters: make(map[string]*limiter),
+	}
+	rules, err := newRules(c.Limits)
+	if err != nil {
+		return nil, fmt.Errorf("invalid limits: %w", err)
+	}
+	if len(rules) > 0 {
+		s.limiters[""] = newLimiter(rules)
+	}
+	for _, name := range sortedKeys(c.Registries) {
+		if err := s.addRegistry(name, c.Registries[name]); err != nil {
+			s.Close()
+			return nil, err
+		}
+	}
+	if err := s.writeMirrors(); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Settings) addRegistry(name string, r *Registry) error {
+	if r == nil {
+		return nil
+	}
+	if name == "" || strings.Contains(name, "/") {
+		return fmt.Errorf("invalid registry %q: should be 'host[:port]'", name)
+	}
+	if (r.Cert == "") != (r.Key == "") {
+		return fmt.Errorf("registry %q: both cert and key should be provided", name)
+	}
+	if r.CertDir != "" && (r.CA != "" || r.Cert != "") {
+		return fmt.Errorf("registry %q: certDir is mutually exclusive with ca, cert and key", name)
+	}
+	rules, err := newRules(append([]*Limit{{
+		MaxConcurrency: r.MaxConcurrency,
+	}}, r.Limits...))
+	if err != nil {
+		return fmt.Errorf("registry %q: %w", name, err)
+	}
+	for _, m := range r.Mirrors {
+		if m.Location == "" {
+			return fmt.Errorf("registry %q: mirror location not provided", name)
+		}
+	}
+	if r.Proxy != "" {
+		return fmt.Errorf("registry %q: proxy of the registry is not supported, "+
+			"use the HTTPS_PROXY and NO_PROXY environment variables instead", name)
+	}
+	if len(rules) > 0 {
+		s.limiters[name] = newLimiter(rules)
+	}
+	if r.CertDir != "" {
+		s.certDirs[name] = r.CertDir
+		return nil
+	}
+	if r.CA == "" && r.Cert == "" {
+		return nil
+	}
+
+	// The certificate files are copied into the directory in the layout
+	// of the Docker certs.d host directory.
+	if err := s.mkTmpDir(); err != nil {
+		return err
+	}
+	dir := filepath.Join(s.tmpDir, "certs.d", strings.ReplaceAll(name, ":", "_"))
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	files := map[string]string{
+		"ca.crt":      r.CA,
+		"client.cert": r.Cert,
+		"client.key":  r.Key,
+	}
+	for dst, src := range files {
+		if src == "" {
+			continue
+		}
+		b, err := os.ReadFile(src)
+		if err != nil {
+			return fmt.Errorf("registry %q: failed to read %q: %w", name, src, err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, dst), b, 0600); err != nil {
+			return fmt.Errorf("registry %q: failed to write %q: %w", name, dst, err)
+		}
+	}
+	s.certDirs[name] = dir
+	return nil
+}
+
+// writeMirrors writes the mirrors into the registries.conf.d drop-in file,
+// the drop-in files of the system and the user are copied into the same
+// directory in the loading order since the directory replaces them.
+func (s *Settings) writeMirrors() error {
+	type registry struct {
+		Prefix   string   `toml:"prefix"`
+		Location string   `toml:"location"`
+		Mirrors  []Mirror `toml:"mirror"`
+	}
+	var conf struct {
+		Registries []registry `toml:"registry"`
+	}
+	for _, name := range sortedKeys(s.config.Registries) {
+		r := s.config.Registries[name]
+		if r == nil || len(r.Mirrors) == 0 {
+			continue
+		}
+		conf.Registries = append(conf.Registries, registry{
+			Prefix:   name,
+			Location: name,
+			Mirrors:  r.Mirrors,
+		})
+	}
+	if len(conf.Registries) == 0 {
+		return nil
+	}
+	if err := s.mkTmpDir(); err != nil {
+		return err
+	}
+	buff := &bytes.Buffer{}
+	if err := toml.NewEncoder(buff).Encode(conf); err != nil {
+		return fmt.Errorf("failed to marshal registries.conf: %w", err)
+	}
+	dir := filepath.Join(s.tmpDir, "registries.conf.d")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	n, err := s.copyDropIns(dir)
+	if err != nil {
+		return err
+	}
+	name := filepath.Join(dir, fmt.Sprintf("%04d-hangar-mirrors.conf", n))
+	if err := os.WriteFile(name, buff.Bytes(), 0600); err != nil {
+		return fmt.Errorf("failed to write %q: %w", name, err)
+	}
+	s.confDir = dir
+	return nil
+}
+
+// dropInDirs returns the registries.conf.d directories loaded by the
+// containers/image in order, the system directory is not loaded if the
+// per-user registries.conf is used.
+func (s *Settings) dropInDirs() []string {
+	userDir := filepath.Join(userConfigDir(), "registries.conf.d")
+	if s.config.RegistriesConf == "" {
+		_, err := os.Stat(filepath.Join(userConfigDir(), "registries.conf"))
+		if err == nil {
+			return []string{userDir}
+		}
+	}
+	return []string{systemRegistriesConfDir, userDir}
+}
+
+// copyDropIns copies the '*.conf' drop-in files into the directory with the
+// file names keeping the loading order, returns the number of files copied.
+func (s *Settings) copyDropIns(dir string) (int, error) {
+	var n int
+	for _, src := range s.dropInDirs() {
+		entries, err := os.ReadDir(src)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return n, fmt.Errorf("failed to read registries.conf.d: %w", err)
+		}
+		for _, e := range entries {
+			if e.IsDir() || !strings.HasSuffix(e.Name(), ".conf") {
+				continue
+			}
+			b, err := os.ReadFile(filepath.Join(src, e.Name()))
+			if err != nil {
+				return n, fmt.Errorf("failed to read registries.conf.d: %w", err)
+			}
+			name := filepath.Join(dir, fmt.Sprintf("%04d-%s", n, e.Name()))
+			if err := os.WriteFile(name, b, 0600); err != nil {
+				return n, fmt.Errorf("failed to write %q: %w", name, err)
+			}
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (s *Settings) mkTmpDir() error {
+	if s.tmpDir != "" {
+		return nil
+	}
+	dir, err := os.MkdirTemp("", "hangar-registries-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	s.tmpDir = dir
+	return nil
+}
+
+// SystemContext returns the copy of the system context with the settings
+// of the registry applied.
+func (s *Settings) SystemContext(
+	sysCtx *types.SystemContext, registry string,
+) *types.SystemContext {
+	n := utils.CopySystemContext(sysCtx)
+	if s == nil {
+		return n
+	}
+	if s.config.RegistriesConf != "" {
+		n.SystemRegistriesConfPath = s.config.RegistriesConf
+	}
+	if s.confDir != "" {
+		n.SystemRegistriesConfDirPath = s.confDir
+	}
+	if s.config.CertsDir != "" {
+		n.DockerPerHostCertDirPath = s.config.CertsDir
+	}
+	if dir, ok := s.certDirs[registry]; ok {
+		n.DockerCertPath = dir
+	}
+	if r := s.config.Registries[registry]; r != nil && r.Insecure != nil {
+		n.DockerInsecureSkipTLSVerify = types.NewOptionalBool(*r.Insecure)
+		n.OCIInsecureSkipTLSVerify = *r.Insecure
+	}
+	return n
+}
+
+// Acquire waits until the images can be copied from or to the registries
+// within the concurrency limits, the returned function should be called to
+// release the registries after the image copied.
+func (s *Settings) Acquire(ctx context.Context, registries ...string) (func(), error) {
+	if s == nil || len(s.limiters) == 0 {
+		return func() {}, nil
+	}
+	var acquired []*limiter
+	release := func() {
+		for _, l := range acquired {
+			l.release()
+		}
+	}
+	// Acquire the limiters in order to avoid the deadlock.
+	for _, l := range s.limitersOf(registries) {
+		if !l.hasConcurrency() {
+			continue
+		}
+		if err := l.acquire(ctx); err != nil {
+			release()
+			return nil, err
+		}
+		acquired = append(acquired, l)
+	}
+	return release, nil
+}
+
+// limitersOf returns the limiters of the registries including the limiter
+// of all registries, sorted by the registry.
+func (s *Settings) limitersOf(registries []string) []*limiter {
+	names := append([]string{""}, registries...)
+	sort.Strings(names)
+	names = slices.Compact(names)
+	var limiters []*limiter
+	for _, name := range names {
+		if l, ok := s.limiters[name]; ok {
+			limiters = append(limiters, l)
+		}
+	}
+	return limiters
+}
+
+func sortedKeys(m map[string]*Registry) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Close removes the generated certificate directories and configurations.
+func (s *Settings) Close() error {
+	if s == nil || s.tmpDir == "" {
+		return nil
+	}
+	return os.RemoveAll(s.tmpDir)
+}
@@ -0,0 +1,195 @@
+package registries
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/containers/image/v5/pkg/sysregistriesv2"
+	"github.com/containers/image/v5/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), name)
+	assert.NoError(t, os.WriteFile(p, []byte(content), 0600))
+	return p
+}
+
+func Test_LoadConfig(t *testing.T) {
+	c, err := LoadConfig(writeFile(t, "registries.yaml", `
+certsDir: /etc/docker/certs.d
+registries:
+  registry.lab.local:5000:
+    insecure: true
+    maxConcurrency: 2
+  docker.io:
+    mirrors:
+    - location: mirror.lab.local:5000
+`))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "/etc/docker/certs.d", c.CertsDir)
+	assert.True(t, *c.Registries["registry.lab.local:5000"].Insecure)
+	assert.Equal(t, 2, c.Registries["registry.lab.local:5000"].MaxConcurrency)
+	assert.Equal(t, []Mirror{{Location: "mirror.lab.local:5000"}},
+		c.Registries["docker.io"].Mirrors)
+
+	_, err = LoadConfig(writeFile(t, "registries.yaml", "unknown: true"))
+	assert.Error(t, err)
+	_, err = LoadConfig(filepath.Join(t.TempDir(), "not-exists.yaml"))
+	assert.Error(t, err)
+}
+
+func Test_New(t *testing.T) {
+	invalid := []*Registry{
+		{Cert: "client.cert"},
+		{CertDir: "/certs", CA: "ca.crt"},
+		{MaxConcurrency: -1},
+		{Mirrors: []Mirror{{Insecure: true}}},
+		{CA: filepath.Join(t.TempDir(), "not-exists.crt")},
+		{Proxy: "http://proxy.example.io:3128"},
+	}
+	for _, r := range invalid {
+		_, err := New(&Config{Registries: map[string]*Registry{"example.io": r}})
+		assert.Error(t, err)
+	}
+	_, err := New(&Config{Registries: map[string]*Registry{
+		"example.io/library": {},
+	}})
+	assert.Error(t, err)
+
+	s, err := New(nil)
+	assert.NoError(t, err)
+	assert.Empty(t, s.tmpDir)
+	assert.NoError(t, s.Close())
+}
+
+func Test_SystemContext(t *testing.T) {
+	// The drop-in files of the system are kept with the mirrors.
+	systemDir, configDir := t.TempDir(), t.TempDir()
+	defer func(dir string, fn func() string) {
+		systemRegistriesConfDir, userConfigDir = dir, fn
+	}(systemRegistriesConfDir, userConfigDir)
+	systemRegistriesConfDir = systemDir
+	userConfigDir = func() string { return configDir }
+	assert.NoError(t, os.WriteFile(filepath.Join(systemDir, "quay.conf"), []byte(`
+[[registry]]
+location = "quay.io"
+[[registry.mirror]]
+location = "quay-mirror.example.io"
+`), 0600))
+
+	var s *Settings
+	sysCtx := s.SystemContext(&types.SystemContext{
+		DockerInsecureSkipTLSVerify: types.OptionalBoolTrue,
+	}, "example.io")
+	assert.Equal(t, types.OptionalBoolTrue, sysCtx.DockerInsecureSkipTLSVerify)
+	assert.NoError(t, s.Close())
+
+	ca := writeFile(t, "ca.crt", "CA")
+	verify := false
+	s, err := New(&Config{
+		RegistriesConf: "/etc/containers/registries.conf",
+		CertsDir:       "/etc/docker/certs.d",
+		Registries: map[string]*Registry{
+			"harbor.example.io": {CA: ca},
+			"registry.example.io:5000": {
+				CertDir:  "/etc/hangar/certs",
+				Insecure: &verify,
+			},
+			"docker.io": {
+				Mirrors: []Mirror{{Location: "mirror.example.io", Insecure: true}},
+			},
+		},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	base := &types.SystemContext{
+		DockerInsecureSkipTLSVerify: types.OptionalBoolTrue,
+		OCIInsecureSkipTLSVerify:    true,
+	}
+
+	sysCtx = s.SystemContext(base, "harbor.example.io")
+	assert.Equal(t, "/etc/containers/registries.conf", sysCtx.SystemRegistriesConfPath)
+	assert.Equal(t, "/etc/docker/certs.d", sysCtx.DockerPerHostCertDirPath)
+	b, err := os.ReadFile(filepath.Join(sysCtx.DockerCertPath, "ca.crt"))
+	assert.NoError(t, err)
+	assert.Equal(t, "CA", string(b))
+	assert.Equal(t, types.OptionalBoolTrue, sysCtx.DockerInsecureSkipTLSVerify)
+
+	sysCtx = s.SystemContext(base, "registry.example.io:5000")
+	assert.Equal(t, "/etc/hangar/certs", sysCtx.DockerCertPath)
+	assert.Equal(t, types.OptionalBoolFalse, sysCtx.DockerInsecureSkipTLSVerify)
+	assert.False(t, sysCtx.OCIInsecureSkipTLSVerify)
+	// The base system context is not modified.
+	assert.Equal(t, types.OptionalBoolTrue, base.DockerInsecureSkipTLSVerify)
+
+	// The mirrors are loaded by the containers/image.
+	sysCtx = s.SystemContext(nil, "docker.io")
+	sysCtx.SystemRegistriesConfPath = writeFile(t, "registries.conf", "")
+	r, err := sysregistriesv2.FindRegistry(sysCtx, "docker.io/library/nginx")
+	if assert.NoError(t, err) && assert.NotNil(t, r) {
+		assert.Equal(t, "docker.io", r.Location)
+		assert.Equal(t, []sysregistriesv2.Endpoint{
+			{Location: "mirror.example.io", Insecure: true},
+		}, r.Mirrors)
+	}
+	r, err = sysregistriesv2.FindRegistry(sysCtx, "quay.io/jetstack/cert-manager-controller")
+	if assert.NoError(t, err) && assert.NotNil(t, r) {
+		assert.Equal(t, []sysregistriesv2.Endpoint{
+			{Location: "quay-mirror.example.io"},
+		}, r.Mirrors)
+	}
+
+	dir := s.tmpDir
+	assert.NoError(t, s.Close())
+	_, err = os.Stat(dir)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func Test_Acquire(t *testing.T) {
+	var s *Settings
+	release, err := s.Acquire(context.Background(), "example.io")
+	assert.NoError(t, err)
+	release()
+
+	s, err = New(&Config{Registries: map[string]*Registry{
+		"a.example.io": {MaxConcurrency: 1},
+		"b.example.io": {MaxConcurrency: 2},
+	}})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer s.Close()
+
+	release1, err := s.Acquire(context.Background(),
+		"b.example.io", "a.example.io", "a.example.io", "c.example.io")
+	assert.NoError(t, err)
+	// The registry b.example.io is still available.
+	release2, err := s.Acquire(context.Background(), "b.example.io")
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	_, err = s.Acquire(ctx, "a.example.io")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	// Failed acquiring does not hold the acquired registries.
+	ctx, cancel = context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	_, err = s.Acquire(ctx, "a.example.io", "b.example.io")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 2, s.limiters["b.example.io"].inUse)
+
+	release1()
+	release2()
+	release, err = s.Acquire(context.Background(), "a.example.io", "b.example.io")
+	assert.NoError(t, err)
+	release()
+	assert.Equal(t, 0, s.limiters["a.example.io"].inUse)
+}
@@ -0,0 +1,250 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/cnrancher/hangar/pkg/utils"
+	"github.com/containers/common/pkg/retry"
+	"github.com/containers/image/v5/types"
+	"github.com/sirupsen/logrus"
+)
+
+// client is the HTTP client of the registry v2 API, which supports the
+// basic and bearer token authentication.
+type client struct {
+	client     *http.Client
+	credential *types.DockerAuthConfig
+	// baseURL is the registry URL with scheme, example: https://example.io
+	baseURL string
+	// authorization is the cached authorization header value.
+	authorization string
+}
+
+func newClient(
+	ctx context.Context,
+	registry string,
+	credential *types.DockerAuthConfig,
+	sysCtx *types.SystemContext,
+) (*client, error) {
+	httpClient, err := utils.NewHTTPClient(sysCtx, registry, time.Second*30)
+	if err != nil {
+		return nil, err
+	}
+	c := &client{
+		client:     httpClient,
+		credential: credential,
+	}
+	registry = strings.TrimSuffix(registry, "/")
+	if registry == utils.DockerHubRegistry {
+		registry = "registry-1.docker.io"
+	}
+	// Try ping registry using HTTPS protocol.
+	c.baseURL = fmt.Sprintf("https://%s", registry)
+	resp, err := c.do(ctx, http.MethodGet, c.baseURL+"/v2/", "")
+	if err != nil {
+		if utils.TLSVerify(sysCtx) || !errors.Is(err, http.ErrSchemeMismatch) {
+			return nil, fmt.Errorf("failed to ping registry %q: %w", registry, err)
+		}
+		logrus.Debugf("ping %s: %v", c.baseURL, err)
+		// The tlsVerify not enabled, try re-ping registry using HTTP.
+		c.baseURL = fmt.Sprintf("http://%s", registry)
+		resp, err = c.do(ctx, http.MethodGet, c.baseURL+"/v2/", "")
+		if err != nil {
+			return nil, fmt.Errorf("failed to ping registry %q: %w", registry, err)
+		}
+	}
+	resp.Body.Close()
+	return c, nil
+}
+
+// get sends the GET request to the registry, the scope is used for
+// requesting the bearer token if needed.
+func (c *client) get(ctx context.Context, u, scope string) (*http.Response, error) {
+	return c.do(ctx, http.MethodGet, u, scope)
+}
+
+func (c *client) do(
+	ctx context.Context, method, u, scope string,
+) (*http.Response, error) {
+	resp, err := c.request(ctx, method, u, c.authorization)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusUnauthorized {
+		return resp, nil
+	}
+	challenge := resp.Header.Get("WWW-Authenticate")
+	resp.Body.Close()
+	if challenge == "" {
+		return nil, fmt.Errorf("%q response: %v", u, resp.Status)
+	}
+
+	authorization, err := c.authorize(ctx, challenge, scope)
+	if err != nil {
+		return nil, err
+	}
+	resp, err = c.request(ctx, method, u, authorization)
+	if err != nil {
+		return nil, err
+	}
+	c.authorization = authorization
+	return resp, nil
+}
+
+func (c *client) request(
+	ctx context.Context, method, u, authorization string,
+) (*http.Response, error) {
+	var resp *http.Response
+	err := retry.IfNecessary(ctx, func() error {
+		req, err := http.NewRequestWithContext(ctx, method, u, nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Add("Accept", "application/json")
+		if authorization != "" {
+			req.Header.Add("Authorization", authorization)
+		}
+		logrus.Debugf("client.Do: %v", req.URL.String())
+		resp, err = c.client.Do(req)
+		return err
+	}, &retry.Options{
+		MaxRetry: 3,
+		Delay:    time.Microsecond * 100,
+	})
+	return resp, err
+}
+
+// authorize returns the authorization header value of the challenge.
+func (c *client) authorize(
+	ctx context.Context, challenge, scope string,
+) (string, error) {
+	scheme, params := parseChallenge(challenge)
+	switch strings.ToLower(scheme) {
+	case "basic":
+		if c.credential == nil || c.credential.Username == "" {
+			return "", fmt.Errorf("registry requires basic auth: credential not provided")
+		}
+		auth := fmt.Sprintf("%s:%s", c.credential.Username, c.credential.Password)
+		return "Basic " + utils.Base64(auth), nil
+	case "bearer":
+	default:
+		return "", fmt.Errorf("unsupported auth challenge %q", challenge)
+	}
+
+	realm, err := url.Parse(params["realm"])
+	if err != nil || params["realm"] == "" {
+		return "", fmt.Errorf("invalid auth challenge %q", challenge)
+	}
+	q := realm.Query()
+	if params["service"] != "" {
+		q.Set("service", params["service"])
+	}
+	if scope != "" {
+		q.Set("scope", scope)
+	} else if params["scope"] != "" {
+		q.Set("scope", params["scope"])
+	}
+	realm.RawQuery = q.Encode()
+
+	var resp *http.Response
+	err = retry.IfNecessary(ctx, func() error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+		if err != nil {
+			return err
+		}
+		if c.credential != nil && c.credential.Username != "" {
+			req.SetBasicAuth(c.credential.Username, c.credential.Password)
+		}
+		logrus.Debugf("client.Do: %v", req.URL.String())
+		resp, err = c.client.Do(req)
+		return err
+	}, &retry.Options{
+		MaxRetry: 3,
+		Delay:    time.Microsecond * 100,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to request token: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to request token: %q response: %v",
+			realm.String(), resp.Status)
+	}
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read token: %w", err)
+	}
+	token := struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}{}
+	if err = json.Unmarshal(b, &token); err != nil {
+		return "", fmt.Errorf("failed to decode token: %w", err)
+	}
+	if token.Token == "" {
+		token.Token = token.AccessToken
+	}
+	if token.Token == "" {
+		return "", fmt.Errorf("failed to request token: empty token")
+	}
+	return "Bearer " + token.Token, nil
+}
+
+// parseChallenge parses the WWW-Authenticate header, example:
+//
+//	Bearer realm="https://auth.example.io/token",service="registry.example.io"
+func parseChallenge(challenge string) (string, map[string]string) {
+	params := map[string]string{}
+	challenge = strings.TrimSpace(challenge)
+	scheme, rest, _ := strings.Cut(challenge, " ")
+	for rest != "" {
+		var kv string
+		rest = strings.TrimLeft(rest, " ,")
+		if rest == "" {
+			break
+		}
+		k, v, ok := strings.Cut(rest, "=")
+		if !ok {
+			break
+		}
+		if strings.HasPrefix(v, `"`) {
+			end := strings.Index(v[1:], `"`)
+			if end < 0 {
+				kv, rest = v[1:], ""
+			} else {
+				kv, rest = v[1:end+1], v[end+2:]
+			}
+		} else {
+			kv, rest, _ = strings.Cut(v, ",")
+		}
+		params[strings.ToLower(strings.TrimSpace(k))] = kv
+	}
+	return scheme, params
+}
+
+// nextLink returns the URL of the next page from the Link header, example:
+//
+//	</v2/_catalog?last=b&n=100>; rel="next"
+func (c *client) nextLink(link string) string {
+	if link == "" || !strings.Contains(link, `rel="next"`) {
+		return ""
+	}
+	start := strings.Index(link, "<")
+	end := strings.Index(link, ">")
+	if start < 0 || end < start {
+		return ""
+	}
+	next := link[start+1 : end]
+	if strings.HasPrefix(next, "/") {
+		return c.baseURL + next
+	}
+	return next
+}
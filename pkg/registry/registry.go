@@ -0,0 +1,206 @@
+// Package registry lists the repositories and tags of the container image
+// registry server, used for mirroring the entire repositories or projects.
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/cnrancher/hangar/pkg/harbor"
+	"github.com/cnrancher/hangar/pkg/utils"
+	"github.com/containers/image/v5/docker"
+	"github.com/containers/image/v5/pkg/docker/config"
+	"github.com/containers/image/v5/types"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	ErrCatalogNotSupported = errors.New("registry does not support the catalog API")
+)
+
+// ListTags lists all tags of the repository by the registry v2 API,
+// example repository: docker.io/library/nginx
+func ListTags(
+	ctx context.Context, repository string, sysCtx *types.SystemContext,
+) ([]string, error) {
+	ref, err := docker.ParseReference("//" + repository)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse repository %q: %w", repository, err)
+	}
+	tags, err := docker.GetRepositoryTags(ctx, sysCtx, ref)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags of %q: %w", repository, err)
+	}
+	return tags, nil
+}
+
+// ListRepositories lists all the repositories of the registry server by the
+// registry v2 catalog API, the repository name is in "PROJECT/NAME" format.
+func ListRepositories(
+	ctx context.Context, registry string, sysCtx *types.SystemContext,
+) ([]string, error) {
+	credential, err := config.GetCredentials(sysCtx, registry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get credential of %q: %w", registry, err)
+	}
+	c, err := newClient(ctx, registry, &credential, sysCtx)
+	if err != nil {
+		return nil, err
+	}
+
+	var repositories []string
+	next := c.baseURL + "/v2/_catalog?n=100"
+	for next != "" {
+		resp, err := c.get(ctx, next, "registry:catalog:*")
+		if err != nil {
+			return nil, fmt.Errorf("failed to list repositories of %q: %w",
+				registry, err)
+		}
+		b, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list repositories of %q: %w",
+				registry, err)
+		}
+		switch resp.StatusCode {
+		case http.StatusOK:
+		case http.StatusNotFound, http.StatusForbidden, http.StatusUnauthorized:
+			return nil, fmt.Errorf("%w: %q response: %v",
+				ErrCatalogNotSupported, registry, resp.Status)
+		default:
+			return nil, fmt.Errorf("failed to list repositories of %q: response: %v",
+				registry, resp.Status)
+		}
+		data := struct {
+			Repositories []string `json:"repositories"`
+		}{}
+		if err = json.Unmarshal(b, &data); err != nil {
+			return nil, fmt.Errorf("failed to decode catalog of %q: %w",
+				registry, err)
+		}
+		repositories = append(repositories, data.Repositories...)
+		next = c.nextLink(resp.Header.Get("Link"))
+	}
+	return repositories, nil
+}
+
+// ListProjectRepositories lists the repositories of the project, the Harbor
+// project API is used if the registry is Harbor V2, otherwise the catalog
+// API will be used.
+func ListProjectRepositories(
+	ctx context.Context, registry, project string, sysCtx *types.SystemContext,
+) ([]string, error) {
+	harborURL, err := harbor.GetRegistryURL(ctx, registry, sysCtx)
+	if err == nil {
+		credential, err := config.GetCredentials(sysCtx, registry)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get credential of %q: %w",
+				registry, err)
+		}
+		return harbor.ListRepositories(ctx, project, harborURL,
+			harbor.APICredential(credential, ""), sysCtx)
+	}
+	if !errors.Is(err, harbor.ErrRegistryIsNotHarbor) {
+		logrus.Debugf("failed to detect harbor registry %q: %v", registry, err)
+	}
+
+	repositories, err := ListRepositories(ctx, registry, sysCtx)
+	if err != nil {
+		return nil, err
+	}
+	var result []string
+	for _, r := range repositories {
+		if strings.HasPrefix(r, project+"/") {
+			result = append(result, r)
+		}
+	}
+	return result, nil
+}
+
+// ListImagesOption is the option to list the images of the repositories
+// and projects.
+type ListImagesOption struct {
+	// Registry overrides the registry of the repositories,
+	// required if Projects specified.
+	Registry string
+	// Repositories to list tags, example: docker.io/library/nginx
+	Repositories []string
+	// Projects to list repositories, example: library
+	Projects []string
+	// RepositoryRegex filters the repository names (optional).
+	RepositoryRegex *regexp.Regexp
+	// TagRegex filters the tags (optional).
+	TagRegex *regexp.Regexp
+
+	SystemContext *types.SystemContext
+}
+
+// ListImages lists all the images matching the filters of the repositories
+// and projects, returns the image list in default format, example:
+// docker.io/library/nginx:1.25
+func ListImages(ctx context.Context, o *ListImagesOption) ([]string, error) {
+	repositorySet := map[string]bool{}
+	var repositories []string
+	addRepository := func(r string) {
+		if o.RepositoryRegex != nil && !o.RepositoryRegex.MatchString(r) {
+			logrus.Debugf("Skip repository %q: not match regex", r)
+			return
+		}
+		if repositorySet[r] {
+			return
+		}
+		repositorySet[r] = true
+		repositories = append(repositories, r)
+	}
+
+	for _, r := range o.Repositories {
+		r = strings.TrimSpace(r)
+		if r == "" {
+			continue
+		}
+		registry := utils.GetRegistryName(r)
+		if o.Registry != "" {
+			registry = o.Registry
+		}
+		addRepository(fmt.Sprintf("%s/%s/%s", registry,
+			utils.GetProjectName(r), utils.GetImageName(r)))
+	}
+	if len(o.Projects) > 0 && o.Registry == "" {
+		return nil, fmt.Errorf("registry not provided for listing projects")
+	}
+	for _, p := range o.Projects {
+		names, err := ListProjectRepositories(ctx, o.Registry, p, o.SystemContext)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list repositories of project %q: %w",
+				p, err)
+		}
+		sort.Strings(names)
+		for _, n := range names {
+			addRepository(fmt.Sprintf("%s/%s", o.Registry, n))
+		}
+	}
+
+	var images []string
+	for _, r := range repositories {
+		tags, err := ListTags(ctx, r, o.SystemContext)
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(tags)
+		for _, t := range tags {
+			if o.TagRegex != nil && !o.TagRegex.MatchString(t) {
+				continue
+			}
+			images = append(images, fmt.Sprintf("%s:%s", r, t))
+		}
+		logrus.Infof("Found %d tags in repository %q", len(tags), r)
+	}
+	return images, nil
+}
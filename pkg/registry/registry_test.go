@@ -0,0 +1,89 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/containers/image/v5/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_parseChallenge(t *testing.T) {
+	scheme, params := parseChallenge(
+		`Bearer realm="https://auth.example.io/token",service="registry.example.io",scope="registry:catalog:*"`)
+	assert.Equal(t, "Bearer", scheme)
+	assert.Equal(t, "https://auth.example.io/token", params["realm"])
+	assert.Equal(t, "registry.example.io", params["service"])
+	assert.Equal(t, "registry:catalog:*", params["scope"])
+
+	scheme, params = parseChallenge(`Basic realm="Registry Realm"`)
+	assert.Equal(t, "Basic", scheme)
+	assert.Equal(t, "Registry Realm", params["realm"])
+}
+
+func Test_nextLink(t *testing.T) {
+	c := &client{baseURL: "https://registry.example.io"}
+	assert.Equal(t, "https://registry.example.io/v2/_catalog?last=b&n=2",
+		c.nextLink(`</v2/_catalog?last=b&n=2>; rel="next"`))
+	assert.Equal(t, "https://example.io/v2/_catalog?last=b",
+		c.nextLink(`<https://example.io/v2/_catalog?last=b>; rel="next"`))
+	assert.Equal(t, "", c.nextLink(""))
+}
+
+func Test_ListRepositories(t *testing.T) {
+	const token = "abcdef"
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			assert.Equal(t, "registry:catalog:*", r.URL.Query().Get("scope"))
+			json.NewEncoder(w).Encode(map[string]string{"token": token})
+			return
+		case "/v2/":
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(
+				`Bearer realm="%s/token",service="test"`, server.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Query().Get("last") {
+		case "":
+			w.Header().Set("Link", `</v2/_catalog?last=library%2Fnginx&n=100>; rel="next"`)
+			json.NewEncoder(w).Encode(map[string][]string{
+				"repositories": {"library/busybox", "library/nginx"},
+			})
+		default:
+			json.NewEncoder(w).Encode(map[string][]string{
+				"repositories": {"rancher/hangar"},
+			})
+		}
+	}))
+	defer server.Close()
+
+	sysCtx := &types.SystemContext{
+		AuthFilePath:                filepath.Join(t.TempDir(), "auth.json"),
+		DockerInsecureSkipTLSVerify: types.OptionalBoolTrue,
+	}
+	registry := strings.TrimPrefix(server.URL, "http://")
+	repositories, err := ListRepositories(context.TODO(), registry, sysCtx)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []string{
+		"library/busybox", "library/nginx", "rancher/hangar",
+	}, repositories)
+
+	// HTTP registry is not allowed if TLS verify is enabled.
+	sysCtx.DockerInsecureSkipTLSVerify = types.OptionalBoolFalse
+	_, err = ListRepositories(context.TODO(), registry, sysCtx)
+	assert.Error(t, err)
+}
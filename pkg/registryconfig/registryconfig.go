@@ -0,0 +1,239 @@
+// Package registryconfig generates the registry mirror configurations of
+// RKE2/K3s (registries.yaml), containerd (certs.d/*/hosts.toml) and Docker
+// (daemon.json), to let the clusters pull images from the private registry
+// transparently.
+package registryconfig
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/BurntSushi/toml"
+	"github.com/cnrancher/hangar/pkg/hangar/imagelist"
+	"github.com/cnrancher/hangar/pkg/utils"
+	"github.com/sirupsen/logrus"
+	"sigs.k8s.io/yaml"
+)
+
+// Options is the option to generate the registry configurations.
+type Options struct {
+	// Registry is the private registry server, example: registry.example.io
+	Registry string
+	// Project overrides the project of all images in the private registry,
+	// the image repositories are rewritten to this project (optional).
+	Project string
+	// Images is the image list (in default or mirror format) to get the
+	// upstream registries to be mirrored, the mirror format lines changing
+	// the repository path are skipped.
+	Images []string
+	// Insecure skips verifying the certificate of the private registry.
+	Insecure bool
+	// PlainHTTP uses the HTTP protocol for the private registry.
+	PlainHTTP bool
+	// CAFile is the CA certificate file path of the private registry
+	// on the cluster nodes (optional).
+	CAFile string
+}
+
+// Config generates the registry configurations.
+type Config struct {
+	o          *Options
+	registries []string
+}
+
+// New creates the Config from the options.
+func New(o *Options) (*Config, error) {
+	if o.Registry == "" {
+		return nil, fmt.Errorf("registry not provided")
+	}
+	c := &Config{o: o}
+	registrySet := map[string]bool{}
+	for _, line := range o.Images {
+		var image string
+		switch imagelist.Detect(line) {
+		case imagelist.TypeDefault:
+			image = line
+		case imagelist.TypeMirror:
+			spec, _ := imagelist.GetMirrorSpec(line)
+			if len(spec) != 3 {
+				continue
+			}
+			if c.repositoryChanged(spec[0], spec[1]) {
+				logrus.Warnf("Skip image list line %q: the repository path "+
+					"is changed and can not be mirrored transparently", line)
+				continue
+			}
+			image = spec[0]
+		default:
+			continue
+		}
+		registry := utils.GetRegistryName(image)
+		if registry == o.Registry || registrySet[registry] {
+			continue
+		}
+		registrySet[registry] = true
+		c.registries = append(c.registries, registry)
+	}
+	sort.Strings(c.registries)
+	return c, nil
+}
+
+// repositoryChanged returns true if the repository path of the source image
+// in the mirror format line differs from the destination image, the project
+// of the destination image is ignored if the project is overridden.
+func (c *Config) repositoryChanged(source, dest string) bool {
+	if utils.GetImageName(source) != utils.GetImageName(dest) {
+		return true
+	}
+	return c.o.Project == "" && utils.GetProjectName(source) != utils.GetProjectName(dest)
+}
+
+// Registries returns the upstream registries mirrored to the private registry.
+func (c *Config) Registries() []string {
+	return c.registries
+}
+
+// hosts returns the upstream registries and the private registry.
+func (c *Config) hosts() []string {
+	hosts := make([]string, 0, len(c.registries)+1)
+	hosts = append(hosts, c.registries...)
+	return append(hosts, c.o.Registry)
+}
+
+// endpoint returns the URL of the private registry.
+func (c *Config) endpoint() string {
+	if c.o.PlainHTTP {
+		return "http://" + c.o.Registry
+	}
+	return "https://" + c.o.Registry
+}
+
+// rewrites returns the repository rewrite rules if the project is overridden,
+// example: "rancher/rancher" is rewritten to "PROJECT/rancher".
+func (c *Config) rewrites() map[string]string {
+	if c.o.Project == "" {
+		return nil
+	}
+	return map[string]string{
+		"^.*/([^/]+)$": c.o.Project + "/$1",
+	}
+}
+
+// RKE2 returns the registries.yaml of RKE2/K3s, see
+// https://docs.rke2.io/install/containerd_registry_configuration
+func (c *Config) RKE2() ([]byte, error) {
+	type mirror struct {
+		Endpoint []string          `json:"endpoint"`
+		Rewrite  map[string]string `json:"rewrite,omitempty"`
+	}
+	type tlsConfig struct {
+		CAFile             string `json:"ca_file,omitempty"`
+		InsecureSkipVerify bool   `json:"insecure_skip_verify,omitempty"`
+	}
+	type config struct {
+		TLS *tlsConfig `json:"tls,omitempty"`
+	}
+	data := struct {
+		Mirrors map[string]mirror `json:"mirrors"`
+		Configs map[string]config `json:"configs,omitempty"`
+	}{
+		Mirrors: map[string]mirror{},
+	}
+	for _, r := range c.hosts() {
+		data.Mirrors[r] = mirror{
+			Endpoint: []string{c.endpoint()},
+			Rewrite:  c.rewrites(),
+		}
+	}
+	if c.o.Insecure || c.o.CAFile != "" {
+		data.Configs = map[string]config{
+			c.o.Registry: {
+				TLS: &tlsConfig{
+					CAFile:             c.o.CAFile,
+					InsecureSkipVerify: c.o.Insecure,
+				},
+			},
+		}
+	}
+	b, err := yaml.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal registries.yaml: %w", err)
+	}
+	return b, nil
+}
+
+// Containerd returns the hosts.toml of the containerd registry host
+// namespaces, the key is the namespace directory name in certs.d, see
+// https://github.com/containerd/containerd/blob/main/docs/hosts.md
+func (c *Config) Containerd() (map[string][]byte, error) {
+	type host struct {
+		Capabilities []string `toml:"capabilities"`
+		SkipVerify   bool     `toml:"skip_verify,omitempty"`
+		CA           string   `toml:"ca,omitempty"`
+	}
+	result := map[string][]byte{}
+	for _, r := range c.hosts() {
+		server := "https://" + r
+		if r == utils.DockerHubRegistry {
+			server = "https://registry-1.docker.io"
+		}
+		if r == c.o.Registry {
+			server = c.endpoint()
+		}
+		data := struct {
+			Server string          `toml:"server"`
+			Host   map[string]host `toml:"host"`
+		}{
+			Server: server,
+			Host: map[string]host{
+				c.endpoint(): {
+					Capabilities: []string{"pull", "resolve"},
+					SkipVerify:   c.o.Insecure,
+					CA:           c.o.CAFile,
+				},
+			},
+		}
+		buff := &bytes.Buffer{}
+		if err := toml.NewEncoder(buff).Encode(data); err != nil {
+			return nil, fmt.Errorf("failed to marshal hosts.toml of %q: %w", r, err)
+		}
+		result[r] = buff.Bytes()
+	}
+	return result, nil
+}
+
+// Docker returns the daemon.json of the Docker, only the images of
+// Docker Hub can be mirrored by the registry-mirrors.
+func (c *Config) Docker() ([]byte, error) {
+	data := struct {
+		RegistryMirrors    []string `json:"registry-mirrors,omitempty"`
+		InsecureRegistries []string `json:"insecure-registries,omitempty"`
+	}{}
+	for _, r := range c.registries {
+		if r == utils.DockerHubRegistry {
+			data.RegistryMirrors = []string{c.endpoint()}
+		}
+	}
+	if c.o.Insecure || c.o.PlainHTTP {
+		data.InsecureRegistries = []string{c.o.Registry}
+	}
+	b, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal daemon.json: %w", err)
+	}
+	return append(b, '\n'), nil
+}
+
+// DockerUnsupported returns the upstream registries which can not be
+// mirrored by the Docker registry-mirrors.
+func (c *Config) DockerUnsupported() []string {
+	var result []string
+	for _, r := range c.registries {
+		if r != utils.DockerHubRegistry {
+			result = append(result, r)
+		}
+	}
+	return result
+}
@@ -0,0 +1,122 @@
+package registryconfig
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testImages = []string{
+	"nginx:1.25",
+	"rancher/rancher:v2.8.0",
+	"quay.io/jetstack/cert-manager-controller:v1.13.2",
+	"registry.k8s.io/pause:3.9 private.example.io/library/pause 3.9",
+	"private.example.io/library/busybox:latest",
+}
+
+func Test_New(t *testing.T) {
+	_, err := New(&Options{Images: testImages})
+	assert.Error(t, err)
+
+	c, err := New(&Options{Registry: "private.example.io", Images: testImages})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []string{
+		"docker.io", "quay.io", "registry.k8s.io",
+	}, c.Registries())
+	assert.Equal(t, []string{"quay.io", "registry.k8s.io"}, c.DockerUnsupported())
+
+	// The mirror format line changing the repository path is skipped.
+	c, err = New(&Options{Registry: "private.example.io", Images: []string{
+		"ghcr.io/org/app:v1 private.example.io/library/app v1",
+		"gcr.io/google/pause:3.9 private.example.io/google/pause-amd64 3.9",
+		"quay.io/jetstack/cert-manager-cainjector:v1.13.2 private.example.io/jetstack/cert-manager-cainjector v1.13.2",
+	}})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []string{"quay.io"}, c.Registries())
+	c, err = New(&Options{Registry: "private.example.io", Project: "mirrored", Images: []string{
+		"ghcr.io/org/app:v1 private.example.io/mirrored/app v1",
+	}})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []string{"ghcr.io"}, c.Registries())
+}
+
+func Test_RKE2(t *testing.T) {
+	c, err := New(&Options{
+		Registry: "private.example.io",
+		Project:  "mirrored",
+		Images:   testImages[:2],
+		Insecure: true,
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	b, err := c.RKE2()
+	assert.NoError(t, err)
+	assert.Equal(t, `configs:
+  private.example.io:
+    tls:
+      insecure_skip_verify: true
+mirrors:
+  docker.io:
+    endpoint:
+    - https://private.example.io
+    rewrite:
+      ^.*/([^/]+)$: mirrored/$1
+  private.example.io:
+    endpoint:
+    - https://private.example.io
+    rewrite:
+      ^.*/([^/]+)$: mirrored/$1
+`, string(b))
+}
+
+func Test_Containerd(t *testing.T) {
+	c, err := New(&Options{
+		Registry:  "private.example.io:5000",
+		Images:    testImages[:3],
+		PlainHTTP: true,
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	hosts, err := c.Containerd()
+	assert.NoError(t, err)
+	assert.Len(t, hosts, 3)
+	assert.Equal(t, `server = "https://registry-1.docker.io"
+
+[host]
+  [host."http://private.example.io:5000"]
+    capabilities = ["pull", "resolve"]
+`, string(hosts["docker.io"]))
+	assert.Contains(t, string(hosts["quay.io"]), `server = "https://quay.io"`)
+	assert.Contains(t, string(hosts["private.example.io:5000"]),
+		`server = "http://private.example.io:5000"`)
+}
+
+func Test_Docker(t *testing.T) {
+	c, err := New(&Options{
+		Registry: "private.example.io",
+		Images:   testImages,
+		Insecure: true,
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	b, err := c.Docker()
+	assert.NoError(t, err)
+	assert.Equal(t, `{
+  "registry-mirrors": [
+    "https://private.example.io"
+  ],
+  "insecure-registries": [
+    "private.example.io"
+  ]
+}
+`, string(b))
+}
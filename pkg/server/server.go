@@ -0,0 +1,259 @@
+// Package server serves the Hangar archive as a read-only OCI registry,
+// which implements the pull API of the OCI distribution spec.
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cnrancher/hangar/pkg/hangar/archive"
+	"github.com/sirupsen/logrus"
+)
+
+// Options is the option to create the archive registry server.
+type Options struct {
+	// Archive is the Hangar archive file name.
+	Archive string
+	// Listen is the TCP address to listen, example: ":5000"
+	Listen string
+	// TLSCert and TLSKey are the certificate files to serve HTTPS (optional).
+	TLSCert string
+	TLSKey  string
+	// Username and Password enable the basic authentication (optional).
+	Username string
+	Password string
+}
+
+// Server is the read-only OCI registry server of the Hangar archive.
+type Server struct {
+	o       *Options
+	reader  *archive.Reader
+	storage *storage
+}
+
+// New opens the archive and creates the registry server, needs to call
+// Close() method to release resource after usage.
+func New(o *Options) (*Server, error) {
+	if (o.TLSCert == "") != (o.TLSKey == "") {
+		return nil, fmt.Errorf("both TLS certificate and key should be provided")
+	}
+	reader, err := archive.NewReader(o.Archive)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %q: %w", o.Archive, err)
+	}
+	s := &Server{
+		o:      o,
+		reader: reader,
+	}
+	s.storage, err = newStorage(reader)
+	if err != nil {
+		reader.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Repositories returns the repositories served by the server.
+func (s *Server) Repositories() []string {
+	return s.storage.repositoryNames()
+}
+
+// Serve listens and serves the registry API until the context is done.
+func (s *Server) Serve(ctx context.Context) error {
+	server := &http.Server{
+		Addr:              s.o.Listen,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: time.Second * 30,
+	}
+	errCh := make(chan error, 1)
+	go func() {
+		var err error
+		if s.o.TLSCert != "" {
+			logrus.Infof("Serving archive %q on https://%s", s.o.Archive, s.o.Listen)
+			err = server.ListenAndServeTLS(s.o.TLSCert, s.o.TLSKey)
+		} else {
+			logrus.Infof("Serving archive %q on http://%s", s.o.Archive, s.o.Listen)
+			err = server.ListenAndServe()
+		}
+		errCh <- err
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("failed to shutdown server: %w", err)
+	}
+	if err := <-errCh; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// Handler returns the HTTP handler of the registry API.
+func (s *Server) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logrus.Debugf("%s %s", r.Method, r.URL.Path)
+		w.Header().Set("Docker-Distribution-API-Version", "registry/2.0")
+		if !s.authorized(r) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="hangar"`)
+			writeError(w, http.StatusUnauthorized, "UNAUTHORIZED", "authentication required")
+			return
+		}
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			writeError(w, http.StatusMethodNotAllowed, "UNSUPPORTED",
+				"the registry is read-only")
+			return
+		}
+		s.serveRegistry(w, r)
+	})
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	if s.o.Username == "" {
+		return true
+	}
+	user, pass, ok := r.BasicAuth()
+	if !ok {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(user), []byte(s.o.Username)) == 1 &&
+		subtle.ConstantTimeCompare([]byte(pass), []byte(s.o.Password)) == 1
+}
+
+func (s *Server) serveRegistry(w http.ResponseWriter, r *http.Request) {
+	p := r.URL.Path
+	switch {
+	case p == "/v2/" || p == "/v2":
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte("{}"))
+		return
+	case p == "/v2/_catalog":
+		writeJSON(w, map[string][]string{
+			"repositories": s.storage.repositoryNames(),
+		})
+		return
+	case !strings.HasPrefix(p, "/v2/"):
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "not found")
+		return
+	}
+
+	// Path format: /v2/<name>/(manifests|blobs)/<reference>, /v2/<name>/tags/list
+	p = strings.TrimPrefix(p, "/v2/")
+	if name, ok := strings.CutSuffix(p, "/tags/list"); ok {
+		s.serveTags(w, name)
+		return
+	}
+	for _, kind := range []string{"/manifests/", "/blobs/"} {
+		i := strings.LastIndex(p, kind)
+		if i < 0 {
+			continue
+		}
+		name, reference := p[:i], p[i+len(kind):]
+		if kind == "/manifests/" {
+			s.serveManifest(w, r, name, reference)
+		} else {
+			s.serveBlob(w, r, name, reference)
+		}
+		return
+	}
+	writeError(w, http.StatusNotFound, "NOT_FOUND", "not found")
+}
+
+func (s *Server) serveTags(w http.ResponseWriter, name string) {
+	repo := s.storage.repository(name)
+	if repo == nil {
+		writeError(w, http.StatusNotFound, "NAME_UNKNOWN",
+			fmt.Sprintf("repository %q not found", name))
+		return
+	}
+	writeJSON(w, map[string]any{
+		"name": name,
+		"tags": repo.tagNames(),
+	})
+}
+
+func (s *Server) serveManifest(
+	w http.ResponseWriter, r *http.Request, name, reference string,
+) {
+	repo := s.storage.repository(name)
+	if repo == nil {
+		writeError(w, http.StatusNotFound, "NAME_UNKNOWN",
+			fmt.Sprintf("repository %q not found", name))
+		return
+	}
+	m := repo.manifest(reference)
+	if m == nil {
+		writeError(w, http.StatusNotFound, "MANIFEST_UNKNOWN",
+			fmt.Sprintf("manifest %q not found in repository %q", reference, name))
+		return
+	}
+	w.Header().Set("Content-Type", m.mediaType)
+	w.Header().Set("Docker-Content-Digest", m.digest.String())
+	w.Header().Set("Content-Length", fmt.Sprintf("%d", len(m.content)))
+	if r.Method == http.MethodHead {
+		return
+	}
+	w.Write(m.content)
+}
+
+func (s *Server) serveBlob(
+	w http.ResponseWriter, r *http.Request, name, reference string,
+) {
+	repo := s.storage.repository(name)
+	if repo == nil {
+		writeError(w, http.StatusNotFound, "NAME_UNKNOWN",
+			fmt.Sprintf("repository %q not found", name))
+		return
+	}
+	d, ok := repo.blob(reference)
+	if !ok {
+		writeError(w, http.StatusNotFound, "BLOB_UNKNOWN",
+			fmt.Sprintf("blob %q not found in repository %q", reference, name))
+		return
+	}
+	sr, err := s.reader.OpenFile(blobPath(d))
+	if err != nil {
+		logrus.Warnf("Failed to open blob %q: %v", d, err)
+		writeError(w, http.StatusNotFound, "BLOB_UNKNOWN",
+			fmt.Sprintf("blob %q not found in archive", reference))
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Docker-Content-Digest", d.String())
+	w.Header().Set("Etag", fmt.Sprintf("%q", d.String()))
+	// ServeContent handles the HEAD and Range requests.
+	http.ServeContent(w, r, "", time.Time{}, sr)
+}
+
+// Close releases the archive reader.
+func (s *Server) Close() error {
+	return s.reader.Close()
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logrus.Debugf("failed to write response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{
+		"errors": []map[string]string{
+			{"code": code, "message": message},
+		},
+	})
+}
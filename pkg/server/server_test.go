@@ -0,0 +1,152 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cnrancher/hangar/pkg/hangar/archive"
+	imagemanifest "github.com/containers/image/v5/manifest"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestArchive creates the archive with a single-platform image.
+func newTestArchive(t *testing.T) (string, digest.Digest, digest.Digest) {
+	t.Helper()
+	dir := t.TempDir()
+	blobDir := filepath.Join(dir, "cache", archive.SharedBlobDir, "sha256")
+	assert.NoError(t, os.MkdirAll(blobDir, 0755))
+	writeBlob := func(b []byte) digest.Digest {
+		d := digest.FromBytes(b)
+		assert.NoError(t, os.WriteFile(filepath.Join(blobDir, d.Encoded()), b, 0644))
+		return d
+	}
+	layer := writeBlob([]byte("hello world"))
+	config := writeBlob([]byte(`{"architecture":"amd64","os":"linux"}`))
+	m, err := json.Marshal(imagemanifest.Schema2{
+		SchemaVersion: 2,
+		MediaType:     imagemanifest.DockerV2Schema2MediaType,
+		ConfigDescriptor: imagemanifest.Schema2Descriptor{
+			MediaType: imagemanifest.DockerV2Schema2ConfigMediaType,
+			Digest:    config,
+			Size:      37,
+		},
+		LayersDescriptors: []imagemanifest.Schema2Descriptor{{
+			MediaType: imagemanifest.DockerV2Schema2LayerMediaType,
+			Digest:    layer,
+			Size:      11,
+		}},
+	})
+	assert.NoError(t, err)
+	manifestDigest := writeBlob(m)
+
+	name := filepath.Join(dir, "archive.zip")
+	w, err := archive.NewWriter(name)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.NoError(t, w.Write(filepath.Join(dir, "cache")))
+	index := archive.NewIndex()
+	index.Append(&archive.Image{
+		Source:   "docker.io/library/nginx",
+		Tag:      "1.25",
+		ArchList: []string{"amd64"},
+		OsList:   []string{"linux"},
+		Images: []archive.ImageSpec{{
+			Arch:   "amd64",
+			OS:     "linux",
+			Layers: []digest.Digest{layer},
+			Config: config,
+			Digest: manifestDigest,
+		}},
+	})
+	assert.NoError(t, w.WriteIndex(index))
+	assert.NoError(t, w.Close())
+	return name, manifestDigest, layer
+}
+
+func Test_Server(t *testing.T) {
+	name, manifestDigest, layer := newTestArchive(t)
+	s, err := New(&Options{
+		Archive:  name,
+		Username: "admin",
+		Password: "password",
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer s.Close()
+	assert.Equal(t, []string{"library/nginx"}, s.Repositories())
+
+	server := httptest.NewServer(s.Handler())
+	defer server.Close()
+	get := func(method, path string, header map[string]string) *http.Response {
+		req, _ := http.NewRequest(method, server.URL+path, nil)
+		req.SetBasicAuth("admin", "password")
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		return resp
+	}
+
+	resp, err := http.Get(server.URL + "/v2/")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	resp = get(http.MethodGet, "/v2/", nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp = get(http.MethodPut, "/v2/library/nginx/manifests/1.25", nil)
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+
+	// Manifest list of the tag.
+	resp = get(http.MethodGet, "/v2/nginx/manifests/1.25", nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, imagemanifest.DockerV2ListMediaType, resp.Header.Get("Content-Type"))
+	b, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, digest.FromBytes(b).String(), resp.Header.Get("Docker-Content-Digest"))
+	list, err := imagemanifest.Schema2ListFromManifest(b)
+	if assert.NoError(t, err) && assert.Len(t, list.Manifests, 1) {
+		assert.Equal(t, manifestDigest, list.Manifests[0].Digest)
+		assert.Equal(t, "amd64", list.Manifests[0].Platform.Architecture)
+	}
+
+	// Platform specific manifest by digest.
+	resp = get(http.MethodHead, "/v2/docker.io/library/nginx/manifests/"+manifestDigest.String(), nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, imagemanifest.DockerV2Schema2MediaType, resp.Header.Get("Content-Type"))
+	resp = get(http.MethodGet, "/v2/library/nginx/manifests/latest", nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// Blob with range request.
+	resp = get(http.MethodGet, "/v2/library/nginx/blobs/"+layer.String(),
+		map[string]string{"Range": "bytes=6-10"})
+	assert.Equal(t, http.StatusPartialContent, resp.StatusCode)
+	b, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, "world", string(b))
+	resp = get(http.MethodGet, "/v2/library/nginx/blobs/"+digest.FromString("x").String(), nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// Tags and catalog.
+	resp = get(http.MethodGet, "/v2/library/nginx/tags/list", nil)
+	tags := struct {
+		Tags []string `json:"tags"`
+	}{}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&tags))
+	resp.Body.Close()
+	assert.Equal(t, []string{"1.25"}, tags.Tags)
+	resp = get(http.MethodGet, "/v2/_catalog", nil)
+	catalog := map[string][]string{}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&catalog))
+	resp.Body.Close()
+	assert.Equal(t, []string{"library/nginx"}, catalog["repositories"])
+}
@@ -0,0 +1,257 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"sort"
+
+	"github.com/cnrancher/hangar/pkg/hangar/archive"
+	"github.com/cnrancher/hangar/pkg/utils"
+	imagemanifest "github.com/containers/image/v5/manifest"
+	"github.com/opencontainers/go-digest"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// manifest is the manifest (index) served by the registry.
+type manifest struct {
+	mediaType string
+	digest    digest.Digest
+	content   []byte
+}
+
+// repository is the image repository in archive.
+type repository struct {
+	tags      map[string]*manifest
+	manifests map[digest.Digest]*manifest
+	blobs     map[digest.Digest]bool
+}
+
+func (r *repository) tagNames() []string {
+	tags := make([]string, 0, len(r.tags))
+	for t := range r.tags {
+		tags = append(tags, t)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// manifest returns the manifest by tag or digest, returns nil if not found.
+func (r *repository) manifest(reference string) *manifest {
+	if d, err := digest.Parse(reference); err == nil {
+		return r.manifests[d]
+	}
+	return r.tags[reference]
+}
+
+// blob returns the digest of the blob if it exists in repository.
+func (r *repository) blob(reference string) (digest.Digest, bool) {
+	d, err := digest.Parse(reference)
+	if err != nil {
+		return "", false
+	}
+	// The platform specific manifests are also stored as blobs in archive.
+	return d, r.blobs[d]
+}
+
+// storage indexes the manifests and blobs of the archive by repository.
+type storage struct {
+	repositories map[string]*repository
+	// names is the repository names without alias.
+	names []string
+}
+
+func newStorage(reader *archive.Reader) (*storage, error) {
+	b, err := reader.Index()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get index from archive: %w", err)
+	}
+	index, err := archive.UnmarshalIndex(b)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &storage{
+		repositories: map[string]*repository{},
+	}
+	for _, img := range index.List {
+		if err := s.add(reader, img); err != nil {
+			return nil, fmt.Errorf("failed to load image [%v]: %w",
+				img.Reference(), err)
+		}
+	}
+	sort.Strings(s.names)
+	return s, nil
+}
+
+func (s *storage) repository(name string) *repository {
+	return s.repositories[name]
+}
+
+func (s *storage) repositoryNames() []string {
+	return s.names
+}
+
+// repositoryAlias returns the names of the repository, the image
+// "docker.io/library/nginx" can be pulled by "library/nginx", "nginx"
+// and "docker.io/library/nginx".
+func repositoryAlias(source string) []string {
+	project := utils.GetProjectName(source)
+	name := utils.GetImageName(source)
+	alias := []string{
+		fmt.Sprintf("%s/%s", project, name),
+		fmt.Sprintf("%s/%s/%s", utils.GetRegistryName(source), project, name),
+	}
+	if project == "library" {
+		alias = append(alias, name)
+	}
+	return alias
+}
+
+func (s *storage) add(reader *archive.Reader, img *archive.Image) error {
+	alias := repositoryAlias(img.Source)
+	repo := s.repositories[alias[0]]
+	if repo == nil {
+		repo = &repository{
+			tags:      map[string]*manifest{},
+			manifests: map[digest.Digest]*manifest{},
+			blobs:     map[digest.Digest]bool{},
+		}
+		s.names = append(s.names, alias[0])
+	}
+	for _, a := range alias {
+		if _, ok := s.repositories[a]; !ok {
+			s.repositories[a] = repo
+		}
+	}
+
+	var platforms []*manifest
+	for _, spec := range img.Images {
+		m, err := readManifest(reader, spec.Digest)
+		if err != nil {
+			return err
+		}
+		repo.manifests[m.digest] = m
+		repo.blobs[m.digest] = true
+		if spec.Config != "" {
+			repo.blobs[spec.Config] = true
+		}
+		for _, l := range spec.Layers {
+			repo.blobs[l] = true
+		}
+		platforms = append(platforms, m)
+	}
+	if len(platforms) == 0 {
+		return nil
+	}
+
+	var m *manifest
+	if img.Manifest != "" {
+		// Serve the raw manifest of the digest-pinned image.
+		m = &manifest{
+			mediaType: imagemanifest.GuessMIMEType([]byte(img.Manifest)),
+			digest:    digest.FromString(img.Manifest),
+			content:   []byte(img.Manifest),
+		}
+	} else {
+		var err error
+		m, err = newManifestList(img.Images, platforms)
+		if err != nil {
+			return err
+		}
+	}
+	repo.manifests[m.digest] = m
+	if img.Tag != "" {
+		repo.tags[img.Tag] = m
+	}
+	return nil
+}
+
+// readManifest reads the platform specific manifest from the shared blobs.
+func readManifest(reader *archive.Reader, d digest.Digest) (*manifest, error) {
+	sr, err := reader.OpenFile(blobPath(d))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open manifest %q: %w", d, err)
+	}
+	b, err := io.ReadAll(sr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest %q: %w", d, err)
+	}
+	return &manifest{
+		mediaType: imagemanifest.GuessMIMEType(b),
+		digest:    d,
+		content:   b,
+	}, nil
+}
+
+// newManifestList creates the manifest list of the platform specific
+// manifests, the OCI image index is used if not all the manifests are
+// docker v2 schema2.
+func newManifestList(
+	specs []archive.ImageSpec, platforms []*manifest,
+) (*manifest, error) {
+	docker := true
+	for _, p := range platforms {
+		if p.mediaType != imagemanifest.DockerV2Schema2MediaType {
+			docker = false
+		}
+	}
+
+	var (
+		b         []byte
+		err       error
+		mediaType string
+	)
+	if docker {
+		var components []imagemanifest.Schema2ManifestDescriptor
+		for i, p := range platforms {
+			components = append(components, imagemanifest.Schema2ManifestDescriptor{
+				Schema2Descriptor: imagemanifest.Schema2Descriptor{
+					MediaType: p.mediaType,
+					Size:      int64(len(p.content)),
+					Digest:    p.digest,
+				},
+				Platform: imagemanifest.Schema2PlatformSpec{
+					Architecture: specs[i].Arch,
+					OS:           specs[i].OS,
+					OSVersion:    specs[i].OSVersion,
+					OSFeatures:   specs[i].OSFeatures,
+					Variant:      specs[i].Variant,
+				},
+			})
+		}
+		mediaType = imagemanifest.DockerV2ListMediaType
+		b, err = imagemanifest.Schema2ListFromComponents(components).Serialize()
+	} else {
+		var components []imgspecv1.Descriptor
+		for i, p := range platforms {
+			components = append(components, imgspecv1.Descriptor{
+				MediaType: p.mediaType,
+				Size:      int64(len(p.content)),
+				Digest:    p.digest,
+				Platform: &imgspecv1.Platform{
+					Architecture: specs[i].Arch,
+					OS:           specs[i].OS,
+					OSVersion:    specs[i].OSVersion,
+					OSFeatures:   specs[i].OSFeatures,
+					Variant:      specs[i].Variant,
+				},
+			})
+		}
+		mediaType = imgspecv1.MediaTypeImageIndex
+		b, err = imagemanifest.OCI1IndexFromComponents(components, nil).Serialize()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create manifest list: %w", err)
+	}
+	return &manifest{
+		mediaType: mediaType,
+		digest:    digest.FromBytes(b),
+		content:   b,
+	}, nil
+}
+
+// blobPath returns the path of the blob in archive.
+func blobPath(d digest.Digest) string {
+	return path.Join(archive.SharedBlobDir, string(d.Algorithm()), d.Encoded())
+}
@@ -0,0 +1,243 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/containers/image/v5/types"
+	"github.com/opencontainers/go-digest"
+)
+
+// BlobCache caches the manifests and blobs read from the source registry
+// in a local directory, so the source image is pulled only once when
+// copying to multiple destinations in parallel.
+// The methods of the nil BlobCache do nothing.
+type BlobCache struct {
+	dir string
+
+	mu        sync.Mutex
+	blobs     map[digest.Digest]*cachedBlob
+	manifests map[string]*cachedManifest
+}
+
+type cachedBlob struct {
+	// done is closed after the blob fetched.
+	done chan struct{}
+	path string
+	size int64
+	err  error
+}
+
+type cachedManifest struct {
+	done chan struct{}
+	raw  []byte
+	mime string
+	err  error
+}
+
+// NewBlobCache creates the blob cache in a temporary directory, needs to
+// call Close() to remove the cached blobs.
+func NewBlobCache() (*BlobCache, error) {
+	dir, err := os.MkdirTemp("", "hangar-blobs-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	return &BlobCache{
+		dir:       dir,
+		blobs:     make(map[digest.Digest]*cachedBlob),
+		manifests: make(map[string]*cachedManifest),
+	}, nil
+}
+
+// Close removes the cached blobs.
+func (c *BlobCache) Close() error {
+	if c == nil {
+		return nil
+	}
+	return os.RemoveAll(c.dir)
+}
+
+type blobCacheKey struct{}
+
+// WithBlobCache returns the context carrying the blob cache to read the
+// source images copied within the context.
+func WithBlobCache(ctx context.Context, c *BlobCache) context.Context {
+	if c == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, blobCacheKey{}, c)
+}
+
+func blobCacheFromContext(ctx context.Context) *BlobCache {
+	c, _ := ctx.Value(blobCacheKey{}).(*BlobCache)
+	return c
+}
+
+// Reference returns the image reference whose manifests and blobs are read
+// from the cache, the reference is returned directly if the cache is nil.
+func (c *BlobCache) Reference(ref types.ImageReference) types.ImageReference {
+	if c == nil {
+		return ref
+	}
+	return &cachedReference{
+		ImageReference: ref,
+		cache:          c,
+	}
+}
+
+// manifest returns the cached manifest, the fetch function is called only
+// once for the same key unless it failed.
+func (c *BlobCache) manifest(
+	ctx context.Context, key string, fetch func() ([]byte, string, error),
+) ([]byte, string, error) {
+	c.mu.Lock()
+	m, ok := c.manifests[key]
+	if !ok {
+		m = &cachedManifest{done: make(chan struct{})}
+		c.manifests[key] = m
+	}
+	c.mu.Unlock()
+	if ok {
+		select {
+		case <-m.done:
+		case <-ctx.Done():
+			return nil, "", ctx.Err()
+		}
+		if m.err == nil {
+			return m.raw, m.mime, nil
+		}
+		return fetch()
+	}
+
+	m.raw, m.mime, m.err = fetch()
+	if m.err != nil {
+		// Let the next caller fetch the manifest again.
+		c.mu.Lock()
+		delete(c.manifests, key)
+		c.mu.Unlock()
+	}
+	close(m.done)
+	return m.raw, m.mime, m.err
+}
+
+// blob returns the cached blob file, the fetch function is called only
+// once for the same digest unless it failed.
+func (c *BlobCache) blob(
+	ctx context.Context, dig digest.Digest, fetch func() (io.ReadCloser, int64, error),
+) (io.ReadCloser, int64, error) {
+	c.mu.Lock()
+	b, ok := c.blobs[dig]
+	if !ok {
+		b = &cachedBlob{
+			done: make(chan struct{}),
+			path: filepath.Join(c.dir, dig.Encoded()),
+		}
+		c.blobs[dig] = b
+	}
+	c.mu.Unlock()
+	if ok {
+		select {
+		case <-b.done:
+		case <-ctx.Done():
+			return nil, 0, ctx.Err()
+		}
+		if b.err != nil {
+			return fetch()
+		}
+		f, err := os.Open(b.path)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to open cached blob: %w", err)
+		}
+		return f, b.size, nil
+	}
+
+	b.size, b.err = c.writeBlob(b.path, fetch)
+	if b.err != nil {
+		c.mu.Lock()
+		delete(c.blobs, dig)
+		c.mu.Unlock()
+		close(b.done)
+		return nil, 0, b.err
+	}
+	close(b.done)
+	f, err := os.Open(b.path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open cached blob: %w", err)
+	}
+	return f, b.size, nil
+}
+
+func (c *BlobCache) writeBlob(
+	name string, fetch func() (io.ReadCloser, int64, error),
+) (int64, error) {
+	rc, _, err := fetch()
+	if err != nil {
+		return 0, err
+	}
+	defer rc.Close()
+	f, err := os.Create(name)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create cached blob: %w", err)
+	}
+	n, err := io.Copy(f, rc)
+	if err != nil {
+		f.Close()
+		return 0, err
+	}
+	if err := f.Close(); err != nil {
+		return 0, fmt.Errorf("failed to write cached blob: %w", err)
+	}
+	return n, nil
+}
+
+type cachedReference struct {
+	types.ImageReference
+
+	cache *BlobCache
+}
+
+func (r *cachedReference) NewImageSource(
+	ctx context.Context, sys *types.SystemContext,
+) (types.ImageSource, error) {
+	src, err := r.ImageReference.NewImageSource(ctx, sys)
+	if err != nil {
+		return nil, err
+	}
+	return &cachedSource{
+		ImageSource: src,
+		cache:       r.cache,
+	}, nil
+}
+
+type cachedSource struct {
+	types.ImageSource
+
+	cache *BlobCache
+}
+
+func (s *cachedSource) GetManifest(
+	ctx context.Context, instanceDigest *digest.Digest,
+) ([]byte, string, error) {
+	key := s.Reference().StringWithinTransport()
+	if instanceDigest != nil {
+		key += "@" + instanceDigest.String()
+	}
+	return s.cache.manifest(ctx, key, func() ([]byte, string, error) {
+		return s.ImageSource.GetManifest(ctx, instanceDigest)
+	})
+}
+
+func (s *cachedSource) GetBlob(
+	ctx context.Context, info types.BlobInfo, cache types.BlobInfoCache,
+) (io.ReadCloser, int64, error) {
+	if info.Digest == "" || info.Digest.Validate() != nil {
+		return s.ImageSource.GetBlob(ctx, info, cache)
+	}
+	return s.cache.blob(ctx, info.Digest, func() (io.ReadCloser, int64, error) {
+		return s.ImageSource.GetBlob(ctx, info, cache)
+	})
+}
@@ -0,0 +1,81 @@
+package source
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_BlobCache(t *testing.T) {
+	c, err := NewBlobCache()
+	if !assert.NoError(t, err) {
+		return
+	}
+	data := []byte("blob data")
+	dig := digest.FromBytes(data)
+	var fetched atomic.Int32
+	fetch := func() (io.ReadCloser, int64, error) {
+		fetched.Add(1)
+		return io.NopCloser(bytes.NewReader(data)), -1, nil
+	}
+
+	// The blob is fetched once when read by multiple destinations.
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rc, size, err := c.blob(context.Background(), dig, fetch)
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer rc.Close()
+			b, err := io.ReadAll(rc)
+			assert.NoError(t, err)
+			assert.Equal(t, data, b)
+			assert.Equal(t, int64(len(data)), size)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), fetched.Load())
+
+	// The failed blob is fetched again.
+	other := digest.FromString("other")
+	_, _, err = c.blob(context.Background(), other, func() (io.ReadCloser, int64, error) {
+		return nil, 0, errors.New("failed")
+	})
+	assert.Error(t, err)
+	rc, _, err := c.blob(context.Background(), other, fetch)
+	if assert.NoError(t, err) {
+		rc.Close()
+	}
+	assert.Equal(t, int32(2), fetched.Load())
+
+	raw, mime, err := c.manifest(context.Background(), "key", func() ([]byte, string, error) {
+		return []byte("{}"), "application/json", nil
+	})
+	assert.NoError(t, err)
+	raw2, mime2, err := c.manifest(context.Background(), "key", func() ([]byte, string, error) {
+		return nil, "", errors.New("should not be fetched again")
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, raw, raw2)
+	assert.Equal(t, mime, mime2)
+
+	dir := c.dir
+	assert.NoError(t, c.Close())
+	_, err = os.Stat(dir)
+	assert.True(t, os.IsNotExist(err))
+
+	var nilCache *BlobCache
+	assert.Nil(t, nilCache.Reference(nil))
+	assert.NoError(t, nilCache.Close())
+}
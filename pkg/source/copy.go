@@ -12,17 +12,23 @@ import (
 	"github.com/cnrancher/hangar/pkg/copy"
 	"github.com/cnrancher/hangar/pkg/destination"
 	"github.com/cnrancher/hangar/pkg/hangar/archive"
+	"github.com/cnrancher/hangar/pkg/logging"
 	"github.com/cnrancher/hangar/pkg/manifest"
+	"github.com/cnrancher/hangar/pkg/progress"
+	"github.com/cnrancher/hangar/pkg/registries"
+	"github.com/cnrancher/hangar/pkg/tracing"
 	"github.com/cnrancher/hangar/pkg/types"
 	"github.com/cnrancher/hangar/pkg/utils"
 	"github.com/containers/common/pkg/retry"
 	imagecopy "github.com/containers/image/v5/copy"
+	"github.com/containers/image/v5/docker/reference"
 	imagemanifest "github.com/containers/image/v5/manifest"
 	"github.com/containers/image/v5/signature"
 	"github.com/containers/image/v5/transports/alltransports"
 	imagetypes "github.com/containers/image/v5/types"
 	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 func (s *Source) copyDockerV2ListMediaType(
@@ -41,6 +47,10 @@ func (s *Source) copyDockerV2ListMediaType(
 		variant := m.Platform.Variant
 		dig := m.Digest
 		mime := m.MediaType
+		ctx := logging.WithFields(ctx, logrus.Fields{
+			logging.FieldPlatform: logging.Platform(osInfo, arch, variant),
+			logging.FieldDigest:   dig,
+		})
 
 		// skip image
 		if len(sets["os"]) != 0 && osInfo != "" && !sets["os"][osInfo] {
@@ -53,7 +63,7 @@ func (s *Source) copyDockerV2ListMediaType(
 			continue
 		}
 		if dest.HaveDigest(m.Digest) {
-			logrus.Debugf("dest already have digest %v, skip copy", m.Digest)
+			logging.FromContext(ctx).Debugf("dest already have digest %v, skip copy", m.Digest)
 			copiedNum++
 			continue
 		}
@@ -74,7 +84,7 @@ func (s *Source) copyDockerV2ListMediaType(
 
 		err = copyImage(
 			ctx, sourceRef, destRef, s.systemCtx, dest.SystemContext(),
-			policy, mime)
+			policy, mime, s.progress)
 		if err != nil {
 			errs = append(errs, err)
 			continue
@@ -171,6 +181,10 @@ func (s *Source) copyMediaTypeImageIndex(
 		osFeatures := m.Platform.OSFeatures
 		variant := m.Platform.Variant
 		dig := m.Digest
+		ctx := logging.WithFields(ctx, logrus.Fields{
+			logging.FieldPlatform: logging.Platform(osInfo, arch, variant),
+			logging.FieldDigest:   dig,
+		})
 
 		// skip image
 		if len(sets["os"]) != 0 && osInfo != "" && !sets["os"][osInfo] {
@@ -183,7 +197,7 @@ func (s *Source) copyMediaTypeImageIndex(
 			continue
 		}
 		if dest.HaveDigest(m.Digest) {
-			logrus.Debugf("dest already have digest %v, skip copy", m.Digest)
+			logging.FromContext(ctx).Debugf("dest already have digest %v, skip copy", m.Digest)
 			copiedNum++
 			continue
 		}
@@ -204,7 +218,7 @@ func (s *Source) copyMediaTypeImageIndex(
 
 		err = copyImage(
 			ctx, sourceRef, destRef, s.systemCtx, dest.SystemContext(),
-			policy, mime)
+			policy, mime, s.progress)
 		if err != nil {
 			errs = append(errs, err)
 			continue
@@ -311,7 +325,10 @@ func (s *Source) copyDockerV2Schema2MediaType(
 		return nil
 	}
 	if dest.HaveDigest(s.manifestDigest) {
-		logrus.Debugf("dest already have digest %v, skip copy", s.manifestDigest)
+		logging.FromContext(ctx).WithFields(logrus.Fields{
+			logging.FieldPlatform: logging.Platform(osInfo, arch, variant),
+			logging.FieldDigest:   s.manifestDigest,
+		}).Debugf("dest already have digest %v, skip copy", s.manifestDigest)
 		return nil
 	}
 
@@ -326,7 +343,7 @@ func (s *Source) copyDockerV2Schema2MediaType(
 	}
 	err = copyImage(
 		ctx, sourceRef, destRef, s.systemCtx, dest.SystemContext(),
-		policy, s.mime)
+		policy, s.mime, s.progress)
 	if err != nil {
 		return err
 	}
@@ -384,7 +401,7 @@ func (s *Source) copyDockerV2Schema1MediaType(
 	}
 	err = copyImage(
 		ctx, sourceRef, destRef, s.systemCtx, dest.SystemContext(),
-		policy, s.mime)
+		policy, s.mime, s.progress)
 	if err != nil {
 		return err
 	}
@@ -469,7 +486,7 @@ func (s *Source) copyMediaTypeImageManifest(
 	}
 	err = copyImage(
 		ctx, sourceRef, destRef, s.systemCtx, dest.SystemContext(),
-		policy, s.mime)
+		policy, s.mime, s.progress)
 	if err != nil {
 		return err
 	}
@@ -509,13 +526,34 @@ func (s *Source) GetCopiedImage() *archive.Image {
 	list := &archive.Image{
 		Source:   fmt.Sprintf("%s/%s/%s", s.registry, s.project, s.name),
 		Tag:      s.tag,
+		Digest:   s.digest,
 		ArchList: archies,
 		OsList:   oses,
 		Images:   s.copiedList,
 	}
+	switch s.mime {
+	case imagemanifest.DockerV2Schema1MediaType,
+		imagemanifest.DockerV2Schema1SignedMediaType:
+		// The digest of schema1 image was changed during copy.
+	default:
+		// Record the raw manifest only if all images of the digest-pinned
+		// image were copied, so it can be loaded without changing the digest.
+		if s.digest != "" && len(s.copiedList) == s.imageNum() {
+			list.Manifest = string(s.manifestRaw)
+		}
+	}
 	return list
 }
 
+// progressDestination returns the registry name of the destination
+// reference, or the transport name if the destination is not a registry.
+func progressDestination(ref imagetypes.ImageReference) string {
+	if named := ref.DockerReference(); named != nil {
+		return reference.Domain(named)
+	}
+	return ref.Transport().Name()
+}
+
 func copyImage(
 	ctx context.Context,
 	sourceRef imagetypes.ImageReference,
@@ -524,10 +562,18 @@ func copyImage(
 	destCtx *imagetypes.SystemContext,
 	policy *signature.Policy,
 	sourceMIME string,
-) error {
+	p *progress.Image,
+) (err error) {
+	ctx, span := tracing.Start(ctx, "copy image",
+		attribute.String("source", sourceRef.StringWithinTransport()),
+		attribute.String("destination", destRef.StringWithinTransport()))
+	defer func() { tracing.End(span, err) }()
+	progressCh, stopProgress := p.Channel(progressDestination(destRef))
+	defer stopProgress()
 	copyOpts := &imagecopy.Options{
 		// TODO: Add sign here if needed.
 		ReportWriter:         nil,
+		Progress:             progressCh,
 		SourceCtx:            utils.CopySystemContext(sourceCtx),
 		DestinationCtx:       utils.CopySystemContext(destCtx),
 		ProgressInterval:     time.Second,
@@ -543,7 +589,10 @@ func copyImage(
 		copyOpts.ForceManifestMIMEType = imagemanifest.DockerV2Schema2MediaType
 	}
 
-	var err error
+	// The blobs are read from the local cache if copying to multiple
+	// destinations, and within the bandwidth limits.
+	sourceRef = registries.FromContext(ctx).LimitReference(
+		blobCacheFromContext(ctx).Reference(sourceRef), destRef)
 	copier := copy.NewCopier(&copy.CopierOption{
 		Options: copyOpts,
 		RetryOptions: &retry.Options{
@@ -556,6 +605,8 @@ func copyImage(
 		Policy:    policy,
 	})
 	_, err = copier.Copy(ctx)
+	p.AddRetries(copier.Retries())
+	span.SetAttributes(attribute.Int("retries", copier.Retries()))
 	return err
 }
 
@@ -6,9 +6,13 @@ import (
 	"fmt"
 	"strings"
 
+	_ "github.com/cnrancher/hangar/pkg/containerd" // Register containerd transport.
 	"github.com/cnrancher/hangar/pkg/destination"
 	"github.com/cnrancher/hangar/pkg/hangar/archive"
+	"github.com/cnrancher/hangar/pkg/logging"
 	"github.com/cnrancher/hangar/pkg/manifest"
+	"github.com/cnrancher/hangar/pkg/progress"
+	"github.com/cnrancher/hangar/pkg/tracing"
 	"github.com/cnrancher/hangar/pkg/types"
 	"github.com/cnrancher/hangar/pkg/utils"
 	imagemanifest "github.com/containers/image/v5/manifest"
@@ -17,12 +21,12 @@ import (
 	imagetypes "github.com/containers/image/v5/types"
 	"github.com/opencontainers/go-digest"
 	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
-	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // Source represents the source image to be copied.
 // The type of the source image can be:
-// docker, docker-daemon, docker-archive, oci or dir
+// docker, docker-daemon, docker-archive, oci, dir or containerd
 type Source struct {
 	// imageType
 	imageType types.ImageType
@@ -69,6 +73,8 @@ type Source struct {
 
 	// manifest digest
 	manifestDigest digest.Digest
+	// manifestRaw is the raw manifest of the source image
+	manifestRaw []byte
 
 	systemCtx *imagetypes.SystemContext
 
@@ -80,6 +86,9 @@ type Source struct {
 
 	// copied OS list
 	copiedOS map[string]bool
+
+	// progress reports the copy progress of the image (optional)
+	progress *progress.Image
 }
 
 // Option is used for create the Source object.
@@ -98,7 +107,9 @@ type Option struct {
 	// Image tag, need to provide if Type is docker / docker-daemon / docker-archive
 	Tag string
 	// Digest is used to identify the Digest of the image to be copied,
-	// only available when Type is docker.
+	// only available when Type is docker / containerd.
+	// The image will be pulled by digest and the fetched manifest digest
+	// will be verified if the digest is provided, even if the Tag is set.
 	Digest digest.Digest
 
 	SystemContext *imagetypes.SystemContext
@@ -137,23 +148,45 @@ func NewSource(o *Option) (*Source, error) {
 		if err != nil {
 			return nil, err
 		}
+	case types.TypeContainerd:
+		s, err = newSourceFromContainerd(o)
+		if err != nil {
+			return nil, err
+		}
 	default:
 		return nil, types.ErrInvalidType
 	}
 	s.copiedArch = make(map[string]bool)
 	s.copiedOS = make(map[string]bool)
+	// Init the reference name to be used before the manifest initialized.
+	if err = s.initReferenceName(); err != nil {
+		return nil, err
+	}
 
 	return s, nil
 }
 
 // Init initialize the source image manifest.
-func (s *Source) Init(ctx context.Context) error {
+func (s *Source) Init(ctx context.Context) (err error) {
 	if err := s.initReferenceName(); err != nil {
 		return err
 	}
+	ctx, span := tracing.Start(ctx, "init source",
+		attribute.String("image", s.ReferenceNameWithoutTransport()))
+	defer func() { tracing.End(span, err) }()
 	return s.initManifest(ctx)
 }
 
+// Clone returns a copy of the initialized source image without the copied
+// images, used to copy the source image to multiple destinations in parallel.
+func (s *Source) Clone() *Source {
+	n := *s
+	n.copiedList = nil
+	n.copiedArch = make(map[string]bool)
+	n.copiedOS = make(map[string]bool)
+	return &n
+}
+
 // Type returns the type of the image
 func (s *Source) Type() types.ImageType {
 	return s.imageType
@@ -179,6 +212,41 @@ func (s *Source) Tag() string {
 	return s.tag
 }
 
+// Digest returns the digest of the digest-pinned source image,
+// returns empty string if the source image is not digest-pinned.
+func (s *Source) Digest() digest.Digest {
+	return s.digest
+}
+
+// ManifestDigest returns the manifest digest of the source image.
+func (s *Source) ManifestDigest() digest.Digest {
+	return s.manifestDigest
+}
+
+// ManifestRaw returns the raw manifest of the source image.
+func (s *Source) ManifestRaw() []byte {
+	return s.manifestRaw
+}
+
+// CanPreserveDigest checks whether the digest-pinned source image can be
+// copied without changing its digest, which requires all the images
+// in source manifest match the specified arch & os set.
+func (s *Source) CanPreserveDigest(set map[string]map[string]bool) bool {
+	switch s.mime {
+	case imagemanifest.DockerV2Schema1MediaType,
+		imagemanifest.DockerV2Schema1SignedMediaType:
+		// The mediaType of schema1 image will be changed during copy.
+		return false
+	case "":
+		// The source image is not initialized.
+		return false
+	}
+	if s.digest == "" {
+		return false
+	}
+	return len(s.ImageBySet(set).Images) == s.imageNum()
+}
+
 // ReferenceName returns the reference with transport of the source image.
 //
 //	Example:
@@ -237,6 +305,12 @@ func (s *Source) SystemContext() *imagetypes.SystemContext {
 	return s.systemCtx
 }
 
+// SetProgress sets the progress to report the blob copy progress of the
+// source image.
+func (s *Source) SetProgress(p *progress.Image) {
+	s.progress = p
+}
+
 func (s *Source) Copy(
 	ctx context.Context,
 	dest *destination.Destination,
@@ -250,7 +324,7 @@ func (s *Source) Copy(
 		if err != nil {
 			return err
 		}
-		logrus.Debugf("copied [%d] images", num)
+		logging.FromContext(ctx).Debugf("copied [%d] images", num)
 		if num == 0 {
 			return utils.ErrNoAvailableImage
 		}
@@ -261,7 +335,7 @@ func (s *Source) Copy(
 		if err != nil {
 			return err
 		}
-		logrus.Debugf("copied [%d] images", num)
+		logging.FromContext(ctx).Debugf("copied [%d] images", num)
 		if num == 0 {
 			return utils.ErrNoAvailableImage
 		}
@@ -331,14 +405,37 @@ func newSourceFromDocker(o *Option) (*Source, error) {
 		project:   o.Project,
 		name:      o.Name,
 		tag:       o.Tag,
+		digest:    o.Digest,
 		systemCtx: o.SystemContext,
 	}
-	if s.tag == "" {
-		if o.Digest != "" {
-			s.digest = o.Digest
-		} else {
-			s.tag = "latest"
-		}
+	if s.tag == "" && s.digest == "" {
+		s.tag = "latest"
+	}
+	if s.project == "" {
+		s.project = "library"
+	}
+	if s.registry == "" {
+		s.registry = "docker.io"
+	}
+
+	return s, nil
+}
+
+func newSourceFromContainerd(o *Option) (*Source, error) {
+	if o.Type != types.TypeContainerd {
+		return nil, types.ErrInvalidType
+	}
+	s := &Source{
+		imageType: o.Type,
+		registry:  o.Registry,
+		project:   o.Project,
+		name:      o.Name,
+		tag:       o.Tag,
+		digest:    o.Digest,
+		systemCtx: o.SystemContext,
+	}
+	if s.tag == "" && s.digest == "" {
+		s.tag = "latest"
 	}
 	if s.project == "" {
 		s.project = "library"
@@ -396,18 +493,20 @@ func newSourceFromDockerArchive(o *Option) (*Source, error) {
 
 func (s *Source) initReferenceName() error {
 	switch s.imageType {
-	case types.TypeDocker:
+	case types.TypeDocker, types.TypeContainerd:
 		// docker://docker-reference
-		if s.tag != "" {
-			// example: docker://docker.io/library/nginx:1.23
-			s.referenceName = fmt.Sprintf("%s%s/%s/%s:%s",
-				s.imageType.Transport(),
-				s.registry, s.project, s.name, s.tag)
-		} else {
+		// containerd:docker-reference
+		if s.digest != "" {
+			// Pull the image by digest if the image is digest-pinned.
 			// example: docker://docker.io/library/nginx@sha256:abcdef...
 			s.referenceName = fmt.Sprintf("%s%s/%s/%s@%s",
 				s.imageType.Transport(),
 				s.registry, s.project, s.name, s.digest.String())
+		} else {
+			// example: docker://docker.io/library/nginx:1.23
+			s.referenceName = fmt.Sprintf("%s%s/%s/%s:%s",
+				s.imageType.Transport(),
+				s.registry, s.project, s.name, s.tag)
 		}
 	case types.TypeDockerArhive:
 		// docker-archive:path[:docker-reference]
@@ -456,6 +555,17 @@ func (s *Source) initManifest(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
+	if s.digest != "" {
+		ok, err := imagemanifest.MatchesDigest(b, s.digest)
+		if err != nil {
+			return fmt.Errorf("failed to verify manifest digest: %w", err)
+		}
+		if !ok {
+			return fmt.Errorf("%w: expected [%v], got [%v]",
+				utils.ErrDigestMismatch, s.digest, s.manifestDigest)
+		}
+	}
+	s.manifestRaw = b
 
 	// cache the source MIME
 	s.mime = mime
@@ -536,6 +646,19 @@ func (s *Source) initManifest(ctx context.Context) error {
 	return nil
 }
 
+// imageNum returns the number of images in source manifest.
+func (s *Source) imageNum() int {
+	switch s.mime {
+	case imagemanifest.DockerV2ListMediaType:
+		return len(s.schema2List.Manifests)
+	case imgspecv1.MediaTypeImageIndex:
+		return len(s.ociIndex.Manifests)
+	case "":
+		return 0
+	}
+	return 1
+}
+
 func (s *Source) ImageBySet(set map[string]map[string]bool) *archive.Image {
 	image := &archive.Image{}
 	archSet := map[string]bool{}
@@ -600,7 +723,9 @@ func (s *Source) ImageBySet(set map[string]map[string]bool) *archive.Image {
 			})
 		}
 	case imgspecv1.MediaTypeImageManifest:
-		p := s.ociManifest.Config.Platform
+		// The platform of the config descriptor is optional, use the
+		// platform of the image config instead.
+		p := &s.ociConfig.Platform
 		if len(set["arch"]) != 0 && !set["arch"][p.Architecture] {
 			return image
 		}
@@ -621,3 +746,47 @@ func (s *Source) ImageBySet(set map[string]map[string]bool) *archive.Image {
 	}
 	return image
 }
+
+// Platforms returns the platform images of the source image, the image
+// spec only contains the platform and the digest of the image.
+func (s *Source) Platforms() []archive.ImageSpec {
+	var specs []archive.ImageSpec
+	switch s.mime {
+	case imagemanifest.DockerV2ListMediaType:
+		for _, m := range s.schema2List.Manifests {
+			specs = append(specs, archive.ImageSpec{
+				Arch:    m.Platform.Architecture,
+				OS:      m.Platform.OS,
+				Variant: m.Platform.Variant,
+				Digest:  m.Digest,
+			})
+		}
+	case imgspecv1.MediaTypeImageIndex:
+		for _, m := range s.ociIndex.Manifests {
+			spec := archive.ImageSpec{Digest: m.Digest}
+			if m.Platform != nil {
+				spec.Arch = m.Platform.Architecture
+				spec.OS = m.Platform.OS
+				spec.Variant = m.Platform.Variant
+			}
+			specs = append(specs, spec)
+		}
+	case imagemanifest.DockerV2Schema2MediaType,
+		imgspecv1.MediaTypeImageManifest:
+		specs = append(specs, archive.ImageSpec{
+			Arch:    s.ociConfig.Architecture,
+			OS:      s.ociConfig.OS,
+			Variant: s.ociConfig.Variant,
+			Digest:  s.manifestDigest,
+		})
+	case imagemanifest.DockerV2Schema1MediaType,
+		imagemanifest.DockerV2Schema1SignedMediaType:
+		specs = append(specs, archive.ImageSpec{
+			Arch:    s.imageInspectInfo.Architecture,
+			OS:      s.imageInspectInfo.Os,
+			Variant: s.imageInspectInfo.Variant,
+			Digest:  s.manifestDigest,
+		})
+	}
+	return specs
+}
@@ -0,0 +1,141 @@
+// Package tracing provides the OpenTelemetry tracing of the images copy
+// pipeline, the spans can be exported to the OTLP collector or JSON file.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/cnrancher/hangar/pkg/utils"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/cnrancher/hangar"
+
+// Options is the options to export the spans.
+type Options struct {
+	// Endpoint is the OTLP/HTTP endpoint of the collector, example:
+	// 'localhost:4318' or 'http://localhost:4318' (optional).
+	Endpoint string
+	// File is the file name to write the spans in JSON format (optional).
+	File string
+}
+
+// Init initializes the global tracer provider to export the spans,
+// returns the shutdown function to flush the spans. The spans are not
+// recorded if neither the endpoint nor the file is provided.
+func Init(ctx context.Context, o *Options) (func(context.Context) error, error) {
+	if o == nil || (o.Endpoint == "" && o.File == "") {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	var (
+		exporters []sdktrace.SpanExporter
+		file      io.WriteCloser
+	)
+	if o.Endpoint != "" {
+		opts, err := endpointOptions(o.Endpoint)
+		if err != nil {
+			return nil, err
+		}
+		exporter, err := otlptracehttp.New(ctx, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+		}
+		exporters = append(exporters, exporter)
+	}
+	if o.File != "" {
+		f, err := os.Create(o.File)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create file %q: %w", o.File, err)
+		}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(f))
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to create file exporter: %w", err)
+		}
+		file = f
+		exporters = append(exporters, exporter)
+	}
+
+	r, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName("hangar"),
+		semconv.ServiceVersion(utils.Version),
+	))
+	if err != nil {
+		r = resource.Default()
+	}
+	opts := []sdktrace.TracerProviderOption{sdktrace.WithResource(r)}
+	for _, e := range exporters {
+		opts = append(opts, sdktrace.WithBatcher(e))
+	}
+	provider := sdktrace.NewTracerProvider(opts...)
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if file != nil {
+			err = errors.Join(err, file.Close())
+		}
+		return err
+	}, nil
+}
+
+// endpointOptions returns the OTLP exporter options of the endpoint,
+// the insecure HTTP is used if the URL scheme is 'http'.
+func endpointOptions(endpoint string) ([]otlptracehttp.Option, error) {
+	if !strings.Contains(endpoint, "://") {
+		return []otlptracehttp.Option{
+			otlptracehttp.WithEndpoint(endpoint),
+		}, nil
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OTLP endpoint %q: %w", endpoint, err)
+	}
+	opts := []otlptracehttp.Option{
+		otlptracehttp.WithEndpoint(u.Host),
+	}
+	switch u.Scheme {
+	case "http":
+		opts = append(opts, otlptracehttp.WithInsecure())
+	case "https":
+	default:
+		return nil, fmt.Errorf("invalid OTLP endpoint %q: unsupported scheme %q",
+			endpoint, u.Scheme)
+	}
+	if u.Path != "" && u.Path != "/" {
+		opts = append(opts, otlptracehttp.WithURLPath(u.Path))
+	}
+	return opts, nil
+}
+
+// Start starts a span of the global tracer provider.
+func Start(
+	ctx context.Context, name string, attrs ...attribute.KeyValue,
+) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End records the error of the span if not nil and ends the span.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
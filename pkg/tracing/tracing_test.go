@@ -0,0 +1,54 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func Test_Init(t *testing.T) {
+	shutdown, err := Init(context.Background(), nil)
+	assert.NoError(t, err)
+	assert.NoError(t, shutdown(context.Background()))
+
+	_, err = Init(context.Background(), &Options{Endpoint: "ftp://127.0.0.1"})
+	assert.Error(t, err)
+}
+
+func Test_File(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "trace.json")
+	shutdown, err := Init(context.Background(), &Options{File: name})
+	assert.NoError(t, err)
+
+	ctx, span := Start(context.Background(), "mirror image", attribute.Int("id", 1))
+	_, child := Start(ctx, "init source")
+	End(child, errors.New("some error"))
+	End(span, nil)
+	assert.NoError(t, shutdown(context.Background()))
+
+	b, err := os.ReadFile(name)
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), `"Name":"mirror image"`)
+	assert.Contains(t, string(b), `"Name":"init source"`)
+	assert.Contains(t, string(b), "some error")
+	assert.Contains(t, string(b), `"service.name"`)
+}
+
+func Test_endpointOptions(t *testing.T) {
+	opts, err := endpointOptions("127.0.0.1:4318")
+	assert.NoError(t, err)
+	assert.Len(t, opts, 1)
+	opts, err = endpointOptions("http://127.0.0.1:4318/custom/v1/traces")
+	assert.NoError(t, err)
+	assert.Len(t, opts, 3)
+	opts, err = endpointOptions("https://collector.example.com")
+	assert.NoError(t, err)
+	assert.Len(t, opts, 1)
+	_, err = endpointOptions("ftp://127.0.0.1")
+	assert.Error(t, err)
+}
@@ -13,6 +13,7 @@ const (
 	TypeOci
 	TypeDir
 	TypeHangarArchive
+	TypeContainerd
 )
 
 var (
@@ -31,6 +32,8 @@ func (t ImageType) String() string {
 		return "oci"
 	case TypeDir:
 		return "dir"
+	case TypeContainerd:
+		return "containerd"
 	default:
 		return "undefined"
 	}
@@ -48,6 +51,8 @@ func (t *ImageType) Transport() string {
 		return "oci:"
 	case TypeDir:
 		return "dir:"
+	case TypeContainerd:
+		return "containerd:"
 	default:
 		return ""
 	}
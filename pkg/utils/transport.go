@@ -0,0 +1,76 @@
+package utils
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/containers/image/v5/pkg/tlsclientconfig"
+	"github.com/containers/image/v5/types"
+)
+
+// perHostCertDirs is the per-host certificate directories searched by the
+// containers/image if not specified in the system context, the per-user
+// directory is searched first.
+var perHostCertDirs = []string{
+	filepath.Join(".config", "containers", "certs.d"),
+	"/etc/containers/certs.d",
+	"/etc/docker/certs.d",
+}
+
+// TLSVerify returns false if the system context skips verifying the
+// certificates of the registry.
+func TLSVerify(sysCtx *types.SystemContext) bool {
+	if sysCtx == nil {
+		return true
+	}
+	return sysCtx.DockerInsecureSkipTLSVerify != types.OptionalBoolTrue &&
+		!sysCtx.OCIInsecureSkipTLSVerify
+}
+
+// NewHTTPClient returns the HTTP client of the API server of the registry
+// 'host[:port]', the certificates and the TLS verification of the system
+// context are applied in the same way as the containers/image, the proxy
+// is read from the environment variables.
+func NewHTTPClient(
+	sysCtx *types.SystemContext, host string, timeout time.Duration,
+) (*http.Client, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: !TLSVerify(sysCtx),
+	}
+	if err := tlsclientconfig.SetupCertificates(certDir(sysCtx, host), tlsConfig); err != nil {
+		return nil, fmt.Errorf("failed to setup certificates of %q: %w", host, err)
+	}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: tlsConfig,
+		},
+	}, nil
+}
+
+// certDir returns the certificate directory of the registry 'host[:port]'.
+func certDir(sysCtx *types.SystemContext, host string) string {
+	if sysCtx != nil && sysCtx.DockerCertPath != "" {
+		return sysCtx.DockerCertPath
+	}
+	if sysCtx != nil && sysCtx.DockerPerHostCertDirPath != "" {
+		return filepath.Join(sysCtx.DockerPerHostCertDirPath, host)
+	}
+	home, _ := os.UserHomeDir()
+	for i, dir := range perHostCertDirs {
+		if i == 0 {
+			dir = filepath.Join(home, dir)
+		} else if sysCtx != nil && sysCtx.RootForImplicitAbsolutePaths != "" {
+			dir = filepath.Join(sysCtx.RootForImplicitAbsolutePaths, dir)
+		}
+		if _, err := os.Stat(filepath.Join(dir, host)); err == nil {
+			return filepath.Join(dir, host)
+		}
+	}
+	return ""
+}
@@ -13,12 +13,14 @@ import (
 
 	"github.com/containers/image/v5/signature"
 	"github.com/containers/image/v5/types"
+	"github.com/opencontainers/go-digest"
 	"golang.org/x/mod/semver"
 )
 
 var (
 	ErrVersionIsEmpty   = errors.New("version is empty string")
 	ErrNoAvailableImage = errors.New("no available image for specified arch and os")
+	ErrDigestMismatch   = errors.New("manifest digest mismatch")
 )
 
 const (
@@ -309,8 +311,9 @@ func GetRegistryName(image string) string {
 //	reg.io/nginx:latest -> nginx
 //	library/nginx:latest -> nginx
 //	reg.io/library/nginx -> nginx
+//	reg.io/library/nginx@sha256:abcdef... -> nginx
 func GetImageName(image string) string {
-	spec := strings.Split(image, "/")
+	spec := strings.Split(trimImageDigest(image), "/")
 	var s = make([]string, 0)
 	for _, v := range spec {
 		if len(v) > 0 {
@@ -343,8 +346,14 @@ func GetImageName(image string) string {
 //	reg.io/nginx:1.22 -> 1.22
 //	library/nginx -> latest
 //	reg.io/library/nginx -> latest
+//	reg.io/nginx:1.22@sha256:abcdef... -> 1.22
+//	reg.io/nginx@sha256:abcdef... -> "" (digest-pinned image without tag)
 func GetImageTag(image string) string {
-	spec := strings.Split(image, ":")
+	name := trimImageDigest(image)
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	spec := strings.Split(name, ":")
 	var s = make([]string, 0)
 	for _, v := range spec {
 		if len(v) > 0 {
@@ -352,14 +361,50 @@ func GetImageTag(image string) string {
 		}
 	}
 	switch len(s) {
-	case 1:
-		return "latest"
 	case 2:
 		return s[1]
 	}
+	if GetImageDigest(image) != "" {
+		return ""
+	}
 	return "latest"
 }
 
+// GetImageDigest gets the digest of the digest-pinned image, returns empty
+// string if the image is not digest-pinned or the digest is invalid, example:
+//
+//	nginx:latest -> ""
+//	reg.io/nginx@sha256:abcdef... -> sha256:abcdef...
+//	reg.io/nginx:1.22@sha256:abcdef... -> sha256:abcdef...
+func GetImageDigest(image string) string {
+	_, d, _ := SplitImageDigest(image)
+	return d.String()
+}
+
+// SplitImageDigest splits the digest-pinned image into the image and the
+// digest validated by digest.Parse, example:
+//
+//	nginx:latest -> "nginx:latest", ""
+//	reg.io/nginx:1.22@sha256:abcdef... -> "reg.io/nginx:1.22", "sha256:abcdef..."
+func SplitImageDigest(image string) (string, digest.Digest, error) {
+	image = strings.TrimSpace(image)
+	i := strings.LastIndex(image, "@")
+	if i < 0 {
+		return image, "", nil
+	}
+	d, err := digest.Parse(image[i+1:])
+	if err != nil {
+		return image[:i], "", fmt.Errorf("invalid digest %q: %w", image[i+1:], err)
+	}
+	return image[:i], d, nil
+}
+
+// trimImageDigest removes the '@sha256:...' suffix of the image.
+func trimImageDigest(image string) string {
+	name, _, _ := SplitImageDigest(image)
+	return name
+}
+
 // AddSourceToImage adds image into map[image][source]bool
 func AddSourceToImage(
 	imagesSet map[string]map[string]bool,
@@ -207,4 +207,40 @@ func Test_GetImageName(t *testing.T) {
 	assert.Equal(t, GetImageName("docker.io/nginx:latest"), "nginx")
 	assert.Equal(t, GetImageName("docker.io/library/nginx"), "nginx")
 	assert.Equal(t, GetImageName("docker.io/library/nginx:latest"), "nginx")
+	assert.Equal(t, GetImageName("docker.io/library/nginx@sha256:abcd"), "nginx")
+	assert.Equal(t, GetImageName("nginx:1.25@sha256:abcd"), "nginx")
+}
+
+func Test_GetImageTag(t *testing.T) {
+	assert.Equal(t, GetImageTag("nginx"), "latest")
+	assert.Equal(t, GetImageTag("nginx:1.25"), "1.25")
+	assert.Equal(t, GetImageTag("localhost:5000/nginx"), "latest")
+	assert.Equal(t, GetImageTag("localhost:5000/library/nginx:1.25"), "1.25")
+	assert.Equal(t, GetImageTag("docker.io/library/nginx@"+testDigest), "")
+	assert.Equal(t, GetImageTag("nginx:1.25@"+testDigest), "1.25")
+	assert.Equal(t, GetImageTag("nginx:1.25@"+testDigest+" "), "1.25")
+}
+
+const testDigest = "sha256:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+
+func Test_GetImageDigest(t *testing.T) {
+	assert.Equal(t, GetImageDigest("nginx:1.25"), "")
+	assert.Equal(t, GetImageDigest("docker.io/library/nginx@"+testDigest), testDigest)
+	assert.Equal(t, GetImageDigest("nginx:1.25@"+testDigest+" "), testDigest)
+	assert.Equal(t, GetImageDigest("nginx:1.25@sha256:abcd"), "")
+}
+
+func Test_SplitImageDigest(t *testing.T) {
+	name, d, err := SplitImageDigest(" nginx:1.25@" + testDigest + "\n")
+	assert.NoError(t, err)
+	assert.Equal(t, "nginx:1.25", name)
+	assert.Equal(t, testDigest, d.String())
+
+	name, d, err = SplitImageDigest("nginx:1.25")
+	assert.NoError(t, err)
+	assert.Equal(t, "nginx:1.25", name)
+	assert.Empty(t, d)
+
+	_, _, err = SplitImageDigest("nginx@sha256:abcd")
+	assert.Error(t, err)
 }
@@ -10,10 +10,12 @@ source ./scripts/env.sh
 files=(
     "*.zip"
     "*-failed.txt"
+    "*-failed-reason.txt"
     ".pytest_cache"
     ".tox"
     "suite/converted.txt"
     "suite/*-failed.txt"
+    "suite/*-failed-reason.txt"
     "suite/*.zip"
     "suite/__pycache__"
 )